@@ -17,12 +17,16 @@ import (
 	"github.com/gm-agent-org/gm-agent/pkg/agent/tools"
 	"github.com/gm-agent-org/gm-agent/pkg/api"
 	"github.com/gm-agent-org/gm-agent/pkg/api/service"
+	"github.com/gm-agent-org/gm-agent/pkg/commands"
 	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/hook"
+	"github.com/gm-agent-org/gm-agent/pkg/instructions"
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
 	"github.com/gm-agent-org/gm-agent/pkg/llm/factory"
 	"github.com/gm-agent-org/gm-agent/pkg/patch"
 	"github.com/gm-agent-org/gm-agent/pkg/runtime"
 	"github.com/gm-agent-org/gm-agent/pkg/runtime/permission"
+	"github.com/gm-agent-org/gm-agent/pkg/shell"
 	"github.com/gm-agent-org/gm-agent/pkg/store"
 	"github.com/gm-agent-org/gm-agent/pkg/tool"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
@@ -126,6 +130,36 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 		logger.Warn("could not retrieve active provider options for gateway config", "error", err)
 	}
 	llmGateway := llm.NewGateway(llmProvider, opts)
+	llmGateway.SetRetryPolicy(llm.RetryPolicyFromConfig(cfg.Retry))
+
+	// Setup fallback chain
+	fallbacks, err := factory.NewFallbacks(ctx, cfg, llmProvider)
+	if err != nil {
+		logger.Warn("failed to configure llm fallbacks", "error", err)
+	}
+	for _, fb := range fallbacks {
+		llmGateway.AddFallback(fb.Provider, fb.Options)
+		logger.Info("llm fallback configured", "provider", fb.Provider.ID(), "model", fb.Options.Model)
+	}
+
+	// One gateway per provider named in GM_ROUTES, with the same retries and fallbacks
+	llmRouter := llm.NewRouter(providerID, llmGateway)
+	for _, id := range cfg.Routes.Providers() {
+		if llmRouter.Has(id) {
+			continue
+		}
+		routeProvider, routeOpts, err := factory.NewProviderFor(ctx, cfg, id, llmProvider)
+		if err != nil {
+			return fmt.Errorf("create provider %s for model routes: %w", id, err)
+		}
+		gateway := llm.NewGateway(routeProvider, routeOpts)
+		gateway.SetRetryPolicy(llm.RetryPolicyFromConfig(cfg.Retry))
+		for _, fb := range fallbacks {
+			gateway.AddFallback(fb.Provider, fb.Options)
+		}
+		llmRouter.Add(id, gateway)
+		logger.Info("llm route provider configured", "provider", id, "model", routeOpts.Model)
+	}
 
 	// Setup Tool System
 	toolRegistry := tool.NewRegistry()
@@ -154,6 +188,12 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 	if err := toolRegistry.Register(tools.RunShellTool); err != nil {
 		panic(err)
 	}
+	if err := toolRegistry.Register(tools.ShellOutputTool); err != nil {
+		panic(err)
+	}
+	if err := toolRegistry.Register(tools.ShellKillTool); err != nil {
+		panic(err)
+	}
 
 	// New Advanced File Tools (2026-01-08)
 	if err := toolRegistry.Register(tools.WriteFileTool); err != nil {
@@ -179,11 +219,40 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 		panic(err)
 	}
 
+	// Agent Tools
+	if err := toolRegistry.Register(tools.SpawnAgentTool); err != nil {
+		panic(err)
+	}
+	if err := toolRegistry.Register(tools.TodoWriteTool); err != nil {
+		panic(err)
+	}
+	if err := toolRegistry.Register(tools.TodoReadTool); err != nil {
+		panic(err)
+	}
+
+	// Web Tools (denied by the policy unless GM_SECURITY_ALLOW_NET is set)
+	if err := toolRegistry.Register(tools.WebFetchTool); err != nil {
+		panic(err)
+	}
+	webFetcher := tools.NewWebFetcher(tools.WebFetchConfig{
+		Timeout:        time.Duration(cfg.Web.FetchTimeout) * time.Second,
+		MaxBytes:       cfg.Web.FetchMaxBytes,
+		AllowedDomains: cfg.Web.FetchAllowedDomains,
+	}, tools.ModelExtractor(llmRouter, cfg.Routes[config.RouteWebFetch]))
+	searchBackend, err := newSearchBackend(cfg.Web)
+	if err != nil {
+		return fmt.Errorf("configure web search: %w", err)
+	}
+	if searchBackend != nil {
+		if err := toolRegistry.Register(tools.WebSearchTool); err != nil {
+			panic(err)
+		}
+	}
+
 	// Sub-function to register handlers (avoids duplication)
 	registerHandlers := func(executor *tool.Executor, patchEng patch.Engine) {
 		executor.RegisterHandler("read_file", tools.HandleReadFile)
 		executor.RegisterHandler("create_file", tools.HandleCreateFile)
-		executor.RegisterHandler("run_shell", tools.HandleRunShell)
 		executor.RegisterHandler("talk", tools.HandleTalk)
 		executor.RegisterHandler("task_complete", tools.HandleTaskComplete)
 
@@ -196,6 +265,12 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 		})
 		executor.RegisterHandler("glob", tools.HandleGlob)
 		executor.RegisterHandler("grep", tools.HandleGrep)
+		executor.RegisterHandler("todo_write", runtime.HandleTodoWrite)
+		executor.RegisterHandler("todo_read", runtime.HandleTodoRead)
+		executor.RegisterHandler("web_fetch", webFetcher.Handle)
+		if searchBackend != nil {
+			executor.RegisterHandler("web_search", tools.WebSearchHandler(searchBackend))
+		}
 	}
 
 	// 4. Initialize Runtime
@@ -209,6 +284,40 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 		rtConfig.Model = "gemini-2.0-flash"
 	}
 
+	rtConfig.Routes = cfg.Routes
+
+	// Apply usage pricing and budget
+	if len(cfg.Pricing) > 0 {
+		rtConfig.Pricing = cfg.Pricing
+	}
+	rtConfig.MaxSessionTokens = cfg.Budget.MaxTokens
+	rtConfig.MaxSessionCost = cfg.Budget.MaxCost
+
+	// Apply error policy
+	rtConfig.SurfaceErrors = cfg.Errors.Surface
+	rtConfig.MaxConsecutiveErrors = cfg.Errors.MaxConsecutive
+	rtConfig.RecoveryGoals = cfg.Errors.RecoveryGoals
+	rtConfig.LoopRepeatedCalls = cfg.Loop.RepeatedCalls
+	rtConfig.LoopRepeatedErrors = cfg.Loop.RepeatedErrors
+	rtConfig.LoopIdleSteps = cfg.Loop.IdleSteps
+	rtConfig.LoopAction = cfg.Loop.Action
+	rtConfig.LoopMaxWarnings = cfg.Loop.MaxWarnings
+
+	// Lifecycle hooks from GM_HOOKS; each session gets its own runner
+	hooks, err := hook.New(cfg.Hooks, cfg.Security.WorkspaceRoot, logger)
+	if err != nil {
+		return fmt.Errorf("configure hooks: %w", err)
+	}
+
+	// Instruction files (GM.md, AGENTS.md) shared by all sessions
+	var instructionFiles *instructions.Loader
+	if cfg.Instructions.Enable {
+		instructionFiles, err = instructions.New(cfg.Security.WorkspaceRoot, cfg.Instructions.UserDir, cfg.Instructions.Files, logger)
+		if err != nil {
+			return fmt.Errorf("configure instructions: %w", err)
+		}
+	}
+
 	// 5. Run
 	logger.Info("gm-agent starting...")
 
@@ -228,6 +337,30 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 		// We reuse the registry and policy as they are thread-safe and stateless/config-based
 		sessionExecutor := tool.NewExecutor(toolRegistry, toolPolicy)
 		registerHandlers(sessionExecutor, patchEngine)
+		sessionHooks := hooks.ForSession(sessionID)
+		sessionExecutor.SetHooks(sessionHooks)
+
+		// The persistent and background shells live as long as the session
+		shells := shell.NewManager("")
+		shells.SetLimits(cfg.Limits.ForTool("run_shell"))
+		context.AfterFunc(sessionCtx, func() { shells.Close() })
+		sessionExecutor.RegisterHandler("run_shell", tools.RunShellHandler(shells))
+		sessionExecutor.RegisterHandler("shell_output", tools.ShellOutputHandler(shells))
+		sessionExecutor.RegisterHandler("shell_kill", tools.ShellKillHandler(shells))
+
+		// Sub-agents keep their own events under the session directory
+		sessionExecutor.RegisterHandler("spawn_agent", runtime.SpawnAgentHandler(runtime.SubAgentConfig{
+			Tools:    cfg.SubAgent.Tools,
+			MaxSteps: cfg.SubAgent.MaxSteps,
+			Model:    cfg.SubAgent.Model,
+			NewStore: func(agentID string) (store.Store, error) {
+				agentStore := store.NewFSStore(filepath.Join(sessionDir, "agents", agentID))
+				if err := agentStore.Open(sessionCtx); err != nil {
+					return nil, err
+				}
+				return agentStore, nil
+			},
+		}))
 
 		// Wire Permission Callback
 		sessionExecutor.SetPermissionCallback(func(ctx context.Context, req tool.PermissionRequest) (bool, error) {
@@ -279,20 +412,39 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 			return resp.Approved, nil
 		})
 
-		rt := runtime.New(rtConfig, sessionStore, llmGateway, sessionExecutor, logger)
+		rt := runtime.New(rtConfig, sessionStore, llmRouter, sessionExecutor, logger)
 		// Set file change tracker for Code Rewind support
 		rt.SetFileChangeTracker(patchEngine.GetTracker())
+		rt.SetHooks(sessionHooks)
+		rt.SetInstructions(instructionFiles)
 		return &service.SessionResources{
 			Runtime:     rt,
 			Permissions: permManager,
 			Store:       sessionStore,
 			PatchEngine: patchEngine,
+			Hooks:       sessionHooks,
+			Shells:      shells,
 			Ctx:         sessionCtx,
 			Cancel:      cancel,
 		}, nil
 	}
 
 	sessionSvc := service.NewSessionService(sessionFactory, logger)
+	sessionSvc.SetProviders(llmRouter.Providers())
+
+	// Slash commands from .gm/commands and ~/.gm/commands
+	if cfg.Commands.Enable {
+		projectDir := cfg.Commands.ProjectDir
+		if projectDir != "" && !filepath.IsAbs(projectDir) {
+			projectDir = filepath.Join(cfg.Security.WorkspaceRoot, projectDir)
+		}
+		slashCommands, err := commands.New(projectDir, cfg.Commands.UserDir, logger)
+		if err != nil {
+			return fmt.Errorf("configure commands: %w", err)
+		}
+		sessionSvc.SetCommands(slashCommands)
+	}
+
 	apiCfg := api.Config{Enable: cfg.HTTP.Enable, Addr: cfg.HTTP.Addr, APIKey: cfg.HTTP.APIKey, DevMode: cfg.DevMode}
 	server := api.NewServer(apiCfg, sessionSvc, logger)
 	httpSrv := &http.Server{Addr: cfg.HTTP.Addr, Handler: server.Engine()}
@@ -311,6 +463,29 @@ func cmdServe(ctx context.Context, logger *slog.Logger, configPath string) error
 	return nil
 }
 
+// newSearchBackend returns the backend of the web_search tool, or nil if no
+// search URL is configured
+func newSearchBackend(cfg config.WebConfig) (tools.SearchBackend, error) {
+	if cfg.SearchURL == "" {
+		return nil, nil
+	}
+	switch cfg.SearchBackend {
+	case "", "searxng":
+		return tools.NewSearXNG(cfg.SearchURL, cfg.SearchHeaders), nil
+	case "json":
+		return tools.NewJSONSearch(tools.JSONSearchConfig{
+			URL:          cfg.SearchURL,
+			Headers:      cfg.SearchHeaders,
+			ResultsPath:  cfg.SearchResultsPath,
+			TitleField:   cfg.SearchTitleField,
+			URLField:     cfg.SearchURLField,
+			SnippetField: cfg.SearchSnippetField,
+		}), nil
+	default:
+		return nil, fmt.Errorf("unknown search backend %q: expected searxng or json", cfg.SearchBackend)
+	}
+}
+
 func parseLogLevel(level string) slog.Level {
 	normalized := strings.ToUpper(strings.TrimSpace(level))
 	switch normalized {
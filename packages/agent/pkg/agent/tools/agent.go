@@ -0,0 +1,39 @@
+package tools
+
+import "github.com/gm-agent-org/gm-agent/pkg/types"
+
+// Definitions
+
+// SpawnAgentTool delegates a self-contained task to a sub-agent.
+// The handler lives in the runtime package since it needs the parent runtime.
+var SpawnAgentTool = types.Tool{
+	Name:        "spawn_agent",
+	Description: "Delegate a self-contained task (e.g. researching the codebase) to a sub-agent. The sub-agent works independently with its own context and returns a final summary. Give it a complete, specific prompt since it cannot see this conversation.",
+	Parameters: types.JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"description": map[string]any{
+				"type":        "string",
+				"description": "A short (3-5 word) description of the task",
+			},
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "The full task for the sub-agent to perform",
+			},
+			"tools": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Optional subset of tools the sub-agent may use",
+			},
+			"max_steps": map[string]any{
+				"type":        "integer",
+				"description": "Optional step budget, capped by the configured maximum",
+			},
+		},
+		"required": []string{"description", "prompt"},
+	},
+	Metadata: map[string]string{
+		"category": "agent",
+	},
+	ReadOnly: true, // The sub-agent runs in the parent's mode, so planning stays read-only; otherwise the runtime locks it like a write tool
+}
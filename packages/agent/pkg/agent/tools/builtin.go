@@ -2,11 +2,16 @@ package tools
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"os"
-	"os/exec"
+	"strings"
 
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+	"github.com/gm-agent-org/gm-agent/pkg/shell"
+	"github.com/gm-agent-org/gm-agent/pkg/tool"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
 
@@ -14,7 +19,7 @@ import (
 
 var ReadFileTool = types.Tool{
 	Name:        "read_file",
-	Description: "Read the contents of a file at the given path",
+	Description: "Read the contents of a file at the given path. Use mode \"image\" to look at a screenshot, diagram or other image.",
 	Parameters: types.JSONSchema{
 		"type": "object",
 		"properties": map[string]any{
@@ -22,6 +27,11 @@ var ReadFileTool = types.Tool{
 				"type":        "string",
 				"description": "The absolute path to the file to read",
 			},
+			"mode": map[string]any{
+				"type":        "string",
+				"enum":        []string{"text", "image"},
+				"description": "text (default) returns the file contents; image shows a PNG, JPEG, GIF or WebP file to you as an image",
+			},
 		},
 		"required": []string{"path"},
 	},
@@ -33,7 +43,7 @@ var ReadFileTool = types.Tool{
 
 var RunShellTool = types.Tool{
 	Name:        "run_shell",
-	Description: "Execute a shell command",
+	Description: "Execute a shell command. Commands run in a persistent shell, so the working directory and exported variables carry over between calls; set reset to start a fresh shell. Set background to start a long-running command (e.g. a dev server or a long test run) and keep working; read its output with shell_output and stop it with shell_kill.",
 	Parameters: types.JSONSchema{
 		"type": "object",
 		"properties": map[string]any{
@@ -41,6 +51,18 @@ var RunShellTool = types.Tool{
 				"type":        "string",
 				"description": "The command line to execute",
 			},
+			"background": map[string]any{
+				"type":        "boolean",
+				"description": "Run the command in the background and return a shell ID instead of waiting for it (default: false)",
+			},
+			"timeout": map[string]any{
+				"type":        "integer",
+				"description": "Seconds to wait before the command is stopped; the shell keeps its state (default and maximum: the configured execution time limit)",
+			},
+			"reset": map[string]any{
+				"type":        "boolean",
+				"description": "Restart the persistent shell before running the command, dropping its working directory and environment; the command may be empty to only reset (default: false)",
+			},
 		},
 		"required": []string{"command"},
 	},
@@ -54,8 +76,12 @@ var RunShellTool = types.Tool{
 
 type ReadFileArgs struct {
 	Path string `json:"path"`
+	Mode string `json:"mode,omitempty"` // text (default) or image
 }
 
+// MaxImageSize is the largest image read_file attaches; providers reject bigger ones
+const MaxImageSize = 5 << 20
+
 func HandleReadFile(ctx context.Context, argsJSON string) (string, error) {
 	var args ReadFileArgs
 	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
@@ -67,6 +93,13 @@ func HandleReadFile(ctx context.Context, argsJSON string) (string, error) {
 		return "", fmt.Errorf("path is required")
 	}
 
+	if args.Mode == "image" {
+		return readImage(ctx, args.Path)
+	}
+	if args.Mode != "" && args.Mode != "text" {
+		return "", fmt.Errorf("invalid mode %q: use text or image", args.Mode)
+	}
+
 	data, err := os.ReadFile(args.Path)
 	if err != nil {
 		return "", err
@@ -75,8 +108,40 @@ func HandleReadFile(ctx context.Context, argsJSON string) (string, error) {
 	return string(data), nil
 }
 
+// readImage attaches an image file to the tool result as an image part
+func readImage(ctx context.Context, path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.Size() > MaxImageSize {
+		return "", fmt.Errorf("image is %d bytes, the limit is %d", info.Size(), MaxImageSize)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	mimeType := http.DetectContentType(data)
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("%s is not an image (%s)", path, mimeType)
+	}
+
+	part := types.ContentPart{
+		Type:     types.ContentPartImage,
+		MimeType: mimeType,
+		Data:     base64.StdEncoding.EncodeToString(data),
+	}
+	if !tool.Attach(ctx, part) {
+		return "", fmt.Errorf("image mode is not available here")
+	}
+	return fmt.Sprintf("Attached image %s (%s, %d bytes)", path, mimeType, len(data)), nil
+}
+
 type RunShellArgs struct {
-	Command string `json:"command"`
+	Command    string `json:"command"`
+	Background bool   `json:"background,omitempty"` // Handled by RunShellHandler
+	Timeout    int    `json:"timeout,omitempty"`    // Seconds, capped by the resource limits; persistent shell only
+	Reset      bool   `json:"reset,omitempty"`      // Persistent shell only
 }
 
 func HandleRunShell(ctx context.Context, argsJSON string) (string, error) {
@@ -89,16 +154,8 @@ func HandleRunShell(ctx context.Context, argsJSON string) (string, error) {
 		return "", fmt.Errorf("command is required")
 	}
 
-	// Use bash -c (Caution: Security Risk - This is MVP)
-	cmd := exec.CommandContext(ctx, "bash", "-c", args.Command)
-
-	// Capture combined output
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Sprintf("Error: %v\nOutput:\n%s", err, string(output)), nil // Return error as content so LLM sees it
-	}
-
-	return string(output), nil
+	// A one-off shell without session state, under the default limits
+	return formatShellResult(shell.Exec(ctx, args.Command, "", security.DefaultResourceLimits()))
 }
 
 // CreateFileTool
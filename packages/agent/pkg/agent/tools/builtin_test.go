@@ -1,11 +1,19 @@
 package tools
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"image"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/tool"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
 
 func TestHandleReadFile(t *testing.T) {
@@ -32,6 +40,50 @@ func TestHandleReadFile(t *testing.T) {
 	})
 }
 
+func TestReadFileImageMode(t *testing.T) {
+	dir := t.TempDir()
+	img := filepath.Join(dir, "dot.png")
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	if err := os.WriteFile(img, buf.Bytes(), 0644); err != nil {
+		t.Fatalf("write image: %v", err)
+	}
+	text := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(text, []byte("hello"), 0644); err != nil {
+		t.Fatalf("write text: %v", err)
+	}
+
+	reg := tool.NewRegistry()
+	if err := reg.Register(ReadFileTool); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	exec := tool.NewExecutor(reg, tool.NewPolicy(config.SecurityConfig{AutoApprove: true, AllowFileSystem: true, WorkspaceRoot: dir}, reg, nil))
+	exec.RegisterHandler("read_file", HandleReadFile)
+
+	res, err := exec.Execute(context.Background(), types.ModeExecuting, &types.ToolCall{ID: "c1", Name: "read_file", Arguments: `{"path":"` + img + `","mode":"image"}`})
+	if err != nil || res.IsError {
+		t.Fatalf("read image: %v %+v", err, res)
+	}
+	if len(res.Parts) != 1 || res.Parts[0].Type != types.ContentPartImage || res.Parts[0].MimeType != "image/png" {
+		t.Fatalf("expected one png image part, got %+v", res.Parts)
+	}
+	if data, _ := base64.StdEncoding.DecodeString(res.Parts[0].Data); !bytes.Equal(data, buf.Bytes()) {
+		t.Fatalf("image data does not round-trip")
+	}
+
+	res, err = exec.Execute(context.Background(), types.ModeExecuting, &types.ToolCall{ID: "c2", Name: "read_file", Arguments: `{"path":"` + text + `","mode":"image"}`})
+	if err != nil || !res.IsError || len(res.Parts) != 0 {
+		t.Fatalf("expected text file to be rejected in image mode, got %v %+v", err, res)
+	}
+
+	// Without an executor there is nowhere to attach the image
+	if _, err := HandleReadFile(context.Background(), `{"path":"`+img+`","mode":"image"}`); err == nil {
+		t.Fatalf("expected an error outside an executor")
+	}
+}
+
 func TestHandleRunShell(t *testing.T) {
 	t.Run("empty command", func(t *testing.T) {
 		if _, err := HandleRunShell(context.Background(), "{}"); err == nil {
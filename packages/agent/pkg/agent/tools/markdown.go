@@ -0,0 +1,310 @@
+package tools
+
+import (
+	"io"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlToMarkdown converts an HTML page to readable Markdown and returns its
+// title. Scripts, styles and other invisible elements are dropped; relative
+// links are resolved against base.
+func htmlToMarkdown(r io.Reader, base *url.URL) (title, markdown string, err error) {
+	doc, err := html.Parse(r)
+	if err != nil {
+		return "", "", err
+	}
+	if t := findElement(doc, atom.Title); t != nil {
+		title = collapseSpace(textContent(t))
+	}
+	root := doc
+	if body := findElement(doc, atom.Body); body != nil {
+		root = body
+	}
+
+	m := &mdWriter{base: base}
+	m.children(root)
+	return title, m.String(), nil
+}
+
+var (
+	spaceRun   = regexp.MustCompile(`\s+`)
+	blankLines = regexp.MustCompile(`\n{3,}`)
+)
+
+// mdWriter renders an HTML tree as Markdown
+type mdWriter struct {
+	b     strings.Builder
+	base  *url.URL
+	pre   int       // Depth of <pre> elements; whitespace is kept inside them
+	lists []*mdList // Enclosing lists, innermost last
+}
+
+type mdList struct {
+	ordered bool
+	n       int
+}
+
+// String returns the Markdown with trailing spaces and extra blank lines removed
+func (m *mdWriter) String() string {
+	lines := strings.Split(m.b.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return strings.TrimSpace(blankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n"))
+}
+
+// sub renders the children of n with a fresh writer, e.g. for link text or list items
+func (m *mdWriter) sub(n *html.Node) string {
+	s := &mdWriter{base: m.base, pre: m.pre, lists: m.lists}
+	s.children(n)
+	return s.String()
+}
+
+func (m *mdWriter) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		m.node(c)
+	}
+}
+
+// block starts a new paragraph
+func (m *mdWriter) block() {
+	s := m.b.String()
+	switch {
+	case s == "", strings.HasSuffix(s, "\n\n"):
+	case strings.HasSuffix(s, "\n"):
+		m.b.WriteString("\n")
+	default:
+		m.b.WriteString("\n\n")
+	}
+}
+
+// newline ends the current line
+func (m *mdWriter) newline() {
+	if s := m.b.String(); s != "" && !strings.HasSuffix(s, "\n") {
+		m.b.WriteString("\n")
+	}
+}
+
+func (m *mdWriter) text(s string) {
+	if m.pre > 0 {
+		m.b.WriteString(s)
+		return
+	}
+	s = spaceRun.ReplaceAllString(s, " ")
+	if cur := m.b.String(); cur == "" || strings.HasSuffix(cur, " ") || strings.HasSuffix(cur, "\n") {
+		s = strings.TrimLeft(s, " ")
+	}
+	m.b.WriteString(s)
+}
+
+func (m *mdWriter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		m.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe, atom.Head, atom.Form, atom.Button:
+		return
+
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level := int(n.Data[1] - '0')
+		if text := collapseSpace(m.sub(n)); text != "" {
+			m.block()
+			m.b.WriteString(strings.Repeat("#", level) + " " + text)
+			m.block()
+		}
+
+	case atom.P, atom.Div, atom.Section, atom.Article, atom.Main, atom.Header, atom.Footer,
+		atom.Nav, atom.Aside, atom.Figure, atom.Figcaption, atom.Dl, atom.Dt, atom.Dd, atom.Details, atom.Summary:
+		m.block()
+		m.children(n)
+		m.block()
+
+	case atom.Br:
+		m.b.WriteString("\n")
+
+	case atom.Hr:
+		m.block()
+		m.b.WriteString("---")
+		m.block()
+
+	case atom.A:
+		text := collapseSpace(m.sub(n))
+		href := m.resolve(attr(n, "href"))
+		switch {
+		case text == "":
+		case href == "" || strings.HasPrefix(href, "javascript:") || strings.HasPrefix(href, "#"):
+			m.text(text)
+		default:
+			m.text("[" + text + "](" + href + ")")
+		}
+
+	case atom.Img:
+		src := m.resolve(attr(n, "src"))
+		if src != "" && !strings.HasPrefix(src, "data:") {
+			m.text("![" + collapseSpace(attr(n, "alt")) + "](" + src + ")")
+		}
+
+	case atom.Strong, atom.B:
+		m.wrap(n, "**")
+
+	case atom.Em, atom.I:
+		m.wrap(n, "_")
+
+	case atom.Code:
+		if m.pre > 0 {
+			m.children(n)
+		} else {
+			m.wrap(n, "`")
+		}
+
+	case atom.Pre:
+		m.block()
+		m.pre++
+		code := strings.Trim(m.sub(n), "\n")
+		m.pre--
+		m.b.WriteString("```\n" + code + "\n```")
+		m.block()
+
+	case atom.Ul, atom.Ol:
+		m.block()
+		m.lists = append(m.lists, &mdList{ordered: n.DataAtom == atom.Ol})
+		m.children(n)
+		m.lists = m.lists[:len(m.lists)-1]
+		m.block()
+
+	case atom.Li:
+		marker := "- "
+		if len(m.lists) > 0 {
+			if list := m.lists[len(m.lists)-1]; list.ordered {
+				list.n++
+				marker = strconv.Itoa(list.n) + ". "
+			}
+		}
+		item := m.sub(n)
+		m.newline()
+		m.b.WriteString(marker + indent(item, strings.Repeat(" ", len(marker))))
+		m.newline()
+
+	case atom.Blockquote:
+		m.block()
+		lines := strings.Split(m.sub(n), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		m.b.WriteString(strings.Join(lines, "\n"))
+		m.block()
+
+	case atom.Table:
+		m.block()
+		m.table(n)
+		m.block()
+
+	default:
+		m.children(n)
+	}
+}
+
+// wrap renders inline children between delimiters, e.g. **bold**
+func (m *mdWriter) wrap(n *html.Node, delim string) {
+	if text := collapseSpace(m.sub(n)); text != "" {
+		m.text(delim + text + delim)
+	}
+}
+
+// table renders rows as a Markdown table; the first row is the header
+func (m *mdWriter) table(n *html.Node) {
+	var rows [][]string
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.DataAtom {
+			case atom.Tr:
+				var cells []string
+				for td := c.FirstChild; td != nil; td = td.NextSibling {
+					if td.DataAtom == atom.Td || td.DataAtom == atom.Th {
+						cells = append(cells, strings.ReplaceAll(collapseSpace(m.sub(td)), "|", `\|`))
+					}
+				}
+				rows = append(rows, cells)
+			case atom.Thead, atom.Tbody, atom.Tfoot:
+				walk(c)
+			}
+		}
+	}
+	walk(n)
+
+	for i, cells := range rows {
+		m.b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+		if i == 0 {
+			m.b.WriteString("|" + strings.Repeat(" --- |", len(cells)) + "\n")
+		}
+	}
+}
+
+func (m *mdWriter) resolve(ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" || m.base == nil {
+		return ref
+	}
+	u, err := m.base.Parse(ref)
+	if err != nil {
+		return ref
+	}
+	return u.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == a {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, a); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var b strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		b.WriteString(textContent(c))
+	}
+	return b.String()
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(spaceRun.ReplaceAllString(s, " "))
+}
+
+// indent prefixes every line but the first
+func indent(s, prefix string) string {
+	return strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
@@ -0,0 +1,183 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/shell"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// ShellOutputTool reads the output of a background shell
+var ShellOutputTool = types.Tool{
+	Name:        "shell_output",
+	Description: "Get the new output (stdout and stderr) of a background shell since the last call, and whether it is still running. Once the shell has exited, this returns its final output and the shell ID is released.",
+	Parameters: types.JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"shell_id": map[string]any{
+				"type":        "string",
+				"description": "The shell ID returned by run_shell with background set",
+			},
+			"filter": map[string]any{
+				"type":        "string",
+				"description": "Optional regular expression; only matching lines are returned (the others are discarded)",
+			},
+		},
+		"required": []string{"shell_id"},
+	},
+	Metadata: map[string]string{
+		"category": "shell",
+	},
+	ReadOnly: true, // Only reads output of a process started earlier
+}
+
+// ShellKillTool stops a background shell
+var ShellKillTool = types.Tool{
+	Name:        "shell_kill",
+	Description: "Stop a background shell and all processes it started.",
+	Parameters: types.JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"shell_id": map[string]any{
+				"type":        "string",
+				"description": "The shell ID returned by run_shell with background set",
+			},
+		},
+		"required": []string{"shell_id"},
+	},
+	Metadata: map[string]string{
+		"category": "shell",
+	},
+	ReadOnly: false,
+}
+
+type ShellOutputArgs struct {
+	ShellID string `json:"shell_id"`
+	Filter  string `json:"filter,omitempty"`
+}
+
+type ShellKillArgs struct {
+	ShellID string `json:"shell_id"`
+}
+
+// RunShellHandler returns the run_shell handler of a session; commands run in
+// the persistent shell of shells, or in the background
+func RunShellHandler(shells *shell.Manager) func(ctx context.Context, argsJSON string) (string, error) {
+	return func(ctx context.Context, argsJSON string) (string, error) {
+		var args RunShellArgs
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if args.Reset {
+			shells.Reset()
+			if args.Command == "" {
+				return "The shell was reset.", nil
+			}
+		}
+		if args.Command == "" {
+			return "", fmt.Errorf("command is required")
+		}
+		if !args.Background {
+			return runPersistent(ctx, shells, args)
+		}
+
+		p, err := shells.Start(args.Command)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Started background shell %s. Use shell_output with this ID to read its output and shell_kill to stop it.", p.ID), nil
+	}
+}
+
+func runPersistent(ctx context.Context, shells *shell.Manager, args RunShellArgs) (string, error) {
+	res, err := shells.Run(ctx, args.Command, time.Duration(args.Timeout)*time.Second)
+	return formatShellResult(res, err)
+}
+
+// formatShellResult renders a foreground command for the model; failures are
+// returned as content so that the model sees them
+func formatShellResult(res *shell.Result, err error) (string, error) {
+	if res == nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	switch {
+	case res.TimedOut:
+		fmt.Fprintf(&b, "Error: command timed out after %s and was stopped\nOutput:\n%s", res.Timeout, res.Output)
+	case res.ExitCode != 0:
+		fmt.Fprintf(&b, "Error: exit status %d\nOutput:\n%s", res.ExitCode, res.Output)
+	default:
+		b.WriteString(res.Output)
+	}
+	if res.Reset {
+		b.WriteString("\n[The shell exited; the next command starts a new shell without the previous environment]")
+	}
+	return b.String(), err
+}
+
+// ShellOutputHandler returns the shell_output handler of a session
+func ShellOutputHandler(shells *shell.Manager) func(ctx context.Context, argsJSON string) (string, error) {
+	return func(ctx context.Context, argsJSON string) (string, error) {
+		var args ShellOutputArgs
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if args.ShellID == "" {
+			return "", fmt.Errorf("shell_id is required")
+		}
+		var filter *regexp.Regexp
+		if args.Filter != "" {
+			var err error
+			if filter, err = regexp.Compile(args.Filter); err != nil {
+				return "", fmt.Errorf("invalid filter: %w", err)
+			}
+		}
+
+		out, err := shells.Read(args.ShellID, filter)
+		if err != nil {
+			return "", err
+		}
+
+		var b strings.Builder
+		switch {
+		case out.Running:
+			fmt.Fprintf(&b, "Shell %s is running.\n", out.ID)
+		case out.Error != nil:
+			fmt.Fprintf(&b, "Shell %s stopped: %v.\n", out.ID, out.Error)
+		default:
+			fmt.Fprintf(&b, "Shell %s exited with code %d.\n", out.ID, out.ExitCode)
+		}
+		if out.Dropped > 0 {
+			fmt.Fprintf(&b, "[%d bytes of earlier output were dropped]\n", out.Dropped)
+		}
+		if out.Output == "" {
+			b.WriteString("No new output.")
+		} else {
+			b.WriteString("Output:\n" + out.Output)
+		}
+		return b.String(), nil
+	}
+}
+
+// ShellKillHandler returns the shell_kill handler of a session
+func ShellKillHandler(shells *shell.Manager) func(ctx context.Context, argsJSON string) (string, error) {
+	return func(ctx context.Context, argsJSON string) (string, error) {
+		var args ShellKillArgs
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if args.ShellID == "" {
+			return "", fmt.Errorf("shell_id is required")
+		}
+		if err := shells.Kill(args.ShellID); err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("Shell %s was stopped.", args.ShellID), nil
+	}
+}
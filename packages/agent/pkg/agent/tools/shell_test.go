@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/shell"
+)
+
+func TestBackgroundShellTools(t *testing.T) {
+	ctx := context.Background()
+	shells := shell.NewManager(t.TempDir())
+	defer shells.Close()
+
+	out, err := RunShellHandler(shells)(ctx, `{"command":"echo started; echo ERROR boom; sleep 60","background":true}`)
+	if err != nil {
+		t.Fatalf("run_shell: %v", err)
+	}
+	id := regexp.MustCompile(`shell_\d+`).FindString(out)
+	if id == "" {
+		t.Fatalf("expected a shell id in %q", out)
+	}
+
+	readOutput := ShellOutputHandler(shells)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		out, err = readOutput(ctx, `{"shell_id":"`+id+`","filter":"ERROR"}`)
+		if err != nil {
+			t.Fatalf("shell_output: %v", err)
+		}
+		if strings.Contains(out, "ERROR boom") || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !strings.Contains(out, "is running") || !strings.Contains(out, "ERROR boom") || strings.Contains(out, "started") {
+		t.Fatalf("unexpected shell_output %q", out)
+	}
+
+	if _, err := ShellKillHandler(shells)(ctx, `{"shell_id":"`+id+`"}`); err != nil {
+		t.Fatalf("shell_kill: %v", err)
+	}
+	out, err = readOutput(ctx, `{"shell_id":"`+id+`"}`)
+	if err != nil || !strings.Contains(out, "stopped: killed") {
+		t.Fatalf("expected the shell to be stopped, got %q, %v", out, err)
+	}
+
+	if _, err := readOutput(ctx, `{"shell_id":"shell_99"}`); err == nil {
+		t.Fatalf("expected an error for an unknown shell")
+	}
+	out, err = RunShellHandler(shells)(ctx, `{"command":"echo foreground"}`)
+	if err != nil || out != "foreground\n" {
+		t.Fatalf("expected foreground commands to run to completion, got %q, %v", out, err)
+	}
+}
+
+func TestRunShellPersistentShell(t *testing.T) {
+	ctx := context.Background()
+	shells := shell.NewManager(t.TempDir())
+	defer shells.Close()
+	runShell := RunShellHandler(shells)
+
+	runShell(ctx, `{"command":"export NAME=gm"}`)
+	out, err := runShell(ctx, `{"command":"echo $NAME; sleep 30","timeout":1}`)
+	if err != nil || !strings.HasPrefix(out, "Error: command timed out after 1s") || !strings.Contains(out, "gm\n") {
+		t.Fatalf("expected a timeout with the output so far, got %q, %v", out, err)
+	}
+
+	out, err = runShell(ctx, `{"command":"echo \"[$NAME]\"","reset":true}`)
+	if err != nil || out != "[]\n" {
+		t.Fatalf("expected a fresh shell after reset, got %q, %v", out, err)
+	}
+	if out, err := runShell(ctx, `{"command":"","reset":true}`); err != nil || out != "The shell was reset." {
+		t.Fatalf("expected a reset without command, got %q, %v", out, err)
+	}
+}
@@ -0,0 +1,59 @@
+package tools
+
+import "github.com/gm-agent-org/gm-agent/pkg/types"
+
+// Definitions
+
+// TodoWriteTool replaces the task list of the current goal.
+// The handler lives in the runtime package since the list is kept in State.Tasks.
+var TodoWriteTool = types.Tool{
+	Name:        "todo_write",
+	Description: "Create or update the task list for the current request. Use it for multi-step work to plan and track progress: pass the complete list every time, keep exactly one task running while you work on it, and mark tasks completed as soon as they are done.",
+	Parameters: types.JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"todos": map[string]any{
+				"type":        "array",
+				"description": "The complete, ordered task list",
+				"items": map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"id": map[string]any{
+							"type":        "string",
+							"description": "ID of an existing task; omit for new tasks",
+						},
+						"content": map[string]any{
+							"type":        "string",
+							"description": "What needs to be done",
+						},
+						"status": map[string]any{
+							"type":        "string",
+							"enum":        []string{"pending", "running", "completed", "blocked", "cancelled"},
+							"description": "Task status",
+						},
+					},
+					"required": []string{"content", "status"},
+				},
+			},
+		},
+		"required": []string{"todos"},
+	},
+	Metadata: map[string]string{
+		"category": "task",
+	},
+	ReadOnly: true, // Only updates the agent's own task list
+}
+
+// TodoReadTool returns the task list of the current goal
+var TodoReadTool = types.Tool{
+	Name:        "todo_read",
+	Description: "Read the current task list with task IDs and statuses.",
+	Parameters: types.JSONSchema{
+		"type":       "object",
+		"properties": map[string]any{},
+	},
+	Metadata: map[string]string{
+		"category": "task",
+	},
+	ReadOnly: true,
+}
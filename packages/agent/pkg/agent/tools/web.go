@@ -0,0 +1,231 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// WebFetchTool retrieves a web page as Markdown
+var WebFetchTool = types.Tool{
+	Name:        "web_fetch",
+	Description: "Fetch a URL and return its content as Markdown. HTML pages are converted to readable text. Pass a prompt to get only the information you need from a long page instead of the whole page.",
+	Parameters: types.JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"url": map[string]any{
+				"type":        "string",
+				"description": "The http or https URL to fetch",
+			},
+			"prompt": map[string]any{
+				"type":        "string",
+				"description": "Optional question to answer from the page content",
+			},
+		},
+		"required": []string{"url"},
+	},
+	Metadata: map[string]string{
+		"category": "internet",
+	},
+	ReadOnly: true, // Only reads remote content, safe for planning mode
+}
+
+// Extractor answers a prompt from the content of a fetched page, typically
+// with a call to a smaller model
+type Extractor func(ctx context.Context, prompt, content string) (string, error)
+
+// ChatClient is the part of an LLM gateway used for extraction
+type ChatClient interface {
+	Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error)
+}
+
+const extractPrompt = `You answer questions about a web page. Use only the page content given by the user. Quote code, commands and numbers exactly. If the page does not contain the answer, say so.`
+
+// ModelExtractor answers prompts with one call to the model of route
+func ModelExtractor(client ChatClient, route types.ModelRoute) Extractor {
+	return func(ctx context.Context, prompt, content string) (string, error) {
+		resp, err := client.Chat(ctx, &llm.ChatRequest{
+			Provider: route.Provider,
+			Model:    route.Model,
+			Messages: []types.Message{
+				{Role: "system", Content: extractPrompt},
+				{Role: "user", Content: fmt.Sprintf("<page>\n%s\n</page>\n\n%s", content, prompt)},
+			},
+		})
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(resp.Content), nil
+	}
+}
+
+// WebFetchConfig limits what web_fetch downloads
+type WebFetchConfig struct {
+	Timeout        time.Duration // Whole request, including redirects
+	MaxBytes       int64         // Response bodies are cut off after this many bytes
+	MaxOutput      int           // Characters of Markdown returned to the model
+	AllowedDomains []string      // Redirects may leave the original host only for these domains and their subdomains
+}
+
+// DefaultWebFetchConfig is used for zero fields of a WebFetchConfig
+var DefaultWebFetchConfig = WebFetchConfig{
+	Timeout:   30 * time.Second,
+	MaxBytes:  5 << 20,
+	MaxOutput: 100000,
+}
+
+// maxRedirects bounds redirect chains, like net/http's default client
+const maxRedirects = 10
+
+// WebFetcher implements the web_fetch tool
+type WebFetcher struct {
+	config  WebFetchConfig
+	extract Extractor // Optional: answers prompts instead of returning the page
+	client  *http.Client
+}
+
+// NewWebFetcher creates a fetcher; extract may be nil, in which case prompts are ignored
+func NewWebFetcher(cfg WebFetchConfig, extract Extractor) *WebFetcher {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultWebFetchConfig.Timeout
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = DefaultWebFetchConfig.MaxBytes
+	}
+	if cfg.MaxOutput <= 0 {
+		cfg.MaxOutput = DefaultWebFetchConfig.MaxOutput
+	}
+	f := &WebFetcher{config: cfg, extract: extract}
+	f.client = &http.Client{CheckRedirect: f.checkRedirect}
+	return f
+}
+
+type WebFetchArgs struct {
+	URL    string `json:"url"`
+	Prompt string `json:"prompt,omitempty"`
+}
+
+// Handle is the tool handler of web_fetch
+func (f *WebFetcher) Handle(ctx context.Context, argsJSON string) (string, error) {
+	var args WebFetchArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+	if args.URL == "" {
+		return "", fmt.Errorf("url is required")
+	}
+	target, err := url.Parse(args.URL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return "", fmt.Errorf("invalid url %q: only http and https URLs can be fetched", args.URL)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", "gm-agent web_fetch")
+	req.Header.Set("Accept", "text/html, text/markdown, text/plain;q=0.9, */*;q=0.5")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return "", fmt.Errorf("fetching %s timed out after %s", target, f.config.Timeout)
+		}
+		return "", fmt.Errorf("fetch %s: %w", target, err)
+	}
+	defer resp.Body.Close()
+
+	// A redirect that checkRedirect refused: let the model decide whether to follow it
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 {
+		location := resp.Header.Get("Location")
+		if loc, err := resp.Request.URL.Parse(location); err == nil {
+			location = loc.String()
+		}
+		return fmt.Sprintf("%s redirects to %s, which is outside the allowed domains. Fetch that URL if you want to follow the redirect.", resp.Request.URL, location), nil
+	}
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("fetch %s: HTTP %s", resp.Request.URL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.config.MaxBytes+1))
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", resp.Request.URL, err)
+	}
+	truncated := int64(len(body)) > f.config.MaxBytes
+	if truncated {
+		body = body[:f.config.MaxBytes]
+	}
+
+	title, content, err := convertBody(body, resp.Header.Get("Content-Type"), resp.Request.URL)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", resp.Request.URL, err)
+	}
+	if truncated {
+		content += fmt.Sprintf("\n\n[truncated: the response is larger than %d bytes]", f.config.MaxBytes)
+	}
+
+	var header strings.Builder
+	fmt.Fprintf(&header, "URL: %s\n", resp.Request.URL)
+	if title != "" {
+		fmt.Fprintf(&header, "Title: %s\n", title)
+	}
+
+	if args.Prompt != "" && f.extract != nil {
+		answer, err := f.extract(ctx, args.Prompt, content)
+		if err != nil {
+			return "", fmt.Errorf("extract from %s: %w", resp.Request.URL, err)
+		}
+		return header.String() + "\n" + answer, nil
+	}
+
+	if len(content) > f.config.MaxOutput {
+		content = strings.ToValidUTF8(content[:f.config.MaxOutput], "") + fmt.Sprintf("\n\n[truncated: showing the first %d of %d characters; pass a prompt to extract what you need]", f.config.MaxOutput, len(content))
+	}
+	return header.String() + "\n" + content, nil
+}
+
+// convertBody turns a response body into text for the model
+func convertBody(body []byte, contentType string, base *url.URL) (title, content string, err error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if mediaType == "" {
+		mediaType, _, _ = mime.ParseMediaType(http.DetectContentType(body))
+	}
+
+	switch {
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return htmlToMarkdown(bytes.NewReader(body), base)
+	case strings.HasPrefix(mediaType, "text/"), mediaType == "application/json",
+		mediaType == "application/xml", strings.HasSuffix(mediaType, "+json"), strings.HasSuffix(mediaType, "+xml"):
+		return "", strings.ToValidUTF8(string(body), ""), nil
+	default:
+		return "", "", fmt.Errorf("unsupported content type %s", mediaType)
+	}
+}
+
+// checkRedirect follows redirects on the same host or into an allowed domain
+func (f *WebFetcher) checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return fmt.Errorf("stopped after %d redirects", maxRedirects)
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return http.ErrUseLastResponse
+	}
+	if req.URL.Host == via[len(via)-1].URL.Host || matchDomain(req.URL.Hostname(), f.config.AllowedDomains) {
+		return nil
+	}
+	return http.ErrUseLastResponse
+}
@@ -0,0 +1,262 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// WebSearchTool searches the web through the configured search backend
+var WebSearchTool = types.Tool{
+	Name:        "web_search",
+	Description: "Search the web. Returns ranked results with title, URL and snippet; use web_fetch to read a result.",
+	Parameters: types.JSONSchema{
+		"type": "object",
+		"properties": map[string]any{
+			"query": map[string]any{
+				"type":        "string",
+				"description": "The search query",
+			},
+			"max_results": map[string]any{
+				"type":        "integer",
+				"description": "Maximum number of results to return (default: 10)",
+				"default":     10,
+			},
+			"include_domains": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Only return results from these domains (and their subdomains)",
+			},
+			"exclude_domains": map[string]any{
+				"type":        "array",
+				"items":       map[string]any{"type": "string"},
+				"description": "Never return results from these domains (and their subdomains)",
+			},
+		},
+		"required": []string{"query"},
+	},
+	Metadata: map[string]string{
+		"category": "internet",
+	},
+	ReadOnly: true, // Only reads remote content, safe for planning mode
+}
+
+// SearchResult is one hit of a web search
+type SearchResult struct {
+	Title   string `json:"title"`
+	URL     string `json:"url"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchBackend runs web searches; results are returned best first
+type SearchBackend interface {
+	Search(ctx context.Context, query string) ([]SearchResult, error)
+}
+
+// JSONSearchConfig describes an HTTP search API that answers with JSON.
+// Field paths are dot separated, e.g. "data.items" or "meta.link".
+type JSONSearchConfig struct {
+	URL          string            // Endpoint; "{query}" is replaced by the escaped query, otherwise it is sent as q
+	Headers      map[string]string // e.g. an API key
+	ResultsPath  string            // Array of results in the response
+	TitleField   string
+	URLField     string
+	SnippetField string
+	Timeout      time.Duration
+}
+
+// JSONSearch is a SearchBackend for JSON search APIs
+type JSONSearch struct {
+	config JSONSearchConfig
+	client *http.Client
+}
+
+// NewJSONSearch creates a backend for a JSON search API
+func NewJSONSearch(cfg JSONSearchConfig) *JSONSearch {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 15 * time.Second
+	}
+	return &JSONSearch{config: cfg, client: &http.Client{Timeout: cfg.Timeout}}
+}
+
+// NewSearXNG creates a backend for the JSON API of a SearXNG instance
+// (the instance must enable the json format)
+func NewSearXNG(baseURL string, headers map[string]string) *JSONSearch {
+	return NewJSONSearch(JSONSearchConfig{
+		URL:          strings.TrimRight(baseURL, "/") + "/search?format=json&q={query}",
+		Headers:      headers,
+		ResultsPath:  "results",
+		TitleField:   "title",
+		URLField:     "url",
+		SnippetField: "content",
+	})
+}
+
+// Search implements SearchBackend
+func (s *JSONSearch) Search(ctx context.Context, query string) ([]SearchResult, error) {
+	endpoint := s.config.URL
+	if strings.Contains(endpoint, "{query}") {
+		endpoint = strings.ReplaceAll(endpoint, "{query}", url.QueryEscape(query))
+	} else {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid search url: %w", err)
+		}
+		q := u.Query()
+		q.Set("q", query)
+		u.RawQuery = q.Encode()
+		endpoint = u.String()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range s.config.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("search request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("search request: HTTP %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var doc any
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 10<<20)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode search response: %w", err)
+	}
+	items, ok := jsonPath(doc, s.config.ResultsPath).([]any)
+	if !ok {
+		return nil, fmt.Errorf("search response has no result list at %q", s.config.ResultsPath)
+	}
+
+	results := make([]SearchResult, 0, len(items))
+	for _, item := range items {
+		r := SearchResult{
+			Title:   jsonString(jsonPath(item, s.config.TitleField)),
+			URL:     jsonString(jsonPath(item, s.config.URLField)),
+			Snippet: jsonString(jsonPath(item, s.config.SnippetField)),
+		}
+		if r.URL != "" {
+			results = append(results, r)
+		}
+	}
+	return results, nil
+}
+
+// jsonPath follows a dot separated path through decoded JSON objects
+func jsonPath(v any, path string) any {
+	if path == "" {
+		return v
+	}
+	for _, key := range strings.Split(path, ".") {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return nil
+		}
+		v = obj[key]
+	}
+	return v
+}
+
+func jsonString(v any) string {
+	if s, ok := v.(string); ok {
+		return collapseSpace(s)
+	}
+	return ""
+}
+
+type WebSearchArgs struct {
+	Query          string   `json:"query"`
+	MaxResults     int      `json:"max_results,omitempty"`
+	IncludeDomains []string `json:"include_domains,omitempty"`
+	ExcludeDomains []string `json:"exclude_domains,omitempty"`
+}
+
+// DefaultSearchResults is the number of results returned when max_results is not set
+const DefaultSearchResults = 10
+
+// WebSearchHandler returns the tool handler of web_search for a backend
+func WebSearchHandler(backend SearchBackend) func(ctx context.Context, argsJSON string) (string, error) {
+	return func(ctx context.Context, argsJSON string) (string, error) {
+		var args WebSearchArgs
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		args.Query = strings.TrimSpace(args.Query)
+		if args.Query == "" {
+			return "", fmt.Errorf("query is required")
+		}
+		if args.MaxResults <= 0 {
+			args.MaxResults = DefaultSearchResults
+		}
+
+		results, err := backend.Search(ctx, args.Query)
+		if err != nil {
+			return "", err
+		}
+		results = filterResults(results, args.IncludeDomains, args.ExcludeDomains)
+		if len(results) > args.MaxResults {
+			results = results[:args.MaxResults]
+		}
+		if len(results) == 0 {
+			return fmt.Sprintf("No results found for %q", args.Query), nil
+		}
+
+		var b strings.Builder
+		fmt.Fprintf(&b, "Results for %q:\n", args.Query)
+		for i, r := range results {
+			fmt.Fprintf(&b, "\n%d. %s\n   %s\n", i+1, r.Title, r.URL)
+			if r.Snippet != "" {
+				fmt.Fprintf(&b, "   %s\n", r.Snippet)
+			}
+		}
+		return b.String(), nil
+	}
+}
+
+// filterResults keeps the backend's ranking and drops results outside the
+// included domains or inside the excluded ones
+func filterResults(results []SearchResult, include, exclude []string) []SearchResult {
+	var out []SearchResult
+	for _, r := range results {
+		u, err := url.Parse(r.URL)
+		if err != nil || u.Hostname() == "" {
+			continue
+		}
+		host := u.Hostname()
+		if len(include) > 0 && !matchDomain(host, include) {
+			continue
+		}
+		if matchDomain(host, exclude) {
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// matchDomain reports whether host is one of domains or a subdomain of one; "*" matches any host
+func matchDomain(host string, domains []string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(domain), "."))
+		if domain == "*" || host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
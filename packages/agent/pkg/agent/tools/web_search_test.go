@@ -0,0 +1,85 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWebSearchWithSearXNG(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/search" || r.URL.Query().Get("format") != "json" || r.URL.Query().Get("q") != "go generics" {
+			t.Errorf("unexpected request %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"query":"go generics","results":[
+			{"title":"Tutorial: Getting started with generics","url":"https://go.dev/doc/tutorial/generics","content":"This tutorial introduces\n the basics of generics."},
+			{"title":"Generics in Go","url":"https://blog.example.com/generics","content":"A blog post."},
+			{"title":"Spam","url":"https://spam.test/go","content":"Buy now"},
+			{"title":"No URL"}
+		]}`))
+	}))
+	defer srv.Close()
+
+	search := WebSearchHandler(NewSearXNG(srv.URL+"/", nil))
+	ctx := context.Background()
+
+	out, err := search(ctx, `{"query":"go generics","exclude_domains":["spam.test"]}`)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	want := "1. Tutorial: Getting started with generics\n   https://go.dev/doc/tutorial/generics\n   This tutorial introduces the basics of generics.\n" +
+		"\n2. Generics in Go\n   https://blog.example.com/generics\n"
+	if !strings.Contains(out, want) || strings.Contains(out, "Spam") || strings.Contains(out, "No URL") {
+		t.Fatalf("unexpected results:\n%s", out)
+	}
+
+	out, err = search(ctx, `{"query":"go generics","include_domains":["example.com"]}`)
+	if err != nil || !strings.Contains(out, "1. Generics in Go") || strings.Contains(out, "go.dev") {
+		t.Fatalf("expected only subdomains of example.com, got %q, %v", out, err)
+	}
+
+	out, err = search(ctx, `{"query":"go generics","max_results":1}`)
+	if err != nil || strings.Contains(out, "2.") {
+		t.Fatalf("expected a single result, got %q, %v", out, err)
+	}
+
+	out, err = search(ctx, `{"query":"go generics","include_domains":["nowhere.test"]}`)
+	if err != nil || !strings.Contains(out, "No results") {
+		t.Fatalf("expected no results, got %q, %v", out, err)
+	}
+}
+
+func TestJSONSearchBackend(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"data":{"items":[{"name":"Result","link":{"href":"https://example.com/` + r.URL.Query().Get("q") + `"},"summary":"Snippet"}]}}`))
+	}))
+	defer srv.Close()
+
+	cfg := JSONSearchConfig{
+		URL:          srv.URL + "/api",
+		Headers:      map[string]string{"X-API-Key": "secret"},
+		ResultsPath:  "data.items",
+		TitleField:   "name",
+		URLField:     "link.href",
+		SnippetField: "summary",
+	}
+	results, err := NewJSONSearch(cfg).Search(context.Background(), "term")
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(results) != 1 || results[0] != (SearchResult{Title: "Result", URL: "https://example.com/term", Snippet: "Snippet"}) {
+		t.Fatalf("unexpected results %+v", results)
+	}
+
+	cfg.Headers = nil
+	if _, err := NewJSONSearch(cfg).Search(context.Background(), "term"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Fatalf("expected an HTTP error, got %v", err)
+	}
+}
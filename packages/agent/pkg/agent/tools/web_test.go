@@ -0,0 +1,142 @@
+package tools
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPage = `<html><head><title>Release notes</title><style>body{}</style></head>
+<body>
+<nav><a href="/">Home</a></nav>
+<h1>Version 2.0</h1>
+<p>Adds <strong>streaming</strong> and <a href="/docs/stream">docs</a>.</p>
+<ul><li>Faster</li><li>Smaller</li></ul>
+<pre><code>go get example.com/lib@v2</code></pre>
+<script>alert("x")</script>
+</body></html>`
+
+func TestHTMLToMarkdown(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(testPage))
+	}))
+	defer srv.Close()
+
+	out, err := NewWebFetcher(WebFetchConfig{}, nil).Handle(context.Background(), `{"url":"`+srv.URL+`/notes"}`)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	for _, want := range []string{
+		"Title: Release notes",
+		"# Version 2.0",
+		"Adds **streaming** and [docs](" + srv.URL + "/docs/stream).",
+		"- Faster\n- Smaller",
+		"```\ngo get example.com/lib@v2\n```",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "alert") || strings.Contains(out, "body{}") {
+		t.Errorf("expected scripts and styles to be dropped:\n%s", out)
+	}
+}
+
+func TestWebFetchRedirects(t *testing.T) {
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("other site"))
+	}))
+	defer other.Close()
+	// The same server under another host name
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/moved":
+			http.Redirect(w, r, "/new", http.StatusFound)
+		case "/away":
+			http.Redirect(w, r, otherURL+"/page", http.StatusFound)
+		default:
+			w.Write([]byte("new location"))
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	out, err := NewWebFetcher(WebFetchConfig{}, nil).Handle(ctx, `{"url":"`+srv.URL+`/moved"}`)
+	if err != nil || !strings.Contains(out, "new location") {
+		t.Fatalf("expected a same-host redirect to be followed, got %q, %v", out, err)
+	}
+
+	out, err = NewWebFetcher(WebFetchConfig{}, nil).Handle(ctx, `{"url":"`+srv.URL+`/away"}`)
+	if err != nil || !strings.Contains(out, "redirects to "+otherURL+"/page") || strings.Contains(out, "other site") {
+		t.Fatalf("expected a cross-domain redirect to be reported, got %q, %v", out, err)
+	}
+
+	allowed := NewWebFetcher(WebFetchConfig{AllowedDomains: []string{"localhost"}}, nil)
+	out, err = allowed.Handle(ctx, `{"url":"`+srv.URL+`/away"}`)
+	if err != nil || !strings.Contains(out, "other site") {
+		t.Fatalf("expected a redirect into an allowed domain to be followed, got %q, %v", out, err)
+	}
+}
+
+func TestWebFetchLimits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/big":
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte(strings.Repeat("a", 2000)))
+		case "/missing":
+			http.NotFound(w, r)
+		default:
+			w.Header().Set("Content-Type", "application/octet-stream")
+			w.Write([]byte{0, 1, 2})
+		}
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	f := NewWebFetcher(WebFetchConfig{MaxBytes: 100}, nil)
+	out, err := f.Handle(ctx, `{"url":"`+srv.URL+`/big"}`)
+	if err != nil || strings.Count(out, "a") > 150 || !strings.Contains(out, "[truncated: the response is larger than 100 bytes]") {
+		t.Fatalf("expected a truncated body, got %q, %v", out, err)
+	}
+
+	if _, err := f.Handle(ctx, `{"url":"`+srv.URL+`/missing"}`); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Fatalf("expected an HTTP error, got %v", err)
+	}
+	if _, err := f.Handle(ctx, `{"url":"`+srv.URL+`/binary"}`); err == nil || !strings.Contains(err.Error(), "unsupported content type") {
+		t.Fatalf("expected binary content to be rejected, got %v", err)
+	}
+	if _, err := f.Handle(ctx, `{"url":"file:///etc/passwd"}`); err == nil {
+		t.Fatalf("expected non-http URLs to be rejected")
+	}
+}
+
+func TestWebFetchExtractsWithPrompt(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(testPage))
+	}))
+	defer srv.Close()
+
+	var gotPrompt, gotContent string
+	extract := func(ctx context.Context, prompt, content string) (string, error) {
+		gotPrompt, gotContent = prompt, content
+		return "The latest version is 2.0", nil
+	}
+	out, err := NewWebFetcher(WebFetchConfig{}, extract).Handle(context.Background(),
+		`{"url":"`+srv.URL+`","prompt":"What is the latest version?"}`)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if gotPrompt != "What is the latest version?" || !strings.Contains(gotContent, "# Version 2.0") {
+		t.Fatalf("unexpected extraction input %q / %q", gotPrompt, gotContent)
+	}
+	if !strings.Contains(out, "The latest version is 2.0") || strings.Contains(out, "Faster") {
+		t.Fatalf("expected only the extracted answer, got %q", out)
+	}
+}
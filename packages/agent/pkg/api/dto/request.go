@@ -1,17 +1,30 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
 // CreateSessionRequest is the request body for creating a new session.
 type CreateSessionRequest struct {
-	Prompt       string `json:"prompt,omitempty"`        // Optional: if empty, session is created without LLM call
+	Prompt       string `json:"prompt,omitempty"` // Optional: if empty, session is created without LLM call
 	SystemPrompt string `json:"system_prompt,omitempty"`
-	Priority     int    `json:"priority,omitempty"`
-	Constraints  any    `json:"constraints,omitempty"`
+	Priority     int        `json:"priority,omitempty"`  // Lower runs first; 0 is highest
+	Deadline     *time.Time `json:"deadline,omitempty"`  // The goal fails if not done by then (RFC 3339)
+	MaxSteps     int        `json:"max_steps,omitempty"` // The goal fails after this many LLM calls; 0 is unlimited
+	Constraints  any        `json:"constraints,omitempty"`
+	Mode         string     `json:"mode,omitempty"` // planning/executing (default)
 }
 
 // MessageRequest is the request body for posting a message to a session.
 type MessageRequest struct {
-	Content  string `json:"content" binding:"required"`
-	Semantic string `json:"semantic,omitempty"` // append/fork/preempt/cancel
+	Content  string              `json:"content"`             // Required unless parts are given
+	Parts    []types.ContentPart `json:"parts,omitempty"`     // Text and images in order; images by artifact_id or base64 data
+	Semantic string              `json:"semantic,omitempty"`  // append/fork/preempt/cancel
+	Priority int                 `json:"priority,omitempty"`  // For goals created by fork/preempt or a new request
+	Deadline *time.Time          `json:"deadline,omitempty"`  // RFC 3339
+	MaxSteps int                 `json:"max_steps,omitempty"` // Step budget of the goal the message creates; 0 is unlimited
 }
 
 // PermissionResponseRequest is the request body for responding to a permission request
@@ -20,3 +33,31 @@ type PermissionResponseRequest struct {
 	Approved  bool   `json:"approved"`
 	Always    bool   `json:"always"`
 }
+
+// ModeRequest is the request body for switching a session's runtime mode
+type ModeRequest struct {
+	Mode string `json:"mode" binding:"required"` // planning/executing
+}
+
+// ModelRequest is the request body for overriding a session's model.
+// Both fields empty restores the configured routes.
+type ModelRequest struct {
+	Provider string `json:"provider,omitempty"` // Configured provider; empty is the default one
+	Model    string `json:"model,omitempty"`    // Empty is the provider's configured model
+}
+
+// LimitsRequest is the request body for changing a session's shell limits.
+// Omitted fields keep their value; 0 disables a limit.
+type LimitsRequest struct {
+	ExecutionTime *int64 `json:"execution_time,omitempty"` // Wall-clock seconds per foreground command
+	CPUTime       *int64 `json:"cpu_time,omitempty"`       // CPU seconds per process
+	Memory        *int64 `json:"memory,omitempty"`         // Bytes of address space per process
+	FileSize      *int64 `json:"file_size,omitempty"`      // Bytes per written file
+	OpenFiles     *int64 `json:"open_files,omitempty"`     // Open file descriptors per process
+	Output        *int64 `json:"output,omitempty"`         // Bytes of output returned per command
+}
+
+// PlanRejectRequest is the request body for rejecting a generated plan
+type PlanRejectRequest struct {
+	Feedback string `json:"feedback,omitempty"` // What to change when replanning
+}
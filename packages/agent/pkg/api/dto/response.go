@@ -8,6 +8,25 @@ type SessionResponse struct {
 	Status    string    `json:"status"`
 	CreatedAt time.Time `json:"created_at"`
 	Error     string    `json:"error,omitempty"`
+	Mode      string    `json:"mode,omitempty"` // planning/executing
+}
+
+// ModelResponse is the model override of a session.
+type ModelResponse struct {
+	SessionID string `json:"session_id"`
+	Provider  string `json:"provider,omitempty"`
+	Model     string `json:"model,omitempty"`
+}
+
+// LimitsResponse is the shell limits of a session; 0 is unlimited.
+type LimitsResponse struct {
+	SessionID     string `json:"session_id"`
+	ExecutionTime int64  `json:"execution_time"`
+	CPUTime       int64  `json:"cpu_time"`
+	Memory        int64  `json:"memory"`
+	FileSize      int64  `json:"file_size"`
+	OpenFiles     int64  `json:"open_files"`
+	Output        int64  `json:"output"`
 }
 
 // SessionListResponse is the response for listing sessions.
@@ -30,3 +49,12 @@ type ErrorResponse struct {
 type DeleteResponse struct {
 	Deleted bool `json:"deleted"`
 }
+
+// CompactResponse is the response for a manual context compaction.
+type CompactResponse struct {
+	Compacted         bool   `json:"compacted"`
+	Message           string `json:"message,omitempty"`
+	MessagesCompacted int    `json:"messages_compacted"`
+	TokensBefore      int    `json:"tokens_before"`
+	TokensAfter       int    `json:"tokens_after"`
+}
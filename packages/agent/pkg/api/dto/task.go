@@ -0,0 +1,20 @@
+package dto
+
+import "time"
+
+// TaskResponse is one entry of a session's task list.
+type TaskResponse struct {
+	ID          string     `json:"id"`
+	GoalID      string     `json:"goal_id"`
+	Description string     `json:"description"`
+	Status      string     `json:"status"` // pending/running/completed/blocked/failed/cancelled
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	EndedAt     *time.Time `json:"ended_at,omitempty"`
+}
+
+// TaskListResponse is the ordered task list of a session.
+type TaskListResponse struct {
+	SessionID string         `json:"session_id"`
+	Tasks     []TaskResponse `json:"tasks"`
+}
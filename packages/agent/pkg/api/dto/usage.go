@@ -0,0 +1,18 @@
+package dto
+
+// UsageTotals is token usage and cost for one scope (session, goal or model)
+type UsageTotals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// UsageResponse contains aggregated LLM usage for a session
+type UsageResponse struct {
+	SessionID string                 `json:"session_id"`
+	Total     UsageTotals            `json:"total"`
+	ByGoal    map[string]UsageTotals `json:"by_goal"`
+	ByModel   map[string]UsageTotals `json:"by_model"`
+}
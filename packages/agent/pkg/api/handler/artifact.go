@@ -1,6 +1,8 @@
 package handler
 
 import (
+	"errors"
+	"io"
 	"net/http"
 	"os"
 
@@ -65,5 +67,63 @@ func (h *ArtifactHandler) Get(c *gin.Context) {
 		}
 	}
 
+	// Uploaded images live in the artifact store
+	if art.Type == "image" {
+		data, err := h.svc.ArtifactContent(c.Request.Context(), sessionID, artifactID)
+		if err == nil && len(data) > 0 {
+			c.Header("Content-Type", art.Metadata["mime_type"])
+			c.Writer.WriteHeader(http.StatusOK)
+			_, _ = c.Writer.Write(data)
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, art)
 }
+
+// Upload godoc
+// @Summary      Upload an image
+// @Description  Store a PNG, JPEG, GIF or WebP image (max 5 MB) as a session artifact. Messages reference it with an image part {"type":"image","artifact_id":"..."}.
+// @Tags         artifact
+// @Accept       multipart/form-data
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        file formData file true "Image file"
+// @Success      201 {object} types.Artifact
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      413 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/artifact [post]
+func (h *ArtifactHandler) Upload(c *gin.Context) {
+	sessionID := c.Param("id")
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "missing file field"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, service.MaxImageSize+1))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+	if len(data) > service.MaxImageSize {
+		c.JSON(http.StatusRequestEntityTooLarge, dto.ErrorResponse{Error: "image is larger than 5 MB"})
+		return
+	}
+
+	art, err := h.svc.UploadImage(c.Request.Context(), sessionID, header.Filename, data)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSessionNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+		case errors.Is(err, service.ErrInvalidImage):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, art)
+}
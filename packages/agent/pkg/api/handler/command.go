@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gm-agent-org/gm-agent/pkg/api/service"
+)
+
+type CommandHandler struct {
+	svc *service.SessionService
+}
+
+func NewCommandHandler(svc *service.SessionService) *CommandHandler {
+	return &CommandHandler{svc: svc}
+}
+
+// List godoc
+// @Summary      List slash commands
+// @Description  List the prompt templates in .gm/commands and ~/.gm/commands. Sending "/name args" as a message runs the command, with $ARGUMENTS replaced by args.
+// @Tags         command
+// @Produce      json
+// @Success      200 {object} map[string]any "{"commands": []commands.Command}"
+// @Router       /api/v1/commands [get]
+func (h *CommandHandler) List(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"commands": h.svc.Commands()})
+}
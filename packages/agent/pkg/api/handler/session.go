@@ -4,11 +4,13 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gm-agent-org/gm-agent/pkg/api/dto"
 	"github.com/gm-agent-org/gm-agent/pkg/api/service"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
 
 // SessionHandler handles session-related requests.
@@ -30,6 +32,7 @@ func NewSessionHandler(svc *service.SessionService) *SessionHandler {
 // @Param        request body dto.CreateSessionRequest true "Session request"
 // @Success      201 {object} dto.SessionResponse
 // @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
 // @Failure      500 {object} dto.ErrorResponse
 // @Router       /api/v1/session [post]
 func (h *SessionHandler) Create(c *gin.Context) {
@@ -38,9 +41,21 @@ func (h *SessionHandler) Create(c *gin.Context) {
 		// Allow empty body for session creation without prompt
 		req = dto.CreateSessionRequest{}
 	}
+	if req.MaxSteps < 0 {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "max_steps must not be negative"})
+		return
+	}
 
-	session, err := h.svc.Create(c.Request.Context(), req.Prompt, req.SystemPrompt, req.Priority)
+	session, err := h.svc.Create(c.Request.Context(), req.Prompt, req.SystemPrompt, req.Priority, req.Deadline, req.MaxSteps, req.Mode)
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidMode) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrPromptBlocked) {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
 		return
 	}
@@ -106,6 +121,7 @@ func (h *SessionHandler) Get(c *gin.Context) {
 		Status:    status,
 		CreatedAt: session.CreatedAt,
 		Error:     lastErr,
+		Mode:      string(session.Resources.Runtime.GetState().Mode),
 	})
 }
 
@@ -172,6 +188,7 @@ func (h *SessionHandler) Cancel(c *gin.Context) {
 // @Param        request body dto.MessageRequest true "Message request"
 // @Success      200 {object} dto.SessionResponse
 // @Failure      400 {object} dto.ErrorResponse
+// @Failure      403 {object} dto.ErrorResponse
 // @Failure      404 {object} dto.ErrorResponse
 // @Failure      500 {object} dto.ErrorResponse
 // @Router       /api/v1/session/{id}/message [post]
@@ -183,13 +200,225 @@ func (h *SessionHandler) Message(c *gin.Context) {
 		return
 	}
 
-	session, err := h.svc.Message(c.Request.Context(), id, req.Content, req.Semantic)
+	if req.Content == "" && len(req.Parts) == 0 {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "content or parts is required"})
+		return
+	}
+	if req.MaxSteps < 0 {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "max_steps must not be negative"})
+		return
+	}
+
+	session, err := h.svc.Message(c.Request.Context(), id, req.Content, req.Parts, req.Semantic, req.Priority, req.Deadline, req.MaxSteps)
 	if err != nil {
 		if errors.Is(err, service.ErrSessionNotFound) {
 			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
 			return
 		}
+		if errors.Is(err, service.ErrInvalidImage) {
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		if errors.Is(err, service.ErrPromptBlocked) {
+			c.JSON(http.StatusForbidden, dto.ErrorResponse{Error: err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	status, lastErr := session.GetStatus()
+	c.JSON(http.StatusOK, dto.SessionResponse{
+		ID:        session.ID,
+		Status:    status,
+		CreatedAt: session.CreatedAt,
+		Error:     lastErr,
+	})
+}
+
+// Mode godoc
+// @Summary      Switch runtime mode
+// @Description  Switch a session between planning (read-only, plan for approval) and executing mode
+// @Tags         session
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        request body dto.ModeRequest true "Mode request"
+// @Success      200 {object} dto.SessionResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/mode [post]
+func (h *SessionHandler) Mode(c *gin.Context) {
+	id := c.Param("id")
+	var req dto.ModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	session, err := h.svc.SetMode(c.Request.Context(), id, req.Mode)
+	if err != nil {
+		h.planError(c, err)
+		return
+	}
+
+	status, lastErr := session.GetStatus()
+	c.JSON(http.StatusOK, dto.SessionResponse{
+		ID:        session.ID,
+		Status:    status,
+		CreatedAt: session.CreatedAt,
+		Error:     lastErr,
+		Mode:      req.Mode,
+	})
+}
+
+// Model godoc
+// @Summary      Override session model
+// @Description  Send the session's LLM calls to a configured provider and model, taking precedence over the routes in GM_ROUTES. Sending {} restores the routes.
+// @Tags         session
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        request body dto.ModelRequest true "Model override"
+// @Success      200 {object} dto.ModelResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/model [post]
+func (h *SessionHandler) Model(c *gin.Context) {
+	id := c.Param("id")
+	var req dto.ModelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+
+	route := types.ModelRoute{Provider: strings.TrimSpace(req.Provider), Model: strings.TrimSpace(req.Model)}
+	if _, err := h.svc.SetModel(c.Request.Context(), id, route); err != nil {
+		switch {
+		case errors.Is(err, service.ErrSessionNotFound):
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+		case errors.Is(err, service.ErrInvalidModel):
+			c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		}
+		return
+	}
+	c.JSON(http.StatusOK, dto.ModelResponse{SessionID: id, Provider: route.Provider, Model: route.Model})
+}
+
+// Limits godoc
+// @Summary      Get session shell limits
+// @Description  The time, memory, file and output limits of the session's shell commands; 0 is unlimited
+// @Tags         session
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.LimitsResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/limits [get]
+func (h *SessionHandler) Limits(c *gin.Context) {
+	result, err := h.svc.Limits(c.Param("id"))
+	if err != nil {
+		h.limitsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// SetLimits godoc
+// @Summary      Change session shell limits
+// @Description  Override the GM_LIMITS settings for the session's later shell commands. Omitted fields keep their value, 0 disables a limit. The persistent shell restarts when its rlimits change.
+// @Tags         session
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        request body dto.LimitsRequest true "Limits to change"
+// @Success      200 {object} dto.LimitsResponse
+// @Failure      400 {object} dto.ErrorResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/limits [post]
+func (h *SessionHandler) SetLimits(c *gin.Context) {
+	var req dto.LimitsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: "invalid request body"})
+		return
+	}
+	result, err := h.svc.SetLimits(c.Param("id"), req)
+	if err != nil {
+		h.limitsError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *SessionHandler) limitsError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+	case errors.Is(err, service.ErrInvalidLimits):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrNoShells):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	default:
 		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
+
+// ApprovePlan godoc
+// @Summary      Approve plan
+// @Description  Approve the plan awaiting review; the session switches to executing mode and carries it out
+// @Tags         session
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.SessionResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/plan/approve [post]
+func (h *SessionHandler) ApprovePlan(c *gin.Context) {
+	id := c.Param("id")
+	session, err := h.svc.ApprovePlan(c.Request.Context(), id)
+	if err != nil {
+		h.planError(c, err)
+		return
+	}
+
+	status, lastErr := session.GetStatus()
+	c.JSON(http.StatusOK, dto.SessionResponse{
+		ID:        session.ID,
+		Status:    status,
+		CreatedAt: session.CreatedAt,
+		Error:     lastErr,
+	})
+}
+
+// RejectPlan godoc
+// @Summary      Reject plan
+// @Description  Reject the plan awaiting review; the agent replans using the feedback
+// @Tags         session
+// @Accept       json
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Param        request body dto.PlanRejectRequest false "Rejection feedback"
+// @Success      200 {object} dto.SessionResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      409 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/plan/reject [post]
+func (h *SessionHandler) RejectPlan(c *gin.Context) {
+	id := c.Param("id")
+	var req dto.PlanRejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		// Feedback is optional
+		req = dto.PlanRejectRequest{}
+	}
+
+	session, err := h.svc.RejectPlan(c.Request.Context(), id, req.Feedback)
+	if err != nil {
+		h.planError(c, err)
 		return
 	}
 
@@ -202,6 +431,20 @@ func (h *SessionHandler) Message(c *gin.Context) {
 	})
 }
 
+// planError maps plan and mode errors to HTTP status codes
+func (h *SessionHandler) planError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSessionNotFound):
+		c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+	case errors.Is(err, service.ErrNoPendingPlan):
+		c.JSON(http.StatusConflict, dto.ErrorResponse{Error: err.Error()})
+	case errors.Is(err, service.ErrInvalidMode):
+		c.JSON(http.StatusBadRequest, dto.ErrorResponse{Error: err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+	}
+}
+
 // Permission godoc
 // @Summary      Respond to permission request
 // @Description  Approve or deny a permission request for a session
@@ -336,6 +579,79 @@ func (h *SessionHandler) ListCheckpoints(c *gin.Context) {
 	c.JSON(http.StatusOK, checkpoints)
 }
 
+// Usage godoc
+// @Summary      Get session usage
+// @Description  Token usage and cost for a session, broken down by goal and model
+// @Tags         session
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.UsageResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/usage [get]
+func (h *SessionHandler) Usage(c *gin.Context) {
+	id := c.Param("id")
+	result, err := h.svc.Usage(id)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Tasks godoc
+// @Summary      Get session tasks
+// @Description  The task list the agent maintains with the todo tools, in order
+// @Tags         session
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.TaskListResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/tasks [get]
+func (h *SessionHandler) Tasks(c *gin.Context) {
+	id := c.Param("id")
+	result, err := h.svc.Tasks(id)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Compact godoc
+// @Summary      Compact session context
+// @Description  Summarize older messages to free up context window space
+// @Tags         session
+// @Produce      json
+// @Param        id path string true "Session ID"
+// @Success      200 {object} dto.CompactResponse
+// @Failure      404 {object} dto.ErrorResponse
+// @Failure      500 {object} dto.ErrorResponse
+// @Router       /api/v1/session/{id}/compact [post]
+func (h *SessionHandler) Compact(c *gin.Context) {
+	id := c.Param("id")
+	result, err := h.svc.Compact(c.Request.Context(), id)
+	if err != nil {
+		if errors.Is(err, service.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, dto.ErrorResponse{Error: "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, dto.ErrorResponse{Error: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 // Rewind godoc
 // @Summary      Rewind session
 // @Description  Rewind a session to a previous checkpoint
@@ -29,12 +29,26 @@ func (s *Server) setupRoutes() {
 	v1.POST("/session/:id/permission", sessionHandler.Permission)
 	v1.GET("/session/:id/checkpoints", sessionHandler.ListCheckpoints)
 	v1.POST("/session/:id/rewind", sessionHandler.Rewind)
+	v1.POST("/session/:id/compact", sessionHandler.Compact)
+	v1.GET("/session/:id/usage", sessionHandler.Usage)
+	v1.GET("/session/:id/tasks", sessionHandler.Tasks)
+	v1.POST("/session/:id/mode", sessionHandler.Mode)
+	v1.POST("/session/:id/model", sessionHandler.Model)
+	v1.GET("/session/:id/limits", sessionHandler.Limits)
+	v1.POST("/session/:id/limits", sessionHandler.SetLimits)
+	v1.POST("/session/:id/plan/approve", sessionHandler.ApprovePlan)
+	v1.POST("/session/:id/plan/reject", sessionHandler.RejectPlan)
 
 	// Artifact handlers
 	artifactHandler := handler.NewArtifactHandler(s.sessionSvc)
 	v1.GET("/session/:id/artifact", artifactHandler.List)
+	v1.POST("/session/:id/artifact", artifactHandler.Upload)
 	v1.GET("/session/:id/artifact/:art_id", artifactHandler.Get)
 
+	// Slash commands
+	commandHandler := handler.NewCommandHandler(s.sessionSvc)
+	v1.GET("/commands", commandHandler.List)
+
 	// Legacy routes (deprecated, for backward compat)
 	v1.POST("/sessions", sessionHandler.Create)
 	v1.GET("/sessions/:id", sessionHandler.Get)
@@ -1,16 +1,27 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"image"
+	pngenc "image/png"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/gm-agent-org/gm-agent/pkg/api/dto"
 	"github.com/gm-agent-org/gm-agent/pkg/api/service"
+	"github.com/gm-agent-org/gm-agent/pkg/commands"
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+	"github.com/gm-agent-org/gm-agent/pkg/shell"
 	"github.com/gm-agent-org/gm-agent/pkg/store"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
@@ -144,6 +155,504 @@ func TestCancelSession(t *testing.T) {
 	}
 }
 
+func TestDeleteSessionKillsBackgroundShells(t *testing.T) {
+	memStore := newMemoryStore()
+	shells := shell.NewManager("")
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: &stubRuntime{store: memStore}, Store: memStore, Shells: shells, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/session", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(w, req)
+	var resp map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &resp)
+	sessionID := resp["id"].(string)
+
+	proc, err := shells.Start("sleep 60")
+	if err != nil {
+		t.Fatalf("start shell: %v", err)
+	}
+
+	delReq, _ := http.NewRequest(http.MethodDelete, "/api/v1/session/"+sessionID, nil)
+	delW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(delW, delReq)
+	if delW.Code != http.StatusOK && delW.Code != http.StatusNoContent {
+		t.Fatalf("delete returned %d", delW.Code)
+	}
+	if proc.Running() {
+		t.Fatalf("expected the background shell to be killed with the session")
+	}
+}
+
+func TestSessionLimits(t *testing.T) {
+	memStore := newMemoryStore()
+	shells := shell.NewManager("")
+	defer shells.Close()
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: &stubRuntime{store: memStore}, Store: memStore, Shells: shells, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+	sess, err := svc.Create(context.Background(), "", "", 0, nil, 0, "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	do := func(method, id, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v1/session/"+id+"/limits", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(w, req)
+		return w
+	}
+	w := do(http.MethodPost, sess.ID, `{"execution_time":30,"memory":0}`)
+	var limits dto.LimitsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &limits); err != nil || w.Code != http.StatusOK {
+		t.Fatalf("set limits returned %d: %s", w.Code, w.Body.String())
+	}
+	if limits.ExecutionTime != 30 || limits.Memory != 0 || limits.CPUTime != security.DefaultResourceLimits().MaxCPUTime {
+		t.Fatalf("expected only the given limits to change, got %+v", limits)
+	}
+	if got := shells.Limits(); got.MaxExecutionTime != 30 || got.MaxMemory != 0 {
+		t.Fatalf("expected the session's shells to use the new limits, got %+v", got)
+	}
+	if w := do(http.MethodGet, sess.ID, ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"execution_time":30`) {
+		t.Fatalf("get limits returned %d: %s", w.Code, w.Body.String())
+	}
+	if w := do(http.MethodPost, sess.ID, `{"output":-1}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a negative limit to be rejected, got %d", w.Code)
+	}
+	if w := do(http.MethodGet, "ses_missing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing session, got %d", w.Code)
+	}
+}
+
+func TestSessionUsage(t *testing.T) {
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/session", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(w, req)
+
+	var created map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	sessionID := created["id"].(string)
+
+	state := types.NewState()
+	state.Usage = types.NewUsageLedger()
+	state.Usage.Record("goal_1", "gpt-4o", types.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}, 0.0005)
+	_ = memStore.SaveState(context.Background(), state)
+
+	usageReq, _ := http.NewRequest(http.MethodGet, "/api/v1/session/"+sessionID+"/usage", nil)
+	usageW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(usageW, usageReq)
+	if usageW.Code != http.StatusOK {
+		t.Fatalf("usage endpoint returned %d", usageW.Code)
+	}
+
+	var usage struct {
+		Total struct {
+			TotalTokens int `json:"total_tokens"`
+		} `json:"total"`
+		ByGoal  map[string]any `json:"by_goal"`
+		ByModel map[string]any `json:"by_model"`
+	}
+	_ = json.Unmarshal(usageW.Body.Bytes(), &usage)
+	if usage.Total.TotalTokens != 120 || usage.ByGoal["goal_1"] == nil || usage.ByModel["gpt-4o"] == nil {
+		t.Fatalf("unexpected usage response: %s", usageW.Body.String())
+	}
+
+	missingReq, _ := http.NewRequest(http.MethodGet, "/api/v1/session/missing/usage", nil)
+	missingW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(missingW, missingReq)
+	if missingW.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", missingW.Code)
+	}
+}
+
+func TestSessionTasks(t *testing.T) {
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/session", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(w, req)
+
+	var created map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	sessionID := created["id"].(string)
+
+	state := types.NewState()
+	state.Goals = append(state.Goals, types.Goal{ID: "goal_1"})
+	state.Tasks["tsk_b"] = &types.Task{ID: "tsk_b", GoalID: "goal_1", Description: "write tests", Status: types.TaskStatusPending, Order: 1}
+	state.Tasks["tsk_a"] = &types.Task{ID: "tsk_a", GoalID: "goal_1", Description: "design cache", Status: types.TaskStatusRunning, Order: 0}
+	_ = memStore.SaveState(context.Background(), state)
+
+	tasksReq, _ := http.NewRequest(http.MethodGet, "/api/v1/session/"+sessionID+"/tasks", nil)
+	tasksW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(tasksW, tasksReq)
+	if tasksW.Code != http.StatusOK {
+		t.Fatalf("tasks endpoint returned %d", tasksW.Code)
+	}
+
+	var list struct {
+		Tasks []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"tasks"`
+	}
+	_ = json.Unmarshal(tasksW.Body.Bytes(), &list)
+	if len(list.Tasks) != 2 || list.Tasks[0].ID != "tsk_a" || list.Tasks[0].Status != "running" || list.Tasks[1].ID != "tsk_b" {
+		t.Fatalf("unexpected tasks response: %s", tasksW.Body.String())
+	}
+
+	missingReq, _ := http.NewRequest(http.MethodGet, "/api/v1/session/missing/tasks", nil)
+	missingW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(missingW, missingReq)
+	if missingW.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for unknown session, got %d", missingW.Code)
+	}
+}
+
+func TestGoalPriorityAndDeadlinePassThrough(t *testing.T) {
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/session", strings.NewReader(`{"prompt":"refactor","priority":5,"deadline":"2030-01-02T15:04:05Z","max_steps":8}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create returned %d: %s", w.Code, w.Body.String())
+	}
+	var created map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	sessionID := created["id"].(string)
+
+	msgReq, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+sessionID+"/message", strings.NewReader(`{"content":"fix prod","semantic":"preempt","max_steps":3}`))
+	msgReq.Header.Set("Content-Type", "application/json")
+	msgW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(msgW, msgReq)
+	if msgW.Code != http.StatusOK {
+		t.Fatalf("message returned %d: %s", msgW.Code, msgW.Body.String())
+	}
+
+	memStore.mu.RLock()
+	defer memStore.mu.RUnlock()
+	var msgs []*types.UserMessageEvent
+	for _, e := range memStore.events {
+		if m, ok := e.(*types.UserMessageEvent); ok {
+			msgs = append(msgs, m)
+		}
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 user messages, got %d", len(msgs))
+	}
+	if msgs[0].Priority != 5 || msgs[0].Deadline == nil || msgs[0].Deadline.Year() != 2030 || msgs[0].MaxSteps != 8 {
+		t.Fatalf("expected prompt priority, deadline and step budget, got %+v", msgs[0])
+	}
+	if msgs[1].Priority != -100 || msgs[1].Semantic != types.SemanticPreempt || msgs[1].MaxSteps != 3 {
+		t.Fatalf("expected preempt message to get a high priority, got %+v", msgs[1])
+	}
+
+	badReq, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+sessionID+"/message", strings.NewReader(`{"content":"x","max_steps":-1}`))
+	badReq.Header.Set("Content-Type", "application/json")
+	badW := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(badW, badReq)
+	if badW.Code != http.StatusBadRequest {
+		t.Fatalf("expected a negative step budget to be rejected, got %d", badW.Code)
+	}
+}
+
+func TestImageUploadAndMessageParts(t *testing.T) {
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+	sess, err := svc.Create(context.Background(), "", "", 0, nil, 0, "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	var png bytes.Buffer
+	if err := pngenc.Encode(&png, image.NewRGBA(image.Rect(0, 0, 1, 1))); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	upload := func(name string, data []byte) *httptest.ResponseRecorder {
+		var body bytes.Buffer
+		mw := multipart.NewWriter(&body)
+		fw, _ := mw.CreateFormFile("file", name)
+		_, _ = fw.Write(data)
+		_ = mw.Close()
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+sess.ID+"/artifact", &body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		w := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("screen.png", png.Bytes())
+	if w.Code != http.StatusCreated {
+		t.Fatalf("upload returned %d: %s", w.Code, w.Body.String())
+	}
+	var art types.Artifact
+	_ = json.Unmarshal(w.Body.Bytes(), &art)
+	if art.ID == "" || art.Type != "image" || art.Metadata["mime_type"] != "image/png" || len(art.Content) != 0 {
+		t.Fatalf("unexpected artifact %+v", art)
+	}
+	if stored, err := memStore.GetArtifact(context.Background(), art.ID); err != nil || !bytes.Equal(stored.Content, png.Bytes()) {
+		t.Fatalf("expected the image in the artifact store, got %v", err)
+	}
+	if w := upload("notes.txt", []byte("hello")); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected a text upload to be rejected, got %d", w.Code)
+	}
+
+	message := func(body string) int {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+sess.ID+"/message", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(w, req)
+		return w.Code
+	}
+	data := base64.StdEncoding.EncodeToString(png.Bytes())
+	if code := message(`{"parts":[{"type":"text","text":"what is wrong here?"},{"type":"image","data":"` + data + `"}]}`); code != http.StatusOK {
+		t.Fatalf("message with image returned %d", code)
+	}
+	if code := message(`{"parts":[{"type":"image","data":"aGVsbG8="}]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected non-image data to be rejected, got %d", code)
+	}
+	if code := message(`{"parts":[{"type":"image","artifact_id":"art_missing"}]}`); code != http.StatusBadRequest {
+		t.Fatalf("expected unknown artifact to be rejected, got %d", code)
+	}
+
+	memStore.mu.RLock()
+	defer memStore.mu.RUnlock()
+	var created, messages int
+	for _, e := range memStore.events {
+		switch e := e.(type) {
+		case *types.ArtifactCreatedEvent:
+			created++
+		case *types.UserMessageEvent:
+			messages++
+			if e.Content != "what is wrong here?" || len(e.Parts) != 2 || e.Parts[1].MimeType != "image/png" {
+				t.Fatalf("unexpected user message %+v", e)
+			}
+		}
+	}
+	if created != 1 || messages != 1 {
+		t.Fatalf("expected 1 artifact and 1 message event, got %d and %d", created, messages)
+	}
+}
+
+func TestSlashCommands(t *testing.T) {
+	dir := t.TempDir()
+	review := "---\ndescription: Review a file\nargument-hint: <path>\nallowed-tools: read_file, grep\nmodel: gpt-4o-mini\n---\nReview $ARGUMENTS for bugs.\n"
+	if err := os.WriteFile(filepath.Join(dir, "review.md"), []byte(review), 0644); err != nil {
+		t.Fatalf("write command: %v", err)
+	}
+	loader, err := commands.New(dir, "", nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	svc.SetCommands(loader)
+	srv := NewServer(Config{}, svc, nil)
+
+	req, _ := http.NewRequest(http.MethodGet, "/api/v1/commands", nil)
+	w := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("list commands returned %d", w.Code)
+	}
+	var list struct {
+		Commands []commands.Command `json:"commands"`
+	}
+	_ = json.Unmarshal(w.Body.Bytes(), &list)
+	if len(list.Commands) != 1 || list.Commands[0].Name != "review" || list.Commands[0].ArgumentHint != "<path>" {
+		t.Fatalf("unexpected commands %+v", list.Commands)
+	}
+
+	sess, err := svc.Create(context.Background(), "", "", 0, nil, 0, "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+	for _, content := range []string{"/review main.go", "/unknown main.go"} {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+sess.ID+"/message", strings.NewReader(`{"content":"`+content+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("message %q returned %d", content, w.Code)
+		}
+	}
+
+	memStore.mu.RLock()
+	defer memStore.mu.RUnlock()
+	var msgs []*types.UserMessageEvent
+	for _, e := range memStore.events {
+		if e, ok := e.(*types.UserMessageEvent); ok {
+			msgs = append(msgs, e)
+		}
+	}
+	if len(msgs) != 2 {
+		t.Fatalf("expected 2 message events, got %d", len(msgs))
+	}
+	if msgs[0].Content != "Review main.go for bugs." || msgs[0].Command != "review" || msgs[0].Model != "gpt-4o-mini" ||
+		strings.Join(msgs[0].AllowedTools, ",") != "read_file,grep" {
+		t.Fatalf("expected the expanded command, got %+v", msgs[0])
+	}
+	if msgs[1].Content != "/unknown main.go" || msgs[1].Command != "" {
+		t.Fatalf("expected unknown commands to pass through, got %+v", msgs[1])
+	}
+}
+
+func TestSessionModelOverride(t *testing.T) {
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	svc.SetProviders([]string{"gemini", "openai"})
+	srv := NewServer(Config{}, svc, nil)
+	sess, err := svc.Create(context.Background(), "", "", 0, nil, 0, "")
+	if err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	setModel := func(id, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+id+"/model", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(w, req)
+		return w
+	}
+	w := setModel(sess.ID, `{"provider":"openai","model":"gpt-4o"}`)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"model":"gpt-4o"`) {
+		t.Fatalf("set model returned %d: %s", w.Code, w.Body.String())
+	}
+	if w := setModel(sess.ID, `{"provider":"anthropic"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected an unconfigured provider to be rejected, got %d", w.Code)
+	}
+	if w := setModel("ses_missing", `{}`); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a missing session, got %d", w.Code)
+	}
+
+	memStore.mu.RLock()
+	defer memStore.mu.RUnlock()
+	var overrides []*types.ModelOverrideEvent
+	for _, e := range memStore.events {
+		if e, ok := e.(*types.ModelOverrideEvent); ok {
+			overrides = append(overrides, e)
+		}
+	}
+	if len(overrides) != 1 || overrides[0].Route != (types.ModelRoute{Provider: "openai", Model: "gpt-4o"}) {
+		t.Fatalf("expected one override event, got %+v", overrides)
+	}
+}
+
+func TestPlanReview(t *testing.T) {
+	memStore := newMemoryStore()
+	runtime := &stubRuntime{store: memStore}
+	factory := func(string) (*service.SessionResources, error) {
+		ctx, cancel := context.WithCancel(context.Background())
+		return &service.SessionResources{Runtime: runtime, Store: memStore, Ctx: ctx, Cancel: cancel}, nil
+	}
+	svc := service.NewSessionService(factory, nil)
+	srv := NewServer(Config{}, svc, nil)
+
+	req, _ := http.NewRequest(http.MethodPost, "/api/v1/session", strings.NewReader(`{"mode": "planning"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	srv.Engine().ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create returned %d", w.Code)
+	}
+	var created map[string]any
+	_ = json.Unmarshal(w.Body.Bytes(), &created)
+	sessionID := created["id"].(string)
+
+	post := func(path, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(http.MethodPost, "/api/v1/session/"+sessionID+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		srv.Engine().ServeHTTP(w, req)
+		return w
+	}
+
+	if w := post("/plan/approve", ""); w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 without a pending plan, got %d", w.Code)
+	}
+	if w := post("/mode", `{"mode": "dreaming"}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown mode, got %d", w.Code)
+	}
+
+	state := types.NewState()
+	state.Mode = types.ModePlanning
+	state.Goals = append(state.Goals, types.Goal{ID: "goal_1", Status: types.GoalStatusAwaitingApproval})
+	_ = memStore.SaveState(context.Background(), state)
+
+	if w := post("/plan/reject", `{"feedback": "smaller steps"}`); w.Code != http.StatusOK {
+		t.Fatalf("reject returned %d: %s", w.Code, w.Body.String())
+	}
+
+	var rejected *types.PlanRejectedEvent
+	var transition *types.ModeTransitionEvent
+	for _, e := range memStore.events {
+		switch evt := e.(type) {
+		case *types.PlanRejectedEvent:
+			rejected = evt
+		case *types.ModeTransitionEvent:
+			transition = evt
+		}
+	}
+	if transition == nil || transition.ToMode != types.ModePlanning {
+		t.Fatalf("expected session created in planning mode, got %+v", transition)
+	}
+	if rejected == nil || rejected.GoalID != "goal_1" || rejected.Feedback != "smaller steps" {
+		t.Fatalf("expected plan_rejected event with feedback, got %+v", rejected)
+	}
+}
+
 func TestHealthEndpoint(t *testing.T) {
 	svc := service.NewSessionService(nil, nil)
 	srv := NewServer(Config{}, svc, nil)
@@ -199,7 +708,6 @@ func (s *stubRuntime) Run(ctx context.Context) error {
 		}
 	}
 	return s.lastErr
-	return s.lastErr
 }
 
 func (s *stubRuntime) GetState() *types.State {
@@ -238,9 +746,10 @@ func (s *stubRuntime) waitDone() {
 }
 
 type memoryStore struct {
-	events []types.Event
-	state  *types.State
-	mu     sync.RWMutex
+	events    []types.Event
+	state     *types.State
+	artifacts map[string]*types.Artifact
+	mu        sync.RWMutex
 }
 
 func newMemoryStore() *memoryStore {
@@ -339,8 +848,21 @@ func (m *memoryStore) LoadLatestCheckpoint(ctx context.Context) (*types.Checkpoi
 	return nil, store.ErrNoCheckpoint
 }
 
-func (m *memoryStore) SaveArtifact(ctx context.Context, artifact *types.Artifact) error { return nil }
+func (m *memoryStore) SaveArtifact(ctx context.Context, artifact *types.Artifact) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.artifacts == nil {
+		m.artifacts = make(map[string]*types.Artifact)
+	}
+	m.artifacts[artifact.ID] = artifact.Clone()
+	return nil
+}
 func (m *memoryStore) GetArtifact(ctx context.Context, id string) (*types.Artifact, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if art, ok := m.artifacts[id]; ok {
+		return art.Clone(), nil
+	}
 	return nil, store.ErrNotFound
 }
 func (m *memoryStore) ListArtifacts(ctx context.Context, filter store.ArtifactFilter) ([]types.Artifact, error) {
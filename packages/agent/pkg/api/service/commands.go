@@ -0,0 +1,13 @@
+package service
+
+import "github.com/gm-agent-org/gm-agent/pkg/commands"
+
+// SetCommands enables the slash commands expanded by Message
+func (s *SessionService) SetCommands(loader *commands.Loader) {
+	s.commands = loader
+}
+
+// Commands lists the available slash commands
+func (s *SessionService) Commands() []commands.Command {
+	return s.commands.List()
+}
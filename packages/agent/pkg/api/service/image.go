@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// ErrInvalidImage is returned for uploads and message parts that are not a supported image.
+var ErrInvalidImage = errors.New("invalid image")
+
+// MaxImageSize is the largest image accepted as an upload or inline message part.
+const MaxImageSize = 5 << 20
+
+// imageTypes are the formats all providers accept
+var imageTypes = map[string]bool{"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true}
+
+// UploadImage stores an image as a session artifact that messages can reference.
+func (s *SessionService) UploadImage(ctx context.Context, id string, name string, data []byte) (*types.Artifact, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	mimeType, err := checkImage(data)
+	if err != nil {
+		return nil, err
+	}
+
+	art := &types.Artifact{
+		ID:        types.GenerateID("art"),
+		Type:      "image",
+		Name:      filepath.Base(name),
+		Content:   data,
+		Metadata:  map[string]string{"mime_type": mimeType},
+		Size:      int64(len(data)),
+		CreatedAt: time.Now(),
+	}
+	if err := session.Resources.Store.SaveArtifact(ctx, art); err != nil {
+		return nil, fmt.Errorf("save artifact: %w", err)
+	}
+
+	// Register the artifact in the session state without its content
+	art.Content = nil
+	event := &types.ArtifactCreatedEvent{
+		BaseEvent: types.NewBaseEvent("artifact_created", "user", id),
+		Artifact:  *art,
+	}
+	if err := session.Resources.Runtime.Ingest(session.Resources.Ctx, event); err != nil {
+		return nil, err
+	}
+	return art, nil
+}
+
+// ArtifactContent returns the stored content of an artifact, e.g. an uploaded image.
+func (s *SessionService) ArtifactContent(ctx context.Context, sessionID string, artifactID string) ([]byte, error) {
+	session, err := s.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	art, err := session.Resources.Store.GetArtifact(ctx, artifactID)
+	if err != nil {
+		return nil, err
+	}
+	return art.Content, nil
+}
+
+// checkParts validates the content parts of a message. Image parts must
+// reference an image artifact of the session or carry base64 image data.
+func checkParts(state *types.State, parts []types.ContentPart) ([]types.ContentPart, error) {
+	checked := make([]types.ContentPart, 0, len(parts))
+	for i, p := range parts {
+		switch p.Type {
+		case types.ContentPartText:
+			checked = append(checked, types.ContentPart{Type: p.Type, Text: p.Text})
+		case types.ContentPartImage:
+			if (p.ArtifactID == "") == (p.Data == "") {
+				return nil, fmt.Errorf("%w: part %d: set exactly one of artifact_id and data", ErrInvalidImage, i)
+			}
+			if p.ArtifactID != "" {
+				var art *types.Artifact
+				if state != nil {
+					art = state.Artifacts[p.ArtifactID]
+				}
+				if art == nil || art.Type != "image" {
+					return nil, fmt.Errorf("%w: part %d: no image artifact %q", ErrInvalidImage, i, p.ArtifactID)
+				}
+				checked = append(checked, types.ContentPart{Type: p.Type, ArtifactID: p.ArtifactID, MimeType: art.Metadata["mime_type"]})
+				continue
+			}
+			data, err := base64.StdEncoding.DecodeString(p.Data)
+			if err != nil {
+				return nil, fmt.Errorf("%w: part %d: data is not base64", ErrInvalidImage, i)
+			}
+			mimeType, err := checkImage(data)
+			if err != nil {
+				return nil, fmt.Errorf("part %d: %w", i, err)
+			}
+			checked = append(checked, types.ContentPart{Type: p.Type, MimeType: mimeType, Data: p.Data})
+		default:
+			return nil, fmt.Errorf("%w: part %d: unknown type %q", ErrInvalidImage, i, p.Type)
+		}
+	}
+	return checked, nil
+}
+
+// checkImage returns the MIME type of a supported image
+func checkImage(data []byte) (string, error) {
+	if len(data) > MaxImageSize {
+		return "", fmt.Errorf("%w: %d bytes, the limit is %d", ErrInvalidImage, len(data), MaxImageSize)
+	}
+	mimeType := http.DetectContentType(data)
+	if !imageTypes[mimeType] {
+		return "", fmt.Errorf("%w: unsupported type %s", ErrInvalidImage, mimeType)
+	}
+	return mimeType, nil
+}
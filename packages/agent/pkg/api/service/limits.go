@@ -0,0 +1,76 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/gm-agent-org/gm-agent/pkg/api/dto"
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+)
+
+// ErrInvalidLimits is returned for negative limits.
+var ErrInvalidLimits = errors.New("invalid limits")
+
+// ErrNoShells is returned for limits of a session that cannot run shell commands.
+var ErrNoShells = errors.New("session has no shells")
+
+// Limits returns the shell limits of a session.
+func (s *SessionService) Limits(id string) (*dto.LimitsResponse, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if session.Resources.Shells == nil {
+		return nil, ErrNoShells
+	}
+	return limitsResponse(id, session.Resources.Shells.Limits()), nil
+}
+
+// SetLimits changes the shell limits of a session until it is unloaded.
+// A running persistent shell is restarted to apply them.
+func (s *SessionService) SetLimits(id string, req dto.LimitsRequest) (*dto.LimitsResponse, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	shells := session.Resources.Shells
+	if shells == nil {
+		return nil, ErrNoShells
+	}
+
+	limits := shells.Limits()
+	for _, f := range []struct {
+		name  string
+		value *int64
+		field *int64
+	}{
+		{"execution_time", req.ExecutionTime, &limits.MaxExecutionTime},
+		{"cpu_time", req.CPUTime, &limits.MaxCPUTime},
+		{"memory", req.Memory, &limits.MaxMemory},
+		{"file_size", req.FileSize, &limits.MaxFileSize},
+		{"open_files", req.OpenFiles, &limits.MaxOpenFiles},
+		{"output", req.Output, &limits.MaxOutput},
+	} {
+		if f.value == nil {
+			continue
+		}
+		if *f.value < 0 {
+			return nil, fmt.Errorf("%w: %s must not be negative", ErrInvalidLimits, f.name)
+		}
+		*f.field = *f.value
+	}
+	shells.SetLimits(limits)
+	return limitsResponse(id, limits), nil
+}
+
+func limitsResponse(id string, l security.ResourceLimits) *dto.LimitsResponse {
+	return &dto.LimitsResponse{
+		SessionID:     id,
+		ExecutionTime: l.MaxExecutionTime,
+		CPUTime:       l.MaxCPUTime,
+		Memory:        l.MaxMemory,
+		FileSize:      l.MaxFileSize,
+		OpenFiles:     l.MaxOpenFiles,
+		Output:        l.MaxOutput,
+	}
+}
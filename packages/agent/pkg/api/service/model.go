@@ -0,0 +1,42 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// ErrInvalidModel is returned for a model override naming an unknown provider.
+var ErrInvalidModel = errors.New("invalid model")
+
+// SetProviders lists the providers sessions may switch to; with none, any is accepted.
+func (s *SessionService) SetProviders(ids []string) {
+	s.providers = make(map[string]bool, len(ids))
+	for _, id := range ids {
+		s.providers[id] = true
+	}
+}
+
+// SetModel overrides the provider and model of a session's LLM calls.
+// An empty route restores the configured routes.
+func (s *SessionService) SetModel(ctx context.Context, id string, route types.ModelRoute) (*Session, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if route.Provider != "" && len(s.providers) > 0 && !s.providers[route.Provider] {
+		return nil, fmt.Errorf("%w: provider %q is not configured", ErrInvalidModel, route.Provider)
+	}
+
+	event := &types.ModelOverrideEvent{
+		BaseEvent: types.NewBaseEvent("model_override", "user", id),
+		Route:     route,
+	}
+	if err := session.Resources.Runtime.Ingest(session.Resources.Ctx, event); err != nil {
+		s.log.Error("failed to override model", "error", err)
+		return nil, err
+	}
+	return session, nil
+}
@@ -3,13 +3,18 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 	"sync"
 	"time"
 
 	"github.com/gm-agent-org/gm-agent/pkg/api/dto"
+	"github.com/gm-agent-org/gm-agent/pkg/commands"
+	"github.com/gm-agent-org/gm-agent/pkg/hook"
 	"github.com/gm-agent-org/gm-agent/pkg/patch"
+	"github.com/gm-agent-org/gm-agent/pkg/runtime"
 	"github.com/gm-agent-org/gm-agent/pkg/runtime/permission"
+	"github.com/gm-agent-org/gm-agent/pkg/shell"
 	"github.com/gm-agent-org/gm-agent/pkg/store"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
@@ -17,6 +22,18 @@ import (
 // ErrSessionNotFound is returned when a session is not found.
 var ErrSessionNotFound = errors.New("session not found")
 
+// ErrNoPendingPlan is returned when approving or rejecting without a plan awaiting review.
+var ErrNoPendingPlan = errors.New("no plan awaiting approval")
+
+// ErrInvalidMode is returned for an unknown runtime mode.
+var ErrInvalidMode = errors.New("invalid mode")
+
+// ErrPromptBlocked is returned when a UserPromptSubmit hook rejects a message.
+var ErrPromptBlocked = errors.New("prompt blocked by hook")
+
+// preemptPriority is the goal priority of preempting messages sent without one.
+const preemptPriority = -100
+
 // RuntimeRunner is the minimal runtime contract the service relies on.
 type RuntimeRunner interface {
 	Ingest(ctx context.Context, event types.Event) error
@@ -24,12 +41,19 @@ type RuntimeRunner interface {
 	GetState() *types.State
 }
 
+// Compactor is implemented by runtimes that support manual context compaction.
+type Compactor interface {
+	Compact(ctx context.Context) (*types.ContextCompactedEvent, error)
+}
+
 // SessionResources contains runtime dependencies for a session.
 type SessionResources struct {
 	Runtime     RuntimeRunner
 	Permissions *permission.Manager
 	Store       store.Store
-	PatchEngine patch.Engine // For Code Rewind support
+	PatchEngine patch.Engine   // For Code Rewind support
+	Hooks       *hook.Runner   // Optional lifecycle hooks (SessionStart, UserPromptSubmit, Stop)
+	Shells      *shell.Manager // Optional background shells, killed when the session is cancelled or deleted
 	Ctx         context.Context
 	Cancel      context.CancelFunc
 }
@@ -50,13 +74,18 @@ type Session struct {
 
 // SessionService manages sessions.
 type SessionService struct {
-	factory  SessionFactory
-	sessions sync.Map // map[string]*Session
-	log      *slog.Logger
+	factory   SessionFactory
+	sessions  sync.Map         // map[string]*Session
+	commands  *commands.Loader // Optional slash commands expanded by Message
+	providers map[string]bool  // Providers accepted by SetModel; empty accepts any
+	log       *slog.Logger
 }
 
 // NewSessionService creates a new SessionService.
 func NewSessionService(factory SessionFactory, log *slog.Logger) *SessionService {
+	if log == nil {
+		log = slog.Default()
+	}
 	return &SessionService{
 		factory: factory,
 		log:     log,
@@ -65,7 +94,13 @@ func NewSessionService(factory SessionFactory, log *slog.Logger) *SessionService
 
 // Create creates a new session with the given prompt.
 // If prompt is empty, the session is created but no LLM call is made until a message is sent.
-func (s *SessionService) Create(ctx context.Context, prompt string, systemPrompt string, priority int) (*Session, error) {
+// If mode is "planning", the agent proposes a plan for approval before executing anything.
+// Priority and deadline apply to the goal created for the prompt.
+func (s *SessionService) Create(ctx context.Context, prompt string, systemPrompt string, priority int, deadline *time.Time, maxSteps int, mode string) (*Session, error) {
+	if mode != "" && !validMode(types.RuntimeMode(mode)) {
+		return nil, ErrInvalidMode
+	}
+
 	id := types.GenerateID("ses")
 	resources, err := s.factory(id)
 	if err != nil {
@@ -73,6 +108,14 @@ func (s *SessionService) Create(ctx context.Context, prompt string, systemPrompt
 		return nil, err
 	}
 
+	resources.Hooks.Run(resources.Ctx, hook.Input{Event: hook.SessionStart})
+	if prompt != "" {
+		if err := checkPrompt(resources, prompt); err != nil {
+			resources.Cancel()
+			return nil, err
+		}
+	}
+
 	session := &Session{
 		ID:        id,
 		Status:    "idle", // idle until first message
@@ -94,6 +137,13 @@ func (s *SessionService) Create(ctx context.Context, prompt string, systemPrompt
 		}
 	}
 
+	if types.RuntimeMode(mode) == types.ModePlanning {
+		if err := resources.Runtime.Ingest(resources.Ctx, modeTransition(id, "", types.ModePlanning)); err != nil {
+			s.log.Error("failed to enter planning mode", "error", err)
+			return nil, err
+		}
+	}
+
 	// Only ingest prompt and start runtime if prompt is not empty
 	if prompt != "" {
 		session.Status = "running"
@@ -103,6 +153,8 @@ func (s *SessionService) Create(ctx context.Context, prompt string, systemPrompt
 			BaseEvent: types.NewBaseEvent("user_request", "user", id),
 			Content:   prompt,
 			Priority:  priority,
+			Deadline:  deadline,
+			MaxSteps:  maxSteps,
 		}
 		if err := resources.Runtime.Ingest(resources.Ctx, event); err != nil {
 			s.log.Error("failed to ingest prompt", "error", err)
@@ -144,6 +196,7 @@ func (s *SessionService) Delete(id string) error {
 
 	session := val.(*Session)
 	session.Resources.Cancel()
+	s.killShells(session)
 	s.sessions.Delete(id)
 	return nil
 }
@@ -157,6 +210,7 @@ func (s *SessionService) Cancel(id string) error {
 
 	session := val.(*Session)
 	session.Resources.Cancel()
+	s.killShells(session)
 
 	session.mu.Lock()
 	session.Status = "cancelled"
@@ -165,17 +219,50 @@ func (s *SessionService) Cancel(id string) error {
 	return nil
 }
 
+// killShells stops the background shells of a session
+func (s *SessionService) killShells(session *Session) {
+	if err := session.Resources.Shells.Close(); err != nil {
+		s.log.Warn("failed to kill background shells", "session_id", session.ID, "error", err)
+	}
+}
+
 // Message sends a user message to a session.
-func (s *SessionService) Message(ctx context.Context, id string, content string, semantic string) (*Session, error) {
+// Priority and deadline apply when the message starts a new goal (fork, preempt or no active goal).
+// Parts optionally carry text and images in order; content is then their text if empty.
+func (s *SessionService) Message(ctx context.Context, id string, content string, parts []types.ContentPart, semantic string, priority int, deadline *time.Time, maxSteps int) (*Session, error) {
 	val, ok := s.sessions.Load(id)
 	if !ok {
 		return nil, ErrSessionNotFound
 	}
 	session := val.(*Session)
 
-	priority := 0
-	if types.Semantic(semantic) == types.SemanticPreempt {
-		priority = 100
+	// "/name args" invokes a slash command, whose prompt replaces the message
+	var expansion *commands.Expansion
+	if len(parts) == 0 {
+		if exp, ok := s.commands.Expand(content); ok {
+			s.log.Info("expanded slash command", "session_id", id, "command", exp.Command.Name)
+			expansion = exp
+			content = exp.Prompt
+		}
+	}
+
+	if len(parts) > 0 {
+		var err error
+		if parts, err = checkParts(session.Resources.Runtime.GetState(), parts); err != nil {
+			return nil, err
+		}
+		if content == "" {
+			content = types.PartsText(parts)
+		}
+	}
+
+	if err := checkPrompt(session.Resources, content); err != nil {
+		return nil, err
+	}
+
+	// Preempting messages jump ahead of normal work unless a priority is given
+	if priority == 0 && types.Semantic(semantic) == types.SemanticPreempt {
+		priority = preemptPriority
 	}
 
 	event := &types.UserMessageEvent{
@@ -183,6 +270,14 @@ func (s *SessionService) Message(ctx context.Context, id string, content string,
 		Content:   content,
 		Priority:  priority,
 		Semantic:  types.Semantic(semantic),
+		Deadline:  deadline,
+		MaxSteps:  maxSteps,
+		Parts:     parts,
+	}
+	if expansion != nil {
+		event.Command = expansion.Command.Name
+		event.AllowedTools = expansion.Command.AllowedTools
+		event.Model = expansion.Command.Model
 	}
 
 	// We use the session's context for ingestion to ensure it respects session lifecycle
@@ -191,19 +286,114 @@ func (s *SessionService) Message(ctx context.Context, id string, content string,
 		return nil, err
 	}
 
-	// If session was idle or completed, start/restart the runtime
+	s.resume(session)
+	return session, nil
+}
+
+// checkPrompt runs the UserPromptSubmit hooks, which may reject the prompt
+func checkPrompt(resources *SessionResources, prompt string) error {
+	res := resources.Hooks.Run(resources.Ctx, hook.Input{Event: hook.UserPromptSubmit, Prompt: prompt})
+	if res.Blocked {
+		return fmt.Errorf("%w: %s", ErrPromptBlocked, res.Reason)
+	}
+	return nil
+}
+
+// resume starts or restarts the runtime if the session is not already running.
+func (s *SessionService) resume(session *Session) {
 	session.mu.Lock()
-	if session.Status == "idle" || session.Status == "completed" {
+	if session.Status == "idle" || session.Status == "completed" || session.Status == "awaiting_approval" || session.Status == "awaiting_input" {
 		session.Status = "running"
 		session.mu.Unlock()
 		go s.runSession(session)
 	} else {
 		session.mu.Unlock()
 	}
+}
 
+// SetMode switches a session between planning and executing mode.
+func (s *SessionService) SetMode(ctx context.Context, id string, mode string) (*Session, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	to := types.RuntimeMode(mode)
+	if !validMode(to) {
+		return nil, ErrInvalidMode
+	}
+
+	from := session.Resources.Runtime.GetState().Mode
+	if from == to || (from == "" && to == types.ModeExecuting) {
+		return session, nil
+	}
+	if err := session.Resources.Runtime.Ingest(session.Resources.Ctx, modeTransition(id, from, to)); err != nil {
+		s.log.Error("failed to change mode", "error", err)
+		return nil, err
+	}
 	return session, nil
 }
 
+// ApprovePlan approves the pending plan; the runtime switches to executing mode and carries it out.
+func (s *SessionService) ApprovePlan(ctx context.Context, id string) (*Session, error) {
+	return s.reviewPlan(id, func(goalID string) types.Event {
+		return &types.PlanApprovedEvent{
+			BaseEvent: types.NewBaseEvent("plan_approved", "user", id),
+			GoalID:    goalID,
+		}
+	})
+}
+
+// RejectPlan rejects the pending plan; the runtime replans using the feedback.
+func (s *SessionService) RejectPlan(ctx context.Context, id string, feedback string) (*Session, error) {
+	return s.reviewPlan(id, func(goalID string) types.Event {
+		return &types.PlanRejectedEvent{
+			BaseEvent: types.NewBaseEvent("plan_rejected", "user", id),
+			GoalID:    goalID,
+			Feedback:  feedback,
+		}
+	})
+}
+
+// reviewPlan ingests the review decision for the goal awaiting approval and resumes the runtime.
+func (s *SessionService) reviewPlan(id string, decision func(goalID string) types.Event) (*Session, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	goalID := ""
+	for _, g := range session.Resources.Runtime.GetState().Goals {
+		if g.Status == types.GoalStatusAwaitingApproval {
+			goalID = g.ID
+			break
+		}
+	}
+	if goalID == "" {
+		return nil, ErrNoPendingPlan
+	}
+
+	if err := session.Resources.Runtime.Ingest(session.Resources.Ctx, decision(goalID)); err != nil {
+		s.log.Error("failed to ingest plan review", "error", err)
+		return nil, err
+	}
+
+	s.resume(session)
+	return session, nil
+}
+
+func validMode(mode types.RuntimeMode) bool {
+	return mode == types.ModePlanning || mode == types.ModeExecuting
+}
+
+func modeTransition(sessionID string, from, to types.RuntimeMode) *types.ModeTransitionEvent {
+	return &types.ModeTransitionEvent{
+		BaseEvent: types.NewBaseEvent("mode_transition", "user", sessionID),
+		FromMode:  from,
+		ToMode:    to,
+		Reason:    "requested by user",
+	}
+}
+
 // GetStatus returns the status of a session (thread-safe).
 func (sess *Session) GetStatus() (status string, lastError string) {
 	sess.mu.Lock()
@@ -216,20 +406,31 @@ func (s *SessionService) runSession(sess *Session) {
 	defer sess.Resources.Store.Close()
 
 	err := sess.Resources.Runtime.Run(sess.Resources.Ctx)
+	status := s.finishRun(sess, err)
+
+	// The session context may already be cancelled; Stop hooks still run
+	sess.Resources.Hooks.Run(context.Background(), hook.Input{Event: hook.Stop, Reason: status})
+}
 
+// finishRun records the outcome of a runtime run and returns the new status.
+func (s *SessionService) finishRun(sess *Session, err error) string {
 	sess.mu.Lock()
 	defer sess.mu.Unlock()
 
-	if err != nil {
-		if errors.Is(err, context.Canceled) {
-			sess.Status = "cancelled"
-			return
-		}
+	switch {
+	case err != nil && errors.Is(err, context.Canceled):
+		sess.Status = "cancelled"
+	case err != nil:
 		sess.Status = "error"
 		sess.LastError = err.Error()
-		return
-	}
-	sess.Status = "completed"
+	case runtime.HasPendingPlan(sess.Resources.Runtime.GetState()):
+		sess.Status = "awaiting_approval"
+	case runtime.AwaitingInput(sess.Resources.Runtime.GetState()):
+		sess.Status = "awaiting_input"
+	default:
+		sess.Status = "completed"
+	}
+	return sess.Status
 }
 
 // ListArtifacts returns all artifacts for a session.
@@ -287,6 +488,104 @@ func (s *SessionService) RespondPermission(id string, requestID string, approved
 	return session.Resources.Permissions.Respond(requestID, approved, always)
 }
 
+// Usage returns aggregated token usage and cost for a session
+func (s *SessionService) Usage(id string) (*dto.UsageResponse, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.UsageResponse{
+		SessionID: id,
+		ByGoal:    make(map[string]dto.UsageTotals),
+		ByModel:   make(map[string]dto.UsageTotals),
+	}
+
+	state := session.Resources.Runtime.GetState()
+	if state == nil || state.Usage == nil {
+		return resp, nil
+	}
+
+	resp.Total = toUsageTotals(state.Usage.Session)
+	for goalID, t := range state.Usage.ByGoal {
+		resp.ByGoal[goalID] = toUsageTotals(*t)
+	}
+	for model, t := range state.Usage.ByModel {
+		resp.ByModel[model] = toUsageTotals(*t)
+	}
+	return resp, nil
+}
+
+// Tasks returns the task list the agent maintains for a session
+func (s *SessionService) Tasks(id string) (*dto.TaskListResponse, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &dto.TaskListResponse{SessionID: id, Tasks: []dto.TaskResponse{}}
+	state := session.Resources.Runtime.GetState()
+	if state == nil {
+		return resp, nil
+	}
+	for _, t := range runtime.TaskList(state) {
+		resp.Tasks = append(resp.Tasks, dto.TaskResponse{
+			ID:          t.ID,
+			GoalID:      t.GoalID,
+			Description: t.Description,
+			Status:      string(t.Status),
+			CreatedAt:   t.CreatedAt,
+			StartedAt:   t.StartedAt,
+			EndedAt:     t.EndedAt,
+		})
+	}
+	return resp, nil
+}
+
+func toUsageTotals(t types.UsageTotals) dto.UsageTotals {
+	return dto.UsageTotals{
+		Calls:            t.Calls,
+		PromptTokens:     t.PromptTokens,
+		CompletionTokens: t.CompletionTokens,
+		TotalTokens:      t.TotalTokens,
+		CostUSD:          t.CostUSD,
+	}
+}
+
+// Compact summarizes older messages of a session's context window
+func (s *SessionService) Compact(ctx context.Context, id string) (*dto.CompactResponse, error) {
+	session, err := s.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	compactor, ok := session.Resources.Runtime.(Compactor)
+	if !ok {
+		return nil, errors.New("compaction not supported by runtime")
+	}
+
+	evt, err := compactor.Compact(ctx)
+	if errors.Is(err, runtime.ErrNothingToCompact) {
+		return &dto.CompactResponse{Compacted: false, Message: err.Error()}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	s.log.Info("context compacted",
+		"session_id", id,
+		"messages_compacted", evt.MessagesCompacted,
+		"tokens_before", evt.TokensBefore,
+		"tokens_after", evt.TokensAfter)
+
+	return &dto.CompactResponse{
+		Compacted:         true,
+		MessagesCompacted: evt.MessagesCompacted,
+		TokensBefore:      evt.TokensBefore,
+		TokensAfter:       evt.TokensAfter,
+	}, nil
+}
+
 // ListCheckpoints returns all checkpoints for a session
 func (s *SessionService) ListCheckpoints(ctx context.Context, id string) (*dto.CheckpointListResponse, error) {
 	session, err := s.Get(id)
@@ -404,7 +703,7 @@ func (s *SessionService) Rewind(ctx context.Context, id string, req dto.RewindRe
 
 	if req.RewindConversation {
 		// Restore the checkpoint state
-		if err := session.Resources.Store.SaveState(ctx, checkpoint.State); err != nil {
+		if err := restoreCheckpoint(ctx, session.Resources.Store, checkpoint); err != nil {
 			return &dto.RewindResponse{
 				Success: false,
 				Message: "Failed to restore state: " + err.Error(),
@@ -443,3 +742,32 @@ func (s *SessionService) Rewind(ctx context.Context, id string, req dto.RewindRe
 		},
 	}, nil
 }
+
+// restoreCheckpoint saves a checkpoint's state as the latest state and checkpoint.
+// Both are positioned after the last logged event, so recovery does not replay
+// the rewound events on top of the restored state.
+func restoreCheckpoint(ctx context.Context, st store.Store, restored *types.Checkpoint) error {
+	if restored.State == nil {
+		return errors.New("checkpoint has no state")
+	}
+	var lastEventID string
+	if err := st.IterEvents(ctx, func(e types.Event) error {
+		lastEventID = e.EventID()
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	state := restored.State.Clone()
+	state.LastEventID = lastEventID
+	if err := st.SaveState(ctx, state); err != nil {
+		return err
+	}
+	return st.SaveCheckpoint(ctx, &types.Checkpoint{
+		ID:           types.GenerateID("ckpt"),
+		StateVersion: state.Version,
+		LastEventID:  lastEventID,
+		Timestamp:    time.Now(),
+		State:        state,
+	})
+}
@@ -0,0 +1,320 @@
+// Package commands loads user-defined slash commands: Markdown prompt
+// templates kept in .gm/commands/ in the workspace and in ~/.gm/commands/.
+//
+// The file name (without .md) is the command name. An optional YAML
+// frontmatter block sets the description, argument hint, allowed tools and
+// model:
+//
+//	---
+//	description: Review the staged changes
+//	argument-hint: [focus]
+//	allowed-tools: read_file, grep, run_shell
+//	model: gpt-4o
+//	---
+//	Review the staged changes. Focus on $ARGUMENTS.
+//
+// "/review security" expands to the body with $ARGUMENTS replaced by
+// "security". Project commands override user commands of the same name.
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// argumentsPlaceholder is replaced by the text following the command name
+const argumentsPlaceholder = "$ARGUMENTS"
+
+// Scope tells where a command was defined
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"    // ~/.gm/commands
+	ScopeProject Scope = "project" // .gm/commands in the workspace
+)
+
+// Command is a prompt template invoked as /name
+type Command struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description,omitempty"`
+	ArgumentHint string   `json:"argument_hint,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty"` // Empty allows every tool
+	Model        string   `json:"model,omitempty"`
+	Scope        Scope    `json:"scope"`
+	Path         string   `json:"path"`
+	Body         string   `json:"-"`
+}
+
+// Expansion is a message rewritten by a command
+type Expansion struct {
+	Command   Command
+	Arguments string
+	Prompt    string
+}
+
+// frontmatter is the YAML header of a command file
+type frontmatter struct {
+	Description  string   `yaml:"description"`
+	ArgumentHint hint     `yaml:"argument-hint"`
+	AllowedTools toolList `yaml:"allowed-tools"`
+	Model        string   `yaml:"model"`
+}
+
+// hint accepts a string or, for an unquoted "[arg]", a YAML list, which it
+// renders back in brackets
+type hint string
+
+func (h *hint) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.SequenceNode {
+		var s string
+		if err := node.Decode(&s); err != nil {
+			return err
+		}
+		*h = hint(s)
+		return nil
+	}
+	var args []string
+	if err := node.Decode(&args); err != nil {
+		return err
+	}
+	*h = hint("[" + strings.Join(args, "] [") + "]")
+	return nil
+}
+
+// toolList accepts a YAML list or a comma-separated string
+type toolList []string
+
+func (t *toolList) UnmarshalYAML(node *yaml.Node) error {
+	var names []string
+	if node.Kind == yaml.SequenceNode {
+		if err := node.Decode(&names); err != nil {
+			return err
+		}
+	} else {
+		var s string
+		if err := node.Decode(&s); err != nil {
+			return err
+		}
+		names = strings.Split(s, ",")
+	}
+	*t = nil
+	for _, name := range names {
+		if name = strings.TrimSpace(name); name != "" {
+			*t = append(*t, name)
+		}
+	}
+	return nil
+}
+
+// Loader reads commands from their directories. Files are read on every call,
+// so edits apply without a restart. A nil Loader has no commands.
+type Loader struct {
+	projectDir string
+	userDir    string
+	log        *slog.Logger
+}
+
+// New returns a loader for the project and user command directories; either
+// may be "" to skip it. "~/" is expanded to the home directory.
+func New(projectDir, userDir string, logger *slog.Logger) (*Loader, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	l := &Loader{log: logger}
+	for _, d := range []struct {
+		in  string
+		out *string
+	}{{projectDir, &l.projectDir}, {userDir, &l.userDir}} {
+		if d.in == "" {
+			continue
+		}
+		dir, err := expandHome(d.in)
+		if err != nil {
+			return nil, err
+		}
+		if *d.out, err = filepath.Abs(dir); err != nil {
+			return nil, fmt.Errorf("resolve commands dir: %w", err)
+		}
+	}
+	return l, nil
+}
+
+// List returns the available commands sorted by name
+func (l *Loader) List() []Command {
+	if l == nil {
+		return nil
+	}
+	byName := make(map[string]Command)
+	for _, src := range []struct {
+		dir   string
+		scope Scope
+	}{{l.userDir, ScopeUser}, {l.projectDir, ScopeProject}} {
+		for _, cmd := range l.readDir(src.dir, src.scope) {
+			byName[cmd.Name] = cmd
+		}
+	}
+
+	cmds := make([]Command, 0, len(byName))
+	for _, cmd := range byName {
+		cmds = append(cmds, cmd)
+	}
+	sort.Slice(cmds, func(i, j int) bool { return cmds[i].Name < cmds[j].Name })
+	return cmds
+}
+
+// Get returns the command with the given name
+func (l *Loader) Get(name string) (Command, bool) {
+	for _, cmd := range l.List() {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// Expand rewrites a "/name args" message into the command's prompt. It
+// returns false when the message does not invoke a known command.
+func (l *Loader) Expand(message string) (*Expansion, bool) {
+	name, args, ok := parseInvocation(message)
+	if !ok {
+		return nil, false
+	}
+	cmd, ok := l.Get(name)
+	if !ok {
+		return nil, false
+	}
+	return &Expansion{Command: cmd, Arguments: args, Prompt: render(cmd.Body, args)}, true
+}
+
+func (l *Loader) readDir(dir string, scope Scope) []Command {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.log.Warn("failed to read commands dir", "dir", dir, "error", err)
+		}
+		return nil
+	}
+
+	var cmds []Command
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		cmd, err := parseFile(path)
+		if err != nil {
+			l.log.Warn("skipping invalid command file", "path", path, "error", err)
+			continue
+		}
+		cmd.Scope = scope
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// parseFile reads a command file and its frontmatter
+func parseFile(path string) (Command, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Command{}, err
+	}
+	cmd := Command{
+		Name: strings.TrimSuffix(filepath.Base(path), ".md"),
+		Path: path,
+	}
+	if strings.ContainsAny(cmd.Name, " \t/") {
+		return Command{}, fmt.Errorf("invalid command name %q", cmd.Name)
+	}
+
+	body := data
+	if header, rest, ok := splitFrontmatter(data); ok {
+		var fm frontmatter
+		if err := yaml.Unmarshal(header, &fm); err != nil {
+			return Command{}, fmt.Errorf("parse frontmatter: %w", err)
+		}
+		cmd.Description = fm.Description
+		cmd.ArgumentHint = string(fm.ArgumentHint)
+		cmd.AllowedTools = fm.AllowedTools
+		cmd.Model = fm.Model
+		body = rest
+	}
+	cmd.Body = strings.TrimSpace(string(body))
+
+	// Without a description, the first line of the prompt describes the command
+	if cmd.Description == "" {
+		first, _, _ := strings.Cut(cmd.Body, "\n")
+		cmd.Description = strings.TrimSpace(strings.TrimLeft(first, "# "))
+	}
+	return cmd, nil
+}
+
+// splitFrontmatter separates a leading "---" delimited block from the body
+func splitFrontmatter(data []byte) (header, body []byte, ok bool) {
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	if !bytes.HasPrefix(data, []byte("---\n")) {
+		return nil, data, false
+	}
+	rest := data[len("---\n"):]
+	if bytes.HasPrefix(rest, []byte("---\n")) {
+		return nil, rest[len("---\n"):], true
+	}
+	end := bytes.Index(rest, []byte("\n---\n"))
+	if end < 0 {
+		if !bytes.HasSuffix(rest, []byte("\n---")) {
+			return nil, data, false
+		}
+		end = len(rest) - len("\n---")
+		return rest[:end], nil, true
+	}
+	return rest[:end], rest[end+len("\n---\n"):], true
+}
+
+// parseInvocation splits "/name args" into the name and the arguments
+func parseInvocation(message string) (name, args string, ok bool) {
+	message = strings.TrimSpace(message)
+	if !strings.HasPrefix(message, "/") {
+		return "", "", false
+	}
+	name = message[1:]
+	if i := strings.IndexFunc(name, unicode.IsSpace); i >= 0 {
+		name, args = name[:i], name[i:]
+	}
+	if name == "" {
+		return "", "", false
+	}
+	return name, strings.TrimSpace(args), true
+}
+
+// render substitutes the arguments into the prompt. Arguments given to a
+// template without the placeholder are appended so they are not lost.
+func render(body, args string) string {
+	if strings.Contains(body, argumentsPlaceholder) {
+		return strings.ReplaceAll(body, argumentsPlaceholder, args)
+	}
+	if args == "" {
+		return body
+	}
+	return body + "\n\nARGUMENTS: " + args
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
@@ -0,0 +1,128 @@
+package commands
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeCommand(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+}
+
+func TestListParsesFrontmatterAndOverrides(t *testing.T) {
+	project := filepath.Join(t.TempDir(), ".gm", "commands")
+	user := t.TempDir()
+
+	writeCommand(t, project, "review.md", `---
+description: Review the staged changes
+argument-hint: [focus]
+allowed-tools: read_file, grep
+model: gpt-4o
+---
+Review the staged changes. Focus on $ARGUMENTS.
+`)
+	writeCommand(t, user, "review.md", "User review prompt")
+	writeCommand(t, user, "explain.md", "# Explain the code\n\nExplain $ARGUMENTS in detail.")
+	writeCommand(t, user, "notes.txt", "not a command")
+
+	loader, err := New(project, user, nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+	cmds := loader.List()
+	if len(cmds) != 2 || cmds[0].Name != "explain" || cmds[1].Name != "review" {
+		t.Fatalf("expected explain and review, got %+v", cmds)
+	}
+
+	explain := cmds[0]
+	if explain.Scope != ScopeUser || explain.Description != "Explain the code" {
+		t.Fatalf("expected the first line as description, got %+v", explain)
+	}
+
+	review := cmds[1]
+	if review.Scope != ScopeProject {
+		t.Fatalf("expected the project command to override the user one, got %+v", review)
+	}
+	want := Command{
+		Name:         "review",
+		Description:  "Review the staged changes",
+		ArgumentHint: "[focus]",
+		AllowedTools: []string{"read_file", "grep"},
+		Model:        "gpt-4o",
+		Scope:        ScopeProject,
+		Path:         filepath.Join(project, "review.md"),
+		Body:         "Review the staged changes. Focus on $ARGUMENTS.",
+	}
+	if !reflect.DeepEqual(review, want) {
+		t.Fatalf("expected %+v, got %+v", want, review)
+	}
+}
+
+func TestExpand(t *testing.T) {
+	dir := t.TempDir()
+	writeCommand(t, dir, "fix.md", "Fix issue $ARGUMENTS and add a test for $ARGUMENTS.")
+	writeCommand(t, dir, "lint.md", "---\nallowed-tools:\n  - run_shell\n---\nRun the linter.")
+	loader, err := New(dir, "", nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+
+	tests := []struct {
+		message string
+		ok      bool
+		prompt  string
+	}{
+		{"/fix #42", true, "Fix issue #42 and add a test for #42."},
+		{"  /fix\tthe login bug  ", true, "Fix issue the login bug and add a test for the login bug."},
+		{"/lint", true, "Run the linter."},
+		{"/lint only pkg/api", true, "Run the linter.\n\nARGUMENTS: only pkg/api"},
+		{"/unknown args", false, ""},
+		{"fix #42", false, ""},
+		{"/", false, ""},
+	}
+	for _, tt := range tests {
+		exp, ok := loader.Expand(tt.message)
+		if ok != tt.ok {
+			t.Fatalf("%q: expected ok=%v, got %v", tt.message, tt.ok, ok)
+		}
+		if ok && exp.Prompt != tt.prompt {
+			t.Fatalf("%q: expected prompt %q, got %q", tt.message, tt.prompt, exp.Prompt)
+		}
+	}
+
+	exp, _ := loader.Expand("/lint")
+	if !reflect.DeepEqual(exp.Command.AllowedTools, []string{"run_shell"}) {
+		t.Fatalf("expected allowed tools from a YAML list, got %v", exp.Command.AllowedTools)
+	}
+}
+
+func TestInvalidFrontmatterIsSkipped(t *testing.T) {
+	dir := t.TempDir()
+	writeCommand(t, dir, "broken.md", "---\ndescription: [unclosed\n---\nBody")
+	writeCommand(t, dir, "ok.md", "Fine")
+	loader, err := New(dir, "", nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+	if cmds := loader.List(); len(cmds) != 1 || cmds[0].Name != "ok" {
+		t.Fatalf("expected only the valid command, got %+v", cmds)
+	}
+}
+
+func TestNilLoader(t *testing.T) {
+	var loader *Loader
+	if cmds := loader.List(); cmds != nil {
+		t.Fatalf("expected no commands, got %v", cmds)
+	}
+	if _, ok := loader.Expand("/review"); ok {
+		t.Fatal("expected a nil loader not to expand")
+	}
+}
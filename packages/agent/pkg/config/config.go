@@ -23,6 +23,8 @@ type ProviderOptions struct {
 	Timeout     int     `yaml:"timeout" json:"timeout" envconfig:"TIMEOUT"`          // Request timeout in ms
 	Temperature float64 `yaml:"temperature" json:"temperature" envconfig:"TEMP"`     // Sampling temperature
 	MaxTokens   int     `yaml:"max_tokens" json:"max_tokens" envconfig:"MAX_TOKENS"` // Max tokens to generate
+	Cassette    string  `yaml:"cassette" json:"cassette" envconfig:"CASSETTE"`       // Recording served by the replay provider
+	Scenario    string  `yaml:"scenario" json:"scenario" envconfig:"SCENARIO"`       // Script of turns served by the mock provider
 }
 
 // SecurityConfig contains security-related settings.
@@ -41,6 +43,83 @@ type HTTPConfig struct {
 	APIKey string `yaml:"api_key" envconfig:"API_KEY"`
 }
 
+// RetryConfig controls LLM request retries. Durations are in milliseconds.
+type RetryConfig struct {
+	MaxAttempts    int `yaml:"max_attempts" envconfig:"MAX_ATTEMPTS"`       // Attempts per provider, including the first
+	InitialBackoff int `yaml:"initial_backoff" envconfig:"INITIAL_BACKOFF"` // Delay before the first retry
+	MaxBackoff     int `yaml:"max_backoff" envconfig:"MAX_BACKOFF"`         // Upper bound for backoff delays
+}
+
+// BudgetConfig limits how much a single session may consume.
+// Zero values disable the corresponding limit.
+type BudgetConfig struct {
+	MaxTokens int     `yaml:"max_tokens" envconfig:"MAX_TOKENS"` // Total tokens per session
+	MaxCost   float64 `yaml:"max_cost" envconfig:"MAX_COST"`     // USD per session
+}
+
+// ErrorPolicyConfig controls how the runtime reacts to failed commands.
+type ErrorPolicyConfig struct {
+	Surface        bool `yaml:"surface" envconfig:"SURFACE"`                 // Show recoverable errors to the LLM
+	MaxConsecutive int  `yaml:"max_consecutive" envconfig:"MAX_CONSECUTIVE"` // Fail a goal after this many errors in a row (0 disables)
+	RecoveryGoals  bool `yaml:"recovery_goals" envconfig:"RECOVERY_GOALS"`   // Create a recovery goal for goals that fail on errors
+}
+
+// LoopConfig controls the detection of goals that repeat themselves without
+// progress. A threshold of 0 disables its check.
+type LoopConfig struct {
+	RepeatedCalls  int    `yaml:"repeated_calls" envconfig:"REPEATED_CALLS"`   // Steps in a row making identical tool calls
+	RepeatedErrors int    `yaml:"repeated_errors" envconfig:"REPEATED_ERRORS"` // Identical errors in a row
+	IdleSteps      int    `yaml:"idle_steps" envconfig:"IDLE_STEPS"`           // Steps without a new tool result
+	Action         string `yaml:"action" envconfig:"ACTION"`                   // warn, escalate or stop
+	MaxWarnings    int    `yaml:"max_warnings" envconfig:"MAX_WARNINGS"`       // Stop a goal warned this many times (0 = never)
+}
+
+// WebConfig controls the web tools. Zero values use the tool defaults.
+type WebConfig struct {
+	FetchTimeout        int      `yaml:"fetch_timeout" envconfig:"FETCH_TIMEOUT"`                 // Seconds per fetch, including redirects
+	FetchMaxBytes       int64    `yaml:"fetch_max_bytes" envconfig:"FETCH_MAX_BYTES"`             // Larger responses are truncated
+	FetchAllowedDomains []string `yaml:"fetch_allowed_domains" envconfig:"FETCH_ALLOWED_DOMAINS"` // Domains a redirect may lead to besides the original host
+
+	// web_search is enabled when SearchURL is set
+	SearchBackend      string            `yaml:"search_backend" envconfig:"SEARCH_BACKEND"`             // searxng (default) or json
+	SearchURL          string            `yaml:"search_url" envconfig:"SEARCH_URL"`                     // SearXNG base URL, or the JSON API endpoint
+	SearchHeaders      map[string]string `yaml:"search_headers" envconfig:"SEARCH_HEADERS"`             // Extra request headers, e.g. an API key
+	SearchResultsPath  string            `yaml:"search_results_path" envconfig:"SEARCH_RESULTS_PATH"`   // json backend: path of the result list
+	SearchTitleField   string            `yaml:"search_title_field" envconfig:"SEARCH_TITLE_FIELD"`     // json backend: title of a result
+	SearchURLField     string            `yaml:"search_url_field" envconfig:"SEARCH_URL_FIELD"`         // json backend: URL of a result
+	SearchSnippetField string            `yaml:"search_snippet_field" envconfig:"SEARCH_SNIPPET_FIELD"` // json backend: snippet of a result
+}
+
+// InstructionsConfig controls the instruction files (GM.md, AGENTS.md) added
+// to the system prompt
+type InstructionsConfig struct {
+	Enable  bool     `yaml:"enable" envconfig:"ENABLE"`
+	Files   []string `yaml:"files" envconfig:"FILES"`       // File names looked up in each directory (default: GM.md,AGENTS.md)
+	UserDir string   `yaml:"user_dir" envconfig:"USER_DIR"` // Per-user instructions directory
+}
+
+// CommandsConfig controls the slash commands loaded from Markdown templates
+type CommandsConfig struct {
+	Enable     bool   `yaml:"enable" envconfig:"ENABLE"`
+	ProjectDir string `yaml:"project_dir" envconfig:"PROJECT_DIR"` // Relative to the workspace root
+	UserDir    string `yaml:"user_dir" envconfig:"USER_DIR"`       // Per-user commands directory
+}
+
+// SubAgentConfig controls child agents started by the spawn_agent tool.
+// Empty values fall back to the runtime defaults.
+type SubAgentConfig struct {
+	Tools    []string `yaml:"tools" envconfig:"TOOLS"`         // Tools a sub-agent may be granted
+	MaxSteps int      `yaml:"max_steps" envconfig:"MAX_STEPS"` // Step budget per sub-agent
+	Model    string   `yaml:"model" envconfig:"MODEL"`         // Model override for sub-agents
+}
+
+// CassetteConfig records LLM traffic to a file or replays it offline.
+// Replay is selected with active_provider "replay".
+type CassetteConfig struct {
+	Path   string `yaml:"path" envconfig:"PATH"`     // Cassette file
+	Record bool   `yaml:"record" envconfig:"RECORD"` // Record the active provider's traffic to Path
+}
+
 // Config is the root configuration structure.
 type Config struct {
 	// ActiveProvider explicitly sets the active provider (optional).
@@ -59,6 +138,54 @@ type Config struct {
 	// HTTP server settings.
 	HTTP HTTPConfig `yaml:"http" envconfig:"HTTP"`
 
+	// Retry controls LLM request retries with exponential backoff.
+	Retry RetryConfig `yaml:"retry" envconfig:"RETRY"`
+
+	// Fallbacks is an ordered list of "provider" or "provider:model" entries
+	// tried when the active provider keeps failing.
+	Fallbacks []string `yaml:"fallbacks" envconfig:"FALLBACKS"`
+
+	// Routes send planning, sub-task, recovery or compaction calls to their own
+	// provider and model, e.g. "planning=anthropic:claude-opus-4".
+	Routes RouteTable `yaml:"routes" envconfig:"ROUTES"`
+
+	// Pricing overrides the built-in model price table (USD per million tokens).
+	Pricing PriceTable `yaml:"pricing" envconfig:"PRICING"`
+
+	// Budget limits token usage and cost per session.
+	Budget BudgetConfig `yaml:"budget" envconfig:"BUDGET"`
+
+	// Errors controls how failed commands are fed back and escalated.
+	Errors ErrorPolicyConfig `yaml:"errors" envconfig:"ERRORS"`
+
+	// Loop detects goals stuck repeating the same calls or errors.
+	Loop LoopConfig `yaml:"loop" envconfig:"LOOP"`
+
+	// Web controls the limits of the web tools.
+	Web WebConfig `yaml:"web" envconfig:"WEB"`
+
+	// Limits bounds the time, memory, files and output of shell commands.
+	Limits LimitsConfig `yaml:"limits" envconfig:"LIMITS"`
+
+	// Hooks attaches shell commands or HTTP callbacks to lifecycle events.
+	// The env var names a YAML or JSON hooks file.
+	Hooks HookTable `yaml:"hooks" envconfig:"HOOKS"`
+
+	// Instructions controls the project and user instruction files in the system prompt.
+	Instructions InstructionsConfig `yaml:"instructions" envconfig:"INSTRUCTIONS"`
+
+	// Commands controls the user-defined slash commands expanded on the message endpoint.
+	Commands CommandsConfig `yaml:"commands" envconfig:"COMMANDS"`
+
+	// SubAgent controls the tools and step budget of spawned sub-agents.
+	SubAgent SubAgentConfig `yaml:"subagent" envconfig:"SUBAGENT"`
+
+	// Cassette records or replays LLM traffic for deterministic tests.
+	Cassette CassetteConfig `yaml:"cassette" envconfig:"CASSETTE"`
+
+	// MockScenario is a YAML or JSON file of scripted turns for the mock provider.
+	MockScenario string `yaml:"mock_scenario" envconfig:"MOCK_SCENARIO"`
+
 	// DevMode enables development features like Swagger UI.
 	DevMode bool `yaml:"dev_mode" envconfig:"DEV_MODE"`
 }
@@ -80,8 +207,9 @@ var ProviderEnvVars = map[string]struct {
 		Model:   []string{"OPENAI_MODEL"},
 	},
 	"anthropic": {
-		APIKey: []string{"ANTHROPIC_API_KEY"},
-		Model:  []string{"ANTHROPIC_MODEL"},
+		APIKey:  []string{"ANTHROPIC_API_KEY"},
+		BaseURL: []string{"ANTHROPIC_BASE_URL"},
+		Model:   []string{"ANTHROPIC_MODEL"},
 	},
 	"deepseek": {
 		APIKey: []string{"DEEPSEEK_API_KEY"},
@@ -103,7 +231,8 @@ var ProviderDefaults = map[string]ProviderOptions{
 		Model:   "deepseek-chat",
 	},
 	"anthropic": {
-		Model: "claude-sonnet-4-20250514",
+		BaseURL: "https://api.anthropic.com",
+		Model:   "claude-sonnet-4-20250514",
 	},
 }
 
@@ -120,6 +249,9 @@ func (c *Config) GetActiveProvider() (string, ProviderOptions, error) {
 		if opts, ok := c.detectProviderFromEnv(c.ActiveProvider); ok {
 			return c.ActiveProvider, opts, nil
 		}
+		if opts, ok := c.offlineOptions(c.ActiveProvider); ok {
+			return c.ActiveProvider, opts, nil
+		}
 		return "", ProviderOptions{}, fmt.Errorf("active provider %q not configured", c.ActiveProvider)
 	}
 
@@ -178,6 +310,32 @@ func (c *Config) GetActiveProvider() (string, ProviderOptions, error) {
 	return "", ProviderOptions{}, fmt.Errorf("no provider configured or detected")
 }
 
+// ResolveProvider returns the options for a specific provider, from config or environment.
+// It is used to configure fallback providers.
+func (c *Config) ResolveProvider(providerID string) (ProviderOptions, error) {
+	if p, ok := c.Providers[providerID]; ok && p.Options.APIKey != "" {
+		return mergeOptions(ProviderDefaults[providerID], p.Options), nil
+	}
+	if opts, ok := c.detectProviderFromEnv(providerID); ok {
+		return opts, nil
+	}
+	if opts, ok := c.offlineOptions(providerID); ok {
+		return opts, nil
+	}
+	return ProviderOptions{}, fmt.Errorf("provider %q not configured", providerID)
+}
+
+// offlineOptions returns the options of providers that need no credentials.
+func (c *Config) offlineOptions(providerID string) (ProviderOptions, bool) {
+	switch providerID {
+	case "mock":
+		return ProviderOptions{Scenario: c.MockScenario}, true
+	case "replay":
+		return ProviderOptions{Cassette: c.Cassette.Path}, true
+	}
+	return ProviderOptions{}, false
+}
+
 // detectProviderFromEnv checks if a provider can be configured from environment variables.
 func (c *Config) detectProviderFromEnv(providerID string) (ProviderOptions, bool) {
 	envVars, ok := ProviderEnvVars[providerID]
@@ -255,6 +413,12 @@ func mergeOptions(base, override ProviderOptions) ProviderOptions {
 	if override.MaxTokens != 0 {
 		result.MaxTokens = override.MaxTokens
 	}
+	if override.Cassette != "" {
+		result.Cassette = override.Cassette
+	}
+	if override.Scenario != "" {
+		result.Scenario = override.Scenario
+	}
 	return result
 }
 
@@ -276,6 +440,34 @@ func Load(path string) (*Config, error) {
 		HTTP: HTTPConfig{
 			Addr: ":8080",
 		},
+		Errors: ErrorPolicyConfig{
+			Surface:        true,
+			MaxConsecutive: 3,
+		},
+		Loop: LoopConfig{
+			RepeatedCalls:  3,
+			RepeatedErrors: 3,
+			IdleSteps:      10,
+			Action:         "warn",
+			MaxWarnings:    2,
+		},
+		Instructions: InstructionsConfig{
+			Enable:  true,
+			UserDir: "~/.gm",
+		},
+		Web: WebConfig{
+			SearchBackend:      "searxng",
+			SearchResultsPath:  "results",
+			SearchTitleField:   "title",
+			SearchURLField:     "url",
+			SearchSnippetField: "snippet",
+		},
+		Commands: CommandsConfig{
+			Enable:     true,
+			ProjectDir: ".gm/commands",
+			UserDir:    "~/.gm/commands",
+		},
+		Limits: defaultLimits(),
 	}
 
 	// Process Env Vars (GM_ prefix)
@@ -287,6 +479,7 @@ func Load(path string) (*Config, error) {
 	if cfg.HTTP.Addr == "" {
 		cfg.HTTP.Addr = ":8080"
 	}
+	cfg.Pricing = DefaultPrices.Merge(cfg.Pricing)
 
 	return cfg, nil
 }
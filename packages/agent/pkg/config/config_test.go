@@ -80,3 +80,69 @@ func TestMergeOptions(t *testing.T) {
 		t.Fatalf("expected override-model, got %s", result.Model)
 	}
 }
+
+func TestPriceTableDecodeAndLookup(t *testing.T) {
+	var table PriceTable
+	if err := table.Decode("local-model=0/0, gpt-4o=3/12"); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	merged := DefaultPrices.Merge(table)
+	if p, _ := merged.Lookup("gpt-4o"); p.Input != 3 || p.Output != 12 {
+		t.Fatalf("expected override to win, got %+v", p)
+	}
+	if p, ok := merged.Lookup("gpt-4o-mini-2024-07-18"); !ok || p.Input != DefaultPrices["gpt-4o-mini"].Input {
+		t.Fatalf("expected longest prefix match, got %+v", p)
+	}
+	if _, ok := merged.Lookup("unknown"); ok {
+		t.Fatalf("expected no price for unknown model")
+	}
+	if cost := (ModelPrice{Input: 1, Output: 2}).Cost(1_000_000, 500_000); cost != 2 {
+		t.Fatalf("unexpected cost %f", cost)
+	}
+	if err := table.Decode("broken"); err == nil {
+		t.Fatalf("expected error for malformed entry")
+	}
+}
+
+func TestRouteTableDecode(t *testing.T) {
+	var table RouteTable
+	if err := table.Decode("planning=anthropic:claude-opus-4, compaction=openai:gpt-4o-mini,sub_task=gemini,recovery=ollama:llama3:8b"); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if r := table[RoutePlanning]; r.Provider != "anthropic" || r.Model != "claude-opus-4" {
+		t.Fatalf("unexpected planning route %+v", r)
+	}
+	if r := table[RouteSubTask]; r.Provider != "gemini" || r.Model != "" {
+		t.Fatalf("expected a provider-only route, got %+v", r)
+	}
+	if r := table[RouteRecovery]; r.Model != "llama3:8b" {
+		t.Fatalf("expected the model to keep its colons, got %+v", r)
+	}
+	if ids := table.Providers(); len(ids) != 4 || ids[0] != "anthropic" || ids[3] != "openai" {
+		t.Fatalf("unexpected providers %v", ids)
+	}
+	for _, bad := range []string{"planning", "titles=openai", "planning=:gpt-4o"} {
+		if err := table.Decode(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
+
+func TestToolLimitTable(t *testing.T) {
+	cfg := defaultLimits()
+	if err := cfg.Tools.Decode("run_shell.memory=4294967296, run_shell.execution_time=0"); err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	limits := cfg.ForTool("run_shell")
+	if limits.MaxMemory != 4294967296 || limits.MaxExecutionTime != 0 || limits.MaxCPUTime != cfg.CPUTime {
+		t.Fatalf("expected the overrides on top of the configured limits, got %+v", limits)
+	}
+	if other := cfg.ForTool("spawn_agent"); other.MaxExecutionTime != cfg.ExecutionTime {
+		t.Fatalf("expected other tools to keep the configured limits, got %+v", other)
+	}
+	for _, bad := range []string{"memory=1", "run_shell.swap=1", "run_shell.memory=-1", "run_shell.memory"} {
+		if err := cfg.Tools.Decode(bad); err == nil {
+			t.Fatalf("expected error for %q", bad)
+		}
+	}
+}
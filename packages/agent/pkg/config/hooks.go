@@ -0,0 +1,41 @@
+package config
+
+import (
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// HookConfig runs a shell command or calls a URL on a lifecycle event.
+// The event JSON is sent on stdin (or as the POST body); see package hook.
+type HookConfig struct {
+	Matcher string `yaml:"matcher" json:"matcher"` // Regexp on the tool name (tool events only); empty matches all
+	Command string `yaml:"command" json:"command"` // Shell command, run with bash -c in the workspace root
+	URL     string `yaml:"url" json:"url"`         // HTTP endpoint receiving a POST instead of a command
+	Timeout int    `yaml:"timeout" json:"timeout"` // Seconds; defaults to 30
+}
+
+// HookTable maps lifecycle events (PreToolUse, PostToolUse, UserPromptSubmit,
+// SessionStart, Stop) to the hooks run for them, in order.
+type HookTable map[string][]HookConfig
+
+// Decode implements envconfig.Decoder.
+// The value is the path of a YAML or JSON file holding the table:
+//
+//	PreToolUse:
+//	  - matcher: run_shell|create_file
+//	    command: ./scripts/check-command.sh
+func (t *HookTable) Decode(value string) error {
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return fmt.Errorf("read hooks file: %w", err)
+	}
+	table := HookTable{}
+	// YAML is a superset of JSON, so one decoder handles both
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return fmt.Errorf("parse hooks file %s: %w", value, err)
+	}
+	*t = table
+	return nil
+}
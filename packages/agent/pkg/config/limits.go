@@ -0,0 +1,103 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+)
+
+// LimitsConfig bounds the commands run by the shell tools. A zero value
+// disables the corresponding limit.
+type LimitsConfig struct {
+	ExecutionTime int64          `yaml:"execution_time" envconfig:"EXECUTION_TIME"` // Wall-clock seconds per foreground command
+	CPUTime       int64          `yaml:"cpu_time" envconfig:"CPU_TIME"`             // CPU seconds per process
+	Memory        int64          `yaml:"memory" envconfig:"MEMORY"`                 // Bytes of address space per process
+	FileSize      int64          `yaml:"file_size" envconfig:"FILE_SIZE"`           // Bytes per written file
+	OpenFiles     int64          `yaml:"open_files" envconfig:"OPEN_FILES"`         // Open file descriptors per process
+	Output        int64          `yaml:"output" envconfig:"OUTPUT"`                 // Bytes of output returned per command
+	Tools         ToolLimitTable `yaml:"tools" envconfig:"TOOLS"`                   // Per-tool overrides
+}
+
+// limitFields maps the limit names used in overrides to their fields
+var limitFields = map[string]func(*security.ResourceLimits) *int64{
+	"execution_time": func(l *security.ResourceLimits) *int64 { return &l.MaxExecutionTime },
+	"cpu_time":       func(l *security.ResourceLimits) *int64 { return &l.MaxCPUTime },
+	"memory":         func(l *security.ResourceLimits) *int64 { return &l.MaxMemory },
+	"file_size":      func(l *security.ResourceLimits) *int64 { return &l.MaxFileSize },
+	"open_files":     func(l *security.ResourceLimits) *int64 { return &l.MaxOpenFiles },
+	"output":         func(l *security.ResourceLimits) *int64 { return &l.MaxOutput },
+}
+
+func defaultLimits() LimitsConfig {
+	d := security.DefaultResourceLimits()
+	return LimitsConfig{
+		ExecutionTime: d.MaxExecutionTime,
+		CPUTime:       d.MaxCPUTime,
+		Memory:        d.MaxMemory,
+		FileSize:      d.MaxFileSize,
+		OpenFiles:     d.MaxOpenFiles,
+		Output:        d.MaxOutput,
+	}
+}
+
+// ForTool returns the limits of a tool: the configured ones with the tool's
+// overrides applied
+func (c LimitsConfig) ForTool(name string) security.ResourceLimits {
+	limits := security.ResourceLimits{
+		MaxExecutionTime: c.ExecutionTime,
+		MaxCPUTime:       c.CPUTime,
+		MaxMemory:        c.Memory,
+		MaxFileSize:      c.FileSize,
+		MaxOpenFiles:     c.OpenFiles,
+		MaxOutput:        c.Output,
+	}
+	for field, value := range c.Tools[name] {
+		*limitFields[field](&limits) = value
+	}
+	return limits
+}
+
+// ToolLimitTable maps tool names to the limits they override, by limit name
+type ToolLimitTable map[string]map[string]int64
+
+// Decode implements envconfig.Decoder.
+// Format: "run_shell.memory=4294967296,run_shell.execution_time=600".
+func (t *ToolLimitTable) Decode(value string) error {
+	table := ToolLimitTable{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, raw, ok := strings.Cut(entry, "=")
+		tool, field, hasField := strings.Cut(strings.TrimSpace(key), ".")
+		if !ok || !hasField || tool == "" {
+			return fmt.Errorf("invalid limit entry %q: expected tool.limit=value", entry)
+		}
+		if limitFields[field] == nil {
+			return fmt.Errorf("unknown limit %q: expected one of %s", field, strings.Join(limitNames(), ", "))
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid value for %s: %q", key, raw)
+		}
+		if table[tool] == nil {
+			table[tool] = map[string]int64{}
+		}
+		table[tool][field] = n
+	}
+	*t = table
+	return nil
+}
+
+func limitNames() []string {
+	names := make([]string, 0, len(limitFields))
+	for name := range limitFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
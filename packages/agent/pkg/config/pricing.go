@@ -0,0 +1,102 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ModelPrice is the cost of a model in USD per million tokens.
+type ModelPrice struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// Cost returns the USD cost for the given token counts.
+func (p ModelPrice) Cost(promptTokens, completionTokens int) float64 {
+	return (float64(promptTokens)*p.Input + float64(completionTokens)*p.Output) / 1_000_000
+}
+
+// PriceTable maps model names (or name prefixes) to their prices.
+type PriceTable map[string]ModelPrice
+
+// DefaultPrices contains list prices for commonly used models.
+// Entries can be overridden or extended with GM_PRICING.
+var DefaultPrices = PriceTable{
+	"gpt-4o":            {Input: 2.50, Output: 10.00},
+	"gpt-4o-mini":       {Input: 0.15, Output: 0.60},
+	"gpt-4.1":           {Input: 2.00, Output: 8.00},
+	"gpt-4.1-mini":      {Input: 0.40, Output: 1.60},
+	"o3":                {Input: 2.00, Output: 8.00},
+	"o4-mini":           {Input: 1.10, Output: 4.40},
+	"gemini-2.0-flash":  {Input: 0.10, Output: 0.40},
+	"gemini-2.5-flash":  {Input: 0.30, Output: 2.50},
+	"gemini-2.5-pro":    {Input: 1.25, Output: 10.00},
+	"claude-sonnet-4":   {Input: 3.00, Output: 15.00},
+	"claude-opus-4":     {Input: 15.00, Output: 75.00},
+	"claude-3-5-haiku":  {Input: 0.80, Output: 4.00},
+	"deepseek-chat":     {Input: 0.27, Output: 1.10},
+	"deepseek-reasoner": {Input: 0.55, Output: 2.19},
+}
+
+// Lookup returns the price for a model. An exact match wins,
+// otherwise the longest entry that is a prefix of the model name is used
+// (e.g. "claude-sonnet-4" matches "claude-sonnet-4-20250514").
+func (t PriceTable) Lookup(model string) (ModelPrice, bool) {
+	if p, ok := t[model]; ok {
+		return p, true
+	}
+	best := ""
+	for name := range t {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return t[best], true
+}
+
+// Merge returns a new table with entries from override taking precedence.
+func (t PriceTable) Merge(override PriceTable) PriceTable {
+	result := make(PriceTable, len(t)+len(override))
+	for k, v := range t {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = v
+	}
+	return result
+}
+
+// Decode implements envconfig.Decoder.
+// Format: "model=input/output,model2=input/output" with prices in USD per million tokens.
+func (t *PriceTable) Decode(value string) error {
+	table := PriceTable{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, prices, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("invalid pricing entry %q: expected model=input/output", entry)
+		}
+		in, out, ok := strings.Cut(prices, "/")
+		if !ok {
+			return fmt.Errorf("invalid pricing entry %q: expected model=input/output", entry)
+		}
+		input, err := strconv.ParseFloat(strings.TrimSpace(in), 64)
+		if err != nil {
+			return fmt.Errorf("invalid input price for %s: %w", model, err)
+		}
+		output, err := strconv.ParseFloat(strings.TrimSpace(out), 64)
+		if err != nil {
+			return fmt.Errorf("invalid output price for %s: %w", model, err)
+		}
+		table[strings.TrimSpace(model)] = ModelPrice{Input: input, Output: output}
+	}
+	*t = table
+	return nil
+}
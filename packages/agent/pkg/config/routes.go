@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// Routes are the kinds of LLM calls that can be sent to their own model.
+const (
+	RoutePlanning   = "planning"   // Calls made in planning mode
+	RouteExecuting  = "executing"  // Calls made in executing mode
+	RouteSubTask    = "sub_task"   // Goals of sub-agents
+	RouteRecovery   = "recovery"   // Goals working around a failed goal
+	RouteCompaction = "compaction" // Summaries of compacted context
+	RouteWebFetch   = "web_fetch"  // Answers extracted from fetched pages
+)
+
+var validRoutes = map[string]bool{
+	RoutePlanning:   true,
+	RouteExecuting:  true,
+	RouteSubTask:    true,
+	RouteRecovery:   true,
+	RouteCompaction: true,
+	RouteWebFetch:   true,
+}
+
+// RouteTable maps routes to the provider and model that serve them.
+// Calls without a route use the active provider and its model.
+type RouteTable map[string]types.ModelRoute
+
+// ParseModelRoute parses "provider" or "provider:model"; the model may itself
+// contain colons.
+func ParseModelRoute(value string) (types.ModelRoute, error) {
+	provider, model, _ := strings.Cut(strings.TrimSpace(value), ":")
+	if provider == "" {
+		return types.ModelRoute{}, fmt.Errorf("invalid model route %q: expected provider or provider:model", value)
+	}
+	return types.ModelRoute{Provider: provider, Model: strings.TrimSpace(model)}, nil
+}
+
+// Providers returns the providers named by the routes, sorted
+func (t RouteTable) Providers() []string {
+	seen := map[string]bool{}
+	var ids []string
+	for _, r := range t {
+		if r.Provider != "" && !seen[r.Provider] {
+			seen[r.Provider] = true
+			ids = append(ids, r.Provider)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Decode implements envconfig.Decoder.
+// Format: "planning=anthropic:claude-opus-4,compaction=openai:gpt-4o-mini".
+func (t *RouteTable) Decode(value string) error {
+	table := RouteTable{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, target, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return fmt.Errorf("invalid route entry %q: expected route=provider:model", entry)
+		}
+		if !validRoutes[name] {
+			return fmt.Errorf("unknown route %q: expected one of planning, executing, sub_task, recovery, compaction, web_fetch", name)
+		}
+		route, err := ParseModelRoute(target)
+		if err != nil {
+			return fmt.Errorf("route %s: %w", name, err)
+		}
+		table[name] = route
+	}
+	*t = table
+	return nil
+}
@@ -0,0 +1,279 @@
+// Package hook runs user-configured shell commands and HTTP callbacks on
+// lifecycle events, so teams can enforce their own rules around tool calls,
+// prompts and sessions without changing the tool handlers.
+//
+// A hook receives the event as JSON (Input) on stdin, or as the body of a POST
+// for URL hooks. It answers through its exit code or a JSON Response:
+//
+//   - exit 0: stdout is parsed as a Response if it is a JSON object. Plain text
+//     output of UserPromptSubmit and SessionStart hooks is added as context.
+//   - exit 2: the action is blocked, with stderr as the reason.
+//   - other exit codes, timeouts and non-2xx HTTP statuses: the hook failed;
+//     the failure is logged and the action proceeds.
+package hook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+)
+
+// Event names a point in the agent lifecycle where hooks run
+type Event string
+
+const (
+	PreToolUse       Event = "PreToolUse"       // Before a tool runs; may block it or rewrite its arguments
+	PostToolUse      Event = "PostToolUse"      // After a tool ran; may flag the result as an error
+	UserPromptSubmit Event = "UserPromptSubmit" // Before a user message is accepted; may reject it
+	SessionStart     Event = "SessionStart"     // When a session is created
+	Stop             Event = "Stop"             // When the agent loop finishes a run
+)
+
+var events = map[Event]bool{PreToolUse: true, PostToolUse: true, UserPromptSubmit: true, SessionStart: true, Stop: true}
+
+const (
+	defaultTimeout  = 30 * time.Second
+	maxResponseSize = 1 << 20
+)
+
+// Input is the JSON a hook receives
+type Input struct {
+	Event      Event           `json:"hook_event"`
+	SessionID  string          `json:"session_id,omitempty"`
+	Cwd        string          `json:"cwd,omitempty"`
+	ToolName   string          `json:"tool_name,omitempty"`
+	ToolInput  json.RawMessage `json:"tool_input,omitempty"`
+	ToolOutput string          `json:"tool_output,omitempty"` // PostToolUse
+	ToolError  string          `json:"tool_error,omitempty"`  // PostToolUse
+	Prompt     string          `json:"prompt,omitempty"`      // UserPromptSubmit
+	Reason     string          `json:"reason,omitempty"`      // Stop: completed, error, cancelled, ...
+}
+
+// Response is the optional JSON a hook answers with
+type Response struct {
+	Decision          string          `json:"decision,omitempty"` // "block" stops the action
+	Reason            string          `json:"reason,omitempty"`
+	ToolInput         json.RawMessage `json:"tool_input,omitempty"`         // PreToolUse: replacement arguments
+	AdditionalContext string          `json:"additional_context,omitempty"` // Shown to the model before its next decision
+}
+
+// Result combines the responses of the hooks run for an event
+type Result struct {
+	Blocked   bool
+	Reason    string
+	ToolInput string // Rewritten tool arguments, or "" if unchanged
+}
+
+// Context is text a hook asked to show to the model
+type Context struct {
+	Event   Event
+	Content string
+}
+
+type hook struct {
+	config.HookConfig
+	matcher *regexp.Regexp
+	timeout time.Duration
+}
+
+// Runner runs the configured hooks. A nil Runner runs nothing.
+type Runner struct {
+	hooks     map[Event][]hook
+	dir       string
+	sessionID string
+	client    *http.Client
+	log       *slog.Logger
+
+	mu      sync.Mutex
+	pending []Context
+}
+
+// New validates the hook table. Commands run in dir.
+func New(table config.HookTable, dir string, logger *slog.Logger) (*Runner, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	r := &Runner{hooks: make(map[Event][]hook), dir: dir, client: &http.Client{}, log: logger}
+	for name, configs := range table {
+		event := Event(name)
+		if !events[event] {
+			return nil, fmt.Errorf("unknown hook event %q", name)
+		}
+		for i, cfg := range configs {
+			h := hook{HookConfig: cfg, timeout: defaultTimeout}
+			if (cfg.Command == "") == (cfg.URL == "") {
+				return nil, fmt.Errorf("%s hook %d: set exactly one of command and url", name, i)
+			}
+			if cfg.Matcher != "" {
+				re, err := regexp.Compile("^(?:" + cfg.Matcher + ")$")
+				if err != nil {
+					return nil, fmt.Errorf("%s hook %d: invalid matcher: %w", name, i, err)
+				}
+				h.matcher = re
+			}
+			if cfg.Timeout > 0 {
+				h.timeout = time.Duration(cfg.Timeout) * time.Second
+			}
+			r.hooks[event] = append(r.hooks[event], h)
+		}
+	}
+	return r, nil
+}
+
+// ForSession returns a runner for one session, with its own pending context
+func (r *Runner) ForSession(id string) *Runner {
+	if r == nil {
+		return nil
+	}
+	return &Runner{hooks: r.hooks, dir: r.dir, sessionID: id, client: r.client, log: r.log}
+}
+
+// Run runs the hooks for in.Event in order. A blocking hook stops the
+// remaining ones; a PreToolUse rewrite is passed on to the next hook.
+func (r *Runner) Run(ctx context.Context, in Input) Result {
+	var res Result
+	if r == nil {
+		return res
+	}
+	in.SessionID = r.sessionID
+	in.Cwd = r.dir
+
+	for _, h := range r.hooks[in.Event] {
+		if h.matcher != nil && !h.matcher.MatchString(in.ToolName) {
+			continue
+		}
+		resp, err := r.run(ctx, h, in)
+		if err != nil {
+			r.log.Warn("hook failed", "event", in.Event, "tool", in.ToolName, "error", err)
+			continue
+		}
+		if resp.AdditionalContext != "" {
+			r.mu.Lock()
+			r.pending = append(r.pending, Context{Event: in.Event, Content: resp.AdditionalContext})
+			r.mu.Unlock()
+		}
+		if in.Event == PreToolUse && len(resp.ToolInput) > 0 {
+			in.ToolInput = resp.ToolInput
+			res.ToolInput = string(resp.ToolInput)
+		}
+		if resp.Decision == "block" {
+			res.Blocked = true
+			res.Reason = resp.Reason
+			if res.Reason == "" {
+				res.Reason = fmt.Sprintf("blocked by %s hook", in.Event)
+			}
+			return res
+		}
+	}
+	return res
+}
+
+// TakeContext returns and clears the context hooks added since the last call
+func (r *Runner) TakeContext() []Context {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	pending := r.pending
+	r.pending = nil
+	return pending
+}
+
+// ToolInput returns tool arguments as JSON, quoting them if they are not valid JSON
+func ToolInput(args string) json.RawMessage {
+	if json.Valid([]byte(args)) {
+		return json.RawMessage(args)
+	}
+	quoted, _ := json.Marshal(args)
+	return quoted
+}
+
+func (r *Runner) run(ctx context.Context, h hook, in Input) (*Response, error) {
+	payload, err := json.Marshal(in)
+	if err != nil {
+		return nil, fmt.Errorf("encode hook input: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	// Plain text output only makes sense as context before the model runs
+	textContext := in.Event == UserPromptSubmit || in.Event == SessionStart
+	if h.URL != "" {
+		return r.post(ctx, h.URL, payload, textContext)
+	}
+
+	cmd := exec.CommandContext(ctx, "bash", "-c", h.Command)
+	cmd.Dir = r.dir
+	cmd.Env = append(os.Environ(), "GM_HOOK_EVENT="+string(in.Event), "GM_SESSION_ID="+in.SessionID)
+	cmd.Stdin = bytes.NewReader(payload)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Background children may keep the pipes open after a timeout kill
+	cmd.WaitDelay = time.Second
+
+	err = cmd.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+		return parseResponse(stdout.Bytes(), textContext)
+	case ctx.Err() != nil:
+		return nil, fmt.Errorf("timed out after %s", h.timeout)
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == 2:
+		return &Response{Decision: "block", Reason: strings.TrimSpace(stderr.String())}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+}
+
+func (r *Runner) post(ctx context.Context, url string, payload []byte, textContext bool) (*Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return parseResponse(body, textContext)
+}
+
+// parseResponse reads a hook's output: a JSON Response, or plain text context
+func parseResponse(out []byte, textContext bool) (*Response, error) {
+	text := strings.TrimSpace(string(out))
+	if strings.HasPrefix(text, "{") {
+		var resp Response
+		if err := json.Unmarshal([]byte(text), &resp); err != nil {
+			return nil, fmt.Errorf("invalid hook response: %w", err)
+		}
+		return &resp, nil
+	}
+	if textContext {
+		return &Response{AdditionalContext: text}, nil
+	}
+	return &Response{}, nil
+}
@@ -0,0 +1,146 @@
+package hook
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+)
+
+func newRunner(t *testing.T, table config.HookTable) *Runner {
+	t.Helper()
+	r, err := New(table, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("new runner: %v", err)
+	}
+	return r.ForSession("ses_1")
+}
+
+func TestNewRejectsInvalidHooks(t *testing.T) {
+	cases := map[string]config.HookTable{
+		"unknown event":   {"BeforeLunch": {{Command: "true"}}},
+		"no action":       {"Stop": {{}}},
+		"both actions":    {"Stop": {{Command: "true", URL: "http://localhost"}}},
+		"invalid matcher": {"PreToolUse": {{Matcher: "(", Command: "true"}}},
+	}
+	for name, table := range cases {
+		if _, err := New(table, ".", nil); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestCommandHookBlocksWithExitCode2(t *testing.T) {
+	r := newRunner(t, config.HookTable{
+		"PreToolUse": {{Matcher: "run_shell", Command: `grep -q "rm -rf" && { echo "destructive command" >&2; exit 2; }; exit 0`}},
+	})
+
+	res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "run_shell", ToolInput: ToolInput(`{"command":"rm -rf /"}`)})
+	if !res.Blocked || res.Reason != "destructive command" {
+		t.Fatalf("expected block with stderr reason, got %+v", res)
+	}
+	if res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "run_shell", ToolInput: ToolInput(`{"command":"ls"}`)}); res.Blocked {
+		t.Fatalf("expected harmless command to pass, got %+v", res)
+	}
+	// The matcher is anchored, so it does not match run_shell_extra
+	if res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "run_shell_extra", ToolInput: ToolInput(`{"command":"rm -rf /"}`)}); res.Blocked {
+		t.Fatalf("expected matcher to skip other tools, got %+v", res)
+	}
+}
+
+func TestCommandHookRewritesToolInput(t *testing.T) {
+	r := newRunner(t, config.HookTable{
+		"PreToolUse": {
+			{Command: `echo '{"tool_input":{"path":"safe.txt"}}'`},
+			// The second hook sees the rewritten input
+			{Command: `cat > seen.json`},
+		},
+	})
+
+	res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "read_file", ToolInput: ToolInput(`{"path":"/etc/passwd"}`)})
+	if res.Blocked || res.ToolInput != `{"path":"safe.txt"}` {
+		t.Fatalf("expected rewritten input, got %+v", res)
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.dir, "seen.json"))
+	if err != nil {
+		t.Fatalf("read hook input: %v", err)
+	}
+	var in Input
+	if err := json.Unmarshal(data, &in); err != nil {
+		t.Fatalf("decode hook input: %v", err)
+	}
+	if string(in.ToolInput) != `{"path":"safe.txt"}` || in.SessionID != "ses_1" || in.Event != PreToolUse {
+		t.Fatalf("unexpected hook input %s", data)
+	}
+}
+
+func TestHookContextIsQueued(t *testing.T) {
+	r := newRunner(t, config.HookTable{
+		"UserPromptSubmit": {{Command: `echo "Use tabs for indentation."`}},
+		"PostToolUse":      {{Command: `echo "formatted 3 files"`}, {Command: `echo '{"additional_context":"lint is clean"}'`}},
+	})
+
+	r.Run(context.Background(), Input{Event: UserPromptSubmit, Prompt: "add a function"})
+	r.Run(context.Background(), Input{Event: PostToolUse, ToolName: "create_file"})
+
+	got := r.TakeContext()
+	// Plain output of tool hooks is not context; only the JSON field is
+	if len(got) != 2 || got[0].Content != "Use tabs for indentation." || got[1].Event != PostToolUse || got[1].Content != "lint is clean" {
+		t.Fatalf("unexpected context %+v", got)
+	}
+	if len(r.TakeContext()) != 0 {
+		t.Fatalf("expected context to be cleared once taken")
+	}
+}
+
+func TestFailingHookDoesNotBlock(t *testing.T) {
+	r := newRunner(t, config.HookTable{
+		"PreToolUse": {{Command: "exit 1"}, {Command: "sleep 5; true", Timeout: 1}},
+	})
+	if res := r.Run(context.Background(), Input{Event: PreToolUse, ToolName: "read_file"}); res.Blocked {
+		t.Fatalf("expected failing hooks to be ignored, got %+v", res)
+	}
+}
+
+func TestHTTPHook(t *testing.T) {
+	var got Input
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if err := json.NewDecoder(req.Body).Decode(&got); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if strings.Contains(got.Prompt, "secret") {
+			w.Write([]byte(`{"decision":"block","reason":"prompt mentions a secret"}`))
+		}
+	}))
+	defer srv.Close()
+
+	r := newRunner(t, config.HookTable{"UserPromptSubmit": {{URL: srv.URL}}})
+	res := r.Run(context.Background(), Input{Event: UserPromptSubmit, Prompt: "print the secret key"})
+	if !res.Blocked || res.Reason != "prompt mentions a secret" {
+		t.Fatalf("expected HTTP hook to block, got %+v", res)
+	}
+	if got.Event != UserPromptSubmit || got.SessionID != "ses_1" {
+		t.Fatalf("unexpected hook input %+v", got)
+	}
+	if res := r.Run(context.Background(), Input{Event: UserPromptSubmit, Prompt: "hello"}); res.Blocked {
+		t.Fatalf("expected empty response to allow, got %+v", res)
+	}
+}
+
+func TestNilRunner(t *testing.T) {
+	var r *Runner
+	if res := r.ForSession("s").Run(context.Background(), Input{Event: Stop}); res.Blocked {
+		t.Fatalf("expected nil runner to allow everything")
+	}
+	if r.TakeContext() != nil {
+		t.Fatalf("expected no context from nil runner")
+	}
+}
@@ -0,0 +1,269 @@
+// Package instructions loads the instruction files users keep next to their
+// code (GM.md, AGENTS.md) and in their home directory (~/.gm/GM.md), and
+// renders them for the system prompt.
+//
+// Files are read from the user directory first, then from the filesystem root
+// down to the workspace root, so more specific files come later. A line that
+// consists of "@path" is replaced by the contents of that file, resolved
+// relative to the including file ("~/" is the home directory).
+package instructions
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultFiles are the instruction file names looked up in each directory
+var DefaultFiles = []string{"GM.md", "AGENTS.md"}
+
+const (
+	maxIncludeDepth = 5
+	maxFileSize     = 64 << 10 // Larger files are truncated
+)
+
+// Scope tells where an instruction file was found
+type Scope string
+
+const (
+	ScopeUser    Scope = "user"    // The user directory, e.g. ~/.gm
+	ScopeProject Scope = "project" // The workspace root or one of its parents
+)
+
+// File is a loaded instruction file, with its includes expanded
+type File struct {
+	Path     string
+	Scope    Scope
+	Content  string
+	Includes []string // Paths of the files it included, recursively
+}
+
+// stamp identifies a version of a file; the zero stamp means it does not exist
+type stamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// Loader finds and caches instruction files. A nil Loader has no instructions.
+type Loader struct {
+	root    string
+	userDir string
+	names   []string
+	log     *slog.Logger
+
+	mu     sync.Mutex
+	loaded bool
+	stamps map[string]stamp // Every candidate and included file, present or not
+	files  []File
+	prompt string
+}
+
+// New returns a loader for the workspace root. userDir is the per-user
+// directory (e.g. ~/.gm, "" to skip it) and names the file names to look for.
+func New(root, userDir string, names []string, logger *slog.Logger) (*Loader, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	if len(names) == 0 {
+		names = DefaultFiles
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return nil, fmt.Errorf("resolve workspace root: %w", err)
+	}
+	if userDir != "" {
+		if userDir, err = expandHome(userDir); err != nil {
+			return nil, err
+		}
+	}
+	return &Loader{root: absRoot, userDir: userDir, names: names, log: logger}, nil
+}
+
+// Prompt returns the instructions for the system prompt, or "" if there are
+// none. Files are reloaded when one of them was created, changed or removed.
+func (l *Loader) Prompt() string {
+	if l == nil {
+		return ""
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.loaded || l.changed() {
+		l.load()
+	}
+	return l.prompt
+}
+
+// Files returns the instruction files of the last load
+func (l *Loader) Files() []File {
+	if l == nil {
+		return nil
+	}
+	l.Prompt()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]File(nil), l.files...)
+}
+
+// candidates returns the paths that may hold instructions, most general first
+func (l *Loader) candidates() []File {
+	var out []File
+	if l.userDir != "" {
+		for _, name := range l.names {
+			out = append(out, File{Path: filepath.Join(l.userDir, name), Scope: ScopeUser})
+		}
+	}
+
+	var dirs []string
+	for dir := l.root; ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		for _, name := range l.names {
+			out = append(out, File{Path: filepath.Join(dirs[i], name), Scope: ScopeProject})
+		}
+	}
+	return out
+}
+
+func (l *Loader) load() {
+	l.loaded = true
+	l.stamps = make(map[string]stamp)
+	l.files = nil
+
+	seen := map[string]bool{}
+	for _, c := range l.candidates() {
+		l.stamps[c.Path] = statFile(c.Path)
+		if seen[c.Path] || l.stamps[c.Path] == (stamp{}) {
+			continue
+		}
+		seen[c.Path] = true
+
+		content, err := l.read(c.Path, &c, map[string]bool{c.Path: true}, 0)
+		if err != nil {
+			l.log.Warn("failed to read instruction file", "path", c.Path, "error", err)
+			continue
+		}
+		if strings.TrimSpace(content) == "" {
+			continue
+		}
+		c.Content = content
+		l.files = append(l.files, c)
+	}
+	l.prompt = render(l.files)
+	if len(l.files) > 0 {
+		l.log.Info("loaded instruction files", "count", len(l.files))
+	}
+}
+
+// read returns the contents of path with its @includes expanded
+func (l *Loader) read(path string, file *File, stack map[string]bool, depth int) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	if len(data) > maxFileSize {
+		data = append(data[:maxFileSize:maxFileSize], []byte("\n[truncated]")...)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	for i, line := range lines {
+		ref, ok := includeRef(line)
+		if !ok {
+			continue
+		}
+		target, err := resolve(filepath.Dir(path), ref)
+		if err == nil {
+			l.stamps[target] = statFile(target)
+		}
+		switch {
+		case err != nil:
+			lines[i] = fmt.Sprintf("[could not include %s: %v]", ref, err)
+		case stack[target]:
+			lines[i] = fmt.Sprintf("[skipped circular include %s]", ref)
+		case depth >= maxIncludeDepth:
+			lines[i] = fmt.Sprintf("[skipped include %s: nested too deep]", ref)
+		default:
+			stack[target] = true
+			content, err := l.read(target, file, stack, depth+1)
+			delete(stack, target)
+			if err != nil {
+				lines[i] = fmt.Sprintf("[could not include %s: %v]", ref, err)
+				continue
+			}
+			file.Includes = append(file.Includes, target)
+			lines[i] = fmt.Sprintf("[Included from %s]\n%s", target, strings.TrimRight(content, "\n"))
+		}
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// changed reports whether any known file differs from when it was loaded
+func (l *Loader) changed() bool {
+	for path, old := range l.stamps {
+		if statFile(path) != old {
+			l.log.Info("instruction file changed, reloading", "path", path)
+			return true
+		}
+	}
+	return false
+}
+
+func render(files []File) string {
+	if len(files) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("# Instructions\n\n")
+	b.WriteString("The following instructions come from instruction files, most general first. ")
+	b.WriteString("When they conflict, later files take precedence, and the user's requests take precedence over all of them.")
+	for _, f := range files {
+		fmt.Fprintf(&b, "\n\n## %s (%s instructions)\n\n%s", f.Path, f.Scope, strings.TrimSpace(f.Content))
+	}
+	return b.String()
+}
+
+// includeRef returns the path of an "@path" line
+func includeRef(line string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if len(line) < 2 || line[0] != '@' || strings.ContainsAny(line, " \t") {
+		return "", false
+	}
+	return line[1:], true
+}
+
+func resolve(dir, ref string) (string, error) {
+	path, err := expandHome(ref)
+	if err != nil {
+		return "", err
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return filepath.Clean(path), nil
+}
+
+func expandHome(path string) (string, error) {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[1:]), nil
+}
+
+func statFile(path string) stamp {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return stamp{}
+	}
+	return stamp{modTime: info.ModTime(), size: info.Size()}
+}
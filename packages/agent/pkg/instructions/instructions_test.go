@@ -0,0 +1,109 @@
+package instructions
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func write(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+}
+
+func TestPromptOrderAndProvenance(t *testing.T) {
+	base := t.TempDir()
+	userDir := filepath.Join(base, "home", ".gm")
+	repo := filepath.Join(base, "repo")
+	root := filepath.Join(repo, "service")
+	write(t, filepath.Join(userDir, "GM.md"), "Answer in English.")
+	write(t, filepath.Join(repo, "GM.md"), "Use tabs.")
+	write(t, filepath.Join(root, "AGENTS.md"), "Run make test before finishing.\n@docs/style.md\n@missing.md\nEmail me @ noon")
+	write(t, filepath.Join(root, "docs", "style.md"), "Prefer table-driven tests.")
+
+	l, err := New(root, userDir, nil, nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+	prompt := l.Prompt()
+
+	order := []string{
+		filepath.Join(userDir, "GM.md") + " (user instructions)",
+		"Answer in English.",
+		filepath.Join(repo, "GM.md") + " (project instructions)",
+		"Use tabs.",
+		filepath.Join(root, "AGENTS.md") + " (project instructions)",
+		"[Included from " + filepath.Join(root, "docs", "style.md") + "]\nPrefer table-driven tests.",
+		"[could not include missing.md",
+		"Email me @ noon",
+	}
+	last := -1
+	for _, want := range order {
+		i := strings.Index(prompt, want)
+		if i <= last {
+			t.Fatalf("expected %q after position %d in:\n%s", want, last, prompt)
+		}
+		last = i
+	}
+
+	files := l.Files()
+	if len(files) != 3 || len(files[2].Includes) != 1 || files[0].Scope != ScopeUser {
+		t.Fatalf("unexpected files %+v", files)
+	}
+}
+
+func TestCircularIncludes(t *testing.T) {
+	root := t.TempDir()
+	write(t, filepath.Join(root, "GM.md"), "top\n@a.md")
+	write(t, filepath.Join(root, "a.md"), "a\n@GM.md")
+
+	l, _ := New(root, "", nil, nil)
+	prompt := l.Prompt()
+	if !strings.Contains(prompt, "[skipped circular include GM.md]") || strings.Count(prompt, "top") != 1 {
+		t.Fatalf("expected the cycle to be cut, got:\n%s", prompt)
+	}
+}
+
+func TestReloadOnChange(t *testing.T) {
+	root := t.TempDir()
+	l, _ := New(root, "", []string{"GM.md"}, nil)
+	if p := l.Prompt(); p != "" {
+		t.Fatalf("expected no instructions, got %q", p)
+	}
+
+	write(t, filepath.Join(root, "GM.md"), "v1\n@extra.md")
+	if p := l.Prompt(); !strings.Contains(p, "v1") || !strings.Contains(p, "could not include extra.md") {
+		t.Fatalf("expected new file to be picked up, got %q", p)
+	}
+
+	// Creating a missing include also reloads
+	write(t, filepath.Join(root, "extra.md"), "extra rules")
+	if p := l.Prompt(); !strings.Contains(p, "extra rules") {
+		t.Fatalf("expected include to be picked up, got %q", p)
+	}
+
+	write(t, filepath.Join(root, "GM.md"), "version two")
+	if p := l.Prompt(); !strings.Contains(p, "version two") || strings.Contains(p, "v1") {
+		t.Fatalf("expected changed file to be reloaded, got %q", p)
+	}
+
+	if err := os.Remove(filepath.Join(root, "GM.md")); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if p := l.Prompt(); p != "" {
+		t.Fatalf("expected removed file to drop out, got %q", p)
+	}
+}
+
+func TestNilLoader(t *testing.T) {
+	var l *Loader
+	if l.Prompt() != "" || l.Files() != nil {
+		t.Fatalf("expected nil loader to have no instructions")
+	}
+}
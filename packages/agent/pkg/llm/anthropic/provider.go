@@ -0,0 +1,569 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+const (
+	defaultBaseURL   = "https://api.anthropic.com"
+	defaultModel     = "claude-sonnet-4-20250514"
+	defaultMaxTokens = 4096
+	apiVersion       = "2023-06-01"
+)
+
+// Config contains Anthropic-specific configuration.
+type Config struct {
+	APIKey  string
+	BaseURL string
+}
+
+type Provider struct {
+	client *http.Client
+	config Config
+}
+
+func New(cfg Config) *Provider {
+	if cfg.BaseURL == "" {
+		cfg.BaseURL = defaultBaseURL
+	}
+	return &Provider{
+		client: &http.Client{}, // No timeout: streaming responses are long-lived
+		config: cfg,
+	}
+}
+
+func (p *Provider) ID() string {
+	return "anthropic"
+}
+
+// APIError is returned when the Messages API responds with a non-2xx status
+// or reports an error event in a stream.
+type APIError struct {
+	StatusCode int
+	Type       string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("anthropic api error (status %d): %s: %s", e.StatusCode, e.Type, e.Message)
+}
+
+// Wire types for the Messages API
+
+type messagesRequest struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []message `json:"messages"`
+	Tools       []tool    `json:"tools,omitempty"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type message struct {
+	Role    string         `json:"role"`
+	Content []contentBlock `json:"content"`
+}
+
+type contentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result
+	ToolUseID string `json:"tool_use_id,omitempty"`
+	Content   string `json:"content,omitempty"`
+	IsError   bool   `json:"is_error,omitempty"`
+
+	// image
+	Source *imageSource `json:"source,omitempty"`
+}
+
+type imageSource struct {
+	Type      string `json:"type"` // base64
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type tool struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	InputSchema types.JSONSchema `json:"input_schema"`
+}
+
+type messagesResponse struct {
+	ID         string         `json:"id"`
+	Model      string         `json:"model"`
+	Content    []contentBlock `json:"content"`
+	StopReason string         `json:"stop_reason"`
+	Usage      usage          `json:"usage"`
+}
+
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+type errorResponse struct {
+	Error struct {
+		Type    string `json:"type"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (p *Provider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	body, err := buildRequest(req, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, body)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var mr messagesResponse
+	if err := json.NewDecoder(resp.Body).Decode(&mr); err != nil {
+		return nil, fmt.Errorf("decode response: %w", err)
+	}
+
+	return convertResponse(&mr), nil
+}
+
+func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	body, err := buildRequest(req, true)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, body)
+	if err != nil {
+		return nil, fmt.Errorf("create stream: %w", err)
+	}
+
+	ch := make(chan llm.StreamChunk)
+	go func() {
+		defer close(ch)
+		defer resp.Body.Close()
+
+		// Track tool_use blocks by content block index (input arrives as JSON fragments)
+		toolCallBuilder := make(map[int]*types.ToolCall)
+		// Input tokens arrive in message_start, output tokens in message_delta
+		var streamUsage usage
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		reader := bufio.NewReader(resp.Body)
+		for {
+			data, err := readEventData(reader)
+			if err != nil {
+				// The stream ends with message_stop, so EOF here means it was cut off
+				if err == io.EOF {
+					err = io.ErrUnexpectedEOF
+				}
+				send(llm.StreamChunk{Err: fmt.Errorf("read stream: %w", err)})
+				return
+			}
+			if data == nil {
+				continue
+			}
+
+			var evt streamEvent
+			if err := json.Unmarshal(data, &evt); err != nil {
+				continue
+			}
+
+			switch evt.Type {
+			case "message_start":
+				streamUsage.InputTokens = evt.Message.Usage.InputTokens
+			case "message_delta":
+				streamUsage.OutputTokens = evt.Usage.OutputTokens
+			case "content_block_start":
+				if evt.ContentBlock.Type == "tool_use" {
+					toolCallBuilder[evt.Index] = &types.ToolCall{
+						ID:   evt.ContentBlock.ID,
+						Name: evt.ContentBlock.Name,
+					}
+					start := llm.ToolCallDelta{Index: evt.Index, ID: evt.ContentBlock.ID, Name: evt.ContentBlock.Name}
+					if !send(llm.StreamChunk{ToolCallDeltas: []llm.ToolCallDelta{start}}) {
+						return
+					}
+				}
+			case "content_block_delta":
+				switch evt.Delta.Type {
+				case "text_delta":
+					if evt.Delta.Text != "" && !send(llm.StreamChunk{Content: evt.Delta.Text}) {
+						return
+					}
+				case "thinking_delta":
+					if evt.Delta.Thinking != "" && !send(llm.StreamChunk{Reasoning: evt.Delta.Thinking}) {
+						return
+					}
+				case "input_json_delta":
+					if tc, ok := toolCallBuilder[evt.Index]; ok {
+						tc.Arguments += evt.Delta.PartialJSON
+						if evt.Delta.PartialJSON != "" && !send(llm.StreamChunk{ToolCallDeltas: []llm.ToolCallDelta{{Index: evt.Index, Arguments: evt.Delta.PartialJSON}}}) {
+							return
+						}
+					}
+				}
+			case "message_stop":
+				// Emit all completed tool calls in content block order, plus final usage
+				finalUsage := convertUsage(streamUsage)
+				final := llm.StreamChunk{Usage: &finalUsage}
+				if len(toolCallBuilder) > 0 {
+					final.ToolCalls = collectToolCalls(toolCallBuilder)
+				}
+				select {
+				case ch <- final:
+				case <-ctx.Done():
+				}
+				return
+			case "error":
+				send(llm.StreamChunk{Err: streamError(evt.Error.Type, evt.Error.Message)})
+				return
+			}
+		}
+	}()
+
+	return ch, nil
+}
+
+type streamEvent struct {
+	Type         string       `json:"type"`
+	Index        int          `json:"index"`
+	ContentBlock contentBlock `json:"content_block"`
+	Delta        struct {
+		Type        string `json:"type"`
+		Text        string `json:"text"`
+		PartialJSON string `json:"partial_json"`
+		Thinking    string `json:"thinking"`
+	} `json:"delta"`
+	Message struct {
+		Usage usage `json:"usage"`
+	} `json:"message"` // message_start
+	Usage         usage `json:"usage"` // message_delta
+	errorResponse       // error
+}
+
+// readEventData reads one SSE event and returns its data payload.
+// Returns nil data for events without a data field (e.g. comments).
+func readEventData(r *bufio.Reader) ([]byte, error) {
+	var data []byte
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && len(data) > 0 {
+				return data, nil
+			}
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			return data, nil // Event boundary
+		}
+		if strings.HasPrefix(line, "data:") {
+			data = append(data, strings.TrimSpace(strings.TrimPrefix(line, "data:"))...)
+		}
+	}
+}
+
+func (p *Provider) do(ctx context.Context, body []byte) (*http.Response, error) {
+	url := strings.TrimRight(p.config.BaseURL, "/") + "/v1/messages"
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("x-api-key", p.config.APIKey)
+	httpReq.Header.Set("anthropic-version", apiVersion)
+
+	resp, err := p.client.Do(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		return nil, parseError(resp)
+	}
+	return resp, nil
+}
+
+func parseError(resp *http.Response) error {
+	apiErr := &APIError{StatusCode: resp.StatusCode}
+	data, _ := io.ReadAll(resp.Body)
+	var er errorResponse
+	if err := json.Unmarshal(data, &er); err == nil && er.Error.Message != "" {
+		apiErr.Type = er.Error.Type
+		apiErr.Message = er.Error.Message
+	} else {
+		apiErr.Type = http.StatusText(resp.StatusCode)
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return &llm.ProviderError{
+		Provider:   "anthropic",
+		StatusCode: resp.StatusCode,
+		RetryAfter: llm.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		Message:    apiErr.Type + ": " + apiErr.Message,
+		Err:        apiErr,
+	}
+}
+
+// streamErrorStatus maps the error types of stream error events to the HTTP
+// status the API uses for them, so they are classified like failed requests
+var streamErrorStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      529,
+}
+
+func streamError(typ, message string) error {
+	status, ok := streamErrorStatus[typ]
+	if !ok {
+		status = http.StatusInternalServerError
+	}
+	return &llm.ProviderError{
+		Provider:   "anthropic",
+		StatusCode: status,
+		Message:    typ + ": " + message,
+		Err:        &APIError{StatusCode: status, Type: typ, Message: message},
+	}
+}
+
+// Helpers
+
+func buildRequest(req *llm.ProviderRequest, stream bool) ([]byte, error) {
+	system, msgs, err := convertMessages(req.Messages)
+	if err != nil {
+		return nil, fmt.Errorf("convert messages: %w", err)
+	}
+
+	mr := messagesRequest{
+		Model:     req.Model,
+		System:    system,
+		Messages:  msgs,
+		Tools:     convertTools(req.Tools),
+		MaxTokens: req.MaxTokens,
+		Stream:    stream,
+	}
+	if mr.Model == "" {
+		mr.Model = defaultModel
+	}
+	if mr.MaxTokens <= 0 {
+		mr.MaxTokens = defaultMaxTokens // Required by the Messages API
+	}
+	if req.Temperature > 0 {
+		temp := req.Temperature
+		mr.Temperature = &temp
+	}
+
+	return json.Marshal(mr)
+}
+
+// imageBlocks returns the image parts as base64 image blocks
+func imageBlocks(parts []types.ContentPart) []contentBlock {
+	var blocks []contentBlock
+	for _, p := range parts {
+		if p.Type == types.ContentPartImage && p.Data != "" {
+			blocks = append(blocks, contentBlock{
+				Type:   "image",
+				Source: &imageSource{Type: "base64", MediaType: p.MimeType, Data: p.Data},
+			})
+		}
+	}
+	return blocks
+}
+
+// convertMessages splits out the system prompt and maps the remaining messages
+// to Messages API turns. Tool results become tool_result blocks in a user turn,
+// and consecutive turns with the same role are merged since the API requires
+// strict user/assistant alternation.
+func convertMessages(msgs []types.Message) (string, []message, error) {
+	var systemParts []string
+	var result []message
+
+	for _, m := range msgs {
+		var role string
+		var blocks []contentBlock
+
+		switch m.Role {
+		case "system":
+			if m.Content != "" {
+				systemParts = append(systemParts, m.Content)
+			}
+			continue
+		case "assistant":
+			role = "assistant"
+			if strings.TrimSpace(m.Content) != "" {
+				blocks = append(blocks, contentBlock{Type: "text", Text: m.Content})
+			}
+			for _, tc := range m.ToolCalls {
+				input := json.RawMessage("{}")
+				if strings.TrimSpace(tc.Arguments) != "" {
+					if !json.Valid([]byte(tc.Arguments)) {
+						return "", nil, fmt.Errorf("invalid tool arguments for %s", tc.Name)
+					}
+					input = json.RawMessage(tc.Arguments)
+				}
+				blocks = append(blocks, contentBlock{
+					Type:  "tool_use",
+					ID:    tc.ID,
+					Name:  tc.Name,
+					Input: input,
+				})
+			}
+		case "tool":
+			role = "user"
+			content := m.Content
+			isError := strings.HasPrefix(content, "Error: ")
+			if content == "" {
+				content = " "
+			}
+			blocks = append(blocks, contentBlock{
+				Type:      "tool_result",
+				ToolUseID: m.ToolCallID,
+				Content:   content,
+				IsError:   isError,
+			})
+			blocks = append(blocks, imageBlocks(m.Parts)...)
+		default:
+			role = "user"
+			if m.HasImages() {
+				for _, p := range m.Parts {
+					if p.Type == types.ContentPartText && strings.TrimSpace(p.Text) != "" {
+						blocks = append(blocks, contentBlock{Type: "text", Text: p.Text})
+					} else {
+						blocks = append(blocks, imageBlocks([]types.ContentPart{p})...)
+					}
+				}
+				break
+			}
+			content := m.Content
+			if strings.TrimSpace(content) == "" {
+				content = " " // Empty text blocks are rejected
+			}
+			blocks = append(blocks, contentBlock{Type: "text", Text: content})
+		}
+
+		if len(blocks) == 0 {
+			continue
+		}
+
+		if n := len(result); n > 0 && result[n-1].Role == role {
+			// Tool results must lead the turn, ahead of images returned with them
+			merged := append(result[n-1].Content, blocks...)
+			sort.SliceStable(merged, func(i, j int) bool {
+				return merged[i].Type == "tool_result" && merged[j].Type != "tool_result"
+			})
+			result[n-1].Content = merged
+			continue
+		}
+		result = append(result, message{Role: role, Content: blocks})
+	}
+
+	return strings.Join(systemParts, "\n\n"), result, nil
+}
+
+func convertTools(tools []types.Tool) []tool {
+	if len(tools) == 0 {
+		return nil
+	}
+	result := make([]tool, len(tools))
+	for i, t := range tools {
+		schema := t.Parameters
+		if schema == nil {
+			schema = types.JSONSchema{"type": "object"}
+		}
+		result[i] = tool{
+			Name:        t.Name,
+			Description: t.Description,
+			InputSchema: schema,
+		}
+	}
+	return result
+}
+
+func convertUsage(u usage) types.Usage {
+	return types.Usage{
+		PromptTokens:     u.InputTokens,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      u.InputTokens + u.OutputTokens,
+	}
+}
+
+func convertResponse(mr *messagesResponse) *llm.ProviderResponse {
+	var content strings.Builder
+	var toolCalls []types.ToolCall
+
+	for _, block := range mr.Content {
+		switch block.Type {
+		case "text":
+			content.WriteString(block.Text)
+		case "tool_use":
+			args := string(block.Input)
+			if args == "" {
+				args = "{}"
+			}
+			toolCalls = append(toolCalls, types.ToolCall{
+				ID:        block.ID,
+				Name:      block.Name,
+				Arguments: args,
+			})
+		}
+	}
+
+	return &llm.ProviderResponse{
+		ID:        mr.ID,
+		Model:     mr.Model,
+		Content:   content.String(),
+		ToolCalls: toolCalls,
+		Usage:     convertUsage(mr.Usage),
+	}
+}
+
+func collectToolCalls(builder map[int]*types.ToolCall) []types.ToolCall {
+	indexes := make([]int, 0, len(builder))
+	for idx := range builder {
+		indexes = append(indexes, idx)
+	}
+	sort.Ints(indexes)
+
+	toolCalls := make([]types.ToolCall, 0, len(indexes))
+	for _, idx := range indexes {
+		tc := *builder[idx]
+		if tc.Arguments == "" {
+			tc.Arguments = "{}"
+		}
+		toolCalls = append(toolCalls, tc)
+	}
+	return toolCalls
+}
@@ -0,0 +1,266 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func TestConvertMessages(t *testing.T) {
+	msgs := []types.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "hi"},
+		{Role: "assistant", Content: " ", ToolCalls: []types.ToolCall{
+			{ID: "toolu_1", Name: "read_file", Arguments: `{"path":"a"}`},
+			{ID: "toolu_2", Name: "glob", Arguments: ""},
+		}},
+		{Role: "tool", ToolCallID: "toolu_1", ToolName: "read_file", Content: "contents"},
+		{Role: "tool", ToolCallID: "toolu_2", ToolName: "glob", Content: "Error: boom"},
+	}
+	system, converted, err := convertMessages(msgs)
+	if err != nil {
+		t.Fatalf("convert messages error: %v", err)
+	}
+	if system != "be brief" {
+		t.Fatalf("unexpected system prompt %q", system)
+	}
+	if len(converted) != 3 {
+		t.Fatalf("expected 3 turns, got %d: %+v", len(converted), converted)
+	}
+
+	assistant := converted[1]
+	if assistant.Role != "assistant" || len(assistant.Content) != 2 {
+		t.Fatalf("expected assistant turn with only tool_use blocks, got %+v", assistant)
+	}
+	if assistant.Content[0].Type != "tool_use" || string(assistant.Content[0].Input) != `{"path":"a"}` {
+		t.Fatalf("unexpected tool_use block: %+v", assistant.Content[0])
+	}
+	if string(assistant.Content[1].Input) != "{}" {
+		t.Fatalf("expected empty arguments to become {}, got %s", assistant.Content[1].Input)
+	}
+
+	results := converted[2]
+	if results.Role != "user" || len(results.Content) != 2 {
+		t.Fatalf("expected tool results merged into one user turn, got %+v", results)
+	}
+	if results.Content[0].ToolUseID != "toolu_1" || results.Content[0].IsError {
+		t.Fatalf("unexpected tool_result block: %+v", results.Content[0])
+	}
+	if !results.Content[1].IsError {
+		t.Fatalf("expected failed tool result to be flagged as error")
+	}
+}
+
+func TestConvertMessagesWithImages(t *testing.T) {
+	image := types.ContentPart{Type: types.ContentPartImage, MimeType: "image/png", Data: "iVBORw0K"}
+	msgs := []types.Message{
+		{Role: "user", Content: "what is this?", Parts: []types.ContentPart{{Type: types.ContentPartText, Text: "what is this?"}, image}},
+		{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "toolu_1", Name: "read_file", Arguments: "{}"}, {ID: "toolu_2", Name: "glob", Arguments: "{}"}}},
+		{Role: "tool", ToolCallID: "toolu_1", ToolName: "read_file", Content: "Attached image", Parts: []types.ContentPart{{Type: types.ContentPartText, Text: "Attached image"}, image}},
+		{Role: "tool", ToolCallID: "toolu_2", ToolName: "glob", Content: "a.png"},
+	}
+	_, converted, err := convertMessages(msgs)
+	if err != nil {
+		t.Fatalf("convert messages error: %v", err)
+	}
+	user := converted[0].Content
+	if len(user) != 2 || user[0].Text != "what is this?" || user[1].Type != "image" || user[1].Source.MediaType != "image/png" {
+		t.Fatalf("unexpected user blocks %+v", user)
+	}
+	results := converted[2].Content
+	if len(results) != 3 || results[0].Type != "tool_result" || results[1].Type != "tool_result" || results[2].Type != "image" {
+		t.Fatalf("expected tool results before the image, got %+v", results)
+	}
+}
+
+func TestConvertMessagesErrorsOnBadJSON(t *testing.T) {
+	msgs := []types.Message{{Role: "assistant", ToolCalls: []types.ToolCall{{Name: "tool", Arguments: "{bad"}}}}
+	if _, _, err := convertMessages(msgs); err == nil {
+		t.Fatalf("expected error for invalid tool call arguments")
+	}
+}
+
+func TestCall(t *testing.T) {
+	var got messagesRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" || r.Header.Get("anthropic-version") == "" {
+			t.Errorf("missing auth headers: %v", r.Header)
+		}
+		body, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(body, &got)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{
+			"id": "msg_1",
+			"model": "claude-test",
+			"stop_reason": "tool_use",
+			"content": [
+				{"type": "text", "text": "Let me check."},
+				{"type": "tool_use", "id": "toolu_1", "name": "read_file", "input": {"path": "main.go"}}
+			],
+			"usage": {"input_tokens": 10, "output_tokens": 5}
+		}`))
+	}))
+	defer srv.Close()
+
+	p := New(Config{APIKey: "test-key", BaseURL: srv.URL})
+	resp, err := p.Call(context.Background(), &llm.ProviderRequest{
+		Model:    "claude-test",
+		Messages: []types.Message{{Role: "system", Content: "sys"}, {Role: "user", Content: "hi"}},
+		Tools:    []types.Tool{{Name: "read_file", Parameters: types.JSONSchema{"type": "object"}}},
+	})
+	if err != nil {
+		t.Fatalf("call error: %v", err)
+	}
+
+	if got.System != "sys" || got.MaxTokens != defaultMaxTokens || len(got.Tools) != 1 {
+		t.Fatalf("unexpected request: %+v", got)
+	}
+	if resp.Content != "Let me check." {
+		t.Fatalf("unexpected content %q", resp.Content)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].ID != "toolu_1" || resp.ToolCalls[0].Arguments != `{"path": "main.go"}` {
+		t.Fatalf("unexpected tool calls: %+v", resp.ToolCalls)
+	}
+	if resp.Usage.TotalTokens != 15 {
+		t.Fatalf("unexpected usage: %+v", resp.Usage)
+	}
+}
+
+func TestCallReturnsAPIError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	defer srv.Close()
+
+	p := New(Config{APIKey: "k", BaseURL: srv.URL})
+	_, err := p.Call(context.Background(), &llm.ProviderRequest{Messages: []types.Message{{Role: "user", Content: "hi"}}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusTooManyRequests || apiErr.Type != "rate_limit_error" {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+	if llm.ClassifyError(err) != llm.ErrorKindRateLimit || llm.RetryAfterHint(err) != 2*time.Second {
+		t.Fatalf("expected retryable rate limit with Retry-After hint, got %s %v", llm.ClassifyError(err), llm.RetryAfterHint(err))
+	}
+}
+
+func TestCallStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		events := []string{
+			`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":3}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Look for TODOs."}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"lo"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"grep","input":{}}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"pattern\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"TODO\"}"}}`,
+			`{"type":"content_block_stop","index":1}`,
+			`{"type":"message_delta","delta":{"stop_reason":"tool_use"},"usage":{"output_tokens":7}}`,
+			`{"type":"message_stop"}`,
+		}
+		for _, e := range events {
+			var typ struct {
+				Type string `json:"type"`
+			}
+			_ = json.Unmarshal([]byte(e), &typ)
+			_, _ = w.Write([]byte("event: " + typ.Type + "\ndata: " + e + "\n\n"))
+		}
+	}))
+	defer srv.Close()
+
+	p := New(Config{APIKey: "k", BaseURL: srv.URL})
+	ch, err := p.CallStream(context.Background(), &llm.ProviderRequest{Messages: []types.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	var text, reasoning string
+	var calls []types.ToolCall
+	var deltas []llm.ToolCallDelta
+	var usage *types.Usage
+	for chunk := range ch {
+		text += chunk.Content
+		reasoning += chunk.Reasoning
+		calls = append(calls, chunk.ToolCalls...)
+		deltas = append(deltas, chunk.ToolCallDeltas...)
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+
+	if text != "Hello" || reasoning != "Look for TODOs." {
+		t.Fatalf("unexpected streamed text %q, reasoning %q", text, reasoning)
+	}
+	if len(deltas) != 3 || deltas[0].ID != "toolu_1" || deltas[0].Name != "grep" || deltas[1].Arguments+deltas[2].Arguments != `{"pattern":"TODO"}` {
+		t.Fatalf("unexpected tool call deltas: %+v", deltas)
+	}
+	if len(calls) != 1 || calls[0].Name != "grep" || calls[0].Arguments != `{"pattern":"TODO"}` {
+		t.Fatalf("unexpected streamed tool calls: %+v", calls)
+	}
+	if usage == nil || usage.PromptTokens != 3 || usage.CompletionTokens != 7 || usage.TotalTokens != 10 {
+		t.Fatalf("unexpected streamed usage: %+v", usage)
+	}
+}
+
+func TestCallStreamReportsErrors(t *testing.T) {
+	stream := func(events ...string) <-chan llm.StreamChunk {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/event-stream")
+			for _, e := range events {
+				_, _ = w.Write([]byte("data: " + e + "\n\n"))
+			}
+		}))
+		t.Cleanup(srv.Close)
+		ch, err := New(Config{APIKey: "k", BaseURL: srv.URL}).CallStream(context.Background(), &llm.ProviderRequest{Messages: []types.Message{{Role: "user", Content: "hi"}}})
+		if err != nil {
+			t.Fatalf("stream error: %v", err)
+		}
+		return ch
+	}
+	collect := func(ch <-chan llm.StreamChunk) (text string, err error) {
+		for chunk := range ch {
+			text += chunk.Content
+			if chunk.Err != nil {
+				err = chunk.Err
+			}
+		}
+		return text, err
+	}
+
+	text, err := collect(stream(
+		`{"type":"message_start","message":{"id":"msg_1","usage":{"input_tokens":3}}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`,
+		`{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`,
+	))
+	var apiErr *APIError
+	if text != "Hel" || !errors.As(err, &apiErr) || apiErr.Type != "overloaded_error" || llm.ClassifyError(err) != llm.ErrorKindServer {
+		t.Fatalf("expected a retryable overloaded error after the text, got %q, %v", text, err)
+	}
+
+	// A stream cut off before message_stop is not a completion either
+	_, err = collect(stream(`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hel"}}`))
+	if !errors.Is(err, io.ErrUnexpectedEOF) || llm.ClassifyError(err) != llm.ErrorKindNetwork {
+		t.Fatalf("expected an unexpected EOF, got %v", err)
+	}
+}
@@ -0,0 +1,135 @@
+// Package cassette records the traffic of an LLM provider to a file and
+// replays it offline, so real sessions can become deterministic tests.
+package cassette
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// Cassette is the on-disk recording of a session's provider calls
+type Cassette struct {
+	Provider     string        `json:"provider"` // ID of the session's primary provider
+	RecordedAt   time.Time     `json:"recorded_at"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one provider call and its outcome
+type Interaction struct {
+	Provider string    `json:"provider,omitempty"` // Provider that served the call; empty means the primary
+	Request  Request   `json:"request"`
+	Stream   bool      `json:"stream"`
+	Response *Response `json:"response,omitempty"` // Set for Call
+	Chunks   []Chunk   `json:"chunks,omitempty"`   // Set for CallStream, in order
+	Error    *Error    `json:"error,omitempty"`
+}
+
+// Request is the recorded part of a ProviderRequest
+type Request struct {
+	Model       string          `json:"model"`
+	Messages    []types.Message `json:"messages"`
+	Tools       []string        `json:"tools,omitempty"` // Tool names
+	MaxTokens   int             `json:"max_tokens,omitempty"`
+	Temperature float64         `json:"temperature,omitempty"`
+}
+
+// Response is a recorded ProviderResponse
+type Response struct {
+	ID        string           `json:"id"`
+	Model     string           `json:"model"`
+	Content   string           `json:"content"`
+	ToolCalls []types.ToolCall `json:"tool_calls,omitempty"`
+	Usage     types.Usage      `json:"usage"`
+}
+
+// Chunk is a recorded StreamChunk
+type Chunk struct {
+	Content        string              `json:"content,omitempty"`
+	Reasoning      string              `json:"reasoning,omitempty"`
+	ToolCallDeltas []llm.ToolCallDelta `json:"tool_call_deltas,omitempty"`
+	ToolCalls      []types.ToolCall    `json:"tool_calls,omitempty"`
+	Usage          *types.Usage        `json:"usage,omitempty"`
+	Error          *Error              `json:"error,omitempty"` // The stream failed here
+}
+
+// Error is a recorded provider failure
+type Error struct {
+	Message    string `json:"message"`
+	StatusCode int    `json:"status_code,omitempty"` // From llm.ProviderError, keeps retries classifiable
+}
+
+// Load reads a cassette file
+func Load(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("parse cassette %s: %w", path, err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette atomically, creating parent directories as needed
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func newRequest(req *llm.ProviderRequest) Request {
+	r := Request{
+		Model:       req.Model,
+		Messages:    make([]types.Message, len(req.Messages)),
+		MaxTokens:   req.MaxTokens,
+		Temperature: req.Temperature,
+	}
+	for i, m := range req.Messages {
+		r.Messages[i] = m.Clone()
+	}
+	for _, t := range req.Tools {
+		r.Tools = append(r.Tools, t.Name)
+	}
+	return r
+}
+
+func newResponse(resp *llm.ProviderResponse) *Response {
+	return &Response{
+		ID:        resp.ID,
+		Model:     resp.Model,
+		Content:   resp.Content,
+		ToolCalls: resp.ToolCalls,
+		Usage:     resp.Usage,
+	}
+}
+
+func newError(err error) *Error {
+	e := &Error{Message: err.Error()}
+	var pe *llm.ProviderError
+	if errors.As(err, &pe) {
+		e.StatusCode = pe.StatusCode
+	}
+	return e
+}
+
+// err rebuilds the failure as a ProviderError so the gateway classifies it as before
+func (e *Error) err() error {
+	return &llm.ProviderError{Provider: "replay", StatusCode: e.StatusCode, Message: e.Message}
+}
@@ -0,0 +1,213 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/llm/mock"
+	"github.com/gm-agent-org/gm-agent/pkg/runtime"
+	"github.com/gm-agent-org/gm-agent/pkg/store"
+	"github.com/gm-agent-org/gm-agent/pkg/tool"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func userRequest(content string) *llm.ProviderRequest {
+	return &llm.ProviderRequest{
+		Model:    "recorded-model",
+		Messages: []types.Message{{Role: "user", Content: content}},
+		Tools:    []types.Tool{{Name: "read_file"}},
+	}
+}
+
+func drain(t *testing.T, stream <-chan llm.StreamChunk) string {
+	t.Helper()
+	var content string
+	for chunk := range stream {
+		content += chunk.Content
+	}
+	return content
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	rec := NewRecorder(mock.New("recorded answer"), path)
+
+	if _, err := rec.Call(ctx, userRequest("first")); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+	stream, err := rec.CallStream(ctx, userRequest("second"))
+	if err != nil {
+		t.Fatalf("stream: %v", err)
+	}
+	drain(t, stream)
+
+	c, err := Load(path)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if c.Provider != "mock" || len(c.Interactions) != 2 || !c.Interactions[1].Stream {
+		t.Fatalf("unexpected cassette: %+v", c)
+	}
+
+	player := NewPlayer(c)
+	resp, err := player.Call(ctx, userRequest("first"))
+	if err != nil || resp.Content != "recorded answer" {
+		t.Fatalf("replayed call: %+v %v", resp, err)
+	}
+	stream, err = player.CallStream(ctx, userRequest("second"))
+	if err != nil {
+		t.Fatalf("replayed stream: %v", err)
+	}
+	if got := drain(t, stream); got != "recorded answer" {
+		t.Fatalf("replayed stream content %q", got)
+	}
+	if player.Remaining() != 0 || player.Err() != nil {
+		t.Fatalf("expected all interactions served cleanly, remaining %d err %v", player.Remaining(), player.Err())
+	}
+
+	if _, err := player.Call(ctx, userRequest("third")); !errors.Is(err, ErrExhausted) {
+		t.Fatalf("expected exhausted cassette, got %v", err)
+	}
+}
+
+func TestPlayerFailsOnMismatch(t *testing.T) {
+	ctx := context.Background()
+	rec := NewRecorder(mock.New("answer"), filepath.Join(t.TempDir(), "c.json"))
+	if _, err := rec.Call(ctx, userRequest("recorded")); err != nil {
+		t.Fatalf("call: %v", err)
+	}
+
+	player := NewPlayer(rec.Cassette())
+	_, err := player.Call(ctx, userRequest("changed"))
+	if !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected mismatch, got %v", err)
+	}
+	if player.Err() == nil || player.Remaining() != 1 {
+		t.Fatalf("expected mismatch to be reported and nothing consumed")
+	}
+
+	// The model is not part of the match
+	req := userRequest("recorded")
+	req.Model = "other-model"
+	if _, err := player.Call(ctx, req); err != nil {
+		t.Fatalf("expected match regardless of model: %v", err)
+	}
+}
+
+type failingProvider struct{}
+
+func (failingProvider) ID() string { return "failing" }
+func (failingProvider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	return nil, &llm.ProviderError{Provider: "failing", StatusCode: 429, Message: "slow down"}
+}
+func (failingProvider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	return nil, &llm.ProviderError{Provider: "failing", StatusCode: 429, Message: "slow down"}
+}
+
+func TestReplayedErrorsKeepTheirKind(t *testing.T) {
+	ctx := context.Background()
+	rec := NewRecorder(failingProvider{}, filepath.Join(t.TempDir(), "c.json"))
+	if _, err := rec.CallStream(ctx, userRequest("hi")); err == nil {
+		t.Fatalf("expected recorded failure")
+	}
+
+	_, err := NewPlayer(rec.Cassette()).CallStream(ctx, userRequest("hi"))
+	if kind := llm.ClassifyError(err); kind != llm.ErrorKindRateLimit {
+		t.Fatalf("expected rate limit error, got %v (%s)", err, kind)
+	}
+}
+
+// scriptedProvider reads a file once, then answers
+type scriptedProvider struct{}
+
+func (scriptedProvider) ID() string { return "scripted" }
+func (p scriptedProvider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	last := req.Messages[len(req.Messages)-1]
+	if last.Role == "tool" {
+		return &llm.ProviderResponse{Model: "scripted-model", Content: "the file says " + last.Content}, nil
+	}
+	return &llm.ProviderResponse{
+		Model:     "scripted-model",
+		ToolCalls: []types.ToolCall{{ID: "call-1", Name: "read_file", Arguments: `{"path":"notes.txt"}`}},
+	}, nil
+}
+func (p scriptedProvider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	resp, _ := p.Call(ctx, req)
+	ch := make(chan llm.StreamChunk, 1)
+	ch <- llm.StreamChunk{Content: resp.Content, ToolCalls: resp.ToolCalls, Usage: &types.Usage{TotalTokens: 10}}
+	close(ch)
+	return ch, nil
+}
+
+// runSession runs a one-message session against provider and returns its final state
+func runSession(t *testing.T, provider llm.Provider) *types.State {
+	t.Helper()
+	ctx := context.Background()
+	fsStore := store.NewFSStore(t.TempDir())
+	if err := fsStore.Open(ctx); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+
+	registry := tool.NewRegistry()
+	if err := registry.Register(types.Tool{Name: "read_file", ReadOnly: true, Parameters: types.JSONSchema{"type": "object"}}); err != nil {
+		t.Fatalf("register tool: %v", err)
+	}
+	executor := tool.NewExecutor(registry, tool.NewPolicy(config.SecurityConfig{AutoApprove: true}, registry, fsStore))
+	executor.RegisterHandler("read_file", func(ctx context.Context, args string) (string, error) {
+		return "remember the milk", nil
+	})
+
+	cfg := runtime.DefaultConfig
+	cfg.MaxSteps = 10
+	cfg.DecisionTimeout = 2 * time.Second
+	cfg.DispatchTimeout = 2 * time.Second
+	gateway := llm.NewGateway(provider, config.ProviderOptions{})
+	rt := runtime.New(cfg, fsStore, gateway, executor, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	msg := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "test"), Content: "what is in notes.txt?"}
+	if err := rt.Ingest(ctx, msg); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	return rt.GetState()
+}
+
+func TestReplayRuntimeSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	recorded := runSession(t, NewRecorder(scriptedProvider{}, path))
+
+	player, err := Open(path)
+	if err != nil {
+		t.Fatalf("open cassette: %v", err)
+	}
+	replayed := runSession(t, player)
+	if err := player.Err(); err != nil {
+		t.Fatalf("replay diverged: %v", err)
+	}
+	if player.Remaining() != 0 {
+		t.Fatalf("expected every interaction replayed, %d left", player.Remaining())
+	}
+
+	want, got := recorded.Context.Messages, replayed.Context.Messages
+	if len(got) != len(want) {
+		t.Fatalf("expected %d messages, got %d", len(want), len(got))
+	}
+	for i := range want {
+		if got[i].Role != want[i].Role || got[i].Content != want[i].Content {
+			t.Fatalf("message %d differs: got %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if last := got[len(got)-1].Content; last != "the file says remember the milk" {
+		t.Fatalf("unexpected final answer %q", last)
+	}
+}
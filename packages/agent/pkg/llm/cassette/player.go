@@ -0,0 +1,243 @@
+package cassette
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// ErrMismatch is returned when a request matches no remaining recorded interaction
+var ErrMismatch = errors.New("cassette: request does not match recording")
+
+// ErrExhausted is returned when every recorded interaction has been served
+var ErrExhausted = errors.New("cassette: no recorded interactions left")
+
+// Player is a provider that serves a cassette's recorded responses offline.
+// Requests are matched on their messages and tool names; the model and
+// sampling options are ignored so a recording survives config changes.
+type Player struct {
+	cassette *Cassette
+	provider string // Only interactions recorded from this provider are served
+	id       string
+	state    *playerState
+}
+
+// playerState is shared by the players of one cassette
+type playerState struct {
+	mu    sync.Mutex
+	used  []bool
+	fails []error
+}
+
+// NewPlayer replays the calls c recorded from the session's primary provider
+func NewPlayer(c *Cassette) *Player {
+	return &Player{
+		cassette: c,
+		provider: c.Provider,
+		id:       "replay",
+		state:    &playerState{used: make([]bool, len(c.Interactions))},
+	}
+}
+
+// For returns a player of the same cassette that replays the calls recorded
+// from providerID, e.g. the provider of a model route or a fallback
+func (p *Player) For(providerID string) *Player {
+	return &Player{cassette: p.cassette, provider: providerID, id: providerID, state: p.state}
+}
+
+// Open loads the cassette at path for replay
+func Open(path string) (*Player, error) {
+	if path == "" {
+		return nil, errors.New("cassette path is required for the replay provider")
+	}
+	c, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewPlayer(c), nil
+}
+
+func (p *Player) ID() string {
+	return p.id
+}
+
+func (p *Player) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	it, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if it.Error != nil {
+		return nil, it.Error.err()
+	}
+	if it.Response != nil {
+		return &llm.ProviderResponse{
+			ID:        it.Response.ID,
+			Model:     it.Response.Model,
+			Content:   it.Response.Content,
+			ToolCalls: it.Response.ToolCalls,
+			Usage:     it.Response.Usage,
+		}, nil
+	}
+
+	// Recorded as a stream: assemble the response from its chunks
+	resp := &llm.ProviderResponse{ID: "replay", Model: req.Model}
+	var content strings.Builder
+	for _, c := range it.Chunks {
+		if c.Error != nil {
+			return nil, c.Error.err()
+		}
+		content.WriteString(c.Content)
+		resp.ToolCalls = append(resp.ToolCalls, c.ToolCalls...)
+		if c.Usage != nil {
+			resp.Usage = *c.Usage
+		}
+	}
+	resp.Content = content.String()
+	return resp, nil
+}
+
+func (p *Player) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	it, err := p.next(req)
+	if err != nil {
+		return nil, err
+	}
+	if it.Error != nil {
+		return nil, it.Error.err()
+	}
+
+	chunks := it.Chunks
+	if !it.Stream && it.Response != nil {
+		// Recorded as a call: serve the response as a single chunk
+		usage := it.Response.Usage
+		chunks = []Chunk{{Content: it.Response.Content, ToolCalls: it.Response.ToolCalls, Usage: &usage}}
+	}
+
+	ch := make(chan llm.StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, c := range chunks {
+			chunk := llm.StreamChunk{Content: c.Content, Reasoning: c.Reasoning, ToolCallDeltas: c.ToolCallDeltas, ToolCalls: c.ToolCalls, Usage: c.Usage}
+			if c.Error != nil {
+				chunk.Err = c.Error.err()
+			}
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// Err reports every mismatch seen so far, so a test fails even if the
+// runtime recovered from the provider error
+func (p *Player) Err() error {
+	s := p.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return errors.Join(s.fails...)
+}
+
+// Remaining returns the number of recorded interactions not yet served
+func (p *Player) Remaining() int {
+	s := p.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	for _, used := range s.used {
+		if !used {
+			n++
+		}
+	}
+	return n
+}
+
+// next claims the first unused interaction of the player's provider matching req.
+// Concurrent sessions may issue calls in a different order than recorded,
+// so any unused interaction may match, not only the next one.
+func (p *Player) next(req *llm.ProviderRequest) (*Interaction, error) {
+	s := p.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	got := newRequest(req)
+	first := -1
+	for i, it := range p.cassette.Interactions {
+		if s.used[i] || !p.serves(it) {
+			continue
+		}
+		if first < 0 {
+			first = i
+		}
+		if diffRequest(got, it.Request) == "" {
+			s.used[i] = true
+			return &p.cassette.Interactions[i], nil
+		}
+	}
+
+	var err error
+	if first < 0 {
+		err = fmt.Errorf("%w (%d served)", ErrExhausted, len(s.used))
+	} else {
+		err = fmt.Errorf("%w: interaction %d: %s", ErrMismatch, first, diffRequest(got, p.cassette.Interactions[first].Request))
+	}
+	slog.Error("cassette replay failed", "error", err)
+	s.fails = append(s.fails, err)
+	return nil, err
+}
+
+// serves reports whether it was recorded from the player's provider.
+// Interactions without a provider come from the primary.
+func (p *Player) serves(it Interaction) bool {
+	provider := it.Provider
+	if provider == "" {
+		provider = p.cassette.Provider
+	}
+	return provider == p.provider
+}
+
+// diffRequest describes the first difference between two requests, or returns ""
+func diffRequest(got, want Request) string {
+	if g, w := strings.Join(got.Tools, ","), strings.Join(want.Tools, ","); g != w {
+		return fmt.Sprintf("tools differ: got [%s], want [%s]", g, w)
+	}
+	n := min(len(got.Messages), len(want.Messages))
+	for i := 0; i < n; i++ {
+		if d := diffMessage(got.Messages[i], want.Messages[i]); d != "" {
+			return fmt.Sprintf("message %d: %s", i, d)
+		}
+	}
+	if len(got.Messages) != len(want.Messages) {
+		return fmt.Sprintf("got %d messages, want %d", len(got.Messages), len(want.Messages))
+	}
+	return ""
+}
+
+func diffMessage(got, want types.Message) string {
+	switch {
+	case got.Role != want.Role:
+		return fmt.Sprintf("role: got %q, want %q", got.Role, want.Role)
+	case got.Content != want.Content:
+		return fmt.Sprintf("content: got %q, want %q", got.Content, want.Content)
+	case got.ToolCallID != want.ToolCallID:
+		return fmt.Sprintf("tool_call_id: got %q, want %q", got.ToolCallID, want.ToolCallID)
+	case got.ToolName != want.ToolName:
+		return fmt.Sprintf("tool_name: got %q, want %q", got.ToolName, want.ToolName)
+	case len(got.ToolCalls) != len(want.ToolCalls):
+		return fmt.Sprintf("got %d tool calls, want %d", len(got.ToolCalls), len(want.ToolCalls))
+	}
+	for i, tc := range got.ToolCalls {
+		w := want.ToolCalls[i]
+		if tc.ID != w.ID || tc.Name != w.Name || tc.Arguments != w.Arguments {
+			return fmt.Sprintf("tool call %d: got %s(%s) %s, want %s(%s) %s", i, tc.Name, tc.Arguments, tc.ID, w.Name, w.Arguments, w.ID)
+		}
+	}
+	return ""
+}
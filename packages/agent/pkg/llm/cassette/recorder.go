@@ -0,0 +1,120 @@
+package cassette
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+)
+
+// Recorder wraps a provider and records every call to a cassette file.
+// The file is rewritten after each completed call, so a crashed session
+// still leaves a usable recording.
+type Recorder struct {
+	provider llm.Provider
+	tape     *tape
+}
+
+// tape is the cassette shared by the recorders of one session
+type tape struct {
+	path string
+
+	mu       sync.Mutex
+	cassette *Cassette
+}
+
+// NewRecorder records the calls made to provider into the cassette at path
+func NewRecorder(provider llm.Provider, path string) *Recorder {
+	return &Recorder{
+		provider: provider,
+		tape: &tape{
+			path:     path,
+			cassette: &Cassette{Provider: provider.ID(), RecordedAt: time.Now()},
+		},
+	}
+}
+
+// Wrap records the calls made to another provider of the session, e.g. one
+// named by a model route or a fallback, into the same cassette
+func (r *Recorder) Wrap(provider llm.Provider) *Recorder {
+	return &Recorder{provider: provider, tape: r.tape}
+}
+
+// ID returns the wrapped provider's ID, so pricing and logs are unchanged
+func (r *Recorder) ID() string {
+	return r.provider.ID()
+}
+
+func (r *Recorder) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	it := Interaction{Provider: r.provider.ID(), Request: newRequest(req)}
+	resp, err := r.provider.Call(ctx, req)
+	if err != nil {
+		it.Error = newError(err)
+	} else {
+		it.Response = newResponse(resp)
+	}
+	r.record(it)
+	return resp, err
+}
+
+func (r *Recorder) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	it := Interaction{Provider: r.provider.ID(), Request: newRequest(req), Stream: true}
+	stream, err := r.provider.CallStream(ctx, req)
+	if err != nil {
+		it.Error = newError(err)
+		r.record(it)
+		return nil, err
+	}
+
+	out := make(chan llm.StreamChunk)
+	go func() {
+		defer close(out)
+		for chunk := range stream {
+			c := Chunk{
+				Content:        chunk.Content,
+				Reasoning:      chunk.Reasoning,
+				ToolCallDeltas: chunk.ToolCallDeltas,
+				ToolCalls:      chunk.ToolCalls,
+				Usage:          chunk.Usage,
+			}
+			if chunk.Err != nil {
+				c.Error = newError(chunk.Err)
+			}
+			it.Chunks = append(it.Chunks, c)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// Drain the provider so it can finish; the partial stream is still recorded
+				for range stream {
+				}
+				r.record(it)
+				return
+			}
+		}
+		r.record(it)
+	}()
+	return out, nil
+}
+
+// Cassette returns the interactions recorded so far by every provider of the session
+func (r *Recorder) Cassette() *Cassette {
+	t := r.tape
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := *t.cassette
+	c.Interactions = append([]Interaction(nil), t.cassette.Interactions...)
+	return &c
+}
+
+func (r *Recorder) record(it Interaction) {
+	t := r.tape
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, it)
+	// Recording must never break the session it records
+	if err := t.cassette.Save(t.path); err != nil {
+		slog.Warn("failed to save cassette", "path", t.path, "error", err)
+	}
+}
@@ -0,0 +1,131 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrorKind classifies provider failures so the gateway can decide whether to retry
+type ErrorKind string
+
+const (
+	ErrorKindRateLimit      ErrorKind = "rate_limit"      // 429, quota exhausted
+	ErrorKindServer         ErrorKind = "server"          // 5xx, overloaded
+	ErrorKindNetwork        ErrorKind = "network"         // Connection reset, timeout, EOF
+	ErrorKindContextLength  ErrorKind = "context_length"  // Prompt exceeds the model's context window
+	ErrorKindAuth           ErrorKind = "auth"            // 401/403, bad API key
+	ErrorKindInvalidRequest ErrorKind = "invalid_request" // Other 4xx
+	ErrorKindUnknown        ErrorKind = "unknown"
+)
+
+// Retryable reports whether retrying the same provider may succeed
+func (k ErrorKind) Retryable() bool {
+	switch k {
+	case ErrorKindRateLimit, ErrorKindServer, ErrorKindNetwork:
+		return true
+	default:
+		return false
+	}
+}
+
+// ProviderError is a provider failure annotated with its HTTP status and retry hint.
+// Providers wrap SDK errors in it so failures can be classified uniformly.
+type ProviderError struct {
+	Provider   string
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header, if present
+	Message    string
+	Err        error
+}
+
+func (e *ProviderError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Provider, e.Err)
+	}
+	return fmt.Sprintf("%s api error (status %d): %s", e.Provider, e.StatusCode, e.Message)
+}
+
+func (e *ProviderError) Unwrap() error { return e.Err }
+
+// contextLengthMarkers are substrings providers use to report oversized prompts
+var contextLengthMarkers = []string{
+	"context_length_exceeded",
+	"maximum context length",
+	"context window",
+	"prompt is too long",
+	"too many tokens",
+	"input token count",
+}
+
+// ClassifyError determines the kind of a provider failure
+func ClassifyError(err error) ErrorKind {
+	if err == nil {
+		return ErrorKindUnknown
+	}
+
+	msg := strings.ToLower(err.Error())
+	for _, marker := range contextLengthMarkers {
+		if strings.Contains(msg, marker) {
+			return ErrorKindContextLength
+		}
+	}
+
+	var pe *ProviderError
+	if errors.As(err, &pe) && pe.StatusCode != 0 {
+		switch {
+		case pe.StatusCode == http.StatusTooManyRequests:
+			return ErrorKindRateLimit
+		case pe.StatusCode == http.StatusUnauthorized || pe.StatusCode == http.StatusForbidden:
+			return ErrorKindAuth
+		case pe.StatusCode == http.StatusRequestTimeout:
+			return ErrorKindNetwork
+		case pe.StatusCode >= 500:
+			return ErrorKindServer // Includes Anthropic's 529 overloaded
+		case pe.StatusCode >= 400:
+			return ErrorKindInvalidRequest
+		}
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, context.DeadlineExceeded) {
+		return ErrorKindNetwork
+	}
+	if strings.Contains(msg, "connection reset") || strings.Contains(msg, "connection refused") {
+		return ErrorKindNetwork
+	}
+
+	return ErrorKindUnknown
+}
+
+// RetryAfterHint returns the provider-requested delay before retrying, or 0
+func RetryAfterHint(err error) time.Duration {
+	var pe *ProviderError
+	if errors.As(err, &pe) {
+		return pe.RetryAfter
+	}
+	return 0
+}
+
+// ParseRetryAfter parses a Retry-After header (delay in seconds or an HTTP date)
+func ParseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+		return time.Duration(secs * float64(time.Second))
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
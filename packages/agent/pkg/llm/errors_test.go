@@ -0,0 +1,45 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want ErrorKind
+	}{
+		{&ProviderError{StatusCode: 429}, ErrorKindRateLimit},
+		{&ProviderError{StatusCode: 529, Message: "overloaded"}, ErrorKindServer},
+		{&ProviderError{StatusCode: 503}, ErrorKindServer},
+		{&ProviderError{StatusCode: 401}, ErrorKindAuth},
+		{&ProviderError{StatusCode: 400, Message: "This model's maximum context length is 128000 tokens"}, ErrorKindContextLength},
+		{&ProviderError{StatusCode: 400, Message: "bad field"}, ErrorKindInvalidRequest},
+		{fmt.Errorf("create stream: %w", &ProviderError{StatusCode: 500}), ErrorKindServer},
+		{context.DeadlineExceeded, ErrorKindNetwork},
+		{errors.New("read: connection reset by peer"), ErrorKindNetwork},
+		{errors.New("something odd"), ErrorKindUnknown},
+	}
+	for _, c := range cases {
+		if got := ClassifyError(c.err); got != c.want {
+			t.Errorf("ClassifyError(%v) = %s, want %s", c.err, got, c.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	if d := ParseRetryAfter("3"); d != 3*time.Second {
+		t.Fatalf("expected 3s, got %v", d)
+	}
+	future := time.Now().Add(10 * time.Second).UTC().Format("Mon, 02 Jan 2006 15:04:05 GMT")
+	if d := ParseRetryAfter(future); d <= 0 || d > 10*time.Second {
+		t.Fatalf("expected delay from HTTP date, got %v", d)
+	}
+	if d := ParseRetryAfter("garbage"); d != 0 {
+		t.Fatalf("expected 0 for invalid value, got %v", d)
+	}
+}
@@ -3,9 +3,12 @@ package factory
 import (
 	"context"
 	"fmt"
+	"strings"
 
 	"github.com/gm-agent-org/gm-agent/pkg/config"
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/llm/anthropic"
+	"github.com/gm-agent-org/gm-agent/pkg/llm/cassette"
 	"github.com/gm-agent-org/gm-agent/pkg/llm/gemini"
 	"github.com/gm-agent-org/gm-agent/pkg/llm/mock"
 	"github.com/gm-agent-org/gm-agent/pkg/llm/openai"
@@ -24,9 +27,58 @@ func NewProvider(ctx context.Context, cfg *config.Config) (llm.Provider, string,
 		return nil, "", err
 	}
 
+	// Record the session's traffic so it can be replayed offline
+	if cfg.Cassette.Record && providerID != "replay" {
+		if cfg.Cassette.Path == "" {
+			return nil, "", fmt.Errorf("cassette recording requires a cassette path")
+		}
+		provider = cassette.NewRecorder(provider, cfg.Cassette.Path)
+	}
+
 	return provider, providerID, nil
 }
 
+// NewProviderFor creates the provider of providerID, e.g. one named by a model route.
+// primary is the session's provider from NewProvider: when it records or
+// replays a cassette, so does the new provider.
+func NewProviderFor(ctx context.Context, cfg *config.Config, providerID string, primary llm.Provider) (llm.Provider, config.ProviderOptions, error) {
+	opts, err := resolveProvider(cfg, providerID, primary)
+	if err != nil {
+		return nil, config.ProviderOptions{}, err
+	}
+	provider, err := createProviderLike(ctx, primary, providerID, opts)
+	if err != nil {
+		return nil, config.ProviderOptions{}, err
+	}
+	return provider, opts, nil
+}
+
+// resolveProvider returns the options of providerID. A replayed provider
+// needs no credentials, so it falls back to the provider's defaults.
+func resolveProvider(cfg *config.Config, providerID string, primary llm.Provider) (config.ProviderOptions, error) {
+	opts, err := cfg.ResolveProvider(providerID)
+	if _, replay := primary.(*cassette.Player); err != nil && replay {
+		return config.ProviderDefaults[providerID], nil
+	}
+	return opts, err
+}
+
+// createProviderLike creates a provider that shares primary's cassette: it is
+// served from the recording during replay, and recorded into it while recording.
+func createProviderLike(ctx context.Context, primary llm.Provider, providerID string, opts config.ProviderOptions) (llm.Provider, error) {
+	if player, ok := primary.(*cassette.Player); ok {
+		return player.For(providerID), nil
+	}
+	provider, err := createProvider(ctx, providerID, opts)
+	if err != nil {
+		return nil, err
+	}
+	if recorder, ok := primary.(*cassette.Recorder); ok {
+		return recorder.Wrap(provider), nil
+	}
+	return provider, nil
+}
+
 // createProvider instantiates a provider based on its ID.
 func createProvider(ctx context.Context, providerID string, opts config.ProviderOptions) (llm.Provider, error) {
 	switch providerID {
@@ -43,11 +95,51 @@ func createProvider(ctx context.Context, providerID string, opts config.Provider
 			BaseURL: opts.BaseURL,
 		}), nil
 	case "anthropic":
-		// TODO: Implement Anthropic provider
-		return nil, fmt.Errorf("anthropic provider not yet implemented")
+		return anthropic.New(anthropic.Config{
+			APIKey:  opts.APIKey,
+			BaseURL: opts.BaseURL,
+		}), nil
 	case "mock":
+		if opts.Scenario != "" {
+			scenario, err := mock.LoadScenario(opts.Scenario)
+			if err != nil {
+				return nil, err
+			}
+			return mock.NewScenario(scenario), nil
+		}
 		return mock.New(opts.Model), nil
+	case "replay":
+		return cassette.Open(opts.Cassette)
 	default:
 		return nil, fmt.Errorf("unknown provider: %s", providerID)
 	}
 }
+
+// NewFallbacks creates the fallback chain declared in cfg.Fallbacks.
+// Entries are "provider" or "provider:model"; the model may itself contain colons.
+// Like NewProviderFor, the fallbacks share primary's cassette.
+func NewFallbacks(ctx context.Context, cfg *config.Config, primary llm.Provider) ([]llm.Fallback, error) {
+	var fallbacks []llm.Fallback
+	for _, entry := range cfg.Fallbacks {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		providerID, model, _ := strings.Cut(entry, ":")
+
+		opts, err := resolveProvider(cfg, providerID, primary)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %q: %w", entry, err)
+		}
+		if model != "" {
+			opts.Model = model
+		}
+
+		provider, err := createProviderLike(ctx, primary, providerID, opts)
+		if err != nil {
+			return nil, fmt.Errorf("fallback %q: %w", entry, err)
+		}
+		fallbacks = append(fallbacks, llm.Fallback{Provider: provider, Options: opts})
+	}
+	return fallbacks, nil
+}
@@ -2,10 +2,16 @@ package factory
 
 import (
 	"context"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/llm/cassette"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
 
 func TestNewProviderSelectsOpenAI(t *testing.T) {
@@ -55,3 +61,143 @@ func TestNewProviderAutoDetects(t *testing.T) {
 		t.Fatalf("expected providerID 'gemini', got %s", providerID)
 	}
 }
+
+func TestNewProviderSelectsAnthropic(t *testing.T) {
+	cfg := &config.Config{
+		ActiveProvider: "anthropic",
+		Providers: map[string]config.ProviderConfig{
+			"anthropic": {
+				Options: config.ProviderOptions{
+					APIKey: "test-key",
+				},
+			},
+		},
+	}
+	provider, providerID, err := NewProvider(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected provider, got error %v", err)
+	}
+	if provider.ID() != "anthropic" || providerID != "anthropic" {
+		t.Fatalf("expected anthropic provider, got %s (%s)", provider.ID(), providerID)
+	}
+}
+
+func TestNewFallbacks(t *testing.T) {
+	cfg := &config.Config{
+		Providers: map[string]config.ProviderConfig{
+			"anthropic": {Options: config.ProviderOptions{APIKey: "a-key"}},
+		},
+		Fallbacks: []string{"anthropic:claude-3-5-haiku-latest", "mock:llama3:8b"},
+	}
+	fallbacks, err := NewFallbacks(context.Background(), cfg, nil)
+	if err != nil {
+		t.Fatalf("expected fallbacks, got error %v", err)
+	}
+	if len(fallbacks) != 2 {
+		t.Fatalf("expected 2 fallbacks, got %d", len(fallbacks))
+	}
+	if fallbacks[0].Provider.ID() != "anthropic" || fallbacks[0].Options.Model != "claude-3-5-haiku-latest" {
+		t.Fatalf("unexpected first fallback: %s %+v", fallbacks[0].Provider.ID(), fallbacks[0].Options)
+	}
+	if fallbacks[1].Options.Model != "llama3:8b" {
+		t.Fatalf("expected model to keep its colon, got %q", fallbacks[1].Options.Model)
+	}
+
+	cfg.Fallbacks = []string{"unknown-provider"}
+	if _, err := NewFallbacks(context.Background(), cfg, nil); err == nil {
+		t.Fatalf("expected error for unconfigured fallback")
+	}
+}
+
+func TestNewProviderRecordsAndReplays(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.json")
+	cfg := &config.Config{
+		ActiveProvider: "mock",
+		Cassette:       config.CassetteConfig{Path: path, Record: true},
+	}
+	provider, _, err := NewProvider(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected recording provider, got error %v", err)
+	}
+	if _, ok := provider.(*cassette.Recorder); !ok || provider.ID() != "mock" {
+		t.Fatalf("expected mock wrapped in a recorder, got %T (%s)", provider, provider.ID())
+	}
+	if err := (&cassette.Cassette{Provider: "mock"}).Save(path); err != nil {
+		t.Fatalf("save cassette: %v", err)
+	}
+
+	cfg = &config.Config{ActiveProvider: "replay", Cassette: config.CassetteConfig{Path: path}}
+	provider, providerID, err := NewProvider(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("expected replay provider, got error %v", err)
+	}
+	if provider.ID() != "replay" || providerID != "replay" {
+		t.Fatalf("expected replay provider, got %s (%s)", provider.ID(), providerID)
+	}
+
+	cfg.Cassette.Path = ""
+	if _, _, err := NewProvider(context.Background(), cfg); err == nil {
+		t.Fatalf("expected error for replay without a cassette")
+	}
+}
+
+func TestRoutedProviderRecordsAndReplays(t *testing.T) {
+	ctx := context.Background()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"1","model":"gpt-4o","choices":[{"index":0,"message":{"role":"assistant","content":"from openai"},"finish_reason":"stop"}]}`))
+	}))
+	path := filepath.Join(t.TempDir(), "session.json")
+	cfg := &config.Config{
+		ActiveProvider: "mock",
+		Providers: map[string]config.ProviderConfig{
+			"openai": {Options: config.ProviderOptions{APIKey: "k", BaseURL: srv.URL}},
+		},
+		Cassette: config.CassetteConfig{Path: path, Record: true},
+	}
+	request := func(content string) *llm.ProviderRequest {
+		return &llm.ProviderRequest{Model: "m", Messages: []types.Message{{Role: "user", Content: content}}}
+	}
+
+	primary, _, err := NewProvider(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new provider: %v", err)
+	}
+	routed, _, err := NewProviderFor(ctx, cfg, "openai", primary)
+	if err != nil {
+		t.Fatalf("new routed provider: %v", err)
+	}
+	if _, err := primary.Call(ctx, request("primary")); err != nil {
+		t.Fatalf("primary call: %v", err)
+	}
+	if resp, err := routed.Call(ctx, request("routed")); err != nil || resp.Content != "from openai" {
+		t.Fatalf("routed call: %v, %v", resp, err)
+	}
+	srv.Close()
+
+	// Replay serves each provider its own calls without credentials or network
+	cfg = &config.Config{ActiveProvider: "replay", Cassette: config.CassetteConfig{Path: path}}
+	primary, _, err = NewProvider(ctx, cfg)
+	if err != nil {
+		t.Fatalf("new replay provider: %v", err)
+	}
+	routed, _, err = NewProviderFor(ctx, cfg, "openai", primary)
+	if err != nil {
+		t.Fatalf("new replayed routed provider: %v", err)
+	}
+	if routed.ID() != "openai" {
+		t.Fatalf("expected the replayed route to keep its provider ID, got %s", routed.ID())
+	}
+	if _, err := routed.Call(ctx, request("primary")); err == nil {
+		t.Fatalf("expected the routed provider not to serve the primary's call")
+	}
+	if resp, err := routed.Call(ctx, request("routed")); err != nil || resp.Content != "from openai" {
+		t.Fatalf("replayed routed call: %v, %v", resp, err)
+	}
+	if resp, err := primary.Call(ctx, request("primary")); err != nil || resp.Content != "Mock response to: primary" {
+		t.Fatalf("replayed primary call: %v, %v", resp, err)
+	}
+	if remaining := primary.(*cassette.Player).Remaining(); remaining != 0 {
+		t.Fatalf("expected every call served, %d left", remaining)
+	}
+}
@@ -2,13 +2,83 @@ package llm
 
 import (
 	"context"
+	"math/rand"
+	"time"
 
 	"github.com/gm-agent-org/gm-agent/pkg/config"
 )
 
+// RetryPolicy controls how the gateway retries a failing provider
+type RetryPolicy struct {
+	MaxAttempts    int           // Attempts per provider, including the first
+	InitialBackoff time.Duration // Delay before the first retry
+	MaxBackoff     time.Duration // Upper bound for computed delays
+}
+
+// DefaultRetryPolicy is used when no retry configuration is given
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts:    3,
+	InitialBackoff: 500 * time.Millisecond,
+	MaxBackoff:     30 * time.Second,
+}
+
+// RetryPolicyFromConfig converts config values (ms) to a RetryPolicy, using defaults for unset fields
+func RetryPolicyFromConfig(cfg config.RetryConfig) RetryPolicy {
+	p := DefaultRetryPolicy
+	if cfg.MaxAttempts > 0 {
+		p.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.InitialBackoff > 0 {
+		p.InitialBackoff = time.Duration(cfg.InitialBackoff) * time.Millisecond
+	}
+	if cfg.MaxBackoff > 0 {
+		p.MaxBackoff = time.Duration(cfg.MaxBackoff) * time.Millisecond
+	}
+	return p
+}
+
+// Fallback is an alternative provider tried, in order, when the primary keeps failing
+type Fallback struct {
+	Provider Provider
+	Options  config.ProviderOptions // Options.Model overrides the requested model
+}
+
+// RetryEvent describes a retry or fallback performed by the gateway
+type RetryEvent struct {
+	Action       string // "retry" or "fallback"
+	Provider     string
+	Model        string
+	Attempt      int
+	Kind         ErrorKind
+	Err          error
+	Delay        time.Duration // Wait before the retry (retry only)
+	NextProvider string        // Target of the fallback (fallback only)
+	NextModel    string
+}
+
+// RetryObserver is notified of every retry and fallback
+type RetryObserver func(RetryEvent)
+
+type retryObserverKey struct{}
+
+// WithRetryObserver returns a context that reports gateway retries to fn
+func WithRetryObserver(ctx context.Context, fn RetryObserver) context.Context {
+	return context.WithValue(ctx, retryObserverKey{}, fn)
+}
+
+func notifyRetry(ctx context.Context, evt RetryEvent) {
+	if fn, ok := ctx.Value(retryObserverKey{}).(RetryObserver); ok && fn != nil {
+		fn(evt)
+	}
+}
+
 type Gateway struct {
-	provider Provider
-	options  config.ProviderOptions
+	provider  Provider
+	options   config.ProviderOptions
+	fallbacks []Fallback
+	retry     RetryPolicy
+
+	sleep func(ctx context.Context, d time.Duration) error // Overridable in tests
 }
 
 func NewGateway(provider Provider, opts config.ProviderOptions) *Gateway {
@@ -18,20 +88,37 @@ func NewGateway(provider Provider, opts config.ProviderOptions) *Gateway {
 	return &Gateway{
 		provider: provider,
 		options:  opts,
+		retry:    DefaultRetryPolicy,
+		sleep:    sleepContext,
 	}
 }
 
-func (g *Gateway) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
-	// Map Runtime Request to Provider Request
-	provReq := &ProviderRequest{
-		Model:       req.Model,
-		Messages:    req.Messages, // Assuming shared types.Message
-		Tools:       req.Tools,    // Assuming shared types.Tool
-		MaxTokens:   g.options.MaxTokens,
-		Temperature: g.options.Temperature,
+// SetRetryPolicy replaces the retry policy
+func (g *Gateway) SetRetryPolicy(p RetryPolicy) {
+	if p.MaxAttempts < 1 {
+		p.MaxAttempts = 1
+	}
+	g.retry = p
+}
+
+// AddFallback appends a provider to the fallback chain
+func (g *Gateway) AddFallback(provider Provider, opts config.ProviderOptions) {
+	if opts.Temperature == 0 {
+		opts.Temperature = g.options.Temperature
+	}
+	if opts.MaxTokens == 0 {
+		opts.MaxTokens = g.options.MaxTokens
 	}
+	g.fallbacks = append(g.fallbacks, Fallback{Provider: provider, Options: opts})
+}
 
-	resp, err := g.provider.Call(ctx, provReq)
+func (g *Gateway) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	var resp *ProviderResponse
+	err := g.withRetry(ctx, req, func(p Provider, provReq *ProviderRequest) error {
+		var err error
+		resp, err = p.Call(ctx, provReq)
+		return err
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -44,14 +131,171 @@ func (g *Gateway) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, er
 		Usage:     resp.Usage,
 	}, nil
 }
+
+// StreamChat retries and falls back while establishing the stream, including
+// when its first chunk is an error. Failures after that are not retried; they
+// reach the caller as a chunk with Err set.
 func (g *Gateway) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
-	provReq := &ProviderRequest{
-		Model:       req.Model,
-		Messages:    req.Messages,
-		Tools:       req.Tools,
-		MaxTokens:   g.options.MaxTokens,
-		Temperature: g.options.Temperature,
+	var stream <-chan StreamChunk
+	err := g.withRetry(ctx, req, func(p Provider, provReq *ProviderRequest) error {
+		s, err := p.CallStream(ctx, provReq)
+		if err != nil {
+			return err
+		}
+		first, ok := <-s
+		if ok && first.Err != nil {
+			return first.Err
+		}
+		stream = prependChunk(ctx, first, ok, s)
+		return nil
+	})
+	return stream, err
+}
+
+// prependChunk returns a stream of first, if ok, followed by the rest
+func prependChunk(ctx context.Context, first StreamChunk, ok bool, rest <-chan StreamChunk) <-chan StreamChunk {
+	ch := make(chan StreamChunk)
+	go func() {
+		defer close(ch)
+		if !ok {
+			return
+		}
+		select {
+		case ch <- first:
+		case <-ctx.Done():
+			return
+		}
+		for chunk := range rest {
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch
+}
+
+// target is one provider in the chain with the request mapped for it
+type target struct {
+	provider Provider
+	request  *ProviderRequest
+}
+
+func (g *Gateway) targets(req *ChatRequest) []target {
+	targets := []target{{
+		provider: g.provider,
+		request: &ProviderRequest{
+			Model:       req.Model,
+			Messages:    req.Messages, // Assuming shared types.Message
+			Tools:       req.Tools,    // Assuming shared types.Tool
+			MaxTokens:   g.options.MaxTokens,
+			Temperature: g.options.Temperature,
+		},
+	}}
+	for _, fb := range g.fallbacks {
+		model := fb.Options.Model
+		if model == "" {
+			model = req.Model
+		}
+		targets = append(targets, target{
+			provider: fb.Provider,
+			request: &ProviderRequest{
+				Model:       model,
+				Messages:    req.Messages,
+				Tools:       req.Tools,
+				MaxTokens:   fb.Options.MaxTokens,
+				Temperature: fb.Options.Temperature,
+			},
+		})
+	}
+	return targets
+}
+
+// withRetry runs call against each target in order, retrying retryable errors with backoff.
+// It returns the last error if every target fails.
+func (g *Gateway) withRetry(ctx context.Context, req *ChatRequest, call func(Provider, *ProviderRequest) error) error {
+	targets := g.targets(req)
+
+	var lastErr error
+	for i, t := range targets {
+		for attempt := 1; ; attempt++ {
+			err := call(t.provider, t.request)
+			if err == nil {
+				return nil
+			}
+			lastErr = err
+			if ctx.Err() != nil {
+				return err
+			}
+
+			kind := ClassifyError(err)
+			if !kind.Retryable() || attempt >= g.retry.MaxAttempts {
+				break
+			}
+
+			delay := g.backoff(ctx, attempt, err)
+			notifyRetry(ctx, RetryEvent{
+				Action:   "retry",
+				Provider: t.provider.ID(),
+				Model:    t.request.Model,
+				Attempt:  attempt,
+				Kind:     kind,
+				Err:      err,
+				Delay:    delay,
+			})
+			if err := g.sleep(ctx, delay); err != nil {
+				return lastErr
+			}
+		}
+
+		if i+1 < len(targets) {
+			next := targets[i+1]
+			notifyRetry(ctx, RetryEvent{
+				Action:       "fallback",
+				Provider:     t.provider.ID(),
+				Model:        t.request.Model,
+				Kind:         ClassifyError(lastErr),
+				Err:          lastErr,
+				NextProvider: next.provider.ID(),
+				NextModel:    next.request.Model,
+			})
+		}
 	}
+	return lastErr
+}
 
-	return g.provider.CallStream(ctx, provReq)
+// backoff returns the delay before retry number attempt.
+// A Retry-After hint from the provider takes precedence over the jittered
+// exponential delay. Either is capped by MaxBackoff and by the time left
+// before the ctx deadline.
+func (g *Gateway) backoff(ctx context.Context, attempt int, err error) time.Duration {
+	d := RetryAfterHint(err)
+	if d <= 0 {
+		d = g.retry.InitialBackoff << (attempt - 1)
+		if d <= 0 || d > g.retry.MaxBackoff {
+			d = g.retry.MaxBackoff
+		}
+		// Equal jitter: half fixed, half random
+		half := d / 2
+		d = half + time.Duration(rand.Int63n(int64(half)+1))
+	}
+	if g.retry.MaxBackoff > 0 {
+		d = min(d, g.retry.MaxBackoff)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		d = max(min(d, time.Until(deadline)), 0)
+	}
+	return d
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
 }
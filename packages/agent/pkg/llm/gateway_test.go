@@ -2,7 +2,9 @@ package llm
 
 import (
 	"context"
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/gm-agent-org/gm-agent/pkg/config"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
@@ -41,3 +43,200 @@ func TestGatewayChat(t *testing.T) {
 		t.Fatalf("expected usage to propagate")
 	}
 }
+
+// flakyProvider fails with the queued errors before succeeding
+type flakyProvider struct {
+	id     string
+	errs   []error
+	models []string
+}
+
+func (p *flakyProvider) ID() string { return p.id }
+
+func (p *flakyProvider) Call(ctx context.Context, req *ProviderRequest) (*ProviderResponse, error) {
+	p.models = append(p.models, req.Model)
+	if len(p.errs) > 0 {
+		err := p.errs[0]
+		p.errs = p.errs[1:]
+		return nil, err
+	}
+	return &ProviderResponse{Model: req.Model, Content: p.id}, nil
+}
+
+func (p *flakyProvider) CallStream(ctx context.Context, req *ProviderRequest) (<-chan StreamChunk, error) {
+	if _, err := p.Call(ctx, req); err != nil {
+		return nil, err
+	}
+	ch := make(chan StreamChunk, 1)
+	ch <- StreamChunk{Content: p.id}
+	close(ch)
+	return ch, nil
+}
+
+func newTestGateway(p Provider) (*Gateway, *[]time.Duration) {
+	gw := NewGateway(p, config.ProviderOptions{})
+	var slept []time.Duration
+	gw.sleep = func(ctx context.Context, d time.Duration) error {
+		slept = append(slept, d)
+		return nil
+	}
+	return gw, &slept
+}
+
+func TestGatewayRetriesRetryableErrors(t *testing.T) {
+	primary := &flakyProvider{id: "primary", errs: []error{
+		&ProviderError{StatusCode: 503},
+		&ProviderError{StatusCode: 429, RetryAfter: 7 * time.Second},
+	}}
+	gw, slept := newTestGateway(primary)
+
+	var events []RetryEvent
+	ctx := WithRetryObserver(context.Background(), func(e RetryEvent) { events = append(events, e) })
+
+	resp, err := gw.Chat(ctx, &ChatRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("chat error: %v", err)
+	}
+	if resp.Content != "primary" || len(primary.models) != 3 {
+		t.Fatalf("expected success on third attempt, got %+v after %d calls", resp, len(primary.models))
+	}
+	if len(events) != 2 || events[0].Action != "retry" || events[0].Kind != ErrorKindServer || events[1].Kind != ErrorKindRateLimit {
+		t.Fatalf("unexpected retry events: %+v", events)
+	}
+	if (*slept)[0] < DefaultRetryPolicy.InitialBackoff/2 || (*slept)[0] > DefaultRetryPolicy.InitialBackoff {
+		t.Fatalf("expected jittered initial backoff, got %v", (*slept)[0])
+	}
+	if (*slept)[1] != 7*time.Second {
+		t.Fatalf("expected Retry-After to be honored, got %v", (*slept)[1])
+	}
+}
+
+func TestGatewayFallsBackInOrder(t *testing.T) {
+	primary := &flakyProvider{id: "primary", errs: []error{&ProviderError{StatusCode: 401}}}
+	second := &flakyProvider{id: "second", errs: []error{
+		&ProviderError{StatusCode: 500}, &ProviderError{StatusCode: 500},
+	}}
+	third := &flakyProvider{id: "third"}
+
+	gw, _ := newTestGateway(primary)
+	gw.SetRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	gw.AddFallback(second, config.ProviderOptions{Model: "second-model"})
+	gw.AddFallback(third, config.ProviderOptions{})
+
+	var actions []string
+	ctx := WithRetryObserver(context.Background(), func(e RetryEvent) { actions = append(actions, e.Action+":"+e.Provider) })
+
+	stream, err := gw.StreamChat(ctx, &ChatRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	if chunk := <-stream; chunk.Content != "third" {
+		t.Fatalf("expected third provider to serve the request, got %q", chunk.Content)
+	}
+
+	// Auth errors are not retried; server errors are retried up to MaxAttempts
+	if len(primary.models) != 1 || len(second.models) != 2 {
+		t.Fatalf("unexpected attempts: primary=%d second=%d", len(primary.models), len(second.models))
+	}
+	if second.models[0] != "second-model" || third.models[0] != "m" {
+		t.Fatalf("expected fallback model override, got %v %v", second.models, third.models)
+	}
+	want := []string{"fallback:primary", "retry:second", "fallback:second"}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Fatalf("expected %v, got %v", want, actions)
+	}
+}
+
+// brokenStreamProvider opens streams whose only chunk is the queued error, then succeeds
+type brokenStreamProvider struct {
+	flakyProvider
+	streamErrs []error
+}
+
+func (p *brokenStreamProvider) CallStream(ctx context.Context, req *ProviderRequest) (<-chan StreamChunk, error) {
+	if len(p.streamErrs) == 0 {
+		return p.flakyProvider.CallStream(ctx, req)
+	}
+	p.models = append(p.models, req.Model)
+	ch := make(chan StreamChunk, 1)
+	ch <- StreamChunk{Err: p.streamErrs[0]}
+	close(ch)
+	p.streamErrs = p.streamErrs[1:]
+	return ch, nil
+}
+
+func TestGatewayRetriesStreamsFailingBeforeTheFirstChunk(t *testing.T) {
+	primary := &brokenStreamProvider{flakyProvider: flakyProvider{id: "primary"}, streamErrs: []error{&ProviderError{StatusCode: 529}}}
+	gw, _ := newTestGateway(primary)
+
+	stream, err := gw.StreamChat(context.Background(), &ChatRequest{Model: "m"})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	var chunks []StreamChunk
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	if len(chunks) != 1 || chunks[0].Content != "primary" || chunks[0].Err != nil || len(primary.models) != 2 {
+		t.Fatalf("expected the overloaded stream to be retried, got %+v after %d calls", chunks, len(primary.models))
+	}
+}
+
+func TestGatewayReturnsLastErrorWhenAllFail(t *testing.T) {
+	primary := &flakyProvider{id: "primary", errs: []error{&ProviderError{StatusCode: 400, Message: "bad"}}}
+	gw, _ := newTestGateway(primary)
+	_, err := gw.Chat(context.Background(), &ChatRequest{Model: "m"})
+	if ClassifyError(err) != ErrorKindInvalidRequest {
+		t.Fatalf("expected invalid request error, got %v", err)
+	}
+}
+
+func TestRouterPicksGatewayByProvider(t *testing.T) {
+	primary := &flakyProvider{id: "primary"}
+	secondary := &flakyProvider{id: "secondary"}
+	router := NewRouter("primary", NewGateway(primary, config.ProviderOptions{Model: "big"}))
+	router.Add("secondary", NewGateway(secondary, config.ProviderOptions{Model: "small"}))
+
+	tests := []struct {
+		req     ChatRequest
+		content string
+		model   string
+	}{
+		{ChatRequest{Model: "big"}, "primary", "big"},
+		{ChatRequest{Provider: "secondary"}, "secondary", "small"},
+		{ChatRequest{Provider: "secondary", Model: "tiny"}, "secondary", "tiny"},
+	}
+	for _, tt := range tests {
+		resp, err := router.Chat(context.Background(), &tt.req)
+		if err != nil {
+			t.Fatalf("chat %+v: %v", tt.req, err)
+		}
+		if resp.Content != tt.content || resp.Model != tt.model {
+			t.Fatalf("%+v: expected %s/%s, got %s/%s", tt.req, tt.content, tt.model, resp.Content, resp.Model)
+		}
+	}
+
+	if _, err := router.StreamChat(context.Background(), &ChatRequest{Provider: "missing"}); err == nil {
+		t.Fatal("expected an error for an unknown provider")
+	}
+}
+
+func TestGatewayCapsRetryAfter(t *testing.T) {
+	hours := &ProviderError{StatusCode: 429, RetryAfter: 3 * time.Hour}
+	primary := &flakyProvider{id: "primary", errs: []error{hours, hours}}
+	gw, slept := newTestGateway(primary)
+	gw.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Second, MaxBackoff: time.Minute})
+
+	if _, err := gw.Chat(context.Background(), &ChatRequest{Model: "m"}); err != nil {
+		t.Fatalf("chat error: %v", err)
+	}
+	if (*slept)[0] != time.Minute {
+		t.Fatalf("expected Retry-After capped by MaxBackoff, got %v", (*slept)[0])
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if d := gw.backoff(ctx, 1, hours); d <= 0 || d > 10*time.Second {
+		t.Fatalf("expected Retry-After capped by the deadline, got %v", d)
+	}
+}
@@ -2,7 +2,9 @@ package gemini
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
@@ -58,7 +60,7 @@ func (p *Provider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.Pro
 
 	resp, err := p.client.Models.GenerateContent(ctx, modelName, contents, conf)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	return convertResponse(resp, modelName)
@@ -75,13 +77,44 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 	ch := make(chan llm.StreamChunk)
 	go func() {
 		defer close(ch)
+
+		// Stop sending once the consumer is gone
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		// Usage metadata is cumulative; the last chunk carries the final counts
+		var usage *types.Usage
+		defer func() {
+			if usage != nil {
+				send(llm.StreamChunk{Usage: usage})
+			}
+		}()
+
 		for chunk, err := range stream {
 			if err != nil {
 				return
 			}
+			if chunk.UsageMetadata != nil {
+				u := convertUsage(chunk.UsageMetadata)
+				usage = &u
+			}
+			if len(chunk.Candidates) == 0 || chunk.Candidates[0].Content == nil {
+				continue
+			}
+
 			var toolCalls []types.ToolCall
-			// Extract tool calls if present in this chunk
+			var reasoning string
+			// Extract tool calls and thoughts if present in this chunk
 			for _, part := range chunk.Candidates[0].Content.Parts {
+				if part.Thought && part.Text != "" {
+					reasoning += part.Text
+				}
 				if part.FunctionCall != nil {
 					argsBytes, _ := json.Marshal(part.FunctionCall.Args)
 					toolCalls = append(toolCalls, types.ToolCall{
@@ -91,10 +124,14 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 				}
 			}
 
-			if text := chunk.Text(); text != "" || len(toolCalls) > 0 {
-				ch <- llm.StreamChunk{
+			// Text() skips thought parts
+			if text := chunk.Text(); text != "" || reasoning != "" || len(toolCalls) > 0 {
+				if !send(llm.StreamChunk{
 					Content:   text,
+					Reasoning: reasoning,
 					ToolCalls: toolCalls,
+				}) {
+					return
 				}
 			}
 		}
@@ -155,8 +192,14 @@ func convertMessage(m types.Message) (*genai.Content, error) {
 
 	var parts []*genai.Part
 
-	// 1. Text Content
-	if m.Content != "" {
+	// 1. Text Content, or text and images in order
+	if m.HasImages() {
+		converted, err := convertParts(m.Parts)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, converted...)
+	} else if m.Content != "" {
 		parts = append(parts, &genai.Part{Text: m.Content})
 	}
 
@@ -196,6 +239,24 @@ func convertMessage(m types.Message) (*genai.Content, error) {
 	}, nil
 }
 
+// convertParts maps text and image parts to Gemini parts; images are sent inline
+func convertParts(parts []types.ContentPart) ([]*genai.Part, error) {
+	var result []*genai.Part
+	for _, p := range parts {
+		switch {
+		case p.Type == types.ContentPartText && p.Text != "":
+			result = append(result, &genai.Part{Text: p.Text})
+		case p.Type == types.ContentPartImage && p.Data != "":
+			data, err := base64.StdEncoding.DecodeString(p.Data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid image data: %w", err)
+			}
+			result = append(result, &genai.Part{InlineData: &genai.Blob{MIMEType: p.MimeType, Data: data}})
+		}
+	}
+	return result, nil
+}
+
 func convertTools(tools []types.Tool) []*genai.Tool {
 	if len(tools) == 0 {
 		return nil
@@ -307,11 +368,27 @@ func convertResponse(resp *genai.GenerateContentResponse, model string) (*llm.Pr
 		Model:     model,
 		Content:   content,
 		ToolCalls: toolCalls,
-		Usage: types.Usage{
-			PromptTokens:     int(resp.UsageMetadata.PromptTokenCount),
-			CompletionTokens: int(resp.UsageMetadata.CandidatesTokenCount),
-			TotalTokens:      int(resp.UsageMetadata.TotalTokenCount),
-		},
+		Usage:     convertUsage(resp.UsageMetadata),
 	}
 	return llmResp, nil
 }
+
+// wrapError annotates SDK errors with their HTTP status for retry classification
+func wrapError(err error) error {
+	var apiErr genai.APIError
+	if errors.As(err, &apiErr) {
+		return &llm.ProviderError{Provider: "gemini", StatusCode: apiErr.Code, Message: apiErr.Message, Err: err}
+	}
+	return err
+}
+
+func convertUsage(u *genai.GenerateContentResponseUsageMetadata) types.Usage {
+	if u == nil {
+		return types.Usage{}
+	}
+	return types.Usage{
+		PromptTokens:     int(u.PromptTokenCount),
+		CompletionTokens: int(u.CandidatesTokenCount),
+		TotalTokens:      int(u.TotalTokenCount),
+	}
+}
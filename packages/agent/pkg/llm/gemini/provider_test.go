@@ -1,6 +1,7 @@
 package gemini
 
 import (
+	"encoding/base64"
 	"testing"
 
 	"github.com/gm-agent-org/gm-agent/pkg/types"
@@ -44,3 +45,25 @@ func TestConvertMessageToolResponse(t *testing.T) {
 		t.Fatalf("expected parts for tool response")
 	}
 }
+
+func TestConvertMessageWithImage(t *testing.T) {
+	msg := types.Message{Role: "user", Content: "what is this?", Parts: []types.ContentPart{
+		{Type: types.ContentPartText, Text: "what is this?"},
+		{Type: types.ContentPartImage, MimeType: "image/png", Data: base64.StdEncoding.EncodeToString([]byte("png"))},
+	}}
+	content, err := convertMessage(msg)
+	if err != nil {
+		t.Fatalf("convert message error: %v", err)
+	}
+	if len(content.Parts) != 2 || content.Parts[0].Text != "what is this?" {
+		t.Fatalf("expected text then image parts, got %+v", content.Parts)
+	}
+	if blob := content.Parts[1].InlineData; blob == nil || blob.MIMEType != "image/png" || string(blob.Data) != "png" {
+		t.Fatalf("unexpected image part %+v", content.Parts[1])
+	}
+
+	msg.Parts[1].Data = "not base64!"
+	if _, err := convertMessage(msg); err == nil {
+		t.Fatalf("expected error for invalid image data")
+	}
+}
@@ -2,14 +2,24 @@ package mock
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
 )
 
+// ErrScenarioExhausted is returned when no scenario turn is left to answer a call
+var ErrScenarioExhausted = errors.New("mock scenario: no matching turn left")
+
 type Provider struct {
 	ResponseContent string
+
+	// Scripted mode (see NewScenario)
+	scenario *Scenario
+	mu       sync.Mutex
+	served   []bool
 }
 
 func New(response string) *Provider {
@@ -18,11 +28,20 @@ func New(response string) *Provider {
 	}
 }
 
+// NewScenario returns a provider that answers calls with the scenario's turns
+func NewScenario(s *Scenario) *Provider {
+	return &Provider{scenario: s, served: make([]bool, len(s.Turns))}
+}
+
 func (p *Provider) ID() string {
 	return "mock"
 }
 
 func (p *Provider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	if p.scenario != nil {
+		return p.callScenario(ctx, req)
+	}
+
 	// Simple echo or predefined response
 	content := p.ResponseContent
 	if content == "" {
@@ -38,6 +57,10 @@ func (p *Provider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.Pro
 }
 
 func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	if p.scenario != nil {
+		return p.streamScenario(ctx, req)
+	}
+
 	ch := make(chan llm.StreamChunk)
 	go func() {
 		defer close(ch)
@@ -49,3 +72,102 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 	}()
 	return ch, nil
 }
+
+// Remaining returns the number of scenario turns not yet served
+func (p *Provider) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := 0
+	for _, served := range p.served {
+		if !served {
+			n++
+		}
+	}
+	return n
+}
+
+func (p *Provider) callScenario(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	turn, index, err := p.nextTurn(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return &llm.ProviderResponse{
+		ID:        fmt.Sprintf("mock-%d", index),
+		Model:     "mock-model",
+		Content:   turn.text(),
+		ToolCalls: turn.toolCalls(index),
+		Usage:     turn.usage(),
+	}, nil
+}
+
+func (p *Provider) streamScenario(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	turn, index, err := p.nextTurn(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks := turn.Chunks
+	if len(chunks) == 0 && turn.Content != "" {
+		chunks = []string{turn.Content}
+	}
+	stream := make([]llm.StreamChunk, 0, len(chunks)+2)
+	if turn.Reasoning != "" {
+		stream = append(stream, llm.StreamChunk{Reasoning: turn.Reasoning})
+	}
+	for _, c := range chunks {
+		stream = append(stream, llm.StreamChunk{Content: c})
+	}
+	toolCalls := turn.toolCalls(index)
+	for i, tc := range toolCalls {
+		stream = append(stream, llm.StreamChunk{ToolCallDeltas: []llm.ToolCallDelta{{Index: i, ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments}}})
+	}
+	// Complete tool calls and usage arrive on the final chunk, as with real providers
+	usage := turn.usage()
+	stream = append(stream, llm.StreamChunk{ToolCalls: toolCalls, Usage: &usage})
+
+	ch := make(chan llm.StreamChunk)
+	go func() {
+		defer close(ch)
+		for _, c := range stream {
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return ch, nil
+}
+
+// nextTurn claims the first unserved turn that matches req, then applies its delay and error
+func (p *Provider) nextTurn(ctx context.Context, req *llm.ProviderRequest) (*Turn, int, error) {
+	p.mu.Lock()
+	index := -1
+	for i := range p.scenario.Turns {
+		if !p.served[i] && p.scenario.Turns[i].matches(req.Messages) {
+			p.served[i] = true
+			index = i
+			break
+		}
+	}
+	p.mu.Unlock()
+
+	if index < 0 {
+		return nil, 0, ErrScenarioExhausted
+	}
+	turn := &p.scenario.Turns[index]
+
+	if d := turn.delay(); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return nil, 0, ctx.Err()
+		case <-t.C:
+		}
+	}
+	if turn.Error != "" {
+		return nil, 0, &llm.ProviderError{Provider: "mock", StatusCode: turn.StatusCode, Message: turn.Error}
+	}
+	return turn, index, nil
+}
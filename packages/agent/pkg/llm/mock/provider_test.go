@@ -2,6 +2,7 @@ package mock
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
@@ -29,3 +30,111 @@ func TestProviderCallEcho(t *testing.T) {
 		t.Fatalf("expected echoed content")
 	}
 }
+
+const testScenario = `
+turns:
+  - tool_calls:
+      - id: call-1
+        name: read_file
+        arguments: {path: main.go}
+  - match: {role: tool, tool_name: read_file, contains: "package main"}
+    chunks: ["It is a ", "Go program."]
+    usage: {prompt_tokens: 10, completion_tokens: 4}
+  - match: {role: user, regex: "^retry"}
+    error: overloaded
+    status_code: 529
+  - match: {role: user, regex: "^retry"}
+    delay: 10ms
+    content: recovered
+`
+
+func TestScenarioTurns(t *testing.T) {
+	ctx := context.Background()
+	s, err := ParseScenario([]byte(testScenario))
+	if err != nil {
+		t.Fatalf("parse scenario: %v", err)
+	}
+	p := NewScenario(s)
+
+	user := []types.Message{{Role: "user", Content: "what is main.go?"}}
+	resp, err := p.Call(ctx, &llm.ProviderRequest{Messages: user})
+	if err != nil {
+		t.Fatalf("first turn: %v", err)
+	}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0].Name != "read_file" || resp.ToolCalls[0].Arguments != `{"path":"main.go"}` {
+		t.Fatalf("expected read_file call, got %+v", resp.ToolCalls)
+	}
+
+	toolResult := append(user,
+		types.Message{Role: "assistant", ToolCalls: resp.ToolCalls},
+		types.Message{Role: "tool", ToolName: "read_file", ToolCallID: "call-1", Content: "package main"})
+	stream, err := p.CallStream(ctx, &llm.ProviderRequest{Messages: toolResult})
+	if err != nil {
+		t.Fatalf("second turn: %v", err)
+	}
+	var chunks []string
+	var usage *types.Usage
+	for chunk := range stream {
+		if chunk.Content != "" {
+			chunks = append(chunks, chunk.Content)
+		}
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if len(chunks) != 2 || chunks[0]+chunks[1] != "It is a Go program." {
+		t.Fatalf("expected two streamed chunks, got %q", chunks)
+	}
+	if usage == nil || usage.TotalTokens != 14 {
+		t.Fatalf("expected usage on final chunk, got %+v", usage)
+	}
+
+	retry := []types.Message{{Role: "user", Content: "retry please"}}
+	_, err = p.Call(ctx, &llm.ProviderRequest{Messages: retry})
+	if llm.ClassifyError(err) != llm.ErrorKindServer {
+		t.Fatalf("expected injected server error, got %v", err)
+	}
+	resp, err = p.Call(ctx, &llm.ProviderRequest{Messages: retry})
+	if err != nil || resp.Content != "recovered" {
+		t.Fatalf("expected recovery turn, got %+v %v", resp, err)
+	}
+
+	if _, err := p.Call(ctx, &llm.ProviderRequest{Messages: retry}); !errors.Is(err, ErrScenarioExhausted) {
+		t.Fatalf("expected exhausted scenario, got %v", err)
+	}
+}
+
+func TestScenarioMatchSkipsTurns(t *testing.T) {
+	s, err := ParseScenario([]byte(`{"turns": [
+		{"match": {"contains": "second"}, "content": "two"},
+		{"content": "one"}
+	]}`))
+	if err != nil {
+		t.Fatalf("parse json scenario: %v", err)
+	}
+	p := NewScenario(s)
+
+	resp, err := p.Call(context.Background(), &llm.ProviderRequest{Messages: []types.Message{{Role: "user", Content: "first"}}})
+	if err != nil || resp.Content != "one" {
+		t.Fatalf("expected unmatched turn to be skipped, got %+v %v", resp, err)
+	}
+	resp, err = p.Call(context.Background(), &llm.ProviderRequest{Messages: []types.Message{{Role: "user", Content: "second"}}})
+	if err != nil || resp.Content != "two" {
+		t.Fatalf("expected matched turn, got %+v %v", resp, err)
+	}
+	if p.Remaining() != 0 {
+		t.Fatalf("expected all turns served")
+	}
+}
+
+func TestParseScenarioRejectsInvalidTurns(t *testing.T) {
+	for _, doc := range []string{
+		"turns: [{delay: soon}]",
+		"turns: [{tool_calls: [{arguments: {a: 1}}]}]",
+		"turns: [{match: {regex: '('}}]",
+	} {
+		if _, err := ParseScenario([]byte(doc)); err == nil {
+			t.Fatalf("expected error for %q", doc)
+		}
+	}
+}
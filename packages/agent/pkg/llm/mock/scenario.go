@@ -0,0 +1,193 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// Scenario scripts the mock provider as an ordered list of turns.
+// Each LLM call is answered by the first unserved turn whose match accepts the request.
+//
+//	turns:
+//	  - tool_calls:
+//	      - name: read_file
+//	        arguments: {path: main.go}
+//	  - match: {role: tool, contains: "package main"}
+//	    chunks: ["It is a ", "Go program."]
+type Scenario struct {
+	Turns []Turn `yaml:"turns" json:"turns"`
+}
+
+// Turn is one scripted LLM response
+type Turn struct {
+	Match      *Match           `yaml:"match,omitempty" json:"match,omitempty"`
+	Content    string           `yaml:"content,omitempty" json:"content,omitempty"`
+	Reasoning  string           `yaml:"reasoning,omitempty" json:"reasoning,omitempty"` // Streamed as thinking before the content
+	Chunks     []string         `yaml:"chunks,omitempty" json:"chunks,omitempty"`       // Streamed pieces; joined for Call, Content is used if empty
+	ToolCalls  []ScriptToolCall `yaml:"tool_calls,omitempty" json:"tool_calls,omitempty"`
+	Usage      *ScriptUsage     `yaml:"usage,omitempty" json:"usage,omitempty"`
+	Delay      string           `yaml:"delay,omitempty" json:"delay,omitempty"`             // Wait before answering, e.g. "200ms"
+	Error      string           `yaml:"error,omitempty" json:"error,omitempty"`             // Fail the call with this message
+	StatusCode int              `yaml:"status_code,omitempty" json:"status_code,omitempty"` // HTTP status of the injected error (e.g. 429)
+}
+
+// ScriptToolCall is a tool call emitted by a turn
+type ScriptToolCall struct {
+	ID        string         `yaml:"id,omitempty" json:"id,omitempty"` // Generated when empty
+	Name      string         `yaml:"name" json:"name"`
+	Arguments map[string]any `yaml:"arguments,omitempty" json:"arguments,omitempty"`
+}
+
+// ScriptUsage is the token usage reported by a turn
+type ScriptUsage struct {
+	PromptTokens     int `yaml:"prompt_tokens" json:"prompt_tokens"`
+	CompletionTokens int `yaml:"completion_tokens" json:"completion_tokens"`
+	TotalTokens      int `yaml:"total_tokens,omitempty" json:"total_tokens,omitempty"` // Sum of both when empty
+}
+
+// Match selects a turn by the last user or tool message of the request.
+// Empty fields match anything.
+type Match struct {
+	Role     string `yaml:"role,omitempty" json:"role,omitempty"`           // "user" or "tool"
+	Contains string `yaml:"contains,omitempty" json:"contains,omitempty"`   // Substring of the content
+	Regex    string `yaml:"regex,omitempty" json:"regex,omitempty"`         // Pattern the content must match
+	ToolName string `yaml:"tool_name,omitempty" json:"tool_name,omitempty"` // Tool whose result is last
+
+	re *regexp.Regexp
+}
+
+// LoadScenario reads a YAML or JSON scenario file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario: %w", err)
+	}
+	s, err := ParseScenario(data)
+	if err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// ParseScenario parses a YAML or JSON scenario and validates its turns
+func ParseScenario(data []byte) (*Scenario, error) {
+	var s Scenario
+	// YAML is a superset of JSON, so one decoder handles both
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse scenario: %w", err)
+	}
+	for i := range s.Turns {
+		if err := s.Turns[i].validate(); err != nil {
+			return nil, fmt.Errorf("turn %d: %w", i, err)
+		}
+	}
+	return &s, nil
+}
+
+func (t *Turn) validate() error {
+	if t.Delay != "" {
+		if _, err := time.ParseDuration(t.Delay); err != nil {
+			return fmt.Errorf("invalid delay: %w", err)
+		}
+	}
+	for _, tc := range t.ToolCalls {
+		if tc.Name == "" {
+			return fmt.Errorf("tool call without a name")
+		}
+	}
+	if t.Match != nil && t.Match.Regex != "" {
+		re, err := regexp.Compile(t.Match.Regex)
+		if err != nil {
+			return fmt.Errorf("invalid match regex: %w", err)
+		}
+		t.Match.re = re
+	}
+	return nil
+}
+
+// delay returns the parsed delay (validated on load)
+func (t *Turn) delay() time.Duration {
+	d, _ := time.ParseDuration(t.Delay)
+	return d
+}
+
+// text returns the full response content
+func (t *Turn) text() string {
+	if len(t.Chunks) > 0 {
+		return strings.Join(t.Chunks, "")
+	}
+	return t.Content
+}
+
+// toolCalls converts the scripted calls, numbering calls without an ID
+func (t *Turn) toolCalls(turn int) []types.ToolCall {
+	var calls []types.ToolCall
+	for i, tc := range t.ToolCalls {
+		args := "{}"
+		if len(tc.Arguments) > 0 {
+			encoded, _ := json.Marshal(tc.Arguments)
+			args = string(encoded)
+		}
+		id := tc.ID
+		if id == "" {
+			id = fmt.Sprintf("mock-call-%d-%d", turn, i)
+		}
+		calls = append(calls, types.ToolCall{ID: id, Name: tc.Name, Arguments: args})
+	}
+	return calls
+}
+
+// usage returns the turn's token usage
+func (t *Turn) usage() types.Usage {
+	if t.Usage == nil {
+		return types.Usage{}
+	}
+	u := types.Usage{
+		PromptTokens:     t.Usage.PromptTokens,
+		CompletionTokens: t.Usage.CompletionTokens,
+		TotalTokens:      t.Usage.TotalTokens,
+	}
+	if u.TotalTokens == 0 {
+		u.TotalTokens = u.PromptTokens + u.CompletionTokens
+	}
+	return u
+}
+
+// matches reports whether the turn may answer a request with these messages
+func (t *Turn) matches(messages []types.Message) bool {
+	m := t.Match
+	if m == nil {
+		return true
+	}
+	var last *types.Message
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role == "user" || messages[i].Role == "tool" {
+			last = &messages[i]
+			break
+		}
+	}
+	if last == nil {
+		return false
+	}
+	if m.Role != "" && last.Role != m.Role {
+		return false
+	}
+	if m.ToolName != "" && last.ToolName != m.ToolName {
+		return false
+	}
+	if m.Contains != "" && !strings.Contains(last.Content, m.Contains) {
+		return false
+	}
+	if m.re != nil && !m.re.MatchString(last.Content) {
+		return false
+	}
+	return true
+}
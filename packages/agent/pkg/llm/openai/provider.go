@@ -2,6 +2,7 @@ package openai
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
@@ -61,7 +62,7 @@ func (p *Provider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.Pro
 
 	resp, err := p.client.CreateChatCompletion(ctx, openAIReq)
 	if err != nil {
-		return nil, err
+		return nil, wrapError(err)
 	}
 
 	// 4. Convert Response
@@ -81,7 +82,23 @@ func (p *Provider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.Pro
 
 func convertMessages(msgs []types.Message) ([]openai.ChatCompletionMessage, error) {
 	var result []openai.ChatCompletionMessage
-	for _, m := range msgs {
+	// Tool messages only carry text, so their images follow the run of tool
+	// results as a user message
+	var toolImages []openai.ChatMessagePart
+	for i, m := range msgs {
+		if m.Role != "assistant" && m.Role != "tool" && m.HasImages() {
+			result = append(result, openai.ChatCompletionMessage{Role: m.Role, MultiContent: convertParts(m.Parts)})
+			continue
+		}
+		if m.Role == "tool" && m.HasImages() {
+			toolImages = append(toolImages, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: fmt.Sprintf("Image returned by %s:", m.ToolName)})
+			for _, p := range convertParts(m.Parts) {
+				if p.Type == openai.ChatMessagePartTypeImageURL {
+					toolImages = append(toolImages, p)
+				}
+			}
+		}
+
 		// Ensure content is never empty for API compatibility
 		// go-openai uses `omitempty` on Content field, so empty string gets omitted
 		// DeepSeek API requires content field to be present
@@ -117,10 +134,33 @@ func convertMessages(msgs []types.Message) ([]openai.ChatCompletionMessage, erro
 		}
 
 		result = append(result, msg)
+
+		if len(toolImages) > 0 && (i+1 == len(msgs) || msgs[i+1].Role != "tool") {
+			result = append(result, openai.ChatCompletionMessage{Role: "user", MultiContent: toolImages})
+			toolImages = nil
+		}
 	}
 	return result, nil
 }
 
+// convertParts maps text and image parts to OpenAI content parts.
+// Images are sent inline as data URLs.
+func convertParts(parts []types.ContentPart) []openai.ChatMessagePart {
+	var result []openai.ChatMessagePart
+	for _, p := range parts {
+		switch {
+		case p.Type == types.ContentPartText && p.Text != "":
+			result = append(result, openai.ChatMessagePart{Type: openai.ChatMessagePartTypeText, Text: p.Text})
+		case p.Type == types.ContentPartImage && p.Data != "":
+			result = append(result, openai.ChatMessagePart{
+				Type:     openai.ChatMessagePartTypeImageURL,
+				ImageURL: &openai.ChatMessageImageURL{URL: "data:" + p.MimeType + ";base64," + p.Data},
+			})
+		}
+	}
+	return result
+}
+
 func convertTools(tools []types.Tool) []openai.Tool {
 	if len(tools) == 0 {
 		return nil
@@ -139,6 +179,19 @@ func convertTools(tools []types.Tool) []openai.Tool {
 	return result
 }
 
+// wrapError annotates SDK errors with their HTTP status for retry classification
+func wrapError(err error) error {
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return &llm.ProviderError{Provider: "openai", StatusCode: apiErr.HTTPStatusCode, Message: apiErr.Message, Err: err}
+	}
+	var reqErr *openai.RequestError
+	if errors.As(err, &reqErr) {
+		return &llm.ProviderError{Provider: "openai", StatusCode: reqErr.HTTPStatusCode, Err: err}
+	}
+	return err
+}
+
 func convertUsage(u openai.Usage) types.Usage {
 	return types.Usage{
 		PromptTokens:     u.PromptTokens,
@@ -180,11 +233,13 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 		MaxTokens:   req.MaxTokens,
 		Temperature: float32(req.Temperature),
 		Stream:      true,
+		// Ask for a final usage chunk (sent after finish_reason with empty choices)
+		StreamOptions: &openai.StreamOptions{IncludeUsage: true},
 	}
 
 	stream, err := p.client.CreateChatCompletionStream(ctx, openAIReq)
 	if err != nil {
-		return nil, fmt.Errorf("create stream: %w", err)
+		return nil, fmt.Errorf("create stream: %w", wrapError(err))
 	}
 
 	ch := make(chan llm.StreamChunk)
@@ -192,6 +247,16 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 		defer close(ch)
 		defer stream.Close()
 
+		// Stop sending once the consumer is gone
+		send := func(chunk llm.StreamChunk) bool {
+			select {
+			case ch <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
 		// Track tool calls across chunks (they come in pieces)
 		toolCallBuilder := make(map[int]*types.ToolCall)
 
@@ -202,25 +267,50 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 				return
 			}
 
+			if resp.Usage != nil {
+				usage := convertUsage(*resp.Usage)
+				if !send(llm.StreamChunk{Usage: &usage}) {
+					return
+				}
+			}
+
 			if len(resp.Choices) == 0 {
 				continue
 			}
 
 			delta := resp.Choices[0].Delta
 
+			// Reasoning models served through this API (e.g. DeepSeek) stream their thinking separately
+			if delta.ReasoningContent != "" {
+				if !send(llm.StreamChunk{
+					Reasoning: delta.ReasoningContent,
+				}) {
+					return
+				}
+			}
+
 			// Handle text content
 			if delta.Content != "" {
-				ch <- llm.StreamChunk{
+				if !send(llm.StreamChunk{
 					Content: delta.Content,
+				}) {
+					return
 				}
 			}
 
 			// Handle tool calls (they come in chunks)
+			var deltas []llm.ToolCallDelta
 			for _, tc := range delta.ToolCalls {
 				idx := tc.Index
 				if idx == nil {
 					continue
 				}
+				deltas = append(deltas, llm.ToolCallDelta{
+					Index:     *idx,
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+				})
 
 				// Initialize tool call if first chunk for this index
 				if _, ok := toolCallBuilder[*idx]; !ok {
@@ -242,6 +332,12 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 				}
 			}
 
+			if len(deltas) > 0 {
+				if !send(llm.StreamChunk{ToolCallDeltas: deltas}) {
+					return
+				}
+			}
+
 			// Check if we're done (finish_reason set)
 			if resp.Choices[0].FinishReason != "" {
 				// Emit all completed tool calls
@@ -250,11 +346,14 @@ func (p *Provider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-
 					for _, tc := range toolCallBuilder {
 						toolCalls = append(toolCalls, *tc)
 					}
-					ch <- llm.StreamChunk{
+					if !send(llm.StreamChunk{
 						ToolCalls: toolCalls,
+					}) {
+						return
 					}
+					toolCallBuilder = make(map[int]*types.ToolCall)
 				}
-				return
+				// Keep reading: the usage chunk follows the finish_reason chunk
 			}
 		}
 	}()
@@ -1,8 +1,14 @@
 package openai
 
 import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 	sdk "github.com/sashabaranov/go-openai"
 )
@@ -24,6 +30,34 @@ func TestConvertMessages(t *testing.T) {
 	}
 }
 
+func TestConvertMessagesWithImages(t *testing.T) {
+	image := types.ContentPart{Type: types.ContentPartImage, MimeType: "image/png", Data: "iVBORw0K"}
+	msgs := []types.Message{
+		{Role: "user", Content: "what is this?", Parts: []types.ContentPart{{Type: types.ContentPartText, Text: "what is this?"}, image}},
+		{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "1", Name: "read_file", Arguments: "{}"}, {ID: "2", Name: "glob", Arguments: "{}"}}},
+		{Role: "tool", ToolCallID: "1", ToolName: "read_file", Content: "Attached image", Parts: []types.ContentPart{{Type: types.ContentPartText, Text: "Attached image"}, image}},
+		{Role: "tool", ToolCallID: "2", ToolName: "glob", Content: "a.png"},
+	}
+	converted, err := convertMessages(msgs)
+	if err != nil {
+		t.Fatalf("convert messages error: %v", err)
+	}
+	if len(converted) != 5 {
+		t.Fatalf("expected the tool images as a trailing user message, got %d messages", len(converted))
+	}
+	user := converted[0]
+	if user.Content != "" || len(user.MultiContent) != 2 || user.MultiContent[1].ImageURL.URL != "data:image/png;base64,iVBORw0K" {
+		t.Fatalf("unexpected user message %+v", user)
+	}
+	if converted[2].Content != "Attached image" || converted[3].Role != "tool" {
+		t.Fatalf("expected tool results to stay together, got %+v", converted[2:4])
+	}
+	images := converted[4]
+	if images.Role != "user" || len(images.MultiContent) != 2 || images.MultiContent[1].Type != sdk.ChatMessagePartTypeImageURL {
+		t.Fatalf("unexpected tool image message %+v", images)
+	}
+}
+
 func TestConvertToolsAndBack(t *testing.T) {
 	tools := []types.Tool{{Name: "read", Description: "desc", Parameters: types.JSONSchema{"type": "object"}}}
 	converted := convertTools(tools)
@@ -45,3 +79,122 @@ func TestConvertUsage(t *testing.T) {
 		t.Fatalf("unexpected usage conversion: %+v", res)
 	}
 }
+
+func TestCallStreamReportsUsage(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req sdk.ChatCompletionRequest
+		_ = json.NewDecoder(r.Body).Decode(&req)
+		if req.StreamOptions == nil || !req.StreamOptions.IncludeUsage {
+			t.Errorf("expected stream_options.include_usage to be set")
+		}
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+			`{"id":"1","choices":[],"usage":{"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}}`,
+		}
+		for _, c := range chunks {
+			_, _ = w.Write([]byte("data: " + c + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	p := New(Config{APIKey: "k", BaseURL: srv.URL})
+	ch, err := p.CallStream(context.Background(), &llm.ProviderRequest{Model: "gpt-4o", Messages: []types.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	var text string
+	var usage *types.Usage
+	for chunk := range ch {
+		text += chunk.Content
+		if chunk.Usage != nil {
+			usage = chunk.Usage
+		}
+	}
+	if text != "Hi" {
+		t.Fatalf("unexpected streamed text %q", text)
+	}
+	if usage == nil || usage.TotalTokens != 6 || usage.PromptTokens != 4 {
+		t.Fatalf("unexpected streamed usage: %+v", usage)
+	}
+}
+
+func TestCallStreamStopsWhenCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for range 3 {
+			_, _ = w.Write([]byte(`data: {"id":"1","choices":[{"index":0,"delta":{"content":"Hi"}}]}` + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	p := New(Config{APIKey: "k", BaseURL: srv.URL})
+	ch, err := p.CallStream(ctx, &llm.ProviderRequest{Model: "gpt-4o", Messages: []types.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+	<-ch
+
+	// A consumer that stops reading after cancelling must not keep the sender blocked
+	cancel()
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case chunk, ok := <-ch:
+		if ok {
+			t.Fatalf("expected the stream to close after cancellation, got %+v", chunk)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("stream was not closed after cancellation")
+	}
+}
+
+func TestCallStreamToolCallDeltas(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		chunks := []string{
+			`{"id":"1","choices":[{"index":0,"delta":{"reasoning_content":"Need the file."}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1","type":"function","function":{"name":"read_file","arguments":""}}]}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"path\":"}}]}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"a.go\"}"}}]}}]}`,
+			`{"id":"1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+		}
+		for _, c := range chunks {
+			_, _ = w.Write([]byte("data: " + c + "\n\n"))
+		}
+		_, _ = w.Write([]byte("data: [DONE]\n\n"))
+	}))
+	defer srv.Close()
+
+	p := New(Config{APIKey: "k", BaseURL: srv.URL})
+	ch, err := p.CallStream(context.Background(), &llm.ProviderRequest{Model: "deepseek-reasoner", Messages: []types.Message{{Role: "user", Content: "hi"}}})
+	if err != nil {
+		t.Fatalf("stream error: %v", err)
+	}
+
+	var reasoning, args string
+	var deltas []llm.ToolCallDelta
+	var calls []types.ToolCall
+	for chunk := range ch {
+		reasoning += chunk.Reasoning
+		deltas = append(deltas, chunk.ToolCallDeltas...)
+		calls = append(calls, chunk.ToolCalls...)
+	}
+	for _, d := range deltas {
+		args += d.Arguments
+	}
+	if reasoning != "Need the file." {
+		t.Fatalf("unexpected reasoning %q", reasoning)
+	}
+	if len(deltas) != 3 || deltas[0].ID != "call_1" || deltas[0].Name != "read_file" || args != `{"path":"a.go"}` {
+		t.Fatalf("unexpected tool call deltas: %+v", deltas)
+	}
+	if len(calls) != 1 || calls[0].Arguments != `{"path":"a.go"}` {
+		t.Fatalf("expected the complete call after the deltas, got %+v", calls)
+	}
+}
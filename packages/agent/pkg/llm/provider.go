@@ -19,11 +19,25 @@ type Provider interface {
 }
 
 type StreamChunk struct {
-	Content   string
-	ToolCalls []types.ToolCall
+	Content        string
+	Reasoning      string           // Thinking text, for providers that expose it
+	ToolCallDeltas []ToolCallDelta  // Tool calls as they are generated
+	ToolCalls      []types.ToolCall // Complete tool calls, still sent once they are done
+	Usage          *types.Usage     // Set on the final chunk when the provider reports usage
+	Err            error            // Set on the last chunk when the stream failed partway
+}
+
+// ToolCallDelta is a piece of a tool call being generated.
+// The first delta of a call carries its ID and name.
+type ToolCallDelta struct {
+	Index     int    `json:"index"`               // Position of the call in the response
+	ID        string `json:"id,omitempty"`        // Set when the call starts
+	Name      string `json:"name,omitempty"`      // Set when the call starts
+	Arguments string `json:"arguments,omitempty"` // Next fragment of the JSON arguments
 }
 
 type ChatRequest struct {
+	Provider string // Gateway of a Router; "" is the default one
 	Model    string
 	Messages []types.Message
 	Tools    []types.Tool
@@ -0,0 +1,78 @@
+package llm
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Router sends each request to the gateway of its provider, so several
+// providers (each with its own retries and fallbacks) serve one server
+type Router struct {
+	defaultID string
+	gateways  map[string]*Gateway
+}
+
+// NewRouter returns a router whose default gateway serves providerID
+func NewRouter(providerID string, gateway *Gateway) *Router {
+	return &Router{
+		defaultID: providerID,
+		gateways:  map[string]*Gateway{providerID: gateway},
+	}
+}
+
+// Add registers the gateway of another provider
+func (r *Router) Add(providerID string, gateway *Gateway) {
+	r.gateways[providerID] = gateway
+}
+
+// Has reports whether a gateway serves providerID
+func (r *Router) Has(providerID string) bool {
+	_, ok := r.gateways[providerID]
+	return ok
+}
+
+// Providers returns the IDs of the registered gateways, sorted
+func (r *Router) Providers() []string {
+	ids := make([]string, 0, len(r.gateways))
+	for id := range r.gateways {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (r *Router) Chat(ctx context.Context, req *ChatRequest) (*ChatResponse, error) {
+	gw, req, err := r.route(req)
+	if err != nil {
+		return nil, err
+	}
+	return gw.Chat(ctx, req)
+}
+
+func (r *Router) StreamChat(ctx context.Context, req *ChatRequest) (<-chan StreamChunk, error) {
+	gw, req, err := r.route(req)
+	if err != nil {
+		return nil, err
+	}
+	return gw.StreamChat(ctx, req)
+}
+
+// route picks the gateway of the request; a request without a model gets the
+// provider's configured one
+func (r *Router) route(req *ChatRequest) (*Gateway, *ChatRequest, error) {
+	id := req.Provider
+	if id == "" {
+		id = r.defaultID
+	}
+	gw, ok := r.gateways[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("no gateway for provider %q (configured: %v)", id, r.Providers())
+	}
+	if req.Model == "" {
+		routed := *req
+		routed.Model = gw.options.Model
+		req = &routed
+	}
+	return gw, req, nil
+}
@@ -0,0 +1,283 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// ErrNothingToCompact is returned by Compact when the context is too short to summarize
+var ErrNothingToCompact = errors.New("not enough messages to compact")
+
+const compactionPrompt = `You are summarizing an earlier part of a conversation between a user and an AI coding assistant so the conversation can continue with less context.
+
+Write a concise summary that preserves:
+- The user's requests and goals
+- Decisions made and their reasons
+- Files read, created or modified, and important findings about them
+- Commands run and their notable results or errors
+- Any open questions or unfinished work
+
+Respond with the summary only.`
+
+// errCompactionStale is returned when the context changed while its summary was built
+var errCompactionStale = errors.New("context changed during compaction")
+
+// compactionAttempts bounds how often Compact rebuilds a summary that went stale
+const compactionAttempts = 3
+
+// summaryPrefix marks the synthetic message that replaces compacted history
+const summaryPrefix = "[Summary of earlier conversation]\n"
+
+// imageTokens is the rough cost of one image; providers bill by resolution
+const imageTokens = 800
+
+// estimateTokens approximates the token count of a message (~4 chars per token)
+func estimateTokens(m types.Message) int {
+	chars := len(m.Content)
+	for _, tc := range m.ToolCalls {
+		chars += len(tc.Name) + len(tc.Arguments)
+	}
+	images := 0
+	for _, p := range m.Parts {
+		if p.Type == types.ContentPartImage {
+			images++
+		}
+	}
+	return chars/4 + images*imageTokens + 4 // Per-message overhead for role and framing
+}
+
+// refreshTokenCounts fills in missing Message.TokenCount values and recomputes TotalTokens
+func refreshTokenCounts(c *types.ContextWindow) {
+	if c == nil {
+		return
+	}
+	total := 0
+	for i := range c.Messages {
+		if c.Messages[i].TokenCount == 0 {
+			c.Messages[i].TokenCount = estimateTokens(c.Messages[i])
+		}
+		total += c.Messages[i].TokenCount
+	}
+	c.TotalTokens = total
+}
+
+// contextLimits returns the effective token budget for the context window.
+// Values stored in state take precedence over the runtime configuration.
+func (r *Runtime) contextLimits(c *types.ContextWindow) (maxTokens, reserve int) {
+	maxTokens, reserve = r.config.MaxContextTokens, r.config.ReserveOutputTokens
+	if c != nil && c.MaxTokens > 0 {
+		maxTokens = c.MaxTokens
+	}
+	if c != nil && c.ReserveOutput > 0 {
+		reserve = c.ReserveOutput
+	}
+	return maxTokens, reserve
+}
+
+// needsCompaction reports whether the context exceeds its budget
+func (r *Runtime) needsCompaction() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	maxTokens, reserve := r.contextLimits(r.state.Context)
+	if maxTokens <= 0 || r.state.Context == nil {
+		return false // Compaction disabled
+	}
+	return r.state.Context.TotalTokens > maxTokens-reserve
+}
+
+// compactionSplit returns the index of the first message to keep verbatim.
+// The split never lands on a tool result, so tool_call/tool_result pairs stay intact.
+func compactionSplit(msgs []types.Message, keepRecent int) int {
+	split := len(msgs) - keepRecent
+	if split <= 0 {
+		return 0
+	}
+	for split > 0 && msgs[split].Role == "tool" {
+		split--
+	}
+	return split
+}
+
+// Compact summarizes older messages and replaces them with a single summary message.
+// It is safe to call while the runtime loop is running; a summary that went
+// stale while it was built is rebuilt.
+func (r *Runtime) Compact(ctx context.Context) (*types.ContextCompactedEvent, error) {
+	for attempt := 1; ; attempt++ {
+		evt, base, err := r.buildCompaction(ctx, "manual", "")
+		if err != nil {
+			return nil, err
+		}
+
+		r.mu.Lock()
+		err = r.commitCompactionLocked(ctx, evt, base)
+		if err == nil {
+			err = r.store.SaveState(ctx, r.state)
+		}
+		r.mu.Unlock()
+		if errors.Is(err, errCompactionStale) && attempt < compactionAttempts {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return evt, nil
+	}
+}
+
+// autoCompact runs compaction from the main loop when the budget is exceeded;
+// its cost is charged to goalID. A stale summary is dropped; the next step
+// compacts again if still needed.
+func (r *Runtime) autoCompact(ctx context.Context, goalID string) error {
+	evt, base, err := r.buildCompaction(ctx, "auto", goalID)
+	if errors.Is(err, ErrNothingToCompact) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	err = r.commitCompactionLocked(ctx, evt, base)
+	if errors.Is(err, errCompactionStale) {
+		r.log.Info("context changed during compaction, skipping")
+		return nil
+	}
+	return err
+}
+
+// compactionBase is the part of the state a summary was built from
+type compactionBase struct {
+	version  int64           // State version of the snapshot
+	count    int             // Messages in the snapshot
+	messages []types.Message // The summarized prefix
+}
+
+// commitCompactionLocked persists and applies evt if the summarized messages
+// are still the leading messages of the context. Messages appended since the
+// snapshot are kept; any other change makes the summary stale.
+func (r *Runtime) commitCompactionLocked(ctx context.Context, evt *types.ContextCompactedEvent, base compactionBase) error {
+	current := r.state.Context.Messages
+	if r.state.Version != base.version {
+		if len(current) < base.count || !reflect.DeepEqual(current[:len(base.messages)], base.messages) {
+			return errCompactionStale
+		}
+		// Account for the messages added meanwhile
+		evt.TokensBefore = r.state.Context.TotalTokens
+		for _, m := range current[base.count:] {
+			evt.TokensAfter += estimateTokens(m)
+		}
+	}
+
+	if err := r.store.AppendEvent(ctx, evt); err != nil {
+		return err
+	}
+	return r.applyEventLocked(ctx, evt)
+}
+
+// buildCompaction asks the LLM to summarize the older part of the context
+func (r *Runtime) buildCompaction(ctx context.Context, trigger, goalID string) (*types.ContextCompactedEvent, compactionBase, error) {
+	r.mu.RLock()
+	messages := make([]types.Message, len(r.state.Context.Messages))
+	for i, m := range r.state.Context.Messages {
+		messages[i] = m.Clone()
+	}
+	tokensBefore := r.state.Context.TotalTokens
+	version := r.state.Version
+	r.mu.RUnlock()
+
+	split := compactionSplit(messages, r.config.CompactionKeepRecent)
+	if split < 2 {
+		return nil, compactionBase{}, ErrNothingToCompact
+	}
+	base := compactionBase{version: version, count: len(messages), messages: messages[:split]}
+
+	route := r.config.Routes[config.RouteCompaction]
+	if route == (types.ModelRoute{}) {
+		route.Model = r.config.Model
+	}
+	resp, err := r.llm.Chat(ctx, &llm.ChatRequest{
+		Provider: route.Provider,
+		Model:    route.Model,
+		Messages: []types.Message{
+			{Role: "system", Content: compactionPrompt},
+			{Role: "user", Content: renderTranscript(messages[:split])},
+		},
+	})
+	if err != nil {
+		return nil, base, fmt.Errorf("summarize context: %w", err)
+	}
+	summary := strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return nil, base, errors.New("summarize context: empty summary")
+	}
+
+	model := resp.Model
+	if model == "" {
+		model = route.Model
+	}
+
+	tokensAfter := estimateTokens(types.Message{Role: "user", Content: summaryPrefix + summary})
+	for _, m := range messages[split:] {
+		tokensAfter += estimateTokens(m)
+	}
+
+	return &types.ContextCompactedEvent{
+		BaseEvent:         types.NewBaseEvent("context_compacted", "runtime", ""),
+		Summary:           summary,
+		MessagesCompacted: split,
+		TokensBefore:      tokensBefore,
+		TokensAfter:       tokensAfter,
+		Trigger:           trigger,
+		Model:             model,
+		Usage:             resp.Usage,
+		CostUSD:           r.priceUsage(model, resp.Usage),
+		GoalID:            goalID,
+	}, base, nil
+}
+
+// renderTranscript flattens messages into plain text for the summarization request
+func renderTranscript(msgs []types.Message) string {
+	var b strings.Builder
+	for _, m := range msgs {
+		switch m.Role {
+		case "tool":
+			fmt.Fprintf(&b, "[tool result: %s]\n%s\n\n", m.ToolName, m.Content)
+		default:
+			fmt.Fprintf(&b, "[%s]\n%s\n", m.Role, strings.TrimSpace(m.Content))
+			for _, tc := range m.ToolCalls {
+				fmt.Fprintf(&b, "(called %s with %s)\n", tc.Name, tc.Arguments)
+			}
+			b.WriteString("\n")
+		}
+	}
+	return b.String()
+}
+
+// applyCompaction replaces the compacted prefix of the context with the summary message
+func applyCompaction(c *types.ContextWindow, e *types.ContextCompactedEvent) {
+	n := e.MessagesCompacted
+	if n > len(c.Messages) {
+		n = len(c.Messages)
+	}
+
+	summary := types.Message{
+		Role:      "user",
+		Content:   summaryPrefix + e.Summary,
+		Timestamp: e.EventTimestamp(),
+	}
+	kept := c.Messages[n:]
+	c.Messages = append([]types.Message{summary}, kept...)
+
+	ts := e.EventTimestamp()
+	c.LastCompactionAt = &ts
+	c.CompactionCount++
+}
@@ -0,0 +1,171 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func TestCompactionSplitKeepsToolPairs(t *testing.T) {
+	msgs := []types.Message{
+		{Role: "user", Content: "a"},
+		{Role: "assistant", Content: "b"},
+		{Role: "user", Content: "c"},
+		{Role: "assistant", ToolCalls: []types.ToolCall{{ID: "1", Name: "read_file"}, {ID: "2", Name: "glob"}}},
+		{Role: "tool", ToolCallID: "1", Content: "x"},
+		{Role: "tool", ToolCallID: "2", Content: "y"},
+		{Role: "assistant", Content: "done"},
+	}
+
+	// Keeping the last 2 messages would split the tool results from their call
+	split := compactionSplit(msgs, 2)
+	if split != 3 {
+		t.Fatalf("expected split at the assistant tool call (3), got %d", split)
+	}
+	if compactionSplit(msgs, 10) != 0 {
+		t.Fatalf("expected no split when keeping more messages than exist")
+	}
+}
+
+func TestReducerTracksTokenCounts(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	evt := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: strings.Repeat("x", 400)}
+	if err := rt.applyEvent(context.Background(), evt); err != nil {
+		t.Fatalf("apply event error: %v", err)
+	}
+	msg := rt.state.Context.Messages[0]
+	if msg.TokenCount != estimateTokens(msg) || msg.TokenCount < 100 {
+		t.Fatalf("expected token count to be estimated, got %d", msg.TokenCount)
+	}
+	if rt.state.Context.TotalTokens != msg.TokenCount {
+		t.Fatalf("expected total tokens %d, got %d", msg.TokenCount, rt.state.Context.TotalTokens)
+	}
+}
+
+func TestCompactReplacesOlderMessages(t *testing.T) {
+	ms := newMockStore()
+	cfg := DefaultConfig
+	cfg.CompactionKeepRecent = 2
+	rt := New(cfg, ms, mockLLM{}, &mockTools{}, nil)
+	for i := 0; i < 6; i++ {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		rt.state.Context.Messages = append(rt.state.Context.Messages, types.Message{Role: role, Content: strings.Repeat("y", 100)})
+	}
+	refreshTokenCounts(rt.state.Context)
+
+	evt, err := rt.Compact(context.Background())
+	if err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+	if evt.MessagesCompacted != 4 || evt.Trigger != "manual" {
+		t.Fatalf("unexpected compaction event: %+v", evt)
+	}
+	if evt.TokensAfter >= evt.TokensBefore {
+		t.Fatalf("expected fewer tokens after compaction: %d -> %d", evt.TokensBefore, evt.TokensAfter)
+	}
+
+	state := rt.GetState()
+	if len(state.Context.Messages) != 3 {
+		t.Fatalf("expected summary + 2 kept messages, got %d", len(state.Context.Messages))
+	}
+	if !strings.HasPrefix(state.Context.Messages[0].Content, summaryPrefix) || !strings.Contains(state.Context.Messages[0].Content, "reply") {
+		t.Fatalf("unexpected summary message: %q", state.Context.Messages[0].Content)
+	}
+	if state.Context.CompactionCount != 1 || state.Context.LastCompactionAt == nil {
+		t.Fatalf("expected compaction bookkeeping to be updated: %+v", state.Context)
+	}
+	if len(ms.events) != 1 || ms.events[0].EventType() != "context_compacted" {
+		t.Fatalf("expected compaction event to be persisted")
+	}
+}
+
+func TestCompactRequiresHistory(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	rt.state.Context.Messages = []types.Message{{Role: "user", Content: "hi"}}
+	if _, err := rt.Compact(context.Background()); err != ErrNothingToCompact {
+		t.Fatalf("expected ErrNothingToCompact, got %v", err)
+	}
+}
+
+func TestNeedsCompaction(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.MaxContextTokens = 100
+	cfg.ReserveOutputTokens = 20
+	rt := New(cfg, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	rt.state.Context.TotalTokens = 50
+	if rt.needsCompaction() {
+		t.Fatalf("did not expect compaction under budget")
+	}
+	rt.state.Context.TotalTokens = 90
+	if !rt.needsCompaction() {
+		t.Fatalf("expected compaction over budget")
+	}
+}
+
+// changingLLM changes the runtime state while a summary is being written
+type changingLLM struct {
+	mockLLM
+	calls     int
+	onSummary func(call int)
+}
+
+func (l *changingLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	l.calls++
+	l.onSummary(l.calls)
+	return l.mockLLM.Chat(ctx, req)
+}
+
+func TestCompactHandlesConcurrentChanges(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.CompactionKeepRecent = 2
+	newRuntime := func(l *changingLLM) *Runtime {
+		rt := New(cfg, newMockStore(), l, &mockTools{}, nil)
+		for i := 0; i < 6; i++ {
+			rt.state.Context.Messages = append(rt.state.Context.Messages, types.Message{Role: "user", Content: fmt.Sprintf("message %d", i)})
+		}
+		refreshTokenCounts(rt.state.Context)
+		return rt
+	}
+	userMessage := func(rt *Runtime, content string) {
+		evt := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", ""), Content: content}
+		if err := rt.applyEvent(context.Background(), evt); err != nil {
+			t.Fatalf("apply event error: %v", err)
+		}
+	}
+
+	// A message arriving during the summary is kept after it
+	var rt *Runtime
+	appending := &changingLLM{onSummary: func(call int) { userMessage(rt, "late message") }}
+	rt = newRuntime(appending)
+	if _, err := rt.Compact(context.Background()); err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+	msgs := rt.GetState().Context.Messages
+	if len(msgs) != 4 || msgs[1].Content != "message 4" || msgs[3].Content != "late message" {
+		t.Fatalf("expected the summary, the kept messages and the late one, got %+v", msgs)
+	}
+
+	// A summarized message that changed makes the summary stale, so it is rebuilt
+	rewriting := &changingLLM{onSummary: func(call int) {
+		if call == 1 {
+			rt.mu.Lock()
+			rt.state.Context.Messages[0].Content = "edited"
+			rt.state.Version++
+			rt.mu.Unlock()
+		}
+	}}
+	rt = newRuntime(rewriting)
+	if _, err := rt.Compact(context.Background()); err != nil {
+		t.Fatalf("compact error: %v", err)
+	}
+	if rewriting.calls != 2 || len(rt.GetState().Context.Messages) != 3 {
+		t.Fatalf("expected one retry, got %d calls and %d messages", rewriting.calls, len(rt.GetState().Context.Messages))
+	}
+}
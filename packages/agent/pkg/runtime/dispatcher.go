@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
 
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
@@ -11,42 +12,32 @@ import (
 
 // dispatch executes a list of commands and returns produced events
 func (r *Runtime) dispatch(ctx context.Context, cmds []types.Command) ([]types.Event, error) {
-	var allEvents []types.Event
-
-	for _, cmd := range cmds {
-		var events []types.Event
-		var err error
+	// Events are collected per command so they keep the original order
+	// even when tool calls finish out of order
+	results := make([][]types.Event, len(cmds))
 
-		// Deps injection
-		// This is where we wire dependencies.
-		// NOTE: In strict architecture, Command.Execute should be a method on the runtime/dispatcher
-		// rather than on the DTO itself to avoid dragging deps into `pkg/types`.
-		// Since we removed `Execute` from types.Command, we handle it here via switch.
-
-		switch c := cmd.(type) {
-		case *types.CallLLMCommand:
-			events, err = r.executeCallLLM(ctx, c)
-		case *types.CallToolCommand:
-			events, err = r.executeCallTool(ctx, c)
-		// case *types.ApplyPatchCommand:
-		default:
-			// log unknown
-		}
-
-		if err != nil {
-			r.log.Error("command execution failed", "command_id", cmd.CommandID(), "error", err)
-			// Generate ErrorEvent
-			errEvent := &types.ErrorEvent{
-				BaseEvent: types.NewBaseEvent("error", "runtime", ""),
-				CommandID: cmd.CommandID(),
-				Error:     err.Error(),
-				Severity:  types.SeverityRecoverable, // Default
+	for i := 0; i < len(cmds); {
+		// Consecutive tool calls run concurrently, coordinated by resource locks
+		j := i
+		for j < len(cmds) {
+			if _, ok := cmds[j].(*types.CallToolCommand); !ok {
+				break
 			}
-			allEvents = append(allEvents, errEvent)
-			// For MVP, we don't break on error events, we feed them back to LLM
-		} else {
-			allEvents = append(allEvents, events...)
+			j++
+		}
+		if j > i {
+			r.dispatchToolCalls(ctx, cmds[i:j], results[i:j])
+			i = j
+			continue
 		}
+
+		results[i] = r.execute(ctx, cmds[i])
+		i++
+	}
+
+	var allEvents []types.Event
+	for _, events := range results {
+		allEvents = append(allEvents, events...)
 	}
 
 	// Persist all events
@@ -59,65 +50,202 @@ func (r *Runtime) dispatch(ctx context.Context, cmds []types.Command) ([]types.E
 	return allEvents, nil
 }
 
+// dispatchToolCalls runs tool calls concurrently, writing each call's events to results[i].
+// Locks are acquired in call order, so calls touching the same resource run in the order
+// the LLM issued them while independent reads overlap.
+func (r *Runtime) dispatchToolCalls(ctx context.Context, cmds []types.Command, results [][]types.Event) {
+	readOnly := r.readOnlyTools()
+
+	var wg sync.WaitGroup
+	for i, cmd := range cmds {
+		call := cmd.(*types.CallToolCommand)
+		lock := lockFor(call, readOnly)
+		if lock != nil {
+			if err := r.acquireLock(ctx, lock, call.ToolCallID); err != nil {
+				// Cancelled while waiting: this call and the later ones never run, but each
+				// still gets a result so the assistant's tool calls stay answered
+				for j := i; j < len(cmds); j++ {
+					results[j] = []types.Event{failedToolResult(cmds[j].(*types.CallToolCommand), fmt.Errorf("waiting for resource lock: %w", err))}
+				}
+				break
+			}
+		}
+
+		wg.Add(1)
+		go func(i int, cmd types.Command, lock *toolLock) {
+			defer wg.Done()
+			if lock != nil {
+				defer r.releaseLock(lock)
+			}
+			results[i] = r.execute(ctx, cmd)
+		}(i, cmd, lock)
+	}
+	wg.Wait()
+}
+
+// execute runs a single command, turning a failure into a recoverable ErrorEvent
+func (r *Runtime) execute(ctx context.Context, cmd types.Command) []types.Event {
+	var events []types.Event
+	var err error
+
+	// Deps injection
+	// This is where we wire dependencies.
+	// NOTE: In strict architecture, Command.Execute should be a method on the runtime/dispatcher
+	// rather than on the DTO itself to avoid dragging deps into `pkg/types`.
+	// Since we removed `Execute` from types.Command, we handle it here via switch.
+
+	switch c := cmd.(type) {
+	case *types.CallLLMCommand:
+		events, err = r.executeCallLLM(ctx, c)
+	case *types.CallToolCommand:
+		events, err = r.executeCallTool(ctx, c)
+	case *types.ModeTransitionCommand:
+		events, err = r.executeModeTransition(ctx, c)
+	// case *types.ApplyPatchCommand:
+	default:
+		// log unknown
+	}
+
+	if err != nil {
+		return r.commandError(cmd, err)
+	}
+	return events
+}
+
+// commandError turns the failure of a command into a recoverable ErrorEvent
+func (r *Runtime) commandError(cmd types.Command, err error) []types.Event {
+	r.log.Error("command execution failed", "command_id", cmd.CommandID(), "error", err)
+	errEvent := &types.ErrorEvent{
+		BaseEvent: types.NewBaseEvent("error", "runtime", ""),
+		CommandID: cmd.CommandID(),
+		Error:     err.Error(),
+		Severity:  types.SeverityRecoverable, // Default
+	}
+	if c, ok := cmd.(*types.CallLLMCommand); ok {
+		errEvent.GoalID = c.GoalID
+	}
+	// The reducer feeds recoverable errors back to the LLM (see errors.go)
+	return []types.Event{errEvent}
+}
+
 func (r *Runtime) executeCallLLM(ctx context.Context, cmd *types.CallLLMCommand) ([]types.Event, error) {
 	req := &llm.ChatRequest{
+		Provider: cmd.Provider,
 		Model:    cmd.Model,
-		Messages: cmd.Messages,
+		Messages: r.loadImages(ctx, cmd.Messages),
 		Tools:    cmd.Tools,
 	}
 
+	// Record gateway retries and fallbacks on the session timeline
+	model := cmd.Model
+	ctx = llm.WithRetryObserver(ctx, func(e llm.RetryEvent) {
+		r.log.Warn("llm call failed", "action", e.Action, "provider", e.Provider, "attempt", e.Attempt, "kind", e.Kind, "error", e.Err)
+		if e.Action == "fallback" {
+			model = e.NextModel
+		}
+		evt := &types.LLMRetryEvent{
+			BaseEvent:    types.NewBaseEvent("llm_retry", "llm", e.Provider),
+			Action:       e.Action,
+			Provider:     e.Provider,
+			Model:        e.Model,
+			Attempt:      e.Attempt,
+			ErrorKind:    string(e.Kind),
+			Error:        e.Err.Error(),
+			DelayMs:      e.Delay.Milliseconds(),
+			NextProvider: e.NextProvider,
+			NextModel:    e.NextModel,
+		}
+		if err := r.store.AppendEvent(ctx, evt); err != nil {
+			r.log.Error("failed to append retry event", "error", err)
+		}
+	})
+
 	// 1. Start Stream
 	r.log.Info("attempting to start streaming")
 	stream, err := r.llm.StreamChat(ctx, req)
 	if err != nil {
+		// Provider failures were already retried by the gateway; only fall back
+		// to a sync call when streaming itself is the problem
+		if llm.ClassifyError(err) != llm.ErrorKindUnknown {
+			return nil, err
+		}
 		r.log.Warn("streaming failed, falling back to sync", "error", err)
 		resp, err := r.llm.Chat(ctx, req)
 		if err != nil {
 			return nil, err
 		}
-		return []types.Event{r.createResponseEvent(resp)}, nil
+		return r.withPlan(cmd, r.createResponseEvent(cmd, resp)), nil
 	}
 	r.log.Info("streaming started successfully")
 
 	// 2. Consume Stream
 	var fullContent string
 	var allToolCalls []types.ToolCall
+	var usage types.Usage
+	var calls toolCallStream
 
 	for chunk := range stream {
+		if chunk.Err != nil {
+			// The provider failed partway; the partial response is not a completion
+			return nil, fmt.Errorf("stream: %w", chunk.Err)
+		}
 		r.log.Debug("received chunk", "content_len", len(chunk.Content), "tool_calls", len(chunk.ToolCalls))
+		if chunk.Reasoning != "" {
+			r.appendStreamEvent(ctx, &types.LLMReasoningEvent{
+				BaseEvent: types.NewBaseEvent("llm_reasoning", "llm", ""),
+				Delta:     chunk.Reasoning,
+			})
+		}
 		if chunk.Content != "" {
 			fullContent += chunk.Content
 			// Emit Token Event immediately
-			tokenEvt := &types.LLMTokenEvent{
+			r.appendStreamEvent(ctx, &types.LLMTokenEvent{
 				BaseEvent: types.NewBaseEvent("llm_token", "llm", ""),
 				Delta:     chunk.Content,
-			}
-			if err := r.store.AppendEvent(ctx, tokenEvt); err != nil {
-				r.log.Error("failed to append token event", "error", err)
+			})
+		}
+		for _, d := range chunk.ToolCallDeltas {
+			for _, evt := range calls.delta(d) {
+				r.appendStreamEvent(ctx, evt)
 			}
 		}
 		if len(chunk.ToolCalls) > 0 {
 			allToolCalls = append(allToolCalls, chunk.ToolCalls...)
 		}
+		if chunk.Usage != nil {
+			usage = *chunk.Usage
+		}
+	}
+	for _, evt := range calls.end(allToolCalls) {
+		r.appendStreamEvent(ctx, evt)
 	}
 
 	// 3. Final Response Event
 	evt := &types.LLMResponseEvent{
 		BaseEvent: types.NewBaseEvent("llm_response", "llm", ""),
-		Model:     cmd.Model,
+		Model:     model, // The fallback model if the gateway failed over
 		Content:   fullContent,
 		ToolCalls: allToolCalls,
+		Usage:     usage,
+		CostUSD:   r.priceUsage(model, usage),
+		GoalID:    cmd.GoalID,
 	}
-	return []types.Event{evt}, nil
+	return r.withPlan(cmd, evt), nil
 }
 
-func (r *Runtime) createResponseEvent(resp *llm.ChatResponse) types.Event {
+func (r *Runtime) createResponseEvent(cmd *types.CallLLMCommand, resp *llm.ChatResponse) *types.LLMResponseEvent {
+	model := resp.Model
+	if model == "" {
+		model = cmd.Model
+	}
 	return &types.LLMResponseEvent{
 		BaseEvent: types.NewBaseEvent("llm_response", "llm", ""),
-		Model:     resp.Model,
+		Model:     model,
 		Content:   resp.Content,
 		ToolCalls: resp.ToolCalls,
 		Usage:     resp.Usage,
+		CostUSD:   r.priceUsage(model, resp.Usage),
+		GoalID:    cmd.GoalID,
 	}
 }
 
@@ -140,17 +268,19 @@ func (r *Runtime) executeCallTool(ctx context.Context, cmd *types.CallToolComman
 	// Get current runtime mode
 	currentMode := r.getCurrentMode()
 
-	result, err := r.tools.Execute(ctx, currentMode, call)
+	// Runtime-aware handlers (e.g. spawn_agent) find the calling runtime in ctx
+	tc := &toolCallContext{runtime: r, toolCallID: cmd.ToolCallID, goalID: cmd.GoalID}
+	ctx = context.WithValue(ctx, toolCallKey{}, tc)
+
+	var result *types.ToolResult
+	err := r.checkGoalTool(cmd.GoalID, cmd.ToolName)
+	if err == nil {
+		result, err = r.tools.Execute(ctx, currentMode, call)
+	}
 
 	var resEvent *types.ToolResultEvent
 	if err != nil {
-		resEvent = &types.ToolResultEvent{
-			BaseEvent:  types.NewBaseEvent("tool_result", "tool", cmd.ToolName),
-			ToolCallID: cmd.ToolCallID,
-			ToolName:   cmd.ToolName,
-			Success:    false,
-			Error:      err.Error(),
-		}
+		resEvent = failedToolResult(cmd, err)
 	} else {
 		if result != nil && result.IsError {
 			resEvent = &types.ToolResultEvent{
@@ -160,8 +290,9 @@ func (r *Runtime) executeCallTool(ctx context.Context, cmd *types.CallToolComman
 				Success:    false,
 				Error:      result.Error,
 				Output:     result.Content,
+				GoalID:     cmd.GoalID,
 			}
-			return []types.Event{resEvent}, nil
+			return append(tc.events, resEvent), nil
 		}
 
 		resEvent = &types.ToolResultEvent{
@@ -170,7 +301,21 @@ func (r *Runtime) executeCallTool(ctx context.Context, cmd *types.CallToolComman
 			ToolName:   cmd.ToolName,
 			Success:    true,
 			Output:     result.Content,
+			Parts:      result.Parts,
+			GoalID:     cmd.GoalID,
 		}
 	}
-	return []types.Event{resEvent}, nil
+	return append(tc.events, resEvent), nil
+}
+
+// failedToolResult is the result of a tool call that could not run
+func failedToolResult(cmd *types.CallToolCommand, err error) *types.ToolResultEvent {
+	return &types.ToolResultEvent{
+		BaseEvent:  types.NewBaseEvent("tool_result", "tool", cmd.ToolName),
+		ToolCallID: cmd.ToolCallID,
+		ToolName:   cmd.ToolName,
+		Success:    false,
+		Error:      err.Error(),
+		GoalID:     cmd.GoalID,
+	}
 }
@@ -0,0 +1,80 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// failingProvider always fails with a server error
+type failingProvider struct{ id string }
+
+func (p failingProvider) ID() string { return p.id }
+func (p failingProvider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	return nil, &llm.ProviderError{Provider: p.id, StatusCode: 503, Message: "unavailable"}
+}
+func (p failingProvider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	return nil, &llm.ProviderError{Provider: p.id, StatusCode: 503, Message: "unavailable"}
+}
+
+// echoProvider streams the requested model name
+type echoProvider struct{}
+
+func (echoProvider) ID() string { return "echo" }
+func (echoProvider) Call(ctx context.Context, req *llm.ProviderRequest) (*llm.ProviderResponse, error) {
+	return &llm.ProviderResponse{Model: req.Model, Content: req.Model}, nil
+}
+func (echoProvider) CallStream(ctx context.Context, req *llm.ProviderRequest) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, 1)
+	ch <- llm.StreamChunk{Content: req.Model}
+	close(ch)
+	return ch, nil
+}
+
+func TestExecuteCallLLMRecordsRetriesAndFallback(t *testing.T) {
+	gw := llm.NewGateway(failingProvider{id: "primary"}, config.ProviderOptions{})
+	gw.SetRetryPolicy(llm.RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond, MaxBackoff: time.Millisecond})
+	gw.AddFallback(echoProvider{}, config.ProviderOptions{Model: "backup-model"})
+
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, gw, &mockTools{}, nil)
+
+	events, err := rt.executeCallLLM(context.Background(), &types.CallLLMCommand{Model: "main-model"})
+	if err != nil {
+		t.Fatalf("execute call llm error: %v", err)
+	}
+	resp := events[0].(*types.LLMResponseEvent)
+	if resp.Content != "backup-model" || resp.Model != "backup-model" {
+		t.Fatalf("expected response from fallback model, got %+v", resp)
+	}
+
+	var retries []*types.LLMRetryEvent
+	for _, e := range ms.events {
+		if re, ok := e.(*types.LLMRetryEvent); ok {
+			retries = append(retries, re)
+		}
+	}
+	if len(retries) != 2 {
+		t.Fatalf("expected retry and fallback events, got %d", len(retries))
+	}
+	if retries[0].Action != "retry" || retries[0].ErrorKind != "server" || retries[0].Provider != "primary" {
+		t.Fatalf("unexpected retry event: %+v", retries[0])
+	}
+	if retries[1].Action != "fallback" || retries[1].NextProvider != "echo" || retries[1].NextModel != "backup-model" {
+		t.Fatalf("unexpected fallback event: %+v", retries[1])
+	}
+}
+
+func TestExecuteCallLLMDoesNotRepeatFailedChain(t *testing.T) {
+	gw := llm.NewGateway(failingProvider{id: "primary"}, config.ProviderOptions{})
+	gw.SetRetryPolicy(llm.RetryPolicy{MaxAttempts: 1})
+
+	rt := New(DefaultConfig, newMockStore(), gw, &mockTools{}, nil)
+	if _, err := rt.executeCallLLM(context.Background(), &types.CallLLMCommand{Model: "m"}); llm.ClassifyError(err) != llm.ErrorKindServer {
+		t.Fatalf("expected server error to surface, got %v", err)
+	}
+}
@@ -0,0 +1,107 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// reduceError counts an ErrorEvent against its goal and, if enabled, shows a
+// recoverable error to the LLM so the next decision can work around it
+func (r *Runtime) reduceError(state *types.State, e *types.ErrorEvent) {
+	if goal := findGoal(state, e.GoalID); goal != nil && goalActive(goal) {
+		goal.ConsecutiveErrors++
+		observeError(&goal.Loop, e.Error)
+		goal.UpdatedAt = e.EventTimestamp()
+	}
+
+	if r.config.SurfaceErrors && e.Severity != types.SeverityFatal {
+		state.Context.Messages = append(state.Context.Messages, types.Message{
+			Role:      "user",
+			Content:   fmt.Sprintf("The previous step failed with an error:\n%s\nTry a different approach, or explain why the task cannot be done.", e.Error),
+			Timestamp: e.EventTimestamp(),
+		})
+	}
+}
+
+// escalatedGoals returns the events that give up on goals after errors: a fatal
+// error or too many consecutive errors fails the goal, and a recovery goal may
+// take over its work
+func escalatedGoals(state *types.State, cfg Config, events []types.Event) []types.Event {
+	var out []types.Event
+	failed := map[string]bool{}
+	for _, evt := range events {
+		e, ok := evt.(*types.ErrorEvent)
+		if !ok {
+			continue
+		}
+		goal := findGoal(state, e.GoalID)
+		if goal == nil || !goalActive(goal) || failed[goal.ID] {
+			continue
+		}
+
+		var reason string
+		switch {
+		case e.Severity == types.SeverityFatal:
+			reason = fmt.Sprintf("fatal error: %s", e.Error)
+		case cfg.MaxConsecutiveErrors > 0 && goal.ConsecutiveErrors >= cfg.MaxConsecutiveErrors:
+			reason = fmt.Sprintf("%d consecutive errors, last: %s", goal.ConsecutiveErrors, e.Error)
+		default:
+			continue
+		}
+		failed[goal.ID] = true
+		out = append(out, &types.GoalFailedEvent{
+			BaseEvent: types.NewBaseEvent("goal_failed", "runtime", goal.ID),
+			GoalID:    goal.ID,
+			Reason:    reason,
+		})
+
+		// A failing recovery goal is not recovered again
+		if cfg.RecoveryGoals && goal.Type != types.GoalTypeRecovery {
+			out = append(out, recoveryGoal(goal, e))
+		}
+	}
+	return out
+}
+
+// recoveryGoal creates a goal that takes over from a goal that failed on err.
+// It outranks its parent so the recovery runs while the failure is in context.
+func recoveryGoal(parent *types.Goal, err *types.ErrorEvent) *types.GoalCreatedEvent {
+	now := err.EventTimestamp()
+	goal := types.Goal{
+		ID:            types.GenerateGoalID(),
+		Type:          types.GoalTypeRecovery,
+		Description:   fmt.Sprintf("Recover from the failure of %q (error: %s). Fix the cause if possible, otherwise report what went wrong.", parent.Description, err.Error),
+		Priority:      parent.Priority - 1,
+		Status:        types.GoalStatusPending,
+		SourceEventID: err.EventID(),
+		ParentGoalID:  parent.ID,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	return &types.GoalCreatedEvent{
+		BaseEvent: types.NewBaseEvent("goal_created", "runtime", goal.ID),
+		Goal:      goal,
+	}
+}
+
+// escalateErrors fails goals after the error events of a dispatch
+func (r *Runtime) escalateErrors(ctx context.Context, events []types.Event) error {
+	r.mu.RLock()
+	escalated := escalatedGoals(r.state, r.config, events)
+	r.mu.RUnlock()
+
+	for _, evt := range escalated {
+		if failed, ok := evt.(*types.GoalFailedEvent); ok {
+			r.log.Warn("goal failed", "goal_id", failed.GoalID, "reason", failed.Reason)
+		}
+		if err := r.store.AppendEvent(ctx, evt); err != nil {
+			r.log.Error("failed to append error escalation event", "error", err)
+		}
+		if err := r.applyEvent(ctx, evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
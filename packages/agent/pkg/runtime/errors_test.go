@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// brokenLLM fails every call with a server error
+type brokenLLM struct{}
+
+func (brokenLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	return nil, &llm.ProviderError{Provider: "broken", StatusCode: 500, Message: "upstream down"}
+}
+
+func (brokenLLM) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	return nil, &llm.ProviderError{Provider: "broken", StatusCode: 500, Message: "upstream down"}
+}
+
+func TestRecoverableErrorIsSurfacedAndCounted(t *testing.T) {
+	ctx := context.Background()
+	rt := New(DefaultConfig, newMockStore(), &mockLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusInProgress}}
+
+	errEvt := &types.ErrorEvent{
+		BaseEvent: types.NewBaseEvent("error", "runtime", ""),
+		Error:     "upstream down",
+		Severity:  types.SeverityRecoverable,
+		GoalID:    "g1",
+	}
+	if err := rt.applyEvent(ctx, errEvt); err != nil {
+		t.Fatalf("apply error event: %v", err)
+	}
+	state := rt.GetState()
+	if state.Goals[0].ConsecutiveErrors != 1 {
+		t.Fatalf("expected 1 consecutive error, got %d", state.Goals[0].ConsecutiveErrors)
+	}
+	msgs := state.Context.Messages
+	if len(msgs) != 1 || msgs[0].Role != "user" || !strings.Contains(msgs[0].Content, "upstream down") {
+		t.Fatalf("expected the error to be shown to the LLM, got %+v", msgs)
+	}
+
+	resp := &types.LLMResponseEvent{BaseEvent: types.NewBaseEvent("llm_response", "llm", ""), Content: "ok", GoalID: "g1"}
+	if err := rt.applyEvent(ctx, resp); err != nil {
+		t.Fatalf("apply response: %v", err)
+	}
+	if n := rt.GetState().Goals[0].ConsecutiveErrors; n != 0 {
+		t.Fatalf("expected a successful call to reset the counter, got %d", n)
+	}
+}
+
+func TestErrorsAreNotSurfacedWhenDisabled(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.SurfaceErrors = false
+	rt := New(cfg, newMockStore(), &mockLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusInProgress}}
+
+	errEvt := &types.ErrorEvent{BaseEvent: types.NewBaseEvent("error", "runtime", ""), Error: "boom", Severity: types.SeverityRecoverable}
+	if err := rt.applyEvent(context.Background(), errEvt); err != nil {
+		t.Fatalf("apply error event: %v", err)
+	}
+	state := rt.GetState()
+	if len(state.Context.Messages) != 0 || state.Goals[0].ConsecutiveErrors != 1 {
+		t.Fatalf("expected the error counted but not surfaced, got %+v", state)
+	}
+}
+
+func TestFatalErrorFailsGoal(t *testing.T) {
+	state := types.NewState()
+	state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusInProgress}}
+	fatal := &types.ErrorEvent{BaseEvent: types.NewBaseEvent("error", "runtime", ""), Error: "disk full", Severity: types.SeverityFatal, GoalID: "g1"}
+
+	events := escalatedGoals(state, DefaultConfig, []types.Event{fatal})
+	if len(events) != 1 {
+		t.Fatalf("expected one goal failed event, got %d", len(events))
+	}
+	failed, ok := events[0].(*types.GoalFailedEvent)
+	if !ok || failed.GoalID != "g1" || !strings.Contains(failed.Reason, "disk full") {
+		t.Fatalf("unexpected escalation %+v", events[0])
+	}
+}
+
+func TestRunFailsGoalAfterConsecutiveErrors(t *testing.T) {
+	ms := newMockStore()
+	cfg := DefaultConfig
+	cfg.MaxConsecutiveErrors = 2
+	rt := New(cfg, ms, brokenLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusPending}}
+
+	if err := rt.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	goal := rt.GetState().Goals[0]
+	if goal.Status != types.GoalStatusFailed || goal.ConsecutiveErrors != 2 {
+		t.Fatalf("expected goal failed after 2 errors, got %+v", goal)
+	}
+	var errCount, failed int
+	for _, e := range ms.events {
+		switch e.(type) {
+		case *types.ErrorEvent:
+			errCount++
+		case *types.GoalFailedEvent:
+			failed++
+		}
+	}
+	if errCount != 2 || failed != 1 {
+		t.Fatalf("expected 2 error events and 1 goal_failed event, got %d and %d", errCount, failed)
+	}
+}
+
+func TestRecoveryGoalTakesOver(t *testing.T) {
+	ms := newMockStore()
+	cfg := DefaultConfig
+	cfg.MaxConsecutiveErrors = 1
+	cfg.RecoveryGoals = true
+	rt := New(cfg, ms, brokenLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Description: "fix the build", Priority: 2, Status: types.GoalStatusPending}}
+
+	if err := rt.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	goals := rt.GetState().Goals
+	if len(goals) != 2 {
+		t.Fatalf("expected the failed goal and one recovery goal, got %+v", goals)
+	}
+	recovery := goals[1]
+	if recovery.Type != types.GoalTypeRecovery || recovery.ParentGoalID != "g1" || recovery.Priority != 1 {
+		t.Fatalf("unexpected recovery goal %+v", recovery)
+	}
+	if !strings.Contains(recovery.Description, "fix the build") {
+		t.Fatalf("expected recovery goal to describe its parent, got %q", recovery.Description)
+	}
+	// The recovery goal ran and failed too, without spawning another recovery
+	if goals[0].Status != types.GoalStatusFailed || recovery.Status != types.GoalStatusFailed {
+		t.Fatalf("expected both goals failed, got %s and %s", goals[0].Status, recovery.Status)
+	}
+}
@@ -0,0 +1,46 @@
+package runtime
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// controlTools stay available to goals restricted to a set of tools, so they
+// can still talk to the user and finish
+var controlTools = []string{"talk", "task_complete"}
+
+// goalToolList filters tools down to those a goal may use
+func goalToolList(goal *types.Goal, tools []types.Tool) []types.Tool {
+	if len(goal.AllowedTools) == 0 {
+		return tools
+	}
+	var filtered []types.Tool
+	for _, t := range tools {
+		if goalAllowsTool(goal, t.Name) {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+func goalAllowsTool(goal *types.Goal, name string) bool {
+	return len(goal.AllowedTools) == 0 || slices.Contains(goal.AllowedTools, name) || slices.Contains(controlTools, name)
+}
+
+// checkGoalTool rejects calls to tools outside the allowed tools of the goal
+// that requested them (e.g. a slash command with allowed-tools)
+func (r *Runtime) checkGoalTool(goalID, name string) error {
+	if goalID == "" {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i := range r.state.Goals {
+		if goal := &r.state.Goals[i]; goal.ID == goalID && !goalAllowsTool(goal, name) {
+			return fmt.Errorf("tool %s is not allowed for this goal (allowed: %v)", name, goal.AllowedTools)
+		}
+	}
+	return nil
+}
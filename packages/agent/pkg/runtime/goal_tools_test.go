@@ -0,0 +1,76 @@
+package runtime
+
+import (
+	"context"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+type namedTools struct {
+	mockTools
+	names []string
+}
+
+func (m *namedTools) List() []types.Tool {
+	tools := make([]types.Tool, len(m.names))
+	for i, name := range m.names {
+		tools[i] = types.Tool{Name: name}
+	}
+	return tools
+}
+
+func TestGoalAllowedToolsAndModel(t *testing.T) {
+	tools := &namedTools{names: []string{"read_file", "run_shell", "talk", "task_complete"}}
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, tools, nil)
+	evt := &types.UserMessageEvent{
+		BaseEvent:    types.NewBaseEvent("user_message", "user", "cli"),
+		Content:      "Review main.go",
+		Command:      "review",
+		AllowedTools: []string{"read_file"},
+		Model:        "small-model",
+	}
+	if err := rt.applyEvent(context.Background(), evt); err != nil {
+		t.Fatalf("apply event: %v", err)
+	}
+	goal := rt.GetState().Goals[0]
+
+	decision, err := rt.decide(context.Background(), &goal)
+	if err != nil {
+		t.Fatalf("decide: %v", err)
+	}
+	cmd := decision.Commands[0].(*types.CallLLMCommand)
+	var names []string
+	for _, tool := range cmd.Tools {
+		names = append(names, tool.Name)
+	}
+	if len(names) != 3 || names[0] != "read_file" || names[1] != "talk" || names[2] != "task_complete" {
+		t.Fatalf("expected read_file and the control tools, got %v", names)
+	}
+	if cmd.Model != "small-model" {
+		t.Fatalf("expected the goal model, got %q", cmd.Model)
+	}
+
+	events, err := rt.executeCallTool(context.Background(), &types.CallToolCommand{
+		BaseCommand: types.NewBaseCommand("call_tool"),
+		ToolCallID:  "call-1",
+		ToolName:    "run_shell",
+		GoalID:      goal.ID,
+	})
+	if err != nil {
+		t.Fatalf("execute tool: %v", err)
+	}
+	res := events[len(events)-1].(*types.ToolResultEvent)
+	if res.Success || len(tools.executed) != 0 {
+		t.Fatalf("expected run_shell to be rejected, got %+v (executed %d)", res, len(tools.executed))
+	}
+
+	if _, err := rt.executeCallTool(context.Background(), &types.CallToolCommand{
+		BaseCommand: types.NewBaseCommand("call_tool"),
+		ToolCallID:  "call-2",
+		ToolName:    "read_file",
+		GoalID:      goal.ID,
+	}); err != nil || len(tools.executed) != 1 {
+		t.Fatalf("expected read_file to run, got %v (executed %d)", err, len(tools.executed))
+	}
+}
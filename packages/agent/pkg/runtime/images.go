@@ -0,0 +1,81 @@
+package runtime
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// loadImages returns the messages with artifact image parts replaced by their
+// base64 data, since providers cannot read the artifact store. Messages
+// without artifact images are shared, not copied.
+func (r *Runtime) loadImages(ctx context.Context, msgs []types.Message) []types.Message {
+	var out []types.Message
+	for i, m := range msgs {
+		if !hasArtifactImage(m) {
+			if out != nil {
+				out = append(out, m)
+			}
+			continue
+		}
+		if out == nil {
+			out = append(make([]types.Message, 0, len(msgs)), msgs[:i]...)
+		}
+		m = m.Clone()
+		for j, p := range m.Parts {
+			if p.Type != types.ContentPartImage || p.Data != "" || p.ArtifactID == "" {
+				continue
+			}
+			loaded, err := r.loadArtifactImage(ctx, p)
+			if err != nil {
+				// Tell the model instead of failing every call of the session
+				r.log.Warn("failed to load image artifact", "artifact_id", p.ArtifactID, "error", err)
+				loaded = types.ContentPart{Type: types.ContentPartText, Text: fmt.Sprintf("[image %s is unavailable: %v]", p.ArtifactID, err)}
+			}
+			m.Parts[j] = loaded
+		}
+		out = append(out, m)
+	}
+	if out == nil {
+		return msgs
+	}
+	return out
+}
+
+func (r *Runtime) loadArtifactImage(ctx context.Context, p types.ContentPart) (types.ContentPart, error) {
+	art, err := r.store.GetArtifact(ctx, p.ArtifactID)
+	if err != nil {
+		return p, err
+	}
+	data := art.Content
+	if len(data) == 0 && art.Path != "" {
+		if data, err = os.ReadFile(art.Path); err != nil {
+			return p, err
+		}
+	}
+	if len(data) == 0 {
+		return p, fmt.Errorf("artifact has no content")
+	}
+
+	if p.MimeType == "" {
+		p.MimeType = art.Metadata["mime_type"]
+	}
+	if p.MimeType == "" {
+		p.MimeType = http.DetectContentType(data)
+	}
+	p.Data = base64.StdEncoding.EncodeToString(data)
+	return p, nil
+}
+
+func hasArtifactImage(m types.Message) bool {
+	for _, p := range m.Parts {
+		if p.Type == types.ContentPartImage && p.Data == "" && p.ArtifactID != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,79 @@
+package runtime
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func TestArtifactImagesAreLoadedForTheLLM(t *testing.T) {
+	ctx := context.Background()
+	ms := newMockStore()
+	png := []byte("\x89PNG\r\n\x1a\nfake")
+	art := &types.Artifact{ID: "art_1", Type: "image", Content: png, Metadata: map[string]string{"mime_type": "image/png"}}
+	_ = ms.SaveArtifact(ctx, art)
+
+	gateway := &requestRecorder{}
+	rt := New(DefaultConfig, ms, gateway, &mockTools{}, nil)
+	for _, evt := range []types.Event{
+		&types.ArtifactCreatedEvent{BaseEvent: types.NewBaseEvent("artifact_created", "user", ""), Artifact: *art},
+		&types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_request", "user", ""), Parts: []types.ContentPart{
+			{Type: types.ContentPartText, Text: "what does this show?"},
+			{Type: types.ContentPartImage, ArtifactID: "art_1"},
+			{Type: types.ContentPartImage, ArtifactID: "art_gone"},
+		}},
+	} {
+		if err := rt.applyEvent(ctx, evt); err != nil {
+			t.Fatalf("apply %s: %v", evt.EventType(), err)
+		}
+	}
+
+	state := rt.GetState()
+	if stored := state.Artifacts["art_1"]; stored == nil || len(stored.Content) != 0 {
+		t.Fatalf("expected the artifact in state without content, got %+v", stored)
+	}
+	msg := state.Context.Messages[0]
+	if msg.Content != "what does this show?" || state.Goals[0].Description != "what does this show?" {
+		t.Fatalf("expected the text parts as content, got %q", msg.Content)
+	}
+
+	if _, err := rt.executeCallLLM(ctx, &types.CallLLMCommand{Model: "m", Messages: state.Context.Messages}); err != nil {
+		t.Fatalf("execute call llm: %v", err)
+	}
+	sent := gateway.last.Messages[0].Parts
+	if sent[1].Data != base64.StdEncoding.EncodeToString(png) || sent[1].MimeType != "image/png" {
+		t.Fatalf("expected the artifact to be sent inline, got %+v", sent[1])
+	}
+	if sent[2].Type != types.ContentPartText || !strings.Contains(sent[2].Text, "art_gone") {
+		t.Fatalf("expected a note for the missing artifact, got %+v", sent[2])
+	}
+	if rt.GetState().Context.Messages[0].Parts[1].Data != "" {
+		t.Fatalf("expected the image data to stay out of the state")
+	}
+}
+
+func TestToolImagesReachTheContext(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), &mockLLM{}, &mockTools{}, nil)
+	image := types.ContentPart{Type: types.ContentPartImage, MimeType: "image/png", Data: "iVBORw0K"}
+	evt := &types.ToolResultEvent{
+		BaseEvent:  types.NewBaseEvent("tool_result", "tool", "read_file"),
+		ToolCallID: "call_1",
+		ToolName:   "read_file",
+		Success:    true,
+		Output:     "Attached image a.png",
+		Parts:      []types.ContentPart{image},
+	}
+	if err := rt.applyEvent(context.Background(), evt); err != nil {
+		t.Fatalf("apply tool result: %v", err)
+	}
+	msg := rt.GetState().Context.Messages[0]
+	if msg.Role != "tool" || !msg.HasImages() || msg.Parts[0].Text != "Attached image a.png" {
+		t.Fatalf("expected the tool message to carry the image, got %+v", msg)
+	}
+	if msg.TokenCount < imageTokens {
+		t.Fatalf("expected the image to count towards the context, got %d tokens", msg.TokenCount)
+	}
+}
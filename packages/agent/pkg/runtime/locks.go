@@ -0,0 +1,161 @@
+package runtime
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// globalResource is locked by tools whose side effects cannot be scoped to a path (e.g. run_shell)
+const globalResource = "*"
+
+// resourceArgs are the tool arguments that name the file or directory a call touches
+var resourceArgs = []string{"path", "file_path", "base_dir"}
+
+// toolLock is the lock a tool call needs before it may run
+type toolLock struct {
+	Resource string
+	Type     types.LockType
+}
+
+// lockFor derives the resource and lock type for a tool call.
+// Read-only tools take a shared lock on their path (no lock if they touch no path);
+// other tools take an exclusive lock on their path, or on every resource if they name none.
+func lockFor(cmd *types.CallToolCommand, readOnly map[string]bool) *toolLock {
+	resource := ""
+	for _, key := range resourceArgs {
+		if p, ok := cmd.Arguments[key].(string); ok && p != "" {
+			resource = normalizeResource(p)
+			break
+		}
+	}
+
+	if readOnly[cmd.ToolName] {
+		if resource == "" {
+			return nil
+		}
+		return &toolLock{Resource: resource, Type: types.LockTypeRead}
+	}
+	if resource == "" {
+		resource = globalResource
+	}
+	return &toolLock{Resource: resource, Type: types.LockTypeWrite}
+}
+
+// normalizeResource maps a path argument to an absolute, cleaned lock key
+func normalizeResource(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return filepath.Clean(p)
+}
+
+// resourcesOverlap reports whether two resources may refer to the same file,
+// treating a directory as covering everything below it
+func resourcesOverlap(a, b string) bool {
+	if a == globalResource || b == globalResource || a == b {
+		return true
+	}
+	sep := string(filepath.Separator)
+	return strings.HasPrefix(a, strings.TrimSuffix(b, sep)+sep) ||
+		strings.HasPrefix(b, strings.TrimSuffix(a, sep)+sep)
+}
+
+// lockGrantableLocked reports whether want is compatible with every lock in the table.
+// Caller must hold r.mu.
+func (r *Runtime) lockGrantableLocked(want *toolLock) bool {
+	for _, held := range r.locks {
+		if !resourcesOverlap(held.Resource, want.Resource) {
+			continue
+		}
+		if held.Type == types.LockTypeWrite || want.Type == types.LockTypeWrite {
+			return false
+		}
+	}
+	return true
+}
+
+// acquireLock blocks until want can be granted or ctx is done, then records
+// it in the lock table. Read locks on the same resource are shared and
+// counted in Holders.
+func (r *Runtime) acquireLock(ctx context.Context, want *toolLock, owner string) error {
+	// Wake the wait below when ctx ends
+	stop := context.AfterFunc(ctx, func() {
+		r.mu.Lock()
+		r.locksReleased.Broadcast()
+		r.mu.Unlock()
+	})
+	defer stop()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for !r.lockGrantableLocked(want) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		r.locksReleased.Wait()
+	}
+
+	if held, ok := r.locks[want.Resource]; ok {
+		// Only a shared read lock can be held on a grantable resource
+		held.Holders++
+		return nil
+	}
+
+	now := time.Now()
+	lock := &types.Lock{
+		Resource:   want.Resource,
+		Owner:      owner,
+		Type:       want.Type,
+		Holders:    1,
+		AcquiredAt: now,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		lock.ExpiresAt = deadline
+	}
+	r.locks[want.Resource] = lock
+	return nil
+}
+
+// releaseLock drops one holder of the lock and wakes waiting tool calls
+func (r *Runtime) releaseLock(held *toolLock) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if lock, ok := r.locks[held.Resource]; ok {
+		lock.Holders--
+		if lock.Holders <= 0 {
+			delete(r.locks, held.Resource)
+		}
+	}
+	r.locksReleased.Broadcast()
+}
+
+// Locks returns a copy of the locks currently held by tool calls
+func (r *Runtime) Locks() map[string]types.Lock {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	locks := make(map[string]types.Lock, len(r.locks))
+	for k, v := range r.locks {
+		locks[k] = *v
+	}
+	return locks
+}
+
+// readOnlyTools returns the names of tools that may run without a write lock.
+// spawn_agent declares itself read-only so planning can use it, but outside
+// planning its sub-agent may write, so it is locked like a write tool.
+func (r *Runtime) readOnlyTools() map[string]bool {
+	planning := r.getCurrentMode() == types.ModePlanning
+	readOnly := make(map[string]bool)
+	for _, t := range r.tools.List() {
+		if t.ReadOnly && (planning || t.Name != spawnAgentTool) {
+			readOnly[t.Name] = true
+		}
+	}
+	return readOnly
+}
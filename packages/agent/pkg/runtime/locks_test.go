@@ -0,0 +1,279 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// trackingTools records how many calls run at once, overall and per path
+type trackingTools struct {
+	delay func(call *types.ToolCall) time.Duration
+
+	mu        sync.Mutex
+	running   int
+	maxActive int
+	perPath   map[string]int
+	maxPath   int
+	started   []string
+}
+
+func newTrackingTools(delay func(call *types.ToolCall) time.Duration) *trackingTools {
+	return &trackingTools{delay: delay, perPath: make(map[string]int)}
+}
+
+func (m *trackingTools) Execute(ctx context.Context, mode types.RuntimeMode, call *types.ToolCall) (*types.ToolResult, error) {
+	var args struct {
+		Path string `json:"path"`
+	}
+	_ = json.Unmarshal([]byte(call.Arguments), &args)
+
+	m.mu.Lock()
+	m.running++
+	m.maxActive = max(m.maxActive, m.running)
+	m.perPath[args.Path]++
+	m.maxPath = max(m.maxPath, m.perPath[args.Path])
+	m.started = append(m.started, call.ID)
+	m.mu.Unlock()
+
+	time.Sleep(m.delay(call))
+
+	m.mu.Lock()
+	m.running--
+	m.perPath[args.Path]--
+	m.mu.Unlock()
+	return &types.ToolResult{ToolCallID: call.ID, ToolName: call.Name, Content: call.ID}, nil
+}
+
+func (m *trackingTools) List() []types.Tool {
+	return []types.Tool{
+		{Name: "read_file", ReadOnly: true},
+		{Name: "write_file"},
+		{Name: "run_shell"},
+	}
+}
+
+// spawningTools adds spawn_agent, which is read-only only in planning mode
+type spawningTools struct{ *trackingTools }
+
+func (m spawningTools) List() []types.Tool {
+	return append(m.trackingTools.List(), types.Tool{Name: "spawn_agent", ReadOnly: true})
+}
+
+func toolCmd(id, name, path string) types.Command {
+	args := map[string]any{}
+	if path != "" {
+		args["path"] = path
+	}
+	return &types.CallToolCommand{
+		BaseCommand: types.NewBaseCommand("call_tool"),
+		ToolCallID:  id,
+		ToolName:    name,
+		Arguments:   args,
+	}
+}
+
+func resultIDs(events []types.Event) []string {
+	var ids []string
+	for _, e := range events {
+		if res, ok := e.(*types.ToolResultEvent); ok {
+			ids = append(ids, res.ToolCallID)
+		}
+	}
+	return ids
+}
+
+func TestDispatchRunsReadsConcurrentlyInOrder(t *testing.T) {
+	// Earlier calls take longer so they finish last
+	tools := newTrackingTools(func(call *types.ToolCall) time.Duration {
+		return map[string]time.Duration{"c1": 60, "c2": 40, "c3": 20}[call.ID] * time.Millisecond
+	})
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, tools, nil)
+
+	events, err := rt.dispatch(context.Background(), []types.Command{
+		toolCmd("c1", "read_file", "/tmp/a"),
+		toolCmd("c2", "read_file", "/tmp/a"),
+		toolCmd("c3", "read_file", "/tmp/b"),
+	})
+	if err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+	if tools.maxActive != 3 {
+		t.Fatalf("expected 3 concurrent reads, got %d", tools.maxActive)
+	}
+	if got := resultIDs(events); len(got) != 3 || got[0] != "c1" || got[1] != "c2" || got[2] != "c3" {
+		t.Fatalf("expected results in call order, got %v", got)
+	}
+	if len(rt.Locks()) != 0 {
+		t.Fatalf("expected all locks released, got %v", rt.Locks())
+	}
+}
+
+func TestDispatchSerializesWritesToSamePath(t *testing.T) {
+	tools := newTrackingTools(func(*types.ToolCall) time.Duration { return 10 * time.Millisecond })
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, tools, nil)
+
+	events, err := rt.dispatch(context.Background(), []types.Command{
+		toolCmd("r1", "read_file", "/tmp/a"),
+		toolCmd("w1", "write_file", "/tmp/a"),
+		toolCmd("w2", "write_file", "/tmp/a"),
+		toolCmd("w3", "write_file", "/tmp/b"),
+	})
+	if err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+	if tools.maxPath != 1 {
+		t.Fatalf("expected calls on one path to be serialized, got %d at once", tools.maxPath)
+	}
+	if tools.started[0] != "r1" || tools.started[1] != "w1" {
+		t.Fatalf("expected conflicting calls to start in call order, got %v", tools.started)
+	}
+	if got := resultIDs(events); len(got) != 4 || got[0] != "r1" || got[3] != "w3" {
+		t.Fatalf("expected results in call order, got %v", got)
+	}
+}
+
+func TestDispatchGlobalWriteIsExclusive(t *testing.T) {
+	tools := newTrackingTools(func(*types.ToolCall) time.Duration { return 10 * time.Millisecond })
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, tools, nil)
+
+	_, err := rt.dispatch(context.Background(), []types.Command{
+		toolCmd("r1", "read_file", "/tmp/a"),
+		toolCmd("s1", "run_shell", ""),
+		toolCmd("r2", "read_file", "/tmp/b"),
+	})
+	if err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+	if tools.maxActive != 1 {
+		t.Fatalf("expected run_shell to exclude other calls, got %d at once", tools.maxActive)
+	}
+}
+
+func TestDispatchLocksSubAgentsOutsidePlanning(t *testing.T) {
+	tools := newTrackingTools(func(*types.ToolCall) time.Duration { return 10 * time.Millisecond })
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, spawningTools{tools}, nil)
+	cmds := []types.Command{
+		toolCmd("a1", "spawn_agent", ""),
+		toolCmd("r1", "read_file", "/tmp/a"),
+	}
+
+	if _, err := rt.dispatch(context.Background(), cmds); err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+	if tools.maxActive != 1 {
+		t.Fatalf("expected a sub-agent that may write to exclude other calls, got %d at once", tools.maxActive)
+	}
+
+	// In planning the sub-agent only gets read-only tools, so it runs alongside reads
+	enterPlanning(t, rt, "plan a refactor")
+	tools.maxActive = 0
+	if _, err := rt.dispatch(context.Background(), cmds); err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+	if tools.maxActive != 2 {
+		t.Fatalf("expected a planning sub-agent to run concurrently, got %d at once", tools.maxActive)
+	}
+}
+
+func TestAcquireLockRecordsSharedReads(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	lock := &toolLock{Resource: "/tmp/a", Type: types.LockTypeRead}
+
+	if err := rt.acquireLock(context.Background(), lock, "c1"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	if err := rt.acquireLock(context.Background(), lock, "c2"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+	held, ok := rt.Locks()["/tmp/a"]
+	if !ok || held.Type != types.LockTypeRead || held.Owner != "c1" || held.Holders != 2 {
+		t.Fatalf("expected shared read lock with 2 holders, got %+v", held)
+	}
+	if len(rt.GetState().Locks) != 0 {
+		t.Fatalf("expected held locks to stay out of state, got %v", rt.GetState().Locks)
+	}
+
+	rt.releaseLock(lock)
+	if rt.Locks()["/tmp/a"].Holders != 1 {
+		t.Fatalf("expected 1 holder after release")
+	}
+	rt.releaseLock(lock)
+	if _, ok := rt.Locks()["/tmp/a"]; ok {
+		t.Fatalf("expected lock removed after last release")
+	}
+}
+
+func TestAcquireLockWaitEndsWithContext(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	write := &toolLock{Resource: "/tmp/a", Type: types.LockTypeWrite}
+	if err := rt.acquireLock(context.Background(), write, "c1"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(20*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() { done <- rt.acquireLock(ctx, write, "c2") }()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected the wait to be cancelled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("lock wait ignored the cancelled context")
+	}
+	if held := rt.Locks()["/tmp/a"]; held.Owner != "c1" || held.Holders != 1 {
+		t.Fatalf("expected the cancelled call to leave the lock alone, got %+v", held)
+	}
+}
+
+func TestDispatchReportsCancelledLockWaits(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	if err := rt.acquireLock(context.Background(), &toolLock{Resource: globalResource, Type: types.LockTypeWrite}, "other"); err != nil {
+		t.Fatalf("acquire: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	events, err := rt.dispatch(ctx, []types.Command{
+		toolCmd("w1", "write_file", "/tmp/a"),
+		toolCmd("w2", "write_file", "/tmp/b"),
+	})
+	if err != nil {
+		t.Fatalf("dispatch error: %v", err)
+	}
+	var failed int
+	for _, e := range events {
+		if e, ok := e.(*types.ToolResultEvent); ok && !e.Success && strings.Contains(e.Error, "waiting for resource lock") {
+			failed++
+		}
+	}
+	if failed != 2 || len(events) != 2 {
+		t.Fatalf("expected a failed result for each call waiting for the lock, got %d of %+v", failed, events)
+	}
+}
+
+func TestResourcesOverlap(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want bool
+	}{
+		{"/repo/a.go", "/repo/a.go", true},
+		{"/repo", "/repo/a.go", true},
+		{"/repo/a.go", "/repo/ab.go", false},
+		{"/repo/a", "/repo/ab", false},
+		{globalResource, "/repo/a.go", true},
+	}
+	for _, c := range cases {
+		if got := resourcesOverlap(c.a, c.b); got != c.want {
+			t.Errorf("resourcesOverlap(%q, %q) = %v, want %v", c.a, c.b, got, c.want)
+		}
+	}
+}
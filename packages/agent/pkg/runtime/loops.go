@@ -0,0 +1,234 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strings"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// Kinds of loops reported by LoopDetectedEvent
+const (
+	LoopRepeatedCall  = "repeated_call"  // The same tool calls with the same arguments, step after step
+	LoopRepeatedError = "repeated_error" // The same error, attempt after attempt
+	LoopNoProgress    = "no_progress"    // Steps whose tools return nothing new
+)
+
+// Actions taken when a loop is detected
+const (
+	LoopActionWarn     = "warn"     // Show the model a corrective message
+	LoopActionEscalate = "escalate" // Pause the goal until the user replies
+	LoopActionStop     = "stop"     // Fail the goal
+)
+
+// recentResults bounds the result hashes kept per goal for progress detection
+const recentResults = 20
+
+// AwaitingInput reports whether a goal was paused for the user by the loop detector
+func AwaitingInput(state *types.State) bool {
+	for i := range state.Goals {
+		if state.Goals[i].Status == types.GoalStatusAwaitingInput {
+			return true
+		}
+	}
+	return false
+}
+
+// observeCalls records the tool calls of one decide/act cycle of a goal
+func observeCalls(loop *types.LoopStats, calls []types.ToolCall) {
+	loop.IdleSteps++
+	if len(calls) == 0 {
+		loop.CallsHash, loop.Calls, loop.RepeatedCalls = "", "", 0
+		return
+	}
+
+	signature := make([]string, len(calls))
+	names := make([]string, len(calls))
+	for i, tc := range calls {
+		signature[i] = tc.Name + "\x00" + canonicalArguments(tc.Arguments)
+		names[i] = tc.Name
+	}
+	hash := hashText(strings.Join(signature, "\n"))
+	if hash == loop.CallsHash {
+		loop.RepeatedCalls++
+		return
+	}
+	loop.CallsHash, loop.Calls, loop.RepeatedCalls = hash, strings.Join(names, ", "), 1
+}
+
+// observeResult records a tool result; a successful result not seen before is progress
+func observeResult(loop *types.LoopStats, e *types.ToolResultEvent) {
+	if !e.Success {
+		observeError(loop, fmt.Sprintf("%s: %s", e.ToolName, e.Error))
+		return
+	}
+	loop.LastError, loop.RepeatedErrors = "", 0
+
+	hash := hashText(e.ToolName + "\x00" + e.Output)
+	for _, seen := range loop.Results {
+		if seen == hash {
+			return
+		}
+	}
+	loop.Results = append(loop.Results, hash)
+	if len(loop.Results) > recentResults {
+		loop.Results = loop.Results[len(loop.Results)-recentResults:]
+	}
+	loop.IdleSteps = 0
+}
+
+// observeError records a failed tool call or command
+func observeError(loop *types.LoopStats, msg string) {
+	msg = truncateText(msg, 500)
+	if msg == loop.LastError {
+		loop.RepeatedErrors++
+		return
+	}
+	loop.LastError, loop.RepeatedErrors = msg, 1
+}
+
+// detectLoop returns the loop a goal is stuck in, or nil. Errors are checked
+// first since a repeated failing call is also a repeated call.
+func detectLoop(goal *types.Goal, cfg Config) *types.LoopDetectedEvent {
+	loop := goal.Loop
+	evt := &types.LoopDetectedEvent{
+		BaseEvent: types.NewBaseEvent("loop_detected", "runtime", goal.ID),
+		GoalID:    goal.ID,
+	}
+	switch {
+	case cfg.LoopRepeatedErrors > 0 && loop.RepeatedErrors >= cfg.LoopRepeatedErrors:
+		evt.Kind, evt.Count, evt.Detail = LoopRepeatedError, loop.RepeatedErrors, loop.LastError
+	case cfg.LoopRepeatedCalls > 0 && loop.RepeatedCalls >= cfg.LoopRepeatedCalls:
+		evt.Kind, evt.Count, evt.Detail = LoopRepeatedCall, loop.RepeatedCalls, loop.Calls
+	case cfg.LoopIdleSteps > 0 && loop.IdleSteps >= cfg.LoopIdleSteps:
+		evt.Kind, evt.Count, evt.Detail = LoopNoProgress, loop.IdleSteps, loop.Calls
+	default:
+		return nil
+	}
+
+	switch cfg.LoopAction {
+	case LoopActionEscalate, LoopActionStop:
+		evt.Action = cfg.LoopAction
+	default:
+		evt.Action = LoopActionWarn
+		if cfg.LoopMaxWarnings > 0 && loop.Warnings >= cfg.LoopMaxWarnings {
+			evt.Action = LoopActionStop
+		}
+	}
+	return evt
+}
+
+// loopSummary describes a detected loop in one sentence
+func loopSummary(e *types.LoopDetectedEvent) string {
+	switch e.Kind {
+	case LoopRepeatedCall:
+		return fmt.Sprintf("the same tool calls (%s) were made %d times in a row", e.Detail, e.Count)
+	case LoopRepeatedError:
+		return fmt.Sprintf("the last %d attempts failed with the same error: %s", e.Count, e.Detail)
+	default:
+		return fmt.Sprintf("%d steps in a row produced no new results", e.Count)
+	}
+}
+
+// reduceLoopDetected resets the triggering counter and shows the model a
+// corrective message; escalated goals wait for the user's next message
+func reduceLoopDetected(state *types.State, e *types.LoopDetectedEvent) {
+	goal := findGoal(state, e.GoalID)
+	if goal == nil {
+		return
+	}
+	switch e.Kind {
+	case LoopRepeatedCall:
+		goal.Loop.CallsHash, goal.Loop.RepeatedCalls = "", 0
+	case LoopRepeatedError:
+		goal.Loop.LastError, goal.Loop.RepeatedErrors = "", 0
+	case LoopNoProgress:
+		goal.Loop.IdleSteps = 0
+	}
+	goal.UpdatedAt = e.EventTimestamp()
+
+	switch e.Action {
+	case LoopActionWarn:
+		goal.Loop.Warnings++
+	case LoopActionEscalate:
+		goal.Status = types.GoalStatusAwaitingInput
+	default:
+		return // The goal is failed by the GoalFailedEvent that follows
+	}
+	state.Context.Messages = append(state.Context.Messages, types.Message{
+		Role:      "user",
+		Content:   fmt.Sprintf("You appear to be stuck: %s.\nDo not repeat the same step again. Try a different approach, or explain why the task cannot be done.", loopSummary(e)),
+		Timestamp: e.EventTimestamp(),
+	})
+}
+
+// resumeGoals puts goals paused by the loop detector back to work after the
+// user replied, with fresh loop statistics
+func resumeGoals(state *types.State, e *types.UserMessageEvent) {
+	for i := range state.Goals {
+		goal := &state.Goals[i]
+		if goal.Status == types.GoalStatusAwaitingInput {
+			goal.Status = types.GoalStatusInProgress
+			goal.Loop = types.LoopStats{}
+			goal.UpdatedAt = e.EventTimestamp()
+		}
+	}
+}
+
+// checkLoops records a LoopDetectedEvent if the goal is stuck and reports
+// whether the goal was paused or stopped instead of deciding again
+func (r *Runtime) checkLoops(ctx context.Context, goal *types.Goal) (bool, error) {
+	detected := detectLoop(goal, r.config)
+	if detected == nil {
+		return false, nil
+	}
+	r.log.Warn("loop detected", "goal_id", goal.ID, "kind", detected.Kind, "count", detected.Count, "action", detected.Action)
+
+	events := []types.Event{detected}
+	if detected.Action == LoopActionStop {
+		events = append(events, &types.GoalFailedEvent{
+			BaseEvent: types.NewBaseEvent("goal_failed", "runtime", goal.ID),
+			GoalID:    goal.ID,
+			Reason:    "stuck in a loop: " + loopSummary(detected),
+		})
+	}
+	for _, evt := range events {
+		if err := r.store.AppendEvent(ctx, evt); err != nil {
+			r.log.Error("failed to append loop detection event", "error", err)
+		}
+		if err := r.applyEvent(ctx, evt); err != nil {
+			return false, err
+		}
+	}
+	return detected.Action != LoopActionWarn, nil
+}
+
+// canonicalArguments normalizes JSON arguments so that key order and spacing
+// do not hide a repeated call
+func canonicalArguments(args string) string {
+	var v any
+	if err := json.Unmarshal([]byte(args), &v); err != nil {
+		return args
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return args
+	}
+	return string(out)
+}
+
+func truncateText(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "") + "..."
+}
+
+func hashText(s string) string {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return fmt.Sprintf("%016x", h.Sum64())
+}
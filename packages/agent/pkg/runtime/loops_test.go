@@ -0,0 +1,142 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func TestLoopStatsTrackRepetitionAndProgress(t *testing.T) {
+	var loop types.LoopStats
+	observeCalls(&loop, []types.ToolCall{{Name: "read_file", Arguments: `{"path":"a.go","limit":10}`}})
+	observeCalls(&loop, []types.ToolCall{{Name: "read_file", Arguments: `{"limit": 10, "path": "a.go"}`}})
+	if loop.RepeatedCalls != 2 || loop.Calls != "read_file" {
+		t.Fatalf("expected reordered arguments to count as a repeat, got %+v", loop)
+	}
+	observeCalls(&loop, []types.ToolCall{{Name: "read_file", Arguments: `{"path":"b.go"}`}})
+	if loop.RepeatedCalls != 1 {
+		t.Fatalf("expected new arguments to restart the count, got %d", loop.RepeatedCalls)
+	}
+
+	result := &types.ToolResultEvent{ToolName: "read_file", Success: true, Output: "package a"}
+	observeResult(&loop, result)
+	if loop.IdleSteps != 0 {
+		t.Fatalf("expected a new result to count as progress, got %d idle steps", loop.IdleSteps)
+	}
+	observeCalls(&loop, nil)
+	observeResult(&loop, result)
+	if loop.IdleSteps != 1 {
+		t.Fatalf("expected a repeated result not to count as progress, got %d idle steps", loop.IdleSteps)
+	}
+
+	failed := &types.ToolResultEvent{ToolName: "run_shell", Error: "exit status 1"}
+	observeResult(&loop, failed)
+	observeResult(&loop, failed)
+	observeError(&loop, "run_shell: exit status 1")
+	if loop.RepeatedErrors != 3 || loop.LastError != "run_shell: exit status 1" {
+		t.Fatalf("expected 3 identical errors, got %+v", loop)
+	}
+	observeResult(&loop, result)
+	if loop.RepeatedErrors != 0 {
+		t.Fatalf("expected a success to end the error streak, got %d", loop.RepeatedErrors)
+	}
+}
+
+func TestToolResultsCountForTheCallingGoal(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{
+		{ID: "g1", Status: types.GoalStatusInProgress},
+		{ID: "g2", Status: types.GoalStatusPending, Priority: -1},
+	}
+
+	if err := rt.applyEvent(context.Background(), &types.ToolResultEvent{
+		BaseEvent:  types.NewBaseEvent("tool_result", "tool", "run_shell"),
+		ToolCallID: "c1",
+		ToolName:   "run_shell",
+		Error:      "exit status 1",
+		GoalID:     "g1",
+	}); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	state := rt.GetState()
+	if g1 := findGoal(state, "g1"); g1.Loop.RepeatedErrors != 1 {
+		t.Fatalf("expected the error on g1, got %+v", g1.Loop)
+	}
+	if g2 := findGoal(state, "g2"); g2.Loop.RepeatedErrors != 0 || g2.Loop.LastError != "" {
+		t.Fatalf("expected g2 untouched, got %+v", g2.Loop)
+	}
+}
+
+func TestRunWarnsThenStopsLoopingGoal(t *testing.T) {
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, loopingLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusPending}}
+
+	if err := rt.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	state := rt.GetState()
+	if state.Goals[0].Status != types.GoalStatusFailed {
+		t.Fatalf("expected the looping goal to fail, got %s", state.Goals[0].Status)
+	}
+	var actions []string
+	var failed int
+	for _, e := range ms.events {
+		switch e := e.(type) {
+		case *types.LoopDetectedEvent:
+			if e.Kind != LoopRepeatedCall || e.Detail != "talk" {
+				t.Fatalf("unexpected loop event %+v", e)
+			}
+			actions = append(actions, e.Action)
+		case *types.GoalFailedEvent:
+			failed++
+			if !strings.Contains(e.Reason, "stuck in a loop") {
+				t.Fatalf("unexpected failure reason %q", e.Reason)
+			}
+		}
+	}
+	if strings.Join(actions, ",") != "warn,warn,stop" || failed != 1 {
+		t.Fatalf("expected two warnings then a stop, got %v and %d failures", actions, failed)
+	}
+
+	var warnings int
+	for _, msg := range state.Context.Messages {
+		if msg.Role == "user" && strings.Contains(msg.Content, "You appear to be stuck") {
+			warnings++
+		}
+	}
+	if warnings != 2 {
+		t.Fatalf("expected 2 corrective messages, got %d", warnings)
+	}
+}
+
+func TestLoopEscalationWaitsForUser(t *testing.T) {
+	ctx := context.Background()
+	cfg := DefaultConfig
+	cfg.LoopAction = LoopActionEscalate
+	rt := New(cfg, newMockStore(), loopingLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusPending}}
+
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	state := rt.GetState()
+	if state.Goals[0].Status != types.GoalStatusAwaitingInput || !AwaitingInput(state) {
+		t.Fatalf("expected the goal to wait for the user, got %s", state.Goals[0].Status)
+	}
+
+	reply := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "use the other API"}
+	if err := rt.Ingest(ctx, reply); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	state = rt.GetState()
+	if len(state.Goals) != 1 {
+		t.Fatalf("expected the reply to resume the goal instead of creating one, got %d goals", len(state.Goals))
+	}
+	if goal := state.Goals[0]; goal.Status != types.GoalStatusInProgress || goal.Loop.RepeatedCalls != 0 {
+		t.Fatalf("expected the goal resumed with fresh loop stats, got %+v", goal)
+	}
+}
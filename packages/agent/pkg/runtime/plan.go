@@ -0,0 +1,117 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// planningPrompt is appended to the system prompt while in planning mode
+const planningPrompt = `## Planning Mode
+You are in planning mode. Explore the codebase with read-only tools only; do not modify files or run commands.
+When you understand the task, reply with a concise, numbered implementation plan and no tool calls.
+The user will review the plan before anything is executed.`
+
+// readOnlyToolList filters tools down to those usable in planning mode
+func readOnlyToolList(tools []types.Tool) []types.Tool {
+	var filtered []types.Tool
+	for _, t := range tools {
+		if t.ReadOnly {
+			filtered = append(filtered, t)
+		}
+	}
+	return filtered
+}
+
+// withPlan appends a PlanGeneratedEvent when a planning-mode response is a final answer
+func (r *Runtime) withPlan(cmd *types.CallLLMCommand, resp *types.LLMResponseEvent) []types.Event {
+	events := []types.Event{resp}
+	if r.getCurrentMode() != types.ModePlanning || len(resp.ToolCalls) > 0 || resp.Content == "" {
+		return events
+	}
+	return append(events, &types.PlanGeneratedEvent{
+		BaseEvent:   types.NewBaseEvent("plan_generated", "llm", ""),
+		GoalID:      cmd.GoalID,
+		PlanContent: resp.Content,
+	})
+}
+
+func (r *Runtime) executeModeTransition(ctx context.Context, cmd *types.ModeTransitionCommand) ([]types.Event, error) {
+	return []types.Event{&types.ModeTransitionEvent{
+		BaseEvent: types.NewBaseEvent("mode_transition", "runtime", ""),
+		FromMode:  cmd.FromMode,
+		ToMode:    cmd.ToMode,
+		Reason:    cmd.Reason,
+	}}, nil
+}
+
+// planGoal returns the goal under review, matched by ID or else the first awaiting approval
+func planGoal(state *types.State, goalID string) *types.Goal {
+	for i := range state.Goals {
+		g := &state.Goals[i]
+		if g.Status != types.GoalStatusAwaitingApproval {
+			continue
+		}
+		if goalID == "" || g.ID == goalID {
+			return g
+		}
+	}
+	return nil
+}
+
+// HasPendingPlan reports whether a generated plan is waiting for the user's review
+func HasPendingPlan(state *types.State) bool {
+	return planGoal(state, "") != nil
+}
+
+// reducePlanEvent applies plan workflow events to an already cloned state
+func reducePlanEvent(state *types.State, event types.Event) []types.Command {
+	switch e := event.(type) {
+	case *types.ModeTransitionEvent:
+		state.Mode = e.ToMode
+		if e.ToMode == types.ModePlanning {
+			state.PlanContent = "" // Start a fresh planning cycle
+		}
+
+	case *types.PlanGeneratedEvent:
+		state.PlanContent = e.PlanContent
+		if goal := findGoal(state, e.GoalID); goal != nil {
+			goal.Status = types.GoalStatusAwaitingApproval
+		}
+
+	case *types.PlanApprovedEvent:
+		if goal := planGoal(state, e.GoalID); goal != nil {
+			goal.Status = types.GoalStatusInProgress
+		}
+		state.Context.Messages = append(state.Context.Messages, types.Message{
+			Role:    "user",
+			Content: "The plan is approved. Implement it now.",
+		})
+		from := state.Mode
+		if from == "" {
+			from = types.ModePlanning
+		}
+		return []types.Command{&types.ModeTransitionCommand{
+			BaseCommand: types.NewBaseCommand("mode_transition"),
+			FromMode:    from,
+			ToMode:      types.ModeExecuting,
+			Reason:      "plan approved",
+		}}
+
+	case *types.PlanRejectedEvent:
+		if goal := planGoal(state, e.GoalID); goal != nil {
+			goal.Status = types.GoalStatusInProgress
+		}
+		state.PlanContent = ""
+		content := "The plan was rejected. Propose a different plan."
+		if e.Feedback != "" {
+			content = fmt.Sprintf("The plan was rejected. Revise it based on this feedback:\n%s", e.Feedback)
+		}
+		state.Context.Messages = append(state.Context.Messages, types.Message{
+			Role:    "user",
+			Content: content,
+		})
+	}
+	return nil
+}
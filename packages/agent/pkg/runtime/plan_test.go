@@ -0,0 +1,115 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func enterPlanning(t *testing.T, rt *Runtime, prompt string) {
+	t.Helper()
+	ctx := context.Background()
+	if err := rt.Ingest(ctx, &types.ModeTransitionEvent{
+		BaseEvent: types.NewBaseEvent("mode_transition", "user", ""),
+		ToMode:    types.ModePlanning,
+	}); err != nil {
+		t.Fatalf("ingest mode transition: %v", err)
+	}
+	if err := rt.Ingest(ctx, &types.UserMessageEvent{
+		BaseEvent: types.NewBaseEvent("user_message", "user", ""),
+		Content:   prompt,
+	}); err != nil {
+		t.Fatalf("ingest user message: %v", err)
+	}
+}
+
+func TestPlanApprovalWorkflow(t *testing.T) {
+	ctx := context.Background()
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, mockLLM{}, &mockTools{}, nil)
+	enterPlanning(t, rt, "refactor the parser")
+
+	// Planning: the text reply becomes a plan awaiting review
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("planning run error: %v", err)
+	}
+	state := rt.GetState()
+	if state.PlanContent != "reply" || !HasPendingPlan(state) {
+		t.Fatalf("expected plan awaiting approval, got plan %q goals %+v", state.PlanContent, state.Goals)
+	}
+	var planEvt *types.PlanGeneratedEvent
+	for _, e := range ms.events {
+		if p, ok := e.(*types.PlanGeneratedEvent); ok {
+			planEvt = p
+		}
+	}
+	if planEvt == nil || planEvt.GoalID != state.Goals[0].ID {
+		t.Fatalf("expected plan_generated event for the goal, got %+v", planEvt)
+	}
+
+	// Approval: switch to executing and carry out the plan
+	if err := rt.Ingest(ctx, &types.PlanApprovedEvent{
+		BaseEvent: types.NewBaseEvent("plan_approved", "user", ""),
+		GoalID:    planEvt.GoalID,
+	}); err != nil {
+		t.Fatalf("ingest approval: %v", err)
+	}
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("executing run error: %v", err)
+	}
+	state = rt.GetState()
+	if state.Mode != types.ModeExecuting {
+		t.Fatalf("expected executing mode after approval, got %q", state.Mode)
+	}
+	if state.Goals[0].Status != types.GoalStatusCompleted {
+		t.Fatalf("expected goal completed after execution, got %s", state.Goals[0].Status)
+	}
+}
+
+func TestPlanRejectionReplans(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	enterPlanning(t, rt, "add caching")
+	if err := rt.Run(context.Background()); err != nil {
+		t.Fatalf("planning run error: %v", err)
+	}
+
+	if err := rt.Ingest(context.Background(), &types.PlanRejectedEvent{
+		BaseEvent: types.NewBaseEvent("plan_rejected", "user", ""),
+		Feedback:  "use an LRU cache",
+	}); err != nil {
+		t.Fatalf("ingest rejection: %v", err)
+	}
+
+	state := rt.GetState()
+	if state.Mode != types.ModePlanning || state.PlanContent != "" {
+		t.Fatalf("expected to stay in planning with plan cleared, got mode %q plan %q", state.Mode, state.PlanContent)
+	}
+	if state.Goals[0].Status != types.GoalStatusInProgress {
+		t.Fatalf("expected goal back in progress, got %s", state.Goals[0].Status)
+	}
+	last := state.Context.Messages[len(state.Context.Messages)-1]
+	if last.Role != "user" || !strings.Contains(last.Content, "use an LRU cache") {
+		t.Fatalf("expected feedback message, got %+v", last)
+	}
+}
+
+func TestDecideInPlanningModeOffersReadOnlyTools(t *testing.T) {
+	tools := newTrackingTools(nil)
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, tools, nil)
+	enterPlanning(t, rt, "explore")
+
+	goal, _ := rt.selectGoal()
+	decision, err := rt.decide(context.Background(), goal)
+	if err != nil {
+		t.Fatalf("decide error: %v", err)
+	}
+	cmd := decision.Commands[0].(*types.CallLLMCommand)
+	if len(cmd.Tools) != 1 || cmd.Tools[0].Name != "read_file" {
+		t.Fatalf("expected only read-only tools, got %+v", cmd.Tools)
+	}
+	if !strings.Contains(cmd.Messages[0].Content, "Planning Mode") {
+		t.Fatalf("expected planning instructions in system prompt")
+	}
+}
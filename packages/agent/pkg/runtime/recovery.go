@@ -0,0 +1,128 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gm-agent-org/gm-agent/pkg/store"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// recover restores state from the latest checkpoint and replays the events
+// logged after it, so changes applied since the last save survive a crash
+func (r *Runtime) recover(ctx context.Context) error {
+	saved, err := r.store.LoadLatestState(ctx)
+	if err != nil && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+	cp, err := r.store.LoadLatestCheckpoint(ctx)
+	if err != nil && !errors.Is(err, store.ErrNoCheckpoint) && !errors.Is(err, store.ErrNotFound) {
+		return err
+	}
+
+	base, cursor := recoveryBase(saved, cp)
+
+	// Snapshots written before checkpoints tracked events cannot be replayed onto
+	if base != nil && cursor == "" {
+		r.restoreState(base)
+		r.log.Info("state recovered", "version", base.Version)
+		return nil
+	}
+
+	state := base
+	if state == nil {
+		state = r.GetState()
+	}
+	state, replayed, err := r.replayEvents(ctx, state, cursor)
+	if err != nil {
+		return err
+	}
+	if base == nil && replayed == 0 && saved == nil {
+		r.log.Info("no previous state found, starting fresh")
+		return nil
+	}
+
+	// Consistency check: replaying the log should reach at least the saved version
+	if saved != nil {
+		switch {
+		case saved.Version > state.Version:
+			r.log.Warn("saved state is ahead of the event log, using saved state",
+				"saved_version", saved.Version, "replayed_version", state.Version)
+			state = saved
+		case saved.Version == state.Version && saved.LastEventID != state.LastEventID:
+			r.log.Warn("saved state and event log diverge",
+				"version", state.Version, "saved_event", saved.LastEventID, "replayed_event", state.LastEventID)
+		case saved.Version < state.Version:
+			r.log.Info("replayed events past saved state",
+				"saved_version", saved.Version, "replayed_version", state.Version)
+		}
+	}
+
+	r.restoreState(state)
+	r.log.Info("state recovered", "version", state.Version, "replayed_events", replayed)
+	return nil
+}
+
+// recoveryBase picks the snapshot to replay from and the last event it includes.
+// A nil base means there is no snapshot and the whole log is replayed.
+func recoveryBase(saved *types.State, cp *types.Checkpoint) (*types.State, string) {
+	if cp != nil && cp.State != nil && cp.LastEventID != "" {
+		return cp.State.Clone(), cp.LastEventID
+	}
+	if saved != nil {
+		return saved.Clone(), saved.LastEventID
+	}
+	if cp != nil && cp.State != nil {
+		return cp.State.Clone(), ""
+	}
+	return nil, ""
+}
+
+// replayEvents applies logged events after cursor through the reducer.
+// Commands the reducer returns are dropped: their effects are already in the log.
+func (r *Runtime) replayEvents(ctx context.Context, state *types.State, cursor string) (*types.State, int, error) {
+	events, err := r.store.GetEventsSince(ctx, cursor)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read events since %q: %w", cursor, err)
+	}
+
+	replayed := 0
+	for _, event := range events {
+		if !replayable(event) {
+			continue
+		}
+		next, _, err := r.safeReduce(ctx, state, event)
+		if err != nil {
+			return nil, 0, fmt.Errorf("replay event %s: %w", event.EventID(), err)
+		}
+		state = next
+		replayed++
+	}
+	return state, replayed, nil
+}
+
+// replayable reports whether a logged event was applied by the reducer.
+// Streaming deltas, retries, permission prompts and forwarded sub-agent progress
+// are only recorded for clients and never changed state.
+func replayable(event types.Event) bool {
+	switch e := event.(type) {
+	case *types.LLMTokenEvent, *types.LLMReasoningEvent, *types.LLMRetryEvent, *types.CheckpointEvent,
+		*types.ToolCallStartEvent, *types.ToolCallDeltaEvent, *types.ToolCallEndEvent,
+		*types.PermissionRequestEvent, *types.PermissionResponseEvent:
+		return false
+	case *types.SubAgentEvent:
+		return e.Status == "completed" || e.Status == "failed"
+	case *types.BaseEvent:
+		// Unknown event type in the log
+		return false
+	}
+	return true
+}
+
+// restoreState installs a recovered state
+func (r *Runtime) restoreState(state *types.State) {
+	// Locks belonged to tool calls of the previous process; none are running now
+	state.Locks = make(map[string]*types.Lock)
+	r.updateState(state)
+}
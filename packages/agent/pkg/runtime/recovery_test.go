@@ -0,0 +1,205 @@
+package runtime
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/store"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func openFSStore(t *testing.T) *store.FSStore {
+	t.Helper()
+	s := store.NewFSStore(t.TempDir())
+	if err := s.Open(context.Background()); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	return s
+}
+
+func TestCheckpointRecordsLastEventID(t *testing.T) {
+	ctx := context.Background()
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, mockLLM{}, &mockTools{}, slog.Default())
+
+	evt := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hi"}
+	if err := rt.Ingest(ctx, evt); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := rt.checkpoint(ctx); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+	if got := ms.checkpoints[0].LastEventID; got != evt.EventID() {
+		t.Fatalf("expected checkpoint at %s, got %q", evt.EventID(), got)
+	}
+}
+
+func TestRecoverReplaysEventsAfterCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	fs := openFSStore(t)
+
+	first := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	user := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "list files"}
+	if err := first.Ingest(ctx, user); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := first.checkpoint(ctx); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	// Logged but never saved: the process crashed before the next checkpoint
+	resp := &types.LLMResponseEvent{
+		BaseEvent: types.NewBaseEvent("llm_response", "llm", ""),
+		ToolCalls: []types.ToolCall{{ID: "call-1", Name: "read_file", Arguments: `{"path":"a.go"}`}},
+	}
+	logged := []types.Event{
+		&types.LLMTokenEvent{BaseEvent: types.NewBaseEvent("llm_token", "llm", ""), Delta: "..."},
+		resp,
+	}
+	if err := fs.AppendEvents(ctx, logged); err != nil {
+		t.Fatalf("append events: %v", err)
+	}
+
+	second := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	if err := second.recover(ctx); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+
+	state := second.GetState()
+	if state.Version != 2 {
+		t.Fatalf("expected version 2 after replaying one event, got %d", state.Version)
+	}
+	if state.LastEventID != resp.EventID() {
+		t.Fatalf("expected last event %s, got %s", resp.EventID(), state.LastEventID)
+	}
+	msgs := state.Context.Messages
+	if len(msgs) != 2 || msgs[1].Role != "assistant" || len(msgs[1].ToolCalls) != 1 {
+		t.Fatalf("expected replayed assistant message, got %+v", msgs)
+	}
+	if len(second.pendingCommands) != 0 {
+		t.Fatalf("replay must not re-dispatch tool calls, got %d commands", len(second.pendingCommands))
+	}
+}
+
+func TestRecoverPrefersSavedStateAheadOfLog(t *testing.T) {
+	ctx := context.Background()
+	fs := openFSStore(t)
+
+	first := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	user := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hi"}
+	if err := first.Ingest(ctx, user); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := first.checkpoint(ctx); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	// A saved state the log cannot account for
+	saved := first.GetState()
+	saved.Version = 7
+	if err := fs.SaveState(ctx, saved); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	second := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	if err := second.recover(ctx); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	if got := second.GetState().Version; got != 7 {
+		t.Fatalf("expected saved version 7, got %d", got)
+	}
+}
+
+func TestRecoverSkipsReplayForLegacyState(t *testing.T) {
+	ctx := context.Background()
+	fs := openFSStore(t)
+
+	user := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hi"}
+	if err := fs.AppendEvent(ctx, user); err != nil {
+		t.Fatalf("append event: %v", err)
+	}
+	// Saved before states tracked their last event
+	legacy := types.NewState()
+	legacy.Version = 3
+	if err := fs.SaveState(ctx, legacy); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	rt := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	if err := rt.recover(ctx); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	state := rt.GetState()
+	if state.Version != 3 || len(state.Context.Messages) != 0 {
+		t.Fatalf("expected legacy state untouched, got version %d with %d messages", state.Version, len(state.Context.Messages))
+	}
+}
+
+func TestRecoverReplaysWholeLogWithoutSnapshot(t *testing.T) {
+	ctx := context.Background()
+	fs := openFSStore(t)
+
+	events := []types.Event{
+		&types.SystemPromptEvent{BaseEvent: types.NewBaseEvent("system_prompt", "system", ""), Prompt: "be brief"},
+		&types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hi"},
+	}
+	if err := fs.AppendEvents(ctx, events); err != nil {
+		t.Fatalf("append events: %v", err)
+	}
+
+	rt := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	if err := rt.recover(ctx); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	state := rt.GetState()
+	if state.Version != 2 || state.SystemPrompt != "be brief" || len(state.Goals) != 1 {
+		t.Fatalf("unexpected replayed state: version %d prompt %q goals %d", state.Version, state.SystemPrompt, len(state.Goals))
+	}
+}
+
+func TestRecoverRecreatesIDsOfEntitiesCreatedAfterCheckpoint(t *testing.T) {
+	ctx := context.Background()
+	fs := openFSStore(t)
+
+	first := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	prompt := &types.SystemPromptEvent{BaseEvent: types.NewBaseEvent("system_prompt", "system", ""), Prompt: "be brief"}
+	if err := first.Ingest(ctx, prompt); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := first.checkpoint(ctx); err != nil {
+		t.Fatalf("checkpoint: %v", err)
+	}
+
+	// The goal and the artifact only exist in the log
+	for _, evt := range []types.Event{
+		&types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "write a.go"},
+		&types.LLMResponseEvent{
+			BaseEvent: types.NewBaseEvent("llm_response", "llm", ""),
+			ToolCalls: []types.ToolCall{{ID: "call-1", Name: "create_file", Arguments: `{"path":"a.go"}`}},
+		},
+		&types.ToolResultEvent{BaseEvent: types.NewBaseEvent("tool_result", "tool", ""), ToolCallID: "call-1", ToolName: "create_file", Success: true},
+	} {
+		if err := first.Ingest(ctx, evt); err != nil {
+			t.Fatalf("ingest: %v", err)
+		}
+	}
+	live := first.GetState()
+	if len(live.Goals) != 1 || len(live.Artifacts) != 1 {
+		t.Fatalf("expected a goal and an artifact, got %d and %d", len(live.Goals), len(live.Artifacts))
+	}
+
+	second := New(DefaultConfig, fs, mockLLM{}, &mockTools{}, slog.Default())
+	if err := second.recover(ctx); err != nil {
+		t.Fatalf("recover: %v", err)
+	}
+	state := second.GetState()
+	if len(state.Goals) != 1 || state.Goals[0].ID != live.Goals[0].ID {
+		t.Fatalf("expected goal %s after replay, got %+v", live.Goals[0].ID, state.Goals)
+	}
+	for id := range live.Artifacts {
+		if _, ok := state.Artifacts[id]; !ok || len(state.Artifacts) != 1 {
+			t.Fatalf("expected artifact %s after replay, got %v", id, state.Artifacts)
+		}
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"runtime/debug"
+	"strings"
 
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
@@ -69,6 +70,7 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 
 	newState.Version++
 	newState.UpdatedAt = event.EventTimestamp()
+	newState.LastEventID = event.EventID()
 
 	var cmds []types.Command
 
@@ -77,33 +79,108 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 		// Update System Prompt configuration
 		newState.SystemPrompt = e.Prompt
 
+	case *types.ContextCompactedEvent:
+		// Replace older messages with the summary
+		applyCompaction(newState.Context, e)
+		if newState.Usage == nil {
+			newState.Usage = types.NewUsageLedger()
+		}
+		newState.Usage.Record(e.GoalID, e.Model, e.Usage, e.CostUSD)
+
+	case *types.ModeTransitionEvent, *types.PlanGeneratedEvent, *types.PlanApprovedEvent, *types.PlanRejectedEvent:
+		// Plan workflow: planning -> review -> executing (or replanning)
+		cmds = append(cmds, reducePlanEvent(newState, e)...)
+
+	case *types.ModelOverrideEvent:
+		newState.ModelOverride = nil
+		if e.Route != (types.ModelRoute{}) {
+			route := e.Route
+			newState.ModelOverride = &route
+		}
+
+	case *types.GoalCreatedEvent:
+		newState.Goals = append(newState.Goals, e.Goal)
+
+	case *types.GoalFailedEvent:
+		if goal := findGoal(newState, e.GoalID); goal != nil {
+			goal.Status = types.GoalStatusFailed
+			goal.UpdatedAt = e.EventTimestamp()
+		}
+
+	case *types.ErrorEvent:
+		r.reduceError(newState, e)
+
+	case *types.LoopDetectedEvent:
+		reduceLoopDetected(newState, e)
+
+	case *types.HookContextEvent:
+		newState.Context.Messages = append(newState.Context.Messages, types.Message{
+			Role:      "user",
+			Content:   fmt.Sprintf("Context from the %s hook:\n%s", e.Hook, e.Content),
+			Timestamp: e.EventTimestamp(),
+		})
+
+	case *types.ArtifactCreatedEvent:
+		artifact := e.Artifact.Clone()
+		artifact.Content = nil // Kept in the artifact store
+		newState.Artifacts[artifact.ID] = artifact
+
+	case *types.TasksUpdatedEvent:
+		reduceTasksUpdated(newState, e)
+
+	case *types.SubAgentEvent:
+		// Charge the sub-agent's usage to the goal that spawned it
+		if len(e.Usage) > 0 {
+			if newState.Usage == nil {
+				newState.Usage = types.NewUsageLedger()
+			}
+			goalID := e.GoalID
+			if goalID == "" {
+				// Recorded before events carried the goal
+				if goal := findGoal(newState, ""); goal != nil {
+					goalID = goal.ID
+				}
+			}
+			newState.Usage.Merge(goalID, e.Usage)
+		}
+
 	case *types.UserMessageEvent:
 		// User added a message -> Maybe trigger LLM?
 		// Add to context
 		msg := types.Message{
 			Role:    "user",
 			Content: e.Content,
+			Parts:   e.Parts,
 			// ID, Timestamp...
 		}
-		newState.Context.Messages = append(newState.Context.Messages, msg)
-
-		// Check if there's any active (pending or in-progress) goal
-		hasActiveGoal := false
-		for _, g := range newState.Goals {
-			if g.Status == types.GoalStatusPending || g.Status == types.GoalStatusInProgress {
-				hasActiveGoal = true
-				break
-			}
+		if msg.Content == "" {
+			msg.Content = types.PartsText(e.Parts)
 		}
+		newState.Context.Messages = append(newState.Context.Messages, msg)
+		resumeGoals(newState, e)
 
-		// If no active goal, create one for this user message
-		if !hasActiveGoal {
+		// Create a goal for this message if there is no active goal, or if the
+		// message asks for independent (fork) or urgent (preempt) work
+		if nextGoal(newState) == nil || e.Semantic == types.SemanticFork || e.Semantic == types.SemanticPreempt {
 			goal := types.Goal{
-				ID:          types.GenerateGoalID(),
-				Description: e.Content,
-				Status:      types.GoalStatusPending,
-				Type:        types.GoalTypeUserRequest,
+				ID:            eventScopedID("gol", e),
+				Description:   msg.Content,
+				Status:        types.GoalStatusPending,
+				Type:          types.GoalTypeUserRequest,
+				Priority:      e.Priority,
+				MaxSteps:      e.MaxSteps,
+				SourceEventID: e.EventID(),
+				CreatedAt:     e.EventTimestamp(),
+				UpdatedAt:     e.EventTimestamp(),
+			}
+			if e.Deadline != nil {
+				deadline := *e.Deadline
+				goal.Deadline = &deadline
+			}
+			if len(e.AllowedTools) > 0 {
+				goal.AllowedTools = append([]string(nil), e.AllowedTools...)
 			}
+			goal.Model = e.Model
 			newState.Goals = append(newState.Goals, goal)
 		}
 
@@ -119,16 +196,26 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 		}
 		newState.Context.Messages = append(newState.Context.Messages, msg)
 
+		// Account token usage and cost
+		if newState.Usage == nil {
+			newState.Usage = types.NewUsageLedger()
+		}
+		newState.Usage.Record(e.GoalID, e.Model, e.Usage, e.CostUSD)
+
+		// Each LLM call is one decide/act cycle of the goal it was made for
+		goal := reduceGoalStep(newState, e.GoalID, e.EventTimestamp())
+		if goal != nil {
+			goal.ConsecutiveErrors = 0
+			observeCalls(&goal.Loop, e.ToolCalls)
+		}
+
 		// If LLM responded with content but NO tool calls, this is a direct response
 		// The user will receive the content from the llm_response event via streaming
-		// Mark the current goal as complete since the LLM answered directly
-		if len(e.ToolCalls) == 0 && e.Content != "" && e.Content != " " {
-			// Find and complete the active goal
-			for i := range newState.Goals {
-				if newState.Goals[i].Status == types.GoalStatusPending || newState.Goals[i].Status == types.GoalStatusInProgress {
-					newState.Goals[i].Status = types.GoalStatusCompleted
-					break
-				}
+		// Mark the goal as complete since the LLM answered directly
+		// (in planning mode the response is a plan, which waits for review instead)
+		if len(e.ToolCalls) == 0 && e.Content != "" && e.Content != " " && newState.Mode != types.ModePlanning {
+			if goal != nil {
+				goal.Status = types.GoalStatusCompleted
 			}
 		}
 
@@ -151,6 +238,9 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 				ToolName:    tc.Name,
 				Arguments:   args,
 			}
+			if goal != nil {
+				cmd.GoalID = goal.ID
+			}
 			cmds = append(cmds, cmd)
 		}
 
@@ -164,20 +254,20 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 		}
 		if !e.Success {
 			msg.Content = fmt.Sprintf("Error: %s", e.Error)
+		} else if len(e.Parts) > 0 {
+			msg.Parts = append([]types.ContentPart{{Type: types.ContentPartText, Text: e.Output}}, e.Parts...)
 		}
 		newState.Context.Messages = append(newState.Context.Messages, msg)
+		if goal := findGoal(newState, e.GoalID); goal != nil {
+			observeResult(&goal.Loop, e)
+		}
 
 		// Special Handling: task_complete
 		if e.ToolName == "task_complete" && e.Success {
-			// Find active goal
-			// For MVP, simplistic: Assume first pending/in-progress is targets
-			for i := range newState.Goals {
-				if newState.Goals[i].Status == types.GoalStatusPending || newState.Goals[i].Status == types.GoalStatusInProgress {
-					newState.Goals[i].Status = types.GoalStatusCompleted
-					// Don't break if we want to mark all?
-					// For now, mark the current active one as completed.
-					break
-				}
+			// Complete the goal that made the call
+			if goal := findGoal(newState, e.GoalID); goal != nil {
+				goal.Status = types.GoalStatusCompleted
+				goal.UpdatedAt = e.EventTimestamp()
 			}
 		}
 		// Special Handling: create_file success
@@ -195,7 +285,7 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 						if tc.ID == e.ToolCallID {
 							if err := json.Unmarshal([]byte(tc.Arguments), &args); err == nil && args.Path != "" {
 								// Create Artifact
-								artID := types.GenerateID("art")
+								artID := eventScopedID("art", e)
 								artifact := &types.Artifact{
 									ID:        artID,
 									Type:      "file",
@@ -217,5 +307,18 @@ func (r *Runtime) reducer(state *types.State, event types.Event) (*types.State,
 		}
 	}
 
+	refreshTokenCounts(newState.Context)
+
 	return newState, cmds, nil
 }
+
+// eventScopedID derives the ID of an entity the reducer creates for event from
+// the event's ID, so replaying the log recreates the same IDs. Each event
+// creates at most one entity per prefix.
+func eventScopedID(prefix string, event types.Event) string {
+	id := event.EventID()
+	if id == "" {
+		return types.GenerateID(prefix)
+	}
+	return prefix + "_" + strings.TrimPrefix(id, "evt_")
+}
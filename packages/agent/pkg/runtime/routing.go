@@ -0,0 +1,30 @@
+package runtime
+
+import (
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// route picks the provider and model of a goal's next LLM call. More specific
+// choices win: the route of the mode, then of the goal type, then the session
+// override. A goal's own model (from a slash command) replaces the model but
+// keeps the provider. Without any of them the runtime's model is used.
+func (r *Runtime) route(goal *types.Goal, mode types.RuntimeMode, override *types.ModelRoute) types.ModelRoute {
+	if mode == "" {
+		mode = types.ModeExecuting
+	}
+
+	route := types.ModelRoute{Model: r.config.Model}
+	if rt, ok := r.config.Routes[string(mode)]; ok {
+		route = rt
+	}
+	if rt, ok := r.config.Routes[string(goal.Type)]; ok {
+		route = rt
+	}
+	if override != nil {
+		route = *override
+	}
+	if goal.Model != "" {
+		route.Model = goal.Model
+	}
+	return route
+}
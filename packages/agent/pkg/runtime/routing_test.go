@@ -0,0 +1,97 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func TestRoutePrecedence(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Model = "default-model"
+	cfg.Routes = config.RouteTable{
+		config.RoutePlanning: {Provider: "anthropic", Model: "strong"},
+		config.RouteSubTask:  {Provider: "openai", Model: "cheap"},
+	}
+	rt := New(cfg, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	override := &types.ModelRoute{Provider: "gemini"}
+
+	tests := []struct {
+		name     string
+		goal     types.Goal
+		mode     types.RuntimeMode
+		override *types.ModelRoute
+		want     types.ModelRoute
+	}{
+		{"default", types.Goal{Type: types.GoalTypeUserRequest}, "", nil, types.ModelRoute{Model: "default-model"}},
+		{"mode", types.Goal{Type: types.GoalTypeUserRequest}, types.ModePlanning, nil, types.ModelRoute{Provider: "anthropic", Model: "strong"}},
+		{"goal type over mode", types.Goal{Type: types.GoalTypeSubTask}, types.ModePlanning, nil, types.ModelRoute{Provider: "openai", Model: "cheap"}},
+		{"session override", types.Goal{Type: types.GoalTypeSubTask}, types.ModePlanning, override, types.ModelRoute{Provider: "gemini"}},
+		{"goal model keeps provider", types.Goal{Type: types.GoalTypeUserRequest, Model: "opus"}, types.ModePlanning, nil, types.ModelRoute{Provider: "anthropic", Model: "opus"}},
+	}
+	for _, tt := range tests {
+		if got := rt.route(&tt.goal, tt.mode, tt.override); got != tt.want {
+			t.Fatalf("%s: expected %+v, got %+v", tt.name, tt.want, got)
+		}
+	}
+}
+
+func TestModelOverrideReachesLLMCall(t *testing.T) {
+	gateway := &requestRecorder{}
+	rt := New(DefaultConfig, newMockStore(), gateway, &mockTools{}, nil)
+	ctx := context.Background()
+
+	override := &types.ModelOverrideEvent{BaseEvent: types.NewBaseEvent("model_override", "user", "cli"), Route: types.ModelRoute{Provider: "openai", Model: "gpt-4o"}}
+	if err := rt.Ingest(ctx, override); err != nil {
+		t.Fatalf("ingest override: %v", err)
+	}
+	if err := rt.Ingest(ctx, &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hello"}); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if gateway.last.Provider != "openai" || gateway.last.Model != "gpt-4o" {
+		t.Fatalf("expected the override, got %s/%s", gateway.last.Provider, gateway.last.Model)
+	}
+
+	reset := &types.ModelOverrideEvent{BaseEvent: types.NewBaseEvent("model_override", "user", "cli")}
+	if err := rt.Ingest(ctx, reset); err != nil {
+		t.Fatalf("ingest reset: %v", err)
+	}
+	if rt.GetState().ModelOverride != nil {
+		t.Fatal("expected an empty route to clear the override")
+	}
+}
+
+type compactionRecorder struct {
+	mockLLM
+	last *llm.ChatRequest
+}
+
+func (m *compactionRecorder) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	m.last = req
+	return m.mockLLM.Chat(ctx, req)
+}
+
+func TestCompactionRoute(t *testing.T) {
+	gateway := &compactionRecorder{}
+	cfg := DefaultConfig
+	cfg.CompactionKeepRecent = 1
+	cfg.Routes = config.RouteTable{config.RouteCompaction: {Provider: "openai", Model: "gpt-4o-mini"}}
+	rt := New(cfg, newMockStore(), gateway, &mockTools{}, nil)
+	for _, role := range []string{"user", "assistant", "user", "assistant"} {
+		rt.state.Context.Messages = append(rt.state.Context.Messages, types.Message{Role: role, Content: strings.Repeat("z", 50)})
+	}
+
+	if _, err := rt.Compact(context.Background()); err != nil {
+		t.Fatalf("compact: %v", err)
+	}
+	if gateway.last.Provider != "openai" || gateway.last.Model != "gpt-4o-mini" {
+		t.Fatalf("expected the compaction route, got %s/%s", gateway.last.Provider, gateway.last.Model)
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"sync"
 	"time"
 
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/hook"
+	"github.com/gm-agent-org/gm-agent/pkg/instructions"
 	"github.com/gm-agent-org/gm-agent/pkg/patch"
 	"github.com/gm-agent-org/gm-agent/pkg/store"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
@@ -19,13 +22,49 @@ type Config struct {
 	DecisionTimeout    time.Duration `yaml:"decision_timeout"`
 	DispatchTimeout    time.Duration `yaml:"dispatch_timeout"`
 	Model              string        `yaml:"model"` // Active LLM Model Name
+
+	// Provider and model per kind of call (see routing.go)
+	Routes config.RouteTable `yaml:"routes"`
+
+	// Context compaction (disabled when MaxContextTokens is 0)
+	MaxContextTokens     int `yaml:"max_context_tokens"`     // Context window budget in tokens
+	ReserveOutputTokens  int `yaml:"reserve_output_tokens"`  // Tokens reserved for the model's reply
+	CompactionKeepRecent int `yaml:"compaction_keep_recent"` // Recent messages kept verbatim when compacting
+
+	// Usage accounting (limits disabled when 0)
+	Pricing          config.PriceTable `yaml:"pricing"`            // Model prices used to cost LLM calls
+	MaxSessionTokens int               `yaml:"max_session_tokens"` // Stop the loop after this many tokens
+	MaxSessionCost   float64           `yaml:"max_session_cost"`   // Stop the loop after this much USD
+
+	// Error handling (see errors.go)
+	SurfaceErrors        bool `yaml:"surface_errors"`         // Show recoverable errors to the LLM as context
+	MaxConsecutiveErrors int  `yaml:"max_consecutive_errors"` // Fail a goal after this many errors in a row (0 disables)
+	RecoveryGoals        bool `yaml:"recovery_goals"`         // Create a recovery goal when a goal fails on errors
+
+	// Loop detection (see loops.go; a threshold of 0 disables its check)
+	LoopRepeatedCalls  int    `yaml:"loop_repeated_calls"`  // Steps in a row making identical tool calls
+	LoopRepeatedErrors int    `yaml:"loop_repeated_errors"` // Identical errors in a row
+	LoopIdleSteps      int    `yaml:"loop_idle_steps"`      // Steps without a new tool result
+	LoopAction         string `yaml:"loop_action"`          // warn, escalate or stop
+	LoopMaxWarnings    int    `yaml:"loop_max_warnings"`    // Stop a goal that loops again after this many warnings (0 = never)
 }
 
 var DefaultConfig = Config{
-	MaxSteps:           100,
-	CheckpointInterval: 10,
-	DecisionTimeout:    60 * time.Second,
-	DispatchTimeout:    300 * time.Second,
+	MaxSteps:             100,
+	CheckpointInterval:   10,
+	DecisionTimeout:      60 * time.Second,
+	DispatchTimeout:      300 * time.Second,
+	MaxContextTokens:     128000,
+	ReserveOutputTokens:  8192,
+	CompactionKeepRecent: 10,
+	Pricing:              config.DefaultPrices,
+	SurfaceErrors:        true,
+	MaxConsecutiveErrors: 3,
+	LoopRepeatedCalls:    3,
+	LoopRepeatedErrors:   3,
+	LoopIdleSteps:        10,
+	LoopAction:           LoopActionWarn,
+	LoopMaxWarnings:      2,
 }
 
 type Runtime struct {
@@ -35,6 +74,9 @@ type Runtime struct {
 	tools   ToolExecutor
 	log     *slog.Logger
 	tracker patch.FileChangeTracker // Optional: for Code Rewind support
+	hooks   *hook.Runner            // Optional: lifecycle hooks whose context reaches decide
+
+	instructions *instructions.Loader // Optional: instruction files added to the system prompt
 
 	state *types.State
 
@@ -42,6 +84,12 @@ type Runtime struct {
 	pendingCommands []types.Command
 
 	mu sync.RWMutex
+
+	// Resource locks held by running tool calls; kept out of state so that
+	// a snapshot never records calls that were in flight
+	locks map[string]*types.Lock
+	// Signalled whenever a lock is released or a waiting call is cancelled
+	locksReleased *sync.Cond
 }
 
 // swapPendingCommands atomically retrieves and clears pending commands
@@ -83,14 +131,20 @@ func New(cfg Config, s store.Store, llm LLMGateway, tools ToolExecutor, logger *
 	if logger == nil {
 		logger = slog.Default()
 	}
-	return &Runtime{
+	state := types.NewState()
+	state.Context.MaxTokens = cfg.MaxContextTokens
+	state.Context.ReserveOutput = cfg.ReserveOutputTokens
+	r := &Runtime{
 		config: cfg,
 		store:  s,
 		llm:    llm,
 		tools:  tools,
 		log:    logger,
-		state:  types.NewState(),
+		state:  state,
+		locks:  make(map[string]*types.Lock),
 	}
+	r.locksReleased = sync.NewCond(&r.mu)
+	return r
 }
 
 // SetFileChangeTracker sets the file change tracker for Code Rewind support
@@ -100,6 +154,20 @@ func (r *Runtime) SetFileChangeTracker(tracker patch.FileChangeTracker) {
 	r.tracker = tracker
 }
 
+// SetHooks sets the hooks whose additional context is added before each decision
+func (r *Runtime) SetHooks(hooks *hook.Runner) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hooks = hooks
+}
+
+// SetInstructions sets the instruction files added to every system prompt
+func (r *Runtime) SetInstructions(l *instructions.Loader) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.instructions = l
+}
+
 // Run executes the main loop
 func (r *Runtime) Run(ctx context.Context) error {
 	// 1. Recover state
@@ -136,6 +204,9 @@ func (r *Runtime) Run(ctx context.Context) error {
 					return err
 				}
 			}
+			if err := r.escalateErrors(ctx, events); err != nil {
+				return err
+			}
 
 			if step%r.config.CheckpointInterval == 0 {
 				if err := r.checkpoint(ctx); err != nil {
@@ -145,7 +216,10 @@ func (r *Runtime) Run(ctx context.Context) error {
 			continue
 		}
 
-		// 2.3 Select Goal (Simplistic Logic for MVP: First Pending Goal)
+		// 2.3 Select Goal (fail expired goals, then pick by priority and deadline)
+		if err := r.expireGoals(ctx); err != nil {
+			return err
+		}
 		goal, err := r.selectGoal()
 		if err != nil {
 			return err
@@ -158,7 +232,44 @@ func (r *Runtime) Run(ctx context.Context) error {
 			return nil
 		}
 
-		// 2.4 Decide (LLM)
+		// Stop before calling the LLM again if the session is over budget
+		if err := r.checkBudget(ctx); err != nil {
+			if ckErr := r.checkpoint(ctx); ckErr != nil {
+				r.log.Warn("checkpoint failed", "error", ckErr)
+			}
+			return err
+		}
+
+		// Correct, pause or stop a goal that is going in circles
+		stuck, err := r.checkLoops(ctx, goal)
+		if err != nil {
+			return err
+		}
+		if stuck {
+			continue
+		}
+
+		// Context added by hooks since the last decision
+		if err := r.injectHookContext(ctx); err != nil {
+			return err
+		}
+
+		// 2.4 Compact context if it exceeds the token budget
+		if r.needsCompaction() {
+			r.log.Info("context budget exceeded, compacting")
+			if err := r.autoCompact(ctx, goal.ID); err != nil {
+				r.log.Warn("context compaction failed", "error", err)
+			}
+			// The summary itself may have used up the budget
+			if err := r.checkBudget(ctx); err != nil {
+				if ckErr := r.checkpoint(ctx); ckErr != nil {
+					r.log.Warn("checkpoint failed", "error", ckErr)
+				}
+				return err
+			}
+		}
+
+		// 2.5 Decide (LLM)
 		decisionCtx, cancel := context.WithTimeout(ctx, r.config.DecisionTimeout)
 		decision, err := r.decide(decisionCtx, goal)
 		cancel()
@@ -170,7 +281,7 @@ func (r *Runtime) Run(ctx context.Context) error {
 			return err
 		}
 
-		// 2.5 Act (Dispatch)
+		// 2.6 Act (Dispatch)
 		dispatchCtx, cancel := context.WithTimeout(ctx, r.config.DispatchTimeout)
 		events, err := r.dispatch(dispatchCtx, decision.Commands)
 		cancel()
@@ -180,14 +291,17 @@ func (r *Runtime) Run(ctx context.Context) error {
 			// Continue to apply error events if any
 		}
 
-		// 2.6 Observe (Apply Events)
+		// 2.7 Observe (Apply Events)
 		for _, event := range events {
 			if err := r.applyEvent(ctx, event); err != nil {
 				return err
 			}
 		}
+		if err := r.escalateErrors(ctx, events); err != nil {
+			return err
+		}
 
-		// 2.7 Checkpoint
+		// 2.8 Checkpoint
 		if step%r.config.CheckpointInterval == 0 {
 			if err := r.checkpoint(ctx); err != nil {
 				r.log.Warn("checkpoint failed", "error", err)
@@ -207,39 +321,6 @@ func (r *Runtime) gracefulShutdown(ctx context.Context) error {
 	return r.checkpoint(shutdownCtx)
 }
 
-func (r *Runtime) recover(ctx context.Context) error {
-	// 1. Load latest state directly from FS implementation assumption
-	// (Real logic would check checkpoints)
-	state, err := r.store.LoadLatestState(ctx)
-	if errors.Is(err, store.ErrNotFound) || errors.Is(err, store.ErrNoCheckpoint) {
-		r.log.Info("no previous state found, starting fresh")
-		return nil
-	}
-	if err != nil {
-		return err
-	}
-	r.updateState(state)
-	r.log.Info("state recovered", "version", state.Version)
-	return nil
-}
-
-func (r *Runtime) selectGoal() (*types.Goal, error) {
-	// Thread-safe goal selection with RLock
-	r.mu.RLock()
-	defer r.mu.RUnlock()
-
-	// Simple FIFO: return first Pending or InProgress goal
-	for i := range r.state.Goals {
-		g := &r.state.Goals[i]
-		if g.Status == types.GoalStatusPending || g.Status == types.GoalStatusInProgress {
-			// Return a copy to avoid external mutation
-			goalCopy := *g
-			return &goalCopy, nil
-		}
-	}
-	return nil, nil
-}
-
 // decide asks LLM what to do
 type Decision struct {
 	Commands []types.Command
@@ -272,6 +353,9 @@ func (r *Runtime) decide(ctx context.Context, goal *types.Goal) (*Decision, erro
 		messages[i] = m.Clone()
 	}
 	systemPrompt := r.state.SystemPrompt
+	mode := r.state.Mode
+	override := r.state.ModelOverride
+	loader := r.instructions
 	r.mu.RUnlock()
 
 	// Add System Prompt with Goal
@@ -298,6 +382,21 @@ When the user's request is fully addressed, use 'task_complete'.`, goal.Descript
 		systemPrompt = fmt.Sprintf("%s\n\nCurrent Goal: %s (Status: %s). Use 'task_complete' when done.", systemPrompt, goal.Description, goal.Status)
 	}
 
+	// Project and user instruction files, reloaded when they change
+	if text := loader.Prompt(); text != "" {
+		systemPrompt += "\n\n" + text
+	}
+
+	// Goals from slash commands may be limited to some tools
+	tools := goalToolList(goal, r.tools.List())
+	route := r.route(goal, mode, override)
+
+	// Planning mode only offers read-only tools and asks for a plan
+	if mode == types.ModePlanning {
+		systemPrompt += "\n\n" + planningPrompt
+		tools = readOnlyToolList(tools)
+	}
+
 	sysMsg := types.Message{
 		Role:    "system",
 		Content: systemPrompt,
@@ -308,9 +407,11 @@ When the user's request is fully addressed, use 'task_complete'.`, goal.Descript
 	// Create Command to Call LLM
 	cmd := &types.CallLLMCommand{
 		BaseCommand: types.NewBaseCommand("call_llm"),
-		Model:       r.config.Model,
+		Provider:    route.Provider,
+		Model:       route.Model,
 		Messages:    messages,
-		Tools:       r.tools.List(),
+		Tools:       tools,
+		GoalID:      goal.ID,
 	}
 
 	return &Decision{
@@ -333,6 +434,7 @@ func (r *Runtime) checkpoint(ctx context.Context) error {
 	cp := &types.Checkpoint{
 		ID:           types.GenerateID("ckpt"),
 		StateVersion: stateCopy.Version,
+		LastEventID:  stateCopy.LastEventID,
 		Timestamp:    time.Now(),
 		State:        stateCopy, // Use the cloned state
 		FileChanges:  fileChanges,
@@ -351,3 +453,25 @@ func (r *Runtime) GetState() *types.State {
 	defer r.mu.RUnlock()
 	return r.state.Clone()
 }
+
+// injectHookContext records the context returned by hooks as conversation events
+func (r *Runtime) injectHookContext(ctx context.Context) error {
+	r.mu.RLock()
+	hooks := r.hooks
+	r.mu.RUnlock()
+
+	for _, c := range hooks.TakeContext() {
+		evt := &types.HookContextEvent{
+			BaseEvent: types.NewBaseEvent("hook_context", "hook", string(c.Event)),
+			Hook:      string(c.Event),
+			Content:   c.Content,
+		}
+		if err := r.store.AppendEvent(ctx, evt); err != nil {
+			r.log.Error("failed to append hook context event", "error", err)
+		}
+		if err := r.applyEvent(ctx, evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
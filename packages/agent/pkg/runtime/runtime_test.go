@@ -5,9 +5,15 @@ import (
 	"encoding/json"
 	"errors"
 	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/hook"
+	"github.com/gm-agent-org/gm-agent/pkg/instructions"
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
 	"github.com/gm-agent-org/gm-agent/pkg/store"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
@@ -66,6 +72,9 @@ func (m *mockStore) SaveArtifact(ctx context.Context, artifact *types.Artifact)
 	return nil
 }
 func (m *mockStore) GetArtifact(ctx context.Context, id string) (*types.Artifact, error) {
+	if art, ok := m.artifacts[id]; ok {
+		return art, nil
+	}
 	return nil, store.ErrNotFound
 }
 func (m *mockStore) ListArtifacts(ctx context.Context, filter store.ArtifactFilter) ([]types.Artifact, error) {
@@ -103,7 +112,7 @@ func (mockLLM) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm
 
 type mockTools struct{ executed []*types.ToolCall }
 
-func (m *mockTools) Execute(ctx context.Context, call *types.ToolCall) (*types.ToolResult, error) {
+func (m *mockTools) Execute(ctx context.Context, mode types.RuntimeMode, call *types.ToolCall) (*types.ToolResult, error) {
 	m.executed = append(m.executed, call)
 	if call.Name == "fail" {
 		return nil, errors.New("failure")
@@ -264,3 +273,83 @@ func TestRunStopsWithoutGoals(t *testing.T) {
 		t.Fatalf("run returned error: %v", err)
 	}
 }
+
+// requestRecorder answers like mockLLM and keeps the last request
+type requestRecorder struct {
+	mockLLM
+	last *llm.ChatRequest
+}
+
+func (m *requestRecorder) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	m.last = req
+	return m.mockLLM.StreamChat(ctx, req)
+}
+
+func TestHookContextReachesDecision(t *testing.T) {
+	ms := newMockStore()
+	gateway := &requestRecorder{}
+	rt := New(DefaultConfig, ms, gateway, &mockTools{}, nil)
+	hooks, err := hook.New(config.HookTable{"UserPromptSubmit": {{Command: "echo 'Run gofmt before committing.'"}}}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("new hooks: %v", err)
+	}
+	hooks = hooks.ForSession("ses_1")
+	rt.SetHooks(hooks)
+
+	hooks.Run(context.Background(), hook.Input{Event: hook.UserPromptSubmit, Prompt: "hello"})
+	if err := rt.Ingest(context.Background(), &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hello"}); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := rt.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	if gateway.last == nil {
+		t.Fatalf("expected an LLM call")
+	}
+	last := gateway.last.Messages[len(gateway.last.Messages)-1]
+	if last.Role != "user" || !strings.Contains(last.Content, "Run gofmt before committing.") {
+		t.Fatalf("expected hook context before the decision, got %+v", last)
+	}
+	var recorded bool
+	for _, e := range ms.events {
+		if hc, ok := e.(*types.HookContextEvent); ok && hc.Hook == "UserPromptSubmit" {
+			recorded = true
+		}
+	}
+	if !recorded {
+		t.Fatalf("expected a hook_context event in the log")
+	}
+}
+
+func TestInstructionFilesReachSystemPrompt(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "GM.md"), []byte("Always write tests first."), 0644); err != nil {
+		t.Fatalf("write GM.md: %v", err)
+	}
+	loader, err := instructions.New(root, "", nil, nil)
+	if err != nil {
+		t.Fatalf("new loader: %v", err)
+	}
+
+	gateway := &requestRecorder{}
+	rt := New(DefaultConfig, newMockStore(), gateway, &mockTools{}, nil)
+	rt.SetInstructions(loader)
+	if err := rt.Ingest(context.Background(), &types.SystemPromptEvent{BaseEvent: types.NewBaseEvent("system_prompt", "user", "cli"), Prompt: "You review code."}); err != nil {
+		t.Fatalf("ingest system prompt: %v", err)
+	}
+	if err := rt.Ingest(context.Background(), &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "cli"), Content: "hello"}); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := rt.Run(context.Background()); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	system := gateway.last.Messages[0]
+	if system.Role != "system" || !strings.HasPrefix(system.Content, "You review code.") {
+		t.Fatalf("expected the session prompt first, got %q", system.Content)
+	}
+	if !strings.Contains(system.Content, filepath.Join(root, "GM.md")+" (project instructions)\n\nAlways write tests first.") {
+		t.Fatalf("expected the instruction file with its path, got %q", system.Content)
+	}
+}
@@ -0,0 +1,129 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// goalActive reports whether a goal still needs work
+func goalActive(g *types.Goal) bool {
+	return g.Status == types.GoalStatusPending || g.Status == types.GoalStatusInProgress
+}
+
+// goalBefore reports whether goal a should be scheduled before goal b:
+// lower priority value first, then the earlier deadline (goals without one last)
+func goalBefore(a, b *types.Goal) bool {
+	if a.Priority != b.Priority {
+		return a.Priority < b.Priority
+	}
+	switch {
+	case a.Deadline != nil && b.Deadline != nil:
+		return a.Deadline.Before(*b.Deadline)
+	case a.Deadline != nil:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextGoal returns the active goal to work on next, or nil if there is none.
+// Ties keep creation order, so equal goals run first come, first served.
+func nextGoal(state *types.State) *types.Goal {
+	var next *types.Goal
+	for i := range state.Goals {
+		g := &state.Goals[i]
+		if goalActive(g) && (next == nil || goalBefore(g, next)) {
+			next = g
+		}
+	}
+	return next
+}
+
+func (r *Runtime) selectGoal() (*types.Goal, error) {
+	// Thread-safe goal selection with RLock
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if g := nextGoal(r.state); g != nil {
+		// Return a copy to avoid external mutation
+		goalCopy := g.Clone()
+		return &goalCopy, nil
+	}
+	return nil, nil
+}
+
+// expiredGoals returns GoalFailedEvents for active goals that used up their
+// step budget or passed their deadline
+func expiredGoals(state *types.State, now time.Time) []*types.GoalFailedEvent {
+	var events []*types.GoalFailedEvent
+	for i := range state.Goals {
+		g := &state.Goals[i]
+		if !goalActive(g) {
+			continue
+		}
+
+		var reason string
+		switch {
+		case g.MaxSteps > 0 && g.StepsUsed >= g.MaxSteps:
+			reason = fmt.Sprintf("step budget exhausted (%d/%d steps)", g.StepsUsed, g.MaxSteps)
+		case g.Deadline != nil && now.After(*g.Deadline):
+			reason = fmt.Sprintf("deadline passed (%s)", g.Deadline.Format(time.RFC3339))
+		default:
+			continue
+		}
+		events = append(events, &types.GoalFailedEvent{
+			BaseEvent: types.NewBaseEvent("goal_failed", "runtime", g.ID),
+			GoalID:    g.ID,
+			Reason:    reason,
+		})
+	}
+	return events
+}
+
+// expireGoals fails goals that can no longer be worked on before the next goal is selected
+func (r *Runtime) expireGoals(ctx context.Context) error {
+	r.mu.RLock()
+	events := expiredGoals(r.state, time.Now())
+	r.mu.RUnlock()
+
+	for _, evt := range events {
+		r.log.Warn("goal failed", "goal_id", evt.GoalID, "reason", evt.Reason)
+		if err := r.store.AppendEvent(ctx, evt); err != nil {
+			r.log.Error("failed to append goal failed event", "error", err)
+		}
+		if err := r.applyEvent(ctx, evt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findGoal returns the goal with the given ID, or the scheduled goal if id is empty
+func findGoal(state *types.State, id string) *types.Goal {
+	if id == "" {
+		return nextGoal(state)
+	}
+	for i := range state.Goals {
+		if state.Goals[i].ID == id {
+			return &state.Goals[i]
+		}
+	}
+	return nil
+}
+
+// reduceGoalStep records one decide/act cycle against the goal an LLM call was made for
+func reduceGoalStep(state *types.State, goalID string, at time.Time) *types.Goal {
+	goal := findGoal(state, goalID)
+	if goal == nil {
+		return nil
+	}
+	goal.StepsUsed++
+	if goal.Status == types.GoalStatusPending {
+		goal.Status = types.GoalStatusInProgress
+	}
+	goal.UpdatedAt = at
+	return goal
+}
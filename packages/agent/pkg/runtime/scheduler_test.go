@@ -0,0 +1,208 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// loopingLLM never finishes: every reply is another tool call
+type loopingLLM struct{}
+
+func (loopingLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	return nil, nil
+}
+
+func (loopingLLM) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, 1)
+	ch <- llm.StreamChunk{ToolCalls: []types.ToolCall{{ID: types.GenerateID("call"), Name: "talk", Arguments: "{}"}}}
+	close(ch)
+	return ch, nil
+}
+
+func TestSelectGoalOrdersByPriorityThenDeadline(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	soon := time.Now().Add(time.Hour)
+	later := time.Now().Add(2 * time.Hour)
+	rt.state.Goals = []types.Goal{
+		{ID: "normal", Status: types.GoalStatusPending, Priority: 5},
+		{ID: "done", Status: types.GoalStatusCompleted},
+		{ID: "urgent_later", Status: types.GoalStatusPending, Deadline: &later},
+		{ID: "urgent_soon", Status: types.GoalStatusPending, Deadline: &soon},
+		{ID: "urgent", Status: types.GoalStatusPending},
+	}
+
+	var order []string
+	for {
+		goal, err := rt.selectGoal()
+		if err != nil {
+			t.Fatalf("select error: %v", err)
+		}
+		if goal == nil {
+			break
+		}
+		order = append(order, goal.ID)
+		findGoal(rt.state, goal.ID).Status = types.GoalStatusCompleted
+	}
+
+	want := []string{"urgent_soon", "urgent_later", "urgent", "normal"}
+	if len(order) != len(want) {
+		t.Fatalf("expected order %v, got %v", want, order)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Fatalf("expected order %v, got %v", want, order)
+		}
+	}
+}
+
+func TestLLMResponseCountsStepsForItsGoal(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{
+		{ID: "g1", Status: types.GoalStatusPending},
+		{ID: "g2", Status: types.GoalStatusPending, Priority: 1},
+	}
+	ctx := context.Background()
+
+	if err := rt.applyEvent(ctx, &types.LLMResponseEvent{
+		BaseEvent: types.NewBaseEvent("llm_response", "llm", ""),
+		ToolCalls: []types.ToolCall{{ID: "c1", Name: "talk"}},
+		GoalID:    "g2",
+	}); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	g2 := findGoal(rt.GetState(), "g2")
+	if g2.StepsUsed != 1 || g2.Status != types.GoalStatusInProgress {
+		t.Fatalf("expected g2 in progress after one step, got %+v", g2)
+	}
+
+	// A direct answer completes the goal it was for, not the first one
+	if err := rt.applyEvent(ctx, &types.LLMResponseEvent{
+		BaseEvent: types.NewBaseEvent("llm_response", "llm", ""),
+		Content:   "done",
+		GoalID:    "g2",
+	}); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	state := rt.GetState()
+	if findGoal(state, "g2").Status != types.GoalStatusCompleted || findGoal(state, "g1").Status != types.GoalStatusPending {
+		t.Fatalf("expected only g2 completed, got %+v", state.Goals)
+	}
+	if findGoal(state, "g2").StepsUsed != 2 {
+		t.Fatalf("expected 2 steps on g2, got %d", findGoal(state, "g2").StepsUsed)
+	}
+}
+
+func TestTaskCompleteCompletesTheCallingGoal(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	// g2 arrived with a higher priority while g1's call was running
+	rt.state.Goals = []types.Goal{
+		{ID: "g1", Status: types.GoalStatusInProgress},
+		{ID: "g2", Status: types.GoalStatusPending, Priority: -1},
+	}
+
+	if err := rt.applyEvent(context.Background(), &types.ToolResultEvent{
+		BaseEvent:  types.NewBaseEvent("tool_result", "tool", "task_complete"),
+		ToolCallID: "c1",
+		ToolName:   "task_complete",
+		Success:    true,
+		GoalID:     "g1",
+	}); err != nil {
+		t.Fatalf("apply error: %v", err)
+	}
+	state := rt.GetState()
+	if findGoal(state, "g1").Status != types.GoalStatusCompleted || findGoal(state, "g2").Status != types.GoalStatusPending {
+		t.Fatalf("expected only g1 completed, got %+v", state.Goals)
+	}
+}
+
+func TestExpireGoalsFailsOverBudgetAndLateGoals(t *testing.T) {
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, mockLLM{}, &mockTools{}, nil)
+	past := time.Now().Add(-time.Minute)
+	rt.state.Goals = []types.Goal{
+		{ID: "spent", Status: types.GoalStatusInProgress, MaxSteps: 3, StepsUsed: 3},
+		{ID: "late", Status: types.GoalStatusPending, Deadline: &past},
+		{ID: "fine", Status: types.GoalStatusPending, MaxSteps: 3, StepsUsed: 2},
+	}
+
+	if err := rt.expireGoals(context.Background()); err != nil {
+		t.Fatalf("expire error: %v", err)
+	}
+	state := rt.GetState()
+	if findGoal(state, "spent").Status != types.GoalStatusFailed || findGoal(state, "late").Status != types.GoalStatusFailed {
+		t.Fatalf("expected spent and late goals failed, got %+v", state.Goals)
+	}
+	if findGoal(state, "fine").Status != types.GoalStatusPending {
+		t.Fatalf("expected goal within budget untouched, got %+v", findGoal(state, "fine"))
+	}
+
+	failed := map[string]bool{}
+	for _, e := range ms.events {
+		if f, ok := e.(*types.GoalFailedEvent); ok {
+			failed[f.GoalID] = f.Reason != ""
+		}
+	}
+	if len(failed) != 2 || !failed["spent"] || !failed["late"] {
+		t.Fatalf("expected goal_failed events with reasons, got %v", failed)
+	}
+}
+
+func TestRunStopsGoalAtStepBudget(t *testing.T) {
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, loopingLLM{}, &mockTools{}, nil)
+	ctx := context.Background()
+	if err := rt.Ingest(ctx, &types.UserMessageEvent{
+		BaseEvent: types.NewBaseEvent("user_message", "user", ""),
+		Content:   "keep going",
+		MaxSteps:  2,
+	}); err != nil {
+		t.Fatalf("ingest error: %v", err)
+	}
+
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("run error: %v", err)
+	}
+	goal := rt.GetState().Goals[0]
+	if goal.Type != types.GoalTypeUserRequest || goal.MaxSteps != 2 || goal.Status != types.GoalStatusFailed || goal.StepsUsed != 2 {
+		t.Fatalf("expected the user goal failed after 2 steps, got %+v", goal)
+	}
+	var reason string
+	for _, e := range ms.events {
+		if f, ok := e.(*types.GoalFailedEvent); ok && f.GoalID == goal.ID {
+			reason = f.Reason
+		}
+	}
+	if !strings.Contains(reason, "step budget exhausted (2/2 steps)") {
+		t.Fatalf("expected a budget-exhausted failure, got %q", reason)
+	}
+}
+
+func TestForkedMessageCreatesPrioritizedGoal(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, &mockTools{}, nil)
+	ctx := context.Background()
+	deadline := time.Now().Add(time.Hour)
+
+	for _, evt := range []*types.UserMessageEvent{
+		{BaseEvent: types.NewBaseEvent("user_message", "user", ""), Content: "refactor"},
+		{BaseEvent: types.NewBaseEvent("user_message", "user", ""), Content: "also, what time is it?"},
+		{BaseEvent: types.NewBaseEvent("user_message", "user", ""), Content: "fix prod", Semantic: types.SemanticPreempt, Priority: -100, Deadline: &deadline},
+	} {
+		if err := rt.applyEvent(ctx, evt); err != nil {
+			t.Fatalf("apply error: %v", err)
+		}
+	}
+
+	state := rt.GetState()
+	if len(state.Goals) != 2 {
+		t.Fatalf("expected appended message to join the goal and preempt to add one, got %+v", state.Goals)
+	}
+	next := findGoal(state, "")
+	if next.Description != "fix prod" || next.Priority != -100 || next.Deadline == nil || !next.Deadline.Equal(deadline) {
+		t.Fatalf("expected preempting goal scheduled first, got %+v", next)
+	}
+}
@@ -0,0 +1,86 @@
+package runtime
+
+import (
+	"context"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// appendStreamEvent records a streaming progress event for clients.
+// These events never reach the reducer.
+func (r *Runtime) appendStreamEvent(ctx context.Context, evt types.Event) {
+	if err := r.store.AppendEvent(ctx, evt); err != nil {
+		r.log.Error("failed to append stream event", "type", evt.EventType(), "error", err)
+	}
+}
+
+// toolCallStream turns the tool call deltas of an LLM stream into
+// start, delta and end events
+type toolCallStream struct {
+	calls []*streamedCall // In start order
+}
+
+type streamedCall struct {
+	index int
+	id    string
+	name  string
+	size  int
+}
+
+// delta returns the events for one tool call delta
+func (s *toolCallStream) delta(d llm.ToolCallDelta) []types.Event {
+	var events []types.Event
+	c := s.find(d.Index)
+	if c == nil {
+		c = &streamedCall{index: d.Index, id: d.ID, name: d.Name}
+		s.calls = append(s.calls, c)
+		events = append(events, &types.ToolCallStartEvent{
+			BaseEvent:  types.NewBaseEvent("tool_call_start", "llm", d.Name),
+			ToolCallID: d.ID,
+			ToolName:   d.Name,
+			Index:      d.Index,
+		})
+	}
+	if d.Arguments != "" {
+		c.size += len(d.Arguments)
+		events = append(events, &types.ToolCallDeltaEvent{
+			BaseEvent:  types.NewBaseEvent("tool_call_delta", "llm", c.name),
+			ToolCallID: c.id,
+			Index:      d.Index,
+			Delta:      d.Arguments,
+		})
+	}
+	return events
+}
+
+// end returns the events that close the response's tool calls. Providers that
+// only send complete calls get a start, one delta and an end per call, so
+// clients see the same sequence for every provider.
+func (s *toolCallStream) end(complete []types.ToolCall) []types.Event {
+	var events []types.Event
+	if len(s.calls) == 0 {
+		for i, tc := range complete {
+			events = append(events, s.delta(llm.ToolCallDelta{Index: i, ID: tc.ID, Name: tc.Name, Arguments: tc.Arguments})...)
+		}
+	}
+	for _, c := range s.calls {
+		events = append(events, &types.ToolCallEndEvent{
+			BaseEvent:  types.NewBaseEvent("tool_call_end", "llm", c.name),
+			ToolCallID: c.id,
+			ToolName:   c.name,
+			Index:      c.index,
+			Size:       c.size,
+		})
+	}
+	return events
+}
+
+func (s *toolCallStream) find(index int) *streamedCall {
+	for _, c := range s.calls {
+		if c.index == index {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package runtime
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// chunkLLM streams a fixed list of chunks
+type chunkLLM struct{ chunks []llm.StreamChunk }
+
+func (m chunkLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	return nil, nil
+}
+
+func (m chunkLLM) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, len(m.chunks))
+	for _, c := range m.chunks {
+		ch <- c
+	}
+	close(ch)
+	return ch, nil
+}
+
+func streamEventTypes(events []types.Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.EventType())
+	}
+	return out
+}
+
+func TestStreamingEmitsReasoningAndToolCallDeltas(t *testing.T) {
+	ms := newMockStore()
+	call := types.ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"a.go"}`}
+	rt := New(DefaultConfig, ms, chunkLLM{chunks: []llm.StreamChunk{
+		{Reasoning: "The user wants a.go."},
+		{Content: "Reading it."},
+		{ToolCallDeltas: []llm.ToolCallDelta{{Index: 0, ID: "call_1", Name: "read_file"}}},
+		{ToolCallDeltas: []llm.ToolCallDelta{{Index: 0, Arguments: `{"path":`}}},
+		{ToolCallDeltas: []llm.ToolCallDelta{{Index: 0, Arguments: `"a.go"}`}}},
+		{ToolCalls: []types.ToolCall{call}},
+	}}, &mockTools{}, nil)
+
+	events, err := rt.executeCallLLM(context.Background(), &types.CallLLMCommand{Model: "m"})
+	if err != nil {
+		t.Fatalf("execute call llm error: %v", err)
+	}
+	if resp := events[0].(*types.LLMResponseEvent); len(resp.ToolCalls) != 1 || resp.Content != "Reading it." {
+		t.Fatalf("unexpected response event %+v", resp)
+	}
+
+	want := []string{"llm_reasoning", "llm_token", "tool_call_start", "tool_call_delta", "tool_call_delta", "tool_call_end"}
+	if got := streamEventTypes(ms.events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected stream events %v, got %v", want, got)
+	}
+	start := ms.events[2].(*types.ToolCallStartEvent)
+	delta := ms.events[4].(*types.ToolCallDeltaEvent)
+	end := ms.events[5].(*types.ToolCallEndEvent)
+	if start.ToolCallID != "call_1" || start.ToolName != "read_file" || delta.ToolCallID != "call_1" || delta.Delta != `"a.go"}` {
+		t.Fatalf("unexpected start or delta %+v %+v", start, delta)
+	}
+	if end.ToolName != "read_file" || end.Size != len(call.Arguments) {
+		t.Fatalf("unexpected end event %+v", end)
+	}
+}
+
+func TestStreamingSynthesizesEventsForCompleteCalls(t *testing.T) {
+	ms := newMockStore()
+	rt := New(DefaultConfig, ms, chunkLLM{chunks: []llm.StreamChunk{
+		{ToolCalls: []types.ToolCall{
+			{ID: "call_1", Name: "ls", Arguments: `{}`},
+			{ID: "call_2", Name: "grep", Arguments: `{"pattern":"x"}`},
+		}},
+	}}, &mockTools{}, nil)
+
+	if _, err := rt.executeCallLLM(context.Background(), &types.CallLLMCommand{Model: "m"}); err != nil {
+		t.Fatalf("execute call llm error: %v", err)
+	}
+	want := []string{"tool_call_start", "tool_call_delta", "tool_call_start", "tool_call_delta", "tool_call_end", "tool_call_end"}
+	if got := streamEventTypes(ms.events); !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected stream events %v, got %v", want, got)
+	}
+	if end := ms.events[5].(*types.ToolCallEndEvent); end.ToolCallID != "call_2" || end.Index != 1 || end.Size != len(`{"pattern":"x"}`) {
+		t.Fatalf("unexpected end event %+v", end)
+	}
+}
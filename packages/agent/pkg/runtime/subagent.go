@@ -0,0 +1,347 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/store"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// SubAgentConfig configures the spawn_agent tool
+type SubAgentConfig struct {
+	Tools    []string // Tools a sub-agent may be granted (DefaultSubAgentTools if empty)
+	MaxSteps int      // Step budget per sub-agent (DefaultSubAgentMaxSteps if 0)
+	Model    string   // Overrides the parent's model when set
+
+	// NewStore opens the store that holds a sub-agent's own events and checkpoints
+	NewStore func(agentID string) (store.Store, error)
+}
+
+// DefaultSubAgentTools are granted to sub-agents when none are configured
+var DefaultSubAgentTools = []string{"read_file", "glob", "grep"}
+
+// DefaultSubAgentMaxSteps is the step budget of a sub-agent when none is configured
+const DefaultSubAgentMaxSteps = 20
+
+// spawnAgentTool is never offered to sub-agents, so they cannot nest
+const spawnAgentTool = "spawn_agent"
+
+// subAgentPrompt is the system prompt of every sub-agent
+const subAgentPrompt = `You are a sub-agent working on a single task delegated by another agent.
+Work independently with the tools you are given; you cannot ask the user questions.
+When you are done, reply with a concise summary of your findings or changes and no tool calls.
+That reply is returned to the delegating agent as your result.`
+
+// SpawnAgentArgs are the arguments of the spawn_agent tool
+type SpawnAgentArgs struct {
+	Description string   `json:"description"`
+	Prompt      string   `json:"prompt"`
+	Tools       []string `json:"tools,omitempty"`
+	MaxSteps    int      `json:"max_steps,omitempty"`
+}
+
+// toolCallKey is the context key for the tool call being executed
+type toolCallKey struct{}
+
+// toolCallContext gives runtime-aware tool handlers access to the calling runtime
+type toolCallContext struct {
+	runtime    *Runtime
+	toolCallID string
+	goalID     string        // Goal whose LLM response requested the call
+	events     []types.Event // Recorded before the tool result
+}
+
+func toolCallFrom(ctx context.Context) *toolCallContext {
+	tc, _ := ctx.Value(toolCallKey{}).(*toolCallContext)
+	return tc
+}
+
+// SpawnAgentHandler returns the spawn_agent tool handler.
+// The sub-agent shares the parent's LLM gateway and tool executor, so it inherits
+// the session's policy and permission prompts, but runs with its own goal and context.
+func SpawnAgentHandler(cfg SubAgentConfig) func(ctx context.Context, argsJSON string) (string, error) {
+	return func(ctx context.Context, argsJSON string) (string, error) {
+		var args SpawnAgentArgs
+		if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+			return "", fmt.Errorf("invalid arguments: %w", err)
+		}
+		if args.Prompt == "" {
+			return "", fmt.Errorf("prompt is required")
+		}
+
+		tc := toolCallFrom(ctx)
+		if tc == nil {
+			return "", errors.New("spawn_agent can only be called by a runtime")
+		}
+		return tc.runtime.spawnSubAgent(ctx, cfg, tc, args)
+	}
+}
+
+// spawnSubAgent runs a child runtime to completion and returns its final summary
+func (r *Runtime) spawnSubAgent(ctx context.Context, cfg SubAgentConfig, tc *toolCallContext, args SpawnAgentArgs) (string, error) {
+	if cfg.NewStore == nil {
+		return "", errors.New("sub-agents are not configured")
+	}
+	// The child's budget is what the parent has left
+	if violation := r.budgetViolation(); violation != "" {
+		return "", fmt.Errorf("%w: %s", ErrBudgetExceeded, violation)
+	}
+
+	agentID := types.GenerateID("agent")
+	childStore, err := cfg.NewStore(agentID)
+	if err != nil {
+		return "", fmt.Errorf("open sub-agent store: %w", err)
+	}
+	defer childStore.Close()
+
+	mode := r.getCurrentMode()
+	childCfg := r.subAgentConfig(cfg, args)
+	tools := newRestrictedTools(r.tools, mode, subAgentTools(cfg, args))
+	child := New(childCfg, &forwardingStore{
+		Store:      childStore,
+		parent:     r.store,
+		agentID:    agentID,
+		toolCallID: tc.toolCallID,
+		goalID:     tc.goalID,
+		log:        r.log,
+	}, r.llm, tools, r.log.With("agent_id", agentID))
+	child.SetInstructions(r.instructions)
+
+	started := &types.SubAgentEvent{
+		BaseEvent:   types.NewBaseEvent("subagent", "runtime", agentID),
+		AgentID:     agentID,
+		ToolCallID:  tc.toolCallID,
+		Status:      "started",
+		Description: args.Description,
+		GoalID:      tc.goalID,
+	}
+	if err := r.store.AppendEvent(ctx, started); err != nil {
+		r.log.Error("failed to append sub-agent event", "error", err)
+	}
+
+	description := args.Description
+	if description == "" {
+		description = args.Prompt
+	}
+	now := time.Now()
+	seed := []types.Event{
+		&types.SystemPromptEvent{
+			BaseEvent: types.NewBaseEvent("system_prompt", "runtime", agentID),
+			Prompt:    subAgentPrompt,
+		},
+		&types.GoalCreatedEvent{
+			BaseEvent: types.NewBaseEvent("goal_created", "runtime", agentID),
+			Goal: types.Goal{
+				ID:          types.GenerateGoalID(),
+				Type:        types.GoalTypeSubTask,
+				Description: description,
+				Status:      types.GoalStatusPending,
+				MaxSteps:    childCfg.MaxSteps,
+				CreatedAt:   now,
+				UpdatedAt:   now,
+			},
+		},
+		&types.UserMessageEvent{
+			BaseEvent: types.NewBaseEvent("user_message", "runtime", agentID),
+			Content:   args.Prompt,
+		},
+	}
+	for _, evt := range seed {
+		if err := child.Ingest(ctx, evt); err != nil {
+			return "", fmt.Errorf("start sub-agent: %w", err)
+		}
+	}
+
+	runErr := child.Run(ctx)
+	state := child.GetState()
+	summary := finalSummary(state)
+
+	report := &types.SubAgentEvent{
+		BaseEvent:   types.NewBaseEvent("subagent", "runtime", agentID),
+		AgentID:     agentID,
+		ToolCallID:  tc.toolCallID,
+		Status:      "completed",
+		Description: args.Description,
+		Summary:     summary,
+		GoalID:      tc.goalID,
+	}
+	if state.Usage != nil {
+		report.Usage = subAgentUsage(state.Usage)
+	}
+	if runErr != nil {
+		report.Status = "failed"
+		report.Error = runErr.Error()
+	}
+	tc.events = append(tc.events, report)
+
+	if runErr != nil {
+		return summary, fmt.Errorf("sub-agent %s failed: %w", agentID, runErr)
+	}
+	if summary == "" {
+		return "Sub-agent finished without a summary.", nil
+	}
+	return summary, nil
+}
+
+// subAgentConfig derives the child's runtime config from the parent's
+func (r *Runtime) subAgentConfig(cfg SubAgentConfig, args SpawnAgentArgs) Config {
+	childCfg := r.config
+	childCfg.MaxSteps = cfg.MaxSteps
+	if childCfg.MaxSteps <= 0 {
+		childCfg.MaxSteps = DefaultSubAgentMaxSteps
+	}
+	if args.MaxSteps > 0 && args.MaxSteps < childCfg.MaxSteps {
+		childCfg.MaxSteps = args.MaxSteps
+	}
+	if cfg.Model != "" {
+		childCfg.Model = cfg.Model
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.state.Usage != nil {
+		if childCfg.MaxSessionTokens > 0 {
+			childCfg.MaxSessionTokens -= r.state.Usage.Session.TotalTokens
+		}
+		if childCfg.MaxSessionCost > 0 {
+			childCfg.MaxSessionCost -= r.state.Usage.Session.CostUSD
+		}
+	}
+	return childCfg
+}
+
+// subAgentTools returns the tools granted to a sub-agent: the configured set,
+// narrowed to the requested tools if any
+func subAgentTools(cfg SubAgentConfig, args SpawnAgentArgs) map[string]bool {
+	configured := cfg.Tools
+	if len(configured) == 0 {
+		configured = DefaultSubAgentTools
+	}
+	requested := make(map[string]bool, len(args.Tools))
+	for _, name := range args.Tools {
+		requested[name] = true
+	}
+
+	allowed := make(map[string]bool)
+	for _, name := range configured {
+		if name == spawnAgentTool || (len(requested) > 0 && !requested[name]) {
+			continue
+		}
+		allowed[name] = true
+	}
+	return allowed
+}
+
+// subAgentUsage breaks a sub-agent's usage down by model for the parent's ledger
+func subAgentUsage(ledger *types.UsageLedger) map[string]types.UsageTotals {
+	usage := make(map[string]types.UsageTotals, len(ledger.ByModel))
+	for model, totals := range ledger.ByModel {
+		usage[model] = *totals
+	}
+	// Calls without a model name are only in the session totals
+	if len(usage) == 0 && ledger.Session.Calls > 0 {
+		usage[""] = ledger.Session
+	}
+	return usage
+}
+
+// finalSummary returns the sub-agent's last non-empty reply
+func finalSummary(state *types.State) string {
+	msgs := state.Context.Messages
+	for i := len(msgs) - 1; i >= 0; i-- {
+		if msgs[i].Role == "assistant" && strings.TrimSpace(msgs[i].Content) != "" {
+			return msgs[i].Content
+		}
+	}
+	return ""
+}
+
+// restrictedTools limits a sub-agent to a subset of the parent's tools.
+// Calls run in the parent's mode, so a planning parent only gets read-only sub-agents.
+type restrictedTools struct {
+	ToolExecutor
+	mode    types.RuntimeMode
+	allowed map[string]bool
+}
+
+func newRestrictedTools(tools ToolExecutor, mode types.RuntimeMode, allowed map[string]bool) *restrictedTools {
+	return &restrictedTools{ToolExecutor: tools, mode: mode, allowed: allowed}
+}
+
+func (t *restrictedTools) Execute(ctx context.Context, _ types.RuntimeMode, call *types.ToolCall) (*types.ToolResult, error) {
+	if !t.allowed[call.Name] {
+		return nil, fmt.Errorf("tool %s is not available to this sub-agent", call.Name)
+	}
+	return t.ToolExecutor.Execute(ctx, t.mode, call)
+}
+
+func (t *restrictedTools) List() []types.Tool {
+	var tools []types.Tool
+	for _, tool := range t.ToolExecutor.List() {
+		if !t.allowed[tool.Name] || (t.mode == types.ModePlanning && !tool.ReadOnly) {
+			continue
+		}
+		tools = append(tools, tool)
+	}
+	return tools
+}
+
+// forwardingStore persists a sub-agent's events in its own store and mirrors
+// them to the parent's store as SubAgentEvents, so they stream with the session
+type forwardingStore struct {
+	store.Store
+	parent     store.Store
+	agentID    string
+	toolCallID string
+	goalID     string
+	log        *slog.Logger
+}
+
+func (s *forwardingStore) AppendEvent(ctx context.Context, event types.Event) error {
+	if err := s.Store.AppendEvent(ctx, event); err != nil {
+		return err
+	}
+	s.forward(ctx, event)
+	return nil
+}
+
+func (s *forwardingStore) AppendEvents(ctx context.Context, events []types.Event) error {
+	if err := s.Store.AppendEvents(ctx, events); err != nil {
+		return err
+	}
+	for _, event := range events {
+		s.forward(ctx, event)
+	}
+	return nil
+}
+
+func (s *forwardingStore) forward(ctx context.Context, event types.Event) {
+	// Streaming deltas are too noisy to mirror; the final response is forwarded instead
+	switch event.EventType() {
+	case "llm_token", "llm_reasoning", "tool_call_start", "tool_call_delta", "tool_call_end":
+		return
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.log.Error("failed to encode sub-agent event", "error", err)
+		return
+	}
+	evt := &types.SubAgentEvent{
+		BaseEvent:  types.NewBaseEvent("subagent", "runtime", s.agentID),
+		AgentID:    s.agentID,
+		ToolCallID: s.toolCallID,
+		Status:     "event",
+		ChildType:  event.EventType(),
+		Payload:    payload,
+		GoalID:     s.goalID,
+	}
+	if err := s.parent.AppendEvent(ctx, evt); err != nil {
+		s.log.Error("failed to forward sub-agent event", "error", err)
+	}
+}
@@ -0,0 +1,216 @@
+package runtime
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/store"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// researchLLM reads a file on its first call and then replies with a summary
+type researchLLM struct {
+	mu       sync.Mutex
+	requests []*llm.ChatRequest
+}
+
+func (m *researchLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	return nil, nil
+}
+
+func (m *researchLLM) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	m.mu.Lock()
+	m.requests = append(m.requests, req)
+	first := len(m.requests) == 1
+	m.mu.Unlock()
+
+	ch := make(chan llm.StreamChunk, 1)
+	usage := &types.Usage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120}
+	if first {
+		ch <- llm.StreamChunk{
+			ToolCalls: []types.ToolCall{{ID: "c1", Name: "read_file", Arguments: `{"path":"main.go"}`}},
+			Usage:     usage,
+		}
+	} else {
+		ch <- llm.StreamChunk{Content: "main.go starts the server", Usage: usage}
+	}
+	close(ch)
+	return ch, nil
+}
+
+// agentTools serves spawn_agent through its handler and records other calls
+type agentTools struct {
+	spawn func(ctx context.Context, argsJSON string) (string, error)
+
+	mu       sync.Mutex
+	executed []string
+	modes    []types.RuntimeMode
+}
+
+func (m *agentTools) Execute(ctx context.Context, mode types.RuntimeMode, call *types.ToolCall) (*types.ToolResult, error) {
+	if call.Name == "spawn_agent" {
+		out, err := m.spawn(ctx, call.Arguments)
+		result := &types.ToolResult{ToolCallID: call.ID, ToolName: call.Name, Content: out, IsError: err != nil}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result, nil
+	}
+	m.mu.Lock()
+	m.executed = append(m.executed, call.Name)
+	m.modes = append(m.modes, mode)
+	m.mu.Unlock()
+	return &types.ToolResult{ToolCallID: call.ID, ToolName: call.Name, Content: "package main"}, nil
+}
+
+func (m *agentTools) List() []types.Tool {
+	return []types.Tool{
+		{Name: "read_file", ReadOnly: true},
+		{Name: "glob", ReadOnly: true},
+		{Name: "write_file"},
+		{Name: "spawn_agent", ReadOnly: true},
+	}
+}
+
+func newSubAgentRuntime(t *testing.T, cfg SubAgentConfig) (*Runtime, *mockStore, *researchLLM, *agentTools, map[string]*mockStore) {
+	t.Helper()
+	children := make(map[string]*mockStore)
+	cfg.NewStore = func(agentID string) (store.Store, error) {
+		children[agentID] = newMockStore()
+		return children[agentID], nil
+	}
+	parentStore := newMockStore()
+	gateway := &researchLLM{}
+	tools := &agentTools{spawn: SpawnAgentHandler(cfg)}
+	return New(DefaultConfig, parentStore, gateway, tools, nil), parentStore, gateway, tools, children
+}
+
+func spawnCmd(args map[string]any) *types.CallToolCommand {
+	return &types.CallToolCommand{
+		BaseCommand: types.NewBaseCommand("call_tool"),
+		ToolCallID:  "spawn1",
+		ToolName:    "spawn_agent",
+		Arguments:   args,
+	}
+}
+
+func TestSpawnAgentReturnsChildSummary(t *testing.T) {
+	rt, parentStore, gateway, tools, children := newSubAgentRuntime(t, SubAgentConfig{Tools: []string{"read_file", "write_file", "spawn_agent"}})
+
+	events, err := rt.executeCallTool(context.Background(), spawnCmd(map[string]any{
+		"description": "inspect entrypoint",
+		"prompt":      "What does main.go do?",
+		"tools":       []string{"read_file", "spawn_agent"},
+	}))
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected sub-agent report and tool result, got %d events", len(events))
+	}
+	report, ok := events[0].(*types.SubAgentEvent)
+	if !ok || report.Status != "completed" || report.Summary != "main.go starts the server" {
+		t.Fatalf("expected completed sub-agent report, got %+v", events[0])
+	}
+	result := events[1].(*types.ToolResultEvent)
+	if !result.Success || result.Output != "main.go starts the server" {
+		t.Fatalf("expected summary as tool result, got %+v", result)
+	}
+
+	// The child only sees the granted tools, never spawn_agent
+	offered := gateway.requests[0].Tools
+	if len(offered) != 1 || offered[0].Name != "read_file" {
+		t.Fatalf("expected only read_file offered to sub-agent, got %+v", offered)
+	}
+	if len(tools.executed) != 1 || tools.executed[0] != "read_file" {
+		t.Fatalf("expected sub-agent to run read_file, got %v", tools.executed)
+	}
+
+	// Child events live in its own store and are mirrored to the parent
+	child := children[report.AgentID]
+	if child == nil || len(child.events) == 0 {
+		t.Fatalf("expected events in the sub-agent store")
+	}
+	var goal *types.GoalCreatedEvent
+	for _, e := range child.events {
+		if g, ok := e.(*types.GoalCreatedEvent); ok {
+			goal = g
+		}
+	}
+	if goal == nil || goal.Goal.Type != types.GoalTypeSubTask || goal.Goal.MaxSteps != DefaultSubAgentMaxSteps {
+		t.Fatalf("expected sub-task goal for the child, got %+v", goal)
+	}
+	forwarded := map[string]bool{}
+	for _, e := range parentStore.events {
+		if s, ok := e.(*types.SubAgentEvent); ok && s.AgentID == report.AgentID {
+			forwarded[s.Status+":"+s.ChildType] = true
+		}
+	}
+	if !forwarded["started:"] || !forwarded["event:tool_result"] || !forwarded["event:llm_response"] {
+		t.Fatalf("expected child events forwarded to parent store, got %v", forwarded)
+	}
+}
+
+func TestSpawnAgentUsageChargedToParent(t *testing.T) {
+	rt, _, _, _, _ := newSubAgentRuntime(t, SubAgentConfig{})
+	ctx := context.Background()
+	if err := rt.Ingest(ctx, &types.UserMessageEvent{
+		BaseEvent: types.NewBaseEvent("user_message", "user", ""),
+		Content:   "explain the project",
+	}); err != nil {
+		t.Fatalf("ingest error: %v", err)
+	}
+	parent := rt.GetState().Goals[0].ID
+	// A goal that is scheduled ahead of the spawning one must not be charged
+	rt.state.Goals = append(rt.state.Goals, types.Goal{ID: "urgent", Status: types.GoalStatusPending, Priority: -1})
+
+	cmd := spawnCmd(map[string]any{"prompt": "What does main.go do?"})
+	cmd.GoalID = parent
+	events, err := rt.executeCallTool(ctx, cmd)
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	for _, e := range events {
+		if err := rt.applyEvent(ctx, e); err != nil {
+			t.Fatalf("apply error: %v", err)
+		}
+	}
+
+	state := rt.GetState()
+	if state.Usage == nil || state.Usage.Session.TotalTokens != 240 || state.Usage.Session.Calls != 2 {
+		t.Fatalf("expected child usage in parent ledger, got %+v", state.Usage)
+	}
+	if _, charged := state.Usage.ByGoal["urgent"]; charged || state.Usage.ByGoal[parent].TotalTokens != 240 {
+		t.Fatalf("expected child usage charged to the parent goal, got %+v", state.Usage.ByGoal)
+	}
+}
+
+func TestSpawnAgentInheritsPlanningMode(t *testing.T) {
+	rt, _, gateway, tools, _ := newSubAgentRuntime(t, SubAgentConfig{Tools: []string{"read_file", "write_file"}})
+	enterPlanning(t, rt, "plan a refactor")
+
+	if _, err := rt.executeCallTool(context.Background(), spawnCmd(map[string]any{"prompt": "survey the code"})); err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	offered := gateway.requests[0].Tools
+	if len(offered) != 1 || offered[0].Name != "read_file" {
+		t.Fatalf("expected only read-only tools in planning mode, got %+v", offered)
+	}
+	if len(tools.modes) != 1 || tools.modes[0] != types.ModePlanning {
+		t.Fatalf("expected sub-agent tool calls checked in planning mode, got %v", tools.modes)
+	}
+}
+
+func TestSubAgentTools(t *testing.T) {
+	allowed := subAgentTools(SubAgentConfig{}, SpawnAgentArgs{})
+	if len(allowed) != len(DefaultSubAgentTools) {
+		t.Fatalf("expected default tools, got %v", allowed)
+	}
+
+	allowed = subAgentTools(SubAgentConfig{Tools: []string{"read_file", "run_shell", "spawn_agent"}}, SpawnAgentArgs{Tools: []string{"run_shell", "write_file"}})
+	if len(allowed) != 1 || !allowed["run_shell"] {
+		t.Fatalf("expected requested tools narrowed to configured ones, got %v", allowed)
+	}
+}
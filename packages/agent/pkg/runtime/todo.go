@@ -0,0 +1,203 @@
+package runtime
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// TodoItem is one entry of the todo_write tool arguments
+type TodoItem struct {
+	ID      string           `json:"id,omitempty"`
+	Content string           `json:"content"`
+	Status  types.TaskStatus `json:"status"`
+}
+
+// TodoWriteArgs are the arguments of the todo_write tool
+type TodoWriteArgs struct {
+	Todos []TodoItem `json:"todos"`
+}
+
+// HandleTodoWrite replaces the current goal's task list via a TasksUpdatedEvent
+func HandleTodoWrite(ctx context.Context, argsJSON string) (string, error) {
+	var args TodoWriteArgs
+	if err := json.Unmarshal([]byte(argsJSON), &args); err != nil {
+		return "", fmt.Errorf("invalid arguments: %w", err)
+	}
+
+	tc := toolCallFrom(ctx)
+	if tc == nil {
+		return "", errors.New("todo_write can only be called by a runtime")
+	}
+	state := tc.runtime.GetState()
+
+	goalID := ""
+	if goal := findGoal(state, ""); goal != nil {
+		goalID = goal.ID
+	}
+
+	tasks := make([]types.Task, 0, len(args.Todos))
+	seen := make(map[string]bool, len(args.Todos))
+	for i, item := range args.Todos {
+		if strings.TrimSpace(item.Content) == "" {
+			return "", fmt.Errorf("todo %d: content is required", i+1)
+		}
+		if !validTodoStatus(item.Status) {
+			return "", fmt.Errorf("todo %d: invalid status %q", i+1, item.Status)
+		}
+		id := item.ID
+		if id == "" {
+			id = types.GenerateTaskID()
+		}
+		if seen[id] {
+			return "", fmt.Errorf("todo %d: duplicate id %q", i+1, id)
+		}
+		seen[id] = true
+		tasks = append(tasks, types.Task{
+			ID:          id,
+			GoalID:      goalID,
+			Type:        "todo",
+			Description: item.Content,
+			Status:      item.Status,
+			Order:       i,
+		})
+	}
+
+	tc.events = append(tc.events, &types.TasksUpdatedEvent{
+		BaseEvent: types.NewBaseEvent("tasks_updated", "tool", goalID),
+		GoalID:    goalID,
+		Tasks:     tasks,
+	})
+	return renderTodoList(tasks), nil
+}
+
+// HandleTodoRead returns the current goal's task list
+func HandleTodoRead(ctx context.Context, argsJSON string) (string, error) {
+	tc := toolCallFrom(ctx)
+	if tc == nil {
+		return "", errors.New("todo_read can only be called by a runtime")
+	}
+	state := tc.runtime.GetState()
+
+	goalID := ""
+	if goal := findGoal(state, ""); goal != nil {
+		goalID = goal.ID
+	}
+	var tasks []types.Task
+	for _, t := range TaskList(state) {
+		if t.GoalID == goalID {
+			tasks = append(tasks, t)
+		}
+	}
+	return renderTodoList(tasks), nil
+}
+
+func validTodoStatus(status types.TaskStatus) bool {
+	switch status {
+	case types.TaskStatusPending, types.TaskStatusRunning, types.TaskStatusCompleted,
+		types.TaskStatusBlocked, types.TaskStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// renderTodoList formats tasks as a checklist the LLM can refer back to by ID
+func renderTodoList(tasks []types.Task) string {
+	if len(tasks) == 0 {
+		return "The task list is empty."
+	}
+	var b strings.Builder
+	for _, t := range tasks {
+		mark := " "
+		switch t.Status {
+		case types.TaskStatusRunning:
+			mark = "~"
+		case types.TaskStatusCompleted:
+			mark = "x"
+		case types.TaskStatusBlocked:
+			mark = "!"
+		case types.TaskStatusCancelled, types.TaskStatusFailed:
+			mark = "-"
+		}
+		fmt.Fprintf(&b, "[%s] %s (id: %s, %s)\n", mark, t.Description, t.ID, t.Status)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// TaskList returns the session's tasks ordered by goal, then by position in the goal's list
+func TaskList(state *types.State) []types.Task {
+	goalOrder := make(map[string]int, len(state.Goals))
+	for i, g := range state.Goals {
+		goalOrder[g.ID] = i
+	}
+
+	tasks := make([]types.Task, 0, len(state.Tasks))
+	for _, t := range state.Tasks {
+		tasks = append(tasks, *t.Clone())
+	}
+	sort.SliceStable(tasks, func(i, j int) bool {
+		gi, gj := goalOrder[tasks[i].GoalID], goalOrder[tasks[j].GoalID]
+		if gi != gj {
+			return gi < gj
+		}
+		if tasks[i].Order != tasks[j].Order {
+			return tasks[i].Order < tasks[j].Order
+		}
+		return tasks[i].ID < tasks[j].ID
+	})
+	return tasks
+}
+
+// taskKey is the key of a task in State.Tasks. Task IDs come from the LLM and
+// are only unique within a goal's list, so the key includes the goal.
+func taskKey(goalID, taskID string) string {
+	return goalID + "/" + taskID
+}
+
+// reduceTasksUpdated replaces a goal's tasks in an already cloned state,
+// keeping the timestamps of tasks that already existed
+func reduceTasksUpdated(state *types.State, e *types.TasksUpdatedEvent) {
+	if state.Tasks == nil {
+		state.Tasks = make(map[string]*types.Task)
+	}
+	previous := make(map[string]*types.Task)
+	for key, t := range state.Tasks {
+		if t.GoalID == e.GoalID {
+			previous[t.ID] = t
+			delete(state.Tasks, key)
+		}
+	}
+
+	now := e.EventTimestamp()
+	for _, t := range e.Tasks {
+		task := t
+		task.GoalID = e.GoalID
+		task.CreatedAt = now
+		task.StartedAt, task.EndedAt = nil, nil
+		if old, ok := previous[task.ID]; ok {
+			task.CreatedAt = old.CreatedAt
+			task.StartedAt = old.StartedAt
+			task.EndedAt = old.EndedAt
+		}
+
+		switch task.Status {
+		case types.TaskStatusRunning:
+			if task.StartedAt == nil {
+				task.StartedAt = &now
+			}
+			task.EndedAt = nil
+		case types.TaskStatusCompleted, types.TaskStatusFailed, types.TaskStatusCancelled:
+			if task.EndedAt == nil {
+				task.EndedAt = &now
+			}
+		default:
+			task.EndedAt = nil
+		}
+		state.Tasks[taskKey(e.GoalID, task.ID)] = &task
+	}
+}
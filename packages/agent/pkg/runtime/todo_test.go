@@ -0,0 +1,147 @@
+package runtime
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+func todoCmd(args map[string]any) *types.CallToolCommand {
+	return &types.CallToolCommand{
+		BaseCommand: types.NewBaseCommand("call_tool"),
+		ToolCallID:  "todo1",
+		ToolName:    "todo_write",
+		Arguments:   args,
+	}
+}
+
+// todoTools serves the todo handlers like the session executor does
+type todoTools struct{}
+
+func (todoTools) Execute(ctx context.Context, mode types.RuntimeMode, call *types.ToolCall) (*types.ToolResult, error) {
+	handler := HandleTodoRead
+	if call.Name == "todo_write" {
+		handler = HandleTodoWrite
+	}
+	out, err := handler(ctx, call.Arguments)
+	result := &types.ToolResult{ToolCallID: call.ID, ToolName: call.Name, Content: out, IsError: err != nil}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result, nil
+}
+
+func (todoTools) List() []types.Tool {
+	return []types.Tool{{Name: "todo_write", ReadOnly: true}, {Name: "todo_read", ReadOnly: true}}
+}
+
+func writeTodos(t *testing.T, rt *Runtime, todos ...map[string]any) []types.Event {
+	t.Helper()
+	ctx := context.Background()
+	events, err := rt.executeCallTool(ctx, todoCmd(map[string]any{"todos": todos}))
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	for _, e := range events {
+		if err := rt.applyEvent(ctx, e); err != nil {
+			t.Fatalf("apply error: %v", err)
+		}
+	}
+	return events
+}
+
+func TestTodoWriteMaintainsTaskList(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, todoTools{}, nil)
+	if err := rt.Ingest(context.Background(), &types.UserMessageEvent{
+		BaseEvent: types.NewBaseEvent("user_message", "user", ""),
+		Content:   "add caching",
+	}); err != nil {
+		t.Fatalf("ingest error: %v", err)
+	}
+	goalID := rt.GetState().Goals[0].ID
+
+	events := writeTodos(t, rt,
+		map[string]any{"content": "design cache", "status": "running"},
+		map[string]any{"content": "write tests", "status": "pending"},
+	)
+	if _, ok := events[0].(*types.TasksUpdatedEvent); !ok {
+		t.Fatalf("expected tasks_updated before the tool result, got %T", events[0])
+	}
+
+	tasks := TaskList(rt.GetState())
+	if len(tasks) != 2 || tasks[0].Description != "design cache" || tasks[1].Description != "write tests" {
+		t.Fatalf("expected ordered task list, got %+v", tasks)
+	}
+	if tasks[0].GoalID != goalID || tasks[0].StartedAt == nil || tasks[1].StartedAt != nil {
+		t.Fatalf("expected tasks for the goal with start time on running task, got %+v", tasks)
+	}
+
+	// Updating by ID keeps the task and records completion
+	writeTodos(t, rt,
+		map[string]any{"id": tasks[0].ID, "content": "design cache", "status": "completed"},
+		map[string]any{"id": tasks[1].ID, "content": "write tests", "status": "running"},
+	)
+	updated := TaskList(rt.GetState())
+	if len(updated) != 2 || updated[0].ID != tasks[0].ID || updated[0].EndedAt == nil || !updated[0].CreatedAt.Equal(tasks[0].CreatedAt) {
+		t.Fatalf("expected first task completed in place, got %+v", updated)
+	}
+
+	read, err := HandleTodoRead(context.WithValue(context.Background(), toolCallKey{}, &toolCallContext{runtime: rt}), "{}")
+	if err != nil {
+		t.Fatalf("todo_read error: %v", err)
+	}
+	if !strings.Contains(read, "[x] design cache") || !strings.Contains(read, "[~] write tests") {
+		t.Fatalf("unexpected todo_read output:\n%s", read)
+	}
+}
+
+func TestTodoWriteRejectsInvalidStatus(t *testing.T) {
+	rt := New(DefaultConfig, newMockStore(), mockLLM{}, todoTools{}, nil)
+	events, err := rt.executeCallTool(context.Background(), todoCmd(map[string]any{
+		"todos": []map[string]any{{"content": "x", "status": "done"}},
+	}))
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	res, ok := events[0].(*types.ToolResultEvent)
+	if len(events) != 1 || !ok || res.Success {
+		t.Fatalf("expected a single failed tool result, got %+v", events)
+	}
+
+	events, err = rt.executeCallTool(context.Background(), todoCmd(map[string]any{
+		"todos": []map[string]any{{"id": "1", "content": "x", "status": "pending"}, {"id": "1", "content": "y", "status": "pending"}},
+	}))
+	if err != nil {
+		t.Fatalf("execute error: %v", err)
+	}
+	if res, ok := events[0].(*types.ToolResultEvent); len(events) != 1 || !ok || res.Success || !strings.Contains(res.Error, "duplicate id") {
+		t.Fatalf("expected duplicate IDs to be rejected, got %+v", events)
+	}
+}
+
+func TestTasksOfDifferentGoalsKeepTheirIDs(t *testing.T) {
+	state := types.NewState()
+	for _, goalID := range []string{"goal_a", "goal_b"} {
+		reduceTasksUpdated(state, &types.TasksUpdatedEvent{
+			BaseEvent: types.NewBaseEvent("tasks_updated", "tool", goalID),
+			GoalID:    goalID,
+			Tasks:     []types.Task{{ID: "1", Description: "step of " + goalID, Status: types.TaskStatusPending}},
+		})
+	}
+
+	tasks := TaskList(state)
+	if len(tasks) != 2 || tasks[0].GoalID == tasks[1].GoalID {
+		t.Fatalf("expected both goals to keep task 1, got %+v", tasks)
+	}
+
+	// Replacing one goal's list leaves the other alone
+	reduceTasksUpdated(state, &types.TasksUpdatedEvent{
+		BaseEvent: types.NewBaseEvent("tasks_updated", "tool", "goal_a"),
+		GoalID:    "goal_a",
+	})
+	if tasks := TaskList(state); len(tasks) != 1 || tasks[0].Description != "step of goal_b" {
+		t.Fatalf("expected only goal_b's task left, got %+v", tasks)
+	}
+}
@@ -0,0 +1,61 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// ErrBudgetExceeded is returned by Run when the session exceeds its token or cost budget
+var ErrBudgetExceeded = errors.New("budget exceeded")
+
+// priceUsage returns the USD cost of a call, or 0 if the model has no known price
+func (r *Runtime) priceUsage(model string, usage types.Usage) float64 {
+	price, ok := r.config.Pricing.Lookup(model)
+	if !ok {
+		return 0
+	}
+	return price.Cost(usage.PromptTokens, usage.CompletionTokens)
+}
+
+// budgetViolation describes which budget the session has exceeded, or "" if none
+func (r *Runtime) budgetViolation() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.state.Usage == nil {
+		return ""
+	}
+	session := r.state.Usage.Session
+	if r.config.MaxSessionTokens > 0 && session.TotalTokens >= r.config.MaxSessionTokens {
+		return fmt.Sprintf("used %d tokens, limit is %d", session.TotalTokens, r.config.MaxSessionTokens)
+	}
+	if r.config.MaxSessionCost > 0 && session.CostUSD >= r.config.MaxSessionCost {
+		return fmt.Sprintf("spent $%.4f, limit is $%.4f", session.CostUSD, r.config.MaxSessionCost)
+	}
+	return ""
+}
+
+// checkBudget records a fatal ErrorEvent and returns ErrBudgetExceeded once a budget is exhausted
+func (r *Runtime) checkBudget(ctx context.Context) error {
+	violation := r.budgetViolation()
+	if violation == "" {
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %s", ErrBudgetExceeded, violation)
+	evt := &types.ErrorEvent{
+		BaseEvent: types.NewBaseEvent("error", "runtime", ""),
+		Error:     err.Error(),
+		Severity:  types.SeverityFatal,
+	}
+	if appendErr := r.store.AppendEvent(ctx, evt); appendErr != nil {
+		r.log.Error("failed to append budget error event", "error", appendErr)
+	}
+	if applyErr := r.applyEvent(ctx, evt); applyErr != nil {
+		return applyErr
+	}
+	return err
+}
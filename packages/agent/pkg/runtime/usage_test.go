@@ -0,0 +1,116 @@
+package runtime
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/types"
+)
+
+// usageLLM streams a reply followed by a usage chunk
+type usageLLM struct{ usage types.Usage }
+
+func (m usageLLM) Chat(ctx context.Context, req *llm.ChatRequest) (*llm.ChatResponse, error) {
+	return &llm.ChatResponse{Model: req.Model, Content: "reply", Usage: m.usage}, nil
+}
+
+func (m usageLLM) StreamChat(ctx context.Context, req *llm.ChatRequest) (<-chan llm.StreamChunk, error) {
+	ch := make(chan llm.StreamChunk, 2)
+	ch <- llm.StreamChunk{Content: "reply"}
+	usage := m.usage
+	ch <- llm.StreamChunk{Usage: &usage}
+	close(ch)
+	return ch, nil
+}
+
+func TestStreamingCallRecordsUsage(t *testing.T) {
+	cfg := DefaultConfig
+	cfg.Model = "gpt-4o-2024-08-06"
+	rt := New(cfg, newMockStore(), usageLLM{usage: types.Usage{PromptTokens: 1000, CompletionTokens: 100, TotalTokens: 1100}}, &mockTools{}, nil)
+
+	events, err := rt.executeCallLLM(context.Background(), &types.CallLLMCommand{Model: cfg.Model, GoalID: "goal_1"})
+	if err != nil {
+		t.Fatalf("execute call llm error: %v", err)
+	}
+	resp := events[0].(*types.LLMResponseEvent)
+	if resp.Usage.TotalTokens != 1100 || resp.GoalID != "goal_1" {
+		t.Fatalf("expected streamed usage on response event, got %+v", resp)
+	}
+	// gpt-4o: $2.50 / $10.00 per million tokens, matched by prefix
+	if want := 0.0025 + 0.001; resp.CostUSD < want-1e-9 || resp.CostUSD > want+1e-9 {
+		t.Fatalf("expected cost %f, got %f", want, resp.CostUSD)
+	}
+
+	if err := rt.applyEvent(context.Background(), resp); err != nil {
+		t.Fatalf("apply event error: %v", err)
+	}
+	if err := rt.applyEvent(context.Background(), resp); err != nil {
+		t.Fatalf("apply event error: %v", err)
+	}
+	usage := rt.GetState().Usage
+	if usage == nil || usage.Session.Calls != 2 || usage.Session.TotalTokens != 2200 {
+		t.Fatalf("unexpected session usage: %+v", usage)
+	}
+	if usage.ByGoal["goal_1"].PromptTokens != 2000 || usage.ByModel[cfg.Model].Calls != 2 {
+		t.Fatalf("unexpected usage breakdown: %+v %+v", usage.ByGoal, usage.ByModel)
+	}
+}
+
+func TestRunStopsWhenBudgetExceeded(t *testing.T) {
+	ms := newMockStore()
+	cfg := DefaultConfig
+	cfg.MaxSessionTokens = 500
+	rt := New(cfg, ms, usageLLM{usage: types.Usage{TotalTokens: 600}}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusPending}}
+	rt.state.Usage = types.NewUsageLedger()
+	rt.state.Usage.Record("g1", "m", types.Usage{TotalTokens: 600}, 0)
+
+	err := rt.Run(context.Background())
+	if !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected ErrBudgetExceeded, got %v", err)
+	}
+
+	var errEvt *types.ErrorEvent
+	for _, e := range ms.events {
+		if ee, ok := e.(*types.ErrorEvent); ok {
+			errEvt = ee
+		}
+		if e.EventType() == "llm_response" {
+			t.Fatalf("expected no LLM call once over budget")
+		}
+	}
+	if errEvt == nil || errEvt.Severity != types.SeverityFatal || !strings.Contains(errEvt.Error, "600 tokens") {
+		t.Fatalf("expected fatal budget error event, got %+v", errEvt)
+	}
+}
+
+func TestCompactionCountsTowardBudget(t *testing.T) {
+	ms := newMockStore()
+	cfg := DefaultConfig
+	cfg.MaxSessionTokens = 500
+	cfg.MaxContextTokens = 50
+	cfg.ReserveOutputTokens = 0
+	cfg.CompactionKeepRecent = 2
+	rt := New(cfg, ms, usageLLM{usage: types.Usage{TotalTokens: 600}}, &mockTools{}, nil)
+	rt.state.Goals = []types.Goal{{ID: "g1", Status: types.GoalStatusPending}}
+	for i := 0; i < 6; i++ {
+		rt.state.Context.Messages = append(rt.state.Context.Messages, types.Message{Role: "user", Content: strings.Repeat("z", 100)})
+	}
+	refreshTokenCounts(rt.state.Context)
+
+	if err := rt.Run(context.Background()); !errors.Is(err, ErrBudgetExceeded) {
+		t.Fatalf("expected the summary to exhaust the budget, got %v", err)
+	}
+	usage := rt.GetState().Usage
+	if usage == nil || usage.Session.TotalTokens != 600 || usage.ByGoal["g1"].TotalTokens != 600 {
+		t.Fatalf("expected the compaction call in the ledger, got %+v", usage)
+	}
+	for _, e := range ms.events {
+		if e.EventType() == "llm_response" {
+			t.Fatalf("expected no decision once the summary used up the budget")
+		}
+	}
+}
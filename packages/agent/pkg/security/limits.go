@@ -0,0 +1,31 @@
+package security
+
+import "time"
+
+// ResourceLimits defines resource constraints for command execution.
+// A zero value disables the corresponding limit.
+type ResourceLimits struct {
+	MaxExecutionTime int64 // seconds of wall-clock time per command
+	MaxCPUTime       int64 // seconds of CPU time per process
+	MaxMemory        int64 // bytes of address space per process
+	MaxFileSize      int64 // bytes per written file
+	MaxOpenFiles     int64 // open file descriptors per process
+	MaxOutput        int64 // bytes of output returned per command
+}
+
+// DefaultResourceLimits returns safe default limits
+func DefaultResourceLimits() ResourceLimits {
+	return ResourceLimits{
+		MaxExecutionTime: 300,                // 5 minutes
+		MaxCPUTime:       300,                // 5 minutes
+		MaxMemory:        1024 * 1024 * 1024, // 1 GB
+		MaxFileSize:      100 * 1024 * 1024,  // 100 MB
+		MaxOpenFiles:     1024,
+		MaxOutput:        100 * 1000, // About 25k tokens
+	}
+}
+
+// Timeout returns MaxExecutionTime as a duration, 0 if unlimited
+func (l ResourceLimits) Timeout() time.Duration {
+	return time.Duration(l.MaxExecutionTime) * time.Second
+}
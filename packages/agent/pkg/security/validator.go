@@ -165,19 +165,3 @@ func containsCommandInjection(cmd string) bool {
 	// If multiple dangerous patterns, likely injection
 	return dangerousCount >= 3
 }
-
-// ResourceLimits defines resource constraints for command execution
-type ResourceLimits struct {
-	MaxExecutionTime int64 // seconds
-	MaxMemory        int64 // bytes
-	MaxFileSize      int64 // bytes
-}
-
-// DefaultResourceLimits returns safe default limits
-func DefaultResourceLimits() ResourceLimits {
-	return ResourceLimits{
-		MaxExecutionTime: 300,           // 5 minutes
-		MaxMemory:        1024 * 1024 * 1024, // 1 GB
-		MaxFileSize:      100 * 1024 * 1024,  // 100 MB
-	}
-}
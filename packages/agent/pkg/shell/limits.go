@@ -0,0 +1,119 @@
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+)
+
+// ulimitScript returns the shell lines that apply the rlimits of l to a shell
+// and everything it starts. Limits above the current hard limit fail silently:
+// the stricter limit already applies.
+func ulimitScript(l security.ResourceLimits) string {
+	var b strings.Builder
+	set := func(flag string, value int64) {
+		if value > 0 {
+			fmt.Fprintf(&b, "ulimit %s %d 2>/dev/null\n", flag, value)
+		}
+	}
+	set("-t", l.MaxCPUTime)
+	set("-v", (l.MaxMemory+1023)/1024)   // KiB
+	set("-f", (l.MaxFileSize+1023)/1024) // 1024-byte blocks
+	set("-n", l.MaxOpenFiles)
+	return b.String()
+}
+
+// commandTimeout is the wall-clock limit of a command: the one asked for,
+// capped by the limits
+func commandTimeout(requested time.Duration, l security.ResourceLimits) time.Duration {
+	limit := l.Timeout()
+	switch {
+	case requested <= 0 && limit > 0:
+		return limit
+	case requested <= 0:
+		return DefaultTimeout
+	case limit > 0 && requested > limit:
+		return limit
+	}
+	return requested
+}
+
+// outputBuffer keeps the start and the end of an output up to a limit in
+// total, dropping the middle. A limit of 0 keeps everything.
+type outputBuffer struct {
+	limit   int
+	head    []byte
+	tail    []byte
+	omitted int
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	n := len(p)
+	if b.limit <= 0 {
+		b.head = append(b.head, p...)
+		return n, nil
+	}
+	if room := b.limit/2 - len(b.head); room > 0 {
+		take := min(room, len(p))
+		b.head = append(b.head, p[:take]...)
+		p = p[take:]
+	}
+	b.tail = append(b.tail, p...)
+	if over := len(b.tail) - (b.limit - b.limit/2); over > 0 {
+		b.tail = append(b.tail[:0], b.tail[over:]...)
+		b.omitted += over
+	}
+	return n, nil
+}
+
+// String returns the kept output with a marker where bytes were dropped
+func (b *outputBuffer) String() string {
+	if b.omitted == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return fmt.Sprintf("%s\n[... %d bytes of output truncated ...]\n%s", b.head, b.omitted, b.tail)
+}
+
+// Exec runs command once with bash in dir under the given limits. The command
+// runs in its own process group, which is killed when it times out, when ctx
+// is cancelled and when the command exits, so nothing it started outlives it.
+func Exec(ctx context.Context, command, dir string, limits security.ResourceLimits) (*Result, error) {
+	timeout := commandTimeout(0, limits)
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	out := &outputBuffer{limit: int(limits.MaxOutput)}
+	cmd := exec.CommandContext(runCtx, "bash", "-c", ulimitScript(limits)+command)
+	cmd.Dir = dir
+	cmd.Stdout = out
+	cmd.Stderr = out
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
+	cmd.WaitDelay = killTimeout // Processes that escaped the group may hold the output open
+
+	err := cmd.Run()
+	if cmd.Process != nil {
+		killProcessGroup(cmd) // Leftover background processes
+	}
+
+	res := &Result{Output: out.String(), Dir: dir}
+	if cmd.ProcessState != nil {
+		res.ExitCode = cmd.ProcessState.ExitCode()
+	}
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return res, ctx.Err()
+	case runCtx.Err() != nil:
+		res.TimedOut = true
+		res.Timeout = timeout
+	case err != nil && !errors.As(err, &exitErr) && !errors.Is(err, exec.ErrWaitDelay):
+		return res, err
+	}
+	return res, nil
+}
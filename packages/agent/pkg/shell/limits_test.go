@@ -0,0 +1,107 @@
+//go:build unix
+
+package shell
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+)
+
+func TestExecAppliesLimits(t *testing.T) {
+	limits := security.ResourceLimits{MaxOpenFiles: 64, MaxFileSize: 4096, MaxOutput: 100}
+	res, err := Exec(context.Background(), "ulimit -n; ulimit -f", "", limits)
+	if err != nil || res.Output != "64\n4\n" || res.ExitCode != 0 {
+		t.Fatalf("expected the rlimits in the command, got %+v, %v", res, err)
+	}
+
+	res, err = Exec(context.Background(), "seq 1 1000; exit 2", "", limits)
+	if err != nil || res.ExitCode != 2 {
+		t.Fatalf("unexpected result %+v, %v", res, err)
+	}
+	if !strings.HasPrefix(res.Output, "1\n2\n") || !strings.HasSuffix(res.Output, "999\n1000\n") || !strings.Contains(res.Output, "bytes of output truncated") {
+		t.Fatalf("expected the start and end of the output around a marker, got %q", res.Output)
+	}
+}
+
+func TestExecTimeoutKillsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Now()
+	res, err := Exec(context.Background(), "sleep 60 & echo $! > child.pid; wait", dir, security.ResourceLimits{MaxExecutionTime: 1})
+	if err != nil || !res.TimedOut || res.Timeout != time.Second || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected the command to time out after a second, got %+v, %v", res, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "child.pid"))
+	if err != nil {
+		t.Fatalf("read pid: %v", err)
+	}
+	childPID, _ := strconv.Atoi(strings.TrimSpace(string(data)))
+	waitFor(t, "the child to exit", func() bool {
+		return errors.Is(syscall.Kill(childPID, 0), syscall.ESRCH)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	if _, err := Exec(ctx, "sleep 60", "", security.ResourceLimits{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation, got %v", err)
+	}
+}
+
+func TestRunAppliesLimits(t *testing.T) {
+	m := NewManager(t.TempDir())
+	defer m.Close()
+	ctx := context.Background()
+
+	m.SetLimits(security.ResourceLimits{MaxOpenFiles: 64, MaxExecutionTime: 1, MaxOutput: 100})
+	m.Run(ctx, "export KEPT=1", 0)
+	res, err := m.Run(ctx, "ulimit -n; seq 1 1000", 0)
+	if err != nil || !strings.HasPrefix(res.Output, "64\n") || !strings.Contains(res.Output, "truncated") || !strings.HasSuffix(res.Output, "1000\n") {
+		t.Fatalf("expected limited output from a limited shell, got %+v, %v", res, err)
+	}
+
+	// Asking for more time than the limit allows gets the limit
+	res, err = m.Run(ctx, "sleep 60", time.Minute)
+	if err != nil || !res.TimedOut || res.Timeout != time.Second || res.Reset {
+		t.Fatalf("expected the execution time limit to stop the command, got %+v, %v", res, err)
+	}
+
+	// New limits apply to the next command in the same shell
+	m.SetLimits(security.ResourceLimits{MaxOpenFiles: 32})
+	if res, _ := m.Run(ctx, `ulimit -n; echo "[$KEPT]"`, 0); res.Output != "32\n[1]\n" {
+		t.Fatalf("expected the new limits in the same shell, got %q", res.Output)
+	}
+}
+
+func TestRunLimitsCPUTimePerCommand(t *testing.T) {
+	m := NewManager(t.TempDir())
+	defer m.Close()
+	ctx := context.Background()
+	m.SetLimits(security.ResourceLimits{MaxCPUTime: 1})
+
+	// Each command gets the whole budget, however much the earlier ones used
+	burn := `end=$((${EPOCHREALTIME/./} + 600000)); while [ ${EPOCHREALTIME/./} -lt $end ]; do :; done; echo done`
+	for i := range 3 {
+		if res, err := m.Run(ctx, burn, time.Minute); err != nil || res.Output != "done\n" || res.ExitCode != 0 {
+			t.Fatalf("command %d: expected it to finish within its CPU time, got %+v, %v", i+1, res, err)
+		}
+	}
+
+	// A command over the budget is killed, not the shell
+	m.Run(ctx, "export KEPT=1", 0)
+	res, err := m.Run(ctx, "while :; do :; done", time.Minute)
+	if err != nil || res.ExitCode == 0 || res.TimedOut || res.Reset {
+		t.Fatalf("expected the command to be killed for CPU time, got %+v, %v", res, err)
+	}
+	if res, _ := m.Run(ctx, "echo $KEPT", 0); res.Output != "1\n" {
+		t.Fatalf("expected the shell to survive, got %q", res.Output)
+	}
+}
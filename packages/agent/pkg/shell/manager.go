@@ -0,0 +1,296 @@
+// Package shell runs the shell commands of a session: a persistent shell that
+// keeps its working directory and environment between commands, and
+// background processes the agent can poll and kill while it keeps working.
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+)
+
+// ErrUnknownShell is returned for IDs the manager never started
+var ErrUnknownShell = errors.New("unknown shell id")
+
+// ErrClosed is returned by Start once the manager was closed
+var ErrClosed = errors.New("shell manager is closed")
+
+// MaxBuffer is the output kept per process; older output is dropped once a
+// process writes more than this between two reads
+const MaxBuffer = 1 << 20
+
+// killTimeout is how long Kill waits for a process to exit after SIGKILL
+const killTimeout = 5 * time.Second
+
+// Manager owns the persistent shell and the background processes of one
+// session. A nil Manager has no processes and cannot start any.
+type Manager struct {
+	initialDir string
+	shellLock  chan struct{} // Held while a command runs in the persistent shell
+
+	mu     sync.Mutex
+	limits security.ResourceLimits
+	dir    string              // Where a new persistent shell starts
+	shell  *persistentShell    // Started on the first Run
+	procs  map[string]*Process // Dropped once their final output is read
+	next   int
+	closed bool
+}
+
+// NewManager creates a manager whose shells start in dir ("" for the current
+// directory) under security.DefaultResourceLimits
+func NewManager(dir string) *Manager {
+	return &Manager{
+		initialDir: dir,
+		shellLock:  make(chan struct{}, 1),
+		limits:     security.DefaultResourceLimits(),
+		dir:        dir,
+		procs:      map[string]*Process{},
+	}
+}
+
+// Limits returns the limits applied to new commands
+func (m *Manager) Limits() security.ResourceLimits {
+	if m == nil {
+		return security.ResourceLimits{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.limits
+}
+
+// SetLimits changes the limits of later commands. The persistent shell
+// applies them per command, so it keeps its directory and environment.
+func (m *Manager) SetLimits(limits security.ResourceLimits) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.limits = limits
+	m.mu.Unlock()
+}
+
+// Process is a background shell command
+type Process struct {
+	ID        string
+	Command   string
+	StartedAt time.Time
+
+	cmd  *exec.Cmd
+	done chan struct{}
+
+	mu       sync.Mutex
+	buf      []byte // Output not read yet
+	dropped  int    // Unread bytes dropped since the last read
+	exitCode int
+	exitErr  error
+}
+
+// Write collects stdout and stderr, interleaved as the process writes them
+func (p *Process) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.buf = append(p.buf, b...)
+	if over := len(p.buf) - MaxBuffer; over > 0 {
+		p.buf = append(p.buf[:0], p.buf[over:]...)
+		p.dropped += over
+	}
+	return len(b), nil
+}
+
+// Running reports whether the process has not exited yet
+func (p *Process) Running() bool {
+	select {
+	case <-p.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// Output is what a process wrote since the previous read
+type Output struct {
+	ID       string
+	Output   string
+	Dropped  int // Bytes lost because they were not read in time
+	Running  bool
+	ExitCode int   // Valid once the process has exited
+	Error    error // Why the process could not run or was killed, if it did not exit on its own
+}
+
+// Start runs command with bash in the background and returns its process. It
+// starts in the persistent shell's working directory but does not see its
+// environment. The rlimits apply, the wall-clock and output limits do not.
+func (m *Manager) Start(command string) (*Process, error) {
+	if m == nil {
+		return nil, errors.New("background shells are not available")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return nil, ErrClosed
+	}
+
+	m.next++
+	p := &Process{
+		ID:        fmt.Sprintf("shell_%d", m.next),
+		Command:   command,
+		StartedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	p.cmd = exec.Command("bash", "-c", ulimitScript(m.limits)+command)
+	p.cmd.Dir = m.dir
+	if m.shell != nil {
+		p.cmd.Dir = m.shell.dir
+	}
+	p.cmd.Stdout = p
+	p.cmd.Stderr = p
+	setProcessGroup(p.cmd)
+	if err := p.cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start background shell: %w", err)
+	}
+	m.procs[p.ID] = p
+
+	go func() {
+		err := p.cmd.Wait()
+		p.mu.Lock()
+		p.exitCode = p.cmd.ProcessState.ExitCode()
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			p.exitErr = err
+		}
+		p.mu.Unlock()
+		close(p.done)
+	}()
+	return p, nil
+}
+
+func (m *Manager) get(id string) (*Process, error) {
+	if m == nil {
+		return nil, ErrUnknownShell
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	p, ok := m.procs[id]
+	if !ok {
+		if n, err := strconv.Atoi(strings.TrimPrefix(id, "shell_")); err == nil && n > 0 && n <= m.next && id == fmt.Sprintf("shell_%d", n) {
+			return nil, fmt.Errorf("%w %q: it exited and its final output was already read", ErrUnknownShell, id)
+		}
+		ids := make([]string, 0, len(m.procs))
+		for id := range m.procs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		if len(ids) == 0 {
+			return nil, fmt.Errorf("%w %q: no background shells were started", ErrUnknownShell, id)
+		}
+		return nil, fmt.Errorf("%w %q: started shells are %s", ErrUnknownShell, id, strings.Join(ids, ", "))
+	}
+	return p, nil
+}
+
+// Read returns the output of a process since the previous read. With a filter,
+// only the lines matching it are returned; the others are still consumed.
+// Once a process has exited, this read is its last: the manager forgets it.
+func (m *Manager) Read(id string, filter *regexp.Regexp) (*Output, error) {
+	p, err := m.get(id)
+	if err != nil {
+		return nil, err
+	}
+	running := p.Running() // Before taking the buffer, so no output after the exit is missed
+
+	p.mu.Lock()
+	out := &Output{
+		ID:       p.ID,
+		Output:   string(p.buf),
+		Dropped:  p.dropped,
+		Running:  running,
+		ExitCode: p.exitCode,
+		Error:    p.exitErr,
+	}
+	p.buf = nil
+	p.dropped = 0
+	p.mu.Unlock()
+
+	if !running {
+		// Nothing more will be written, so free the buffer
+		m.mu.Lock()
+		delete(m.procs, p.ID)
+		m.mu.Unlock()
+	}
+
+	if filter != nil && out.Output != "" {
+		var kept []string
+		for _, line := range strings.SplitAfter(out.Output, "\n") {
+			if line != "" && filter.MatchString(strings.TrimRight(line, "\n")) {
+				kept = append(kept, line)
+			}
+		}
+		out.Output = strings.Join(kept, "")
+	}
+	return out, nil
+}
+
+// Kill stops a process and everything it started
+func (m *Manager) Kill(id string) error {
+	p, err := m.get(id)
+	if err != nil {
+		return err
+	}
+	return p.kill()
+}
+
+func (p *Process) kill() error {
+	if !p.Running() {
+		return nil
+	}
+	if err := killProcessGroup(p.cmd); err != nil && p.Running() {
+		return fmt.Errorf("kill %s: %w", p.ID, err)
+	}
+	select {
+	case <-p.done:
+		p.mu.Lock()
+		if p.exitErr == nil {
+			p.exitErr = errors.New("killed")
+		}
+		p.mu.Unlock()
+		return nil
+	case <-time.After(killTimeout):
+		return fmt.Errorf("kill %s: process did not exit", p.ID)
+	}
+}
+
+// Close kills the persistent shell and all processes; the manager cannot
+// start new ones afterwards. It is safe to call more than once.
+func (m *Manager) Close() error {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	m.closed = true
+	if m.shell != nil {
+		m.shell.close()
+		m.shell = nil
+	}
+	procs := make([]*Process, 0, len(m.procs))
+	for _, p := range m.procs {
+		procs = append(procs, p)
+	}
+	m.mu.Unlock()
+
+	var errs []error
+	for _, p := range procs {
+		if err := p.kill(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,152 @@
+//go:build unix
+
+package shell
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// waitFor polls cond until it holds or the test times out
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReadReturnsOutputIncrementally(t *testing.T) {
+	m := NewManager(t.TempDir())
+	defer m.Close()
+
+	p, err := m.Start("echo one; echo two >&2; sleep 0.2; echo three; exit 3")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	var first *Output
+	waitFor(t, "the first lines", func() bool {
+		first, err = m.Read(p.ID, nil)
+		return err == nil && strings.Contains(first.Output, "two")
+	})
+	if !first.Running || first.Output != "one\ntwo\n" {
+		t.Fatalf("unexpected first read %+v", first)
+	}
+
+	waitFor(t, "the exit", func() bool { return !p.Running() })
+	last, err := m.Read(p.ID, nil)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if last.Running || last.ExitCode != 3 || last.Output != "three\n" {
+		t.Fatalf("expected only the new output and the exit code, got %+v", last)
+	}
+}
+
+func TestReadFiltersLines(t *testing.T) {
+	m := NewManager("")
+	defer m.Close()
+
+	p, err := m.Start("printf 'ok 1\\nFAIL 2\\nok 3\\nFAIL 4\\n'; sleep 60")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	var out *Output
+	waitFor(t, "the output", func() bool {
+		out, err = m.Read(p.ID, regexp.MustCompile(`^FAIL`))
+		return err == nil && out.Output != ""
+	})
+	if out.Output != "FAIL 2\nFAIL 4\n" {
+		t.Fatalf("unexpected filtered output %q", out.Output)
+	}
+	if again, _ := m.Read(p.ID, nil); again.Output != "" {
+		t.Fatalf("expected filtered-out lines to be consumed, got %q", again.Output)
+	}
+}
+
+func TestReadForgetsExitedProcesses(t *testing.T) {
+	m := NewManager("")
+	defer m.Close()
+
+	p, err := m.Start("echo done")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitFor(t, "the exit", func() bool { return !p.Running() })
+	if out, err := m.Read(p.ID, nil); err != nil || out.Running || out.Output != "done\n" {
+		t.Fatalf("expected the final output, got %+v, %v", out, err)
+	}
+
+	m.mu.Lock()
+	left := len(m.procs)
+	m.mu.Unlock()
+	if left != 0 {
+		t.Fatalf("expected the exited process to be dropped, %d left", left)
+	}
+	if _, err := m.Read(p.ID, nil); !errors.Is(err, ErrUnknownShell) || !strings.Contains(err.Error(), "already read") {
+		t.Fatalf("expected the id to be reported as read, got %v", err)
+	}
+}
+
+func TestKillStopsProcessGroup(t *testing.T) {
+	dir := t.TempDir()
+	pidFile := filepath.Join(dir, "child.pid")
+	m := NewManager(dir)
+
+	// The child keeps running unless the whole group is killed
+	p, err := m.Start("sleep 60 & echo $! > child.pid; wait")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	var childPID int
+	waitFor(t, "the child pid", func() bool {
+		data, err := os.ReadFile(pidFile)
+		if err != nil {
+			return false
+		}
+		childPID, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		return err == nil
+	})
+
+	if err := m.Kill(p.ID); err != nil {
+		t.Fatalf("kill: %v", err)
+	}
+	out, _ := m.Read(p.ID, nil)
+	if out.Running || out.Error == nil {
+		t.Fatalf("expected the shell to be reported as killed, got %+v", out)
+	}
+	waitFor(t, "the child to exit", func() bool {
+		return errors.Is(syscall.Kill(childPID, 0), syscall.ESRCH)
+	})
+
+	if err := m.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := m.Start("true"); !errors.Is(err, ErrClosed) {
+		t.Fatalf("expected a closed manager to refuse new shells, got %v", err)
+	}
+	if _, err := m.Read("shell_9", nil); !errors.Is(err, ErrUnknownShell) {
+		t.Fatalf("expected unknown shell error, got %v", err)
+	}
+}
+
+func TestOutputBufferDropsOldestBytes(t *testing.T) {
+	p := &Process{done: make(chan struct{})}
+	p.Write([]byte(strings.Repeat("a", MaxBuffer)))
+	p.Write([]byte("bc"))
+	if len(p.buf) != MaxBuffer || p.dropped != 2 || !strings.HasSuffix(string(p.buf), "abc") {
+		t.Fatalf("expected the oldest 2 bytes dropped, got len %d dropped %d", len(p.buf), p.dropped)
+	}
+}
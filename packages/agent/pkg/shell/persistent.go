@@ -0,0 +1,342 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gm-agent-org/gm-agent/pkg/security"
+)
+
+// DefaultTimeout bounds a command when neither the caller nor the limits set one
+const DefaultTimeout = 2 * time.Minute
+
+// interruptGrace is how long an interrupted command may take to stop before
+// the shell is restarted
+const interruptGrace = 3 * time.Second
+
+// shellInit enables job control, so each command runs in its own process
+// group that can be killed without the shell, and defines the wrapper that
+// runs commands.
+//
+// __gm_run LIMITS COMMAND runs COMMAND in a subshell that applies LIMITS, so
+// rlimits such as the CPU time start afresh for every command instead of
+// accumulating in the long-lived shell. When the command ends, the subshell
+// writes its directory, variables, functions, aliases and options to the state
+// file through a pipe, which RLIMIT_FSIZE does not cover, and the shell loads
+// them after __gm_run returns. If the command calls exit, the state file makes
+// the shell exit too. SIGINT makes __gm_run return, with the state unchanged.
+const shellInit = `set -m
+__gm_run() {
+	trap 'trap - INT; return 130' INT
+	{
+		(
+		exec 2>&4 4>&-
+		eval "$1"
+		__gm_vars=$(compgen -v) __gm_funcs=$(compgen -A function)
+		trap __gm_save EXIT
+		eval "$2" </dev/null 3>&-
+		__gm_done=$?
+		exit $__gm_done
+	) 3>&1 >&5 5>&- | cat >"$__gm_state"
+	} 4>&2 5>&1 2>/dev/null
+	local __gm_status=${PIPESTATUS[0]}
+	trap - INT
+	return $__gm_status
+}
+__gm_save() {
+	local __gm_status=$? __gm_name
+	if [ -z "${__gm_done+set}" ]; then
+		echo "exit $__gm_status" >"$__gm_state"
+		return
+	fi
+	{
+	printf 'cd -- %q\n' "$PWD"
+	while IFS= read -r __gm_name; do
+		declare -p "$__gm_name" >/dev/null 2>&1 || echo "unset -v $__gm_name"
+	done < <(printf '%s\n' "$__gm_vars")
+	while IFS= read -r __gm_name; do
+		case $__gm_name in
+		__gm_* | BASH* | COMP_* | DIRSTACK | EPOCH* | EUID | FUNCNAME | GROUPS | HISTCMD | LINENO | PIPESTATUS | PPID | PWD | RANDOM | SECONDS | SHELLOPTS | SHLVL | SRANDOM | UID | _) ;;
+		*) declare -p "$__gm_name" ;;
+		esac
+	done < <(compgen -v)
+	while IFS= read -r __gm_name; do
+		declare -F "$__gm_name" >/dev/null || echo "unset -f $__gm_name"
+	done < <(printf '%s\n' "$__gm_funcs")
+	while IFS= read -r __gm_name; do
+		case $__gm_name in
+		__gm_*) ;;
+		*) declare -f "$__gm_name" ;;
+		esac
+	done < <(compgen -A function)
+	echo "unalias -a"
+	alias -p
+	set +o
+	shopt -p
+	umask -p
+	} >&3
+}
+`
+
+// Result is the outcome of a command in the persistent shell
+type Result struct {
+	Output   string
+	ExitCode int
+	TimedOut bool          // The command ran too long and was stopped
+	Timeout  time.Duration // The wall-clock limit that stopped it
+	Reset    bool          // The shell exited or had to be restarted; directory and environment are lost
+	Dir      string        // Working directory after the command
+}
+
+// persistentShell is a bash process that runs commands one after the other,
+// keeping its working directory and environment between them. Command
+// boundaries are found with a random sentinel printed after each command.
+type persistentShell struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	chunks chan []byte // Output of the shell; closed when it exits
+	dir    string      // Working directory after the last command; guarded by Manager.mu
+}
+
+func startPersistentShell(dir string) (*persistentShell, error) {
+	// Where each command leaves its state for the shell; removed when it exits
+	state, err := os.CreateTemp("", "gm-shell-*")
+	if err != nil {
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+	state.Close()
+
+	cmd := exec.Command("bash", "--noprofile", "--norc")
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		os.Remove(state.Name())
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		os.Remove(state.Name())
+		return nil, err
+	}
+	cmd.Stderr = cmd.Stdout
+	if err := cmd.Start(); err != nil {
+		os.Remove(state.Name())
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+
+	s := &persistentShell{cmd: cmd, stdin: stdin, chunks: make(chan []byte, 64), dir: dir}
+	go func() {
+		defer close(s.chunks)
+		buf := make([]byte, 32<<10)
+		for {
+			n, err := stdout.Read(buf)
+			if n > 0 {
+				s.chunks <- append([]byte(nil), buf[:n]...)
+			}
+			if err != nil {
+				cmd.Wait()
+				os.Remove(state.Name())
+				return
+			}
+		}
+	}()
+	if _, err := io.WriteString(stdin, "__gm_state="+quote(state.Name())+"\n"+shellInit); err != nil {
+		s.close()
+		return nil, fmt.Errorf("start shell: %w", err)
+	}
+	return s, nil
+}
+
+// run executes command under the rlimits of limits and waits for its
+// sentinel, keeping at most maxOutput bytes of output. On timeout or
+// cancellation the command is interrupted; if it does not stop, the shell is
+// killed and the result is marked Reset.
+func (s *persistentShell) run(ctx context.Context, command string, limits security.ResourceLimits, timeout time.Duration, maxOutput int) (*Result, error) {
+	nonce := make([]byte, 8)
+	rand.Read(nonce)
+	sentinel := []byte("__GM_DONE_" + hex.EncodeToString(nonce) + "__ ")
+
+	script := fmt.Sprintf("__gm_run %s %s\n__gm_status=$?\n. \"$__gm_state\" >/dev/null 2>&1; set -m\nprintf '%%s%%d %%s\\n' '%s' \"$__gm_status\" \"$PWD\"\n",
+		quote(ulimitScript(limits)), quote(command), sentinel)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		s.close()
+		return &Result{Reset: true, ExitCode: -1}, fmt.Errorf("shell is not running: %w", err)
+	}
+
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	var grace <-chan time.Time
+	done := ctx.Done()
+
+	result := &Result{Dir: s.dir}
+	out := &outputBuffer{limit: maxOutput}
+	var pending []byte // Output that may be the start of the sentinel
+	for {
+		select {
+		case chunk, ok := <-s.chunks:
+			if !ok {
+				// The command ended the shell, e.g. with exit
+				out.Write(pending)
+				result.Output = out.String()
+				result.ExitCode = s.cmd.ProcessState.ExitCode()
+				result.Reset = true
+				return result, nil
+			}
+			pending = append(pending, chunk...)
+			if i := bytes.Index(pending, sentinel); i >= 0 {
+				rest := pending[i+len(sentinel):]
+				end := bytes.IndexByte(rest, '\n')
+				if end < 0 {
+					continue // The rest of the sentinel line is still coming
+				}
+				code, dir, _ := strings.Cut(string(rest[:end]), " ")
+				result.ExitCode, _ = strconv.Atoi(code)
+				result.Dir = dir
+				out.Write(pending[:i])
+				result.Output = out.String()
+				return result, nil
+			}
+			if keep := len(sentinel) - 1; len(pending) > keep {
+				out.Write(pending[:len(pending)-keep])
+				pending = append(pending[:0], pending[len(pending)-keep:]...)
+			}
+
+		case <-deadline.C:
+			result.TimedOut = true
+			result.Timeout = timeout
+			s.interrupt()
+			grace = time.After(interruptGrace)
+
+		case <-done:
+			done = nil
+			s.interrupt()
+			grace = time.After(interruptGrace)
+
+		case <-grace:
+			s.close()
+			out.Write(pending)
+			result.Output = out.String()
+			result.ExitCode = -1
+			result.Reset = true
+			if ctx.Err() != nil {
+				return result, ctx.Err()
+			}
+			return result, nil
+		}
+	}
+}
+
+// interrupt stops the running command: its process groups are killed and the
+// shell is signalled so that the rest of the command is skipped
+func (s *persistentShell) interrupt() {
+	interruptShell(s.cmd)
+	killChildGroups(s.cmd)
+}
+
+// close kills the shell and everything it started
+func (s *persistentShell) close() {
+	killChildGroups(s.cmd)
+	killProcessGroup(s.cmd)
+	s.stdin.Close()
+	for range s.chunks {
+		// Drain until the reader sees the shell exit
+	}
+}
+
+// quote returns s as a single-quoted shell word
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// Run executes command in the session's persistent shell, starting it if
+// needed. The working directory and environment carry over between commands.
+// The timeout is capped by the manager's limits; 0 uses the limit.
+func (m *Manager) Run(ctx context.Context, command string, timeout time.Duration) (*Result, error) {
+	if m == nil {
+		return nil, errors.New("persistent shell is not available")
+	}
+	// One command at a time; waiting callers give up with their context
+	select {
+	case m.shellLock <- struct{}{}:
+		defer func() { <-m.shellLock }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	m.mu.Lock()
+	if m.closed {
+		m.mu.Unlock()
+		return nil, ErrClosed
+	}
+	sh := m.shell
+	limits := m.limits
+	m.mu.Unlock()
+
+	if sh == nil {
+		var err error
+		if sh, err = startPersistentShell(m.workDir()); err != nil {
+			return nil, err
+		}
+		m.mu.Lock()
+		if m.closed {
+			// Closed while the shell was starting; Close did not see it
+			m.mu.Unlock()
+			sh.close()
+			return nil, ErrClosed
+		}
+		m.shell = sh
+		m.mu.Unlock()
+	}
+
+	res, err := sh.run(ctx, command, limits, commandTimeout(timeout, limits), int(limits.MaxOutput))
+	if res != nil {
+		m.mu.Lock()
+		if m.shell == sh {
+			sh.dir = res.Dir
+			if res.Reset {
+				m.shell = nil
+				m.dir = res.Dir // Where the next shell starts
+			}
+		}
+		m.mu.Unlock()
+	}
+	return res, err
+}
+
+// Reset kills the persistent shell; the next command starts a fresh one in
+// the manager's original directory
+func (m *Manager) Reset() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	sh := m.shell
+	m.shell = nil
+	m.dir = m.initialDir
+	m.mu.Unlock()
+	if sh != nil {
+		sh.close()
+	}
+}
+
+// workDir is the persistent shell's working directory, where background
+// processes start too
+func (m *Manager) workDir() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.shell != nil {
+		return m.shell.dir
+	}
+	return m.dir
+}
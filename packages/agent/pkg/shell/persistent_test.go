@@ -0,0 +1,130 @@
+//go:build unix
+
+package shell
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunKeepsDirectoryAndEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "sub"), 0o755)
+	m := NewManager(dir)
+	defer m.Close()
+	ctx := context.Background()
+
+	res, err := m.Run(ctx, "cd sub && export GREETING=hello\necho set >&2", time.Minute)
+	if err != nil || res.ExitCode != 0 || res.Output != "set\n" {
+		t.Fatalf("unexpected first result %+v, %v", res, err)
+	}
+	res, err = m.Run(ctx, "echo $GREETING; pwd; false", time.Minute)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	sub := filepath.Join(dir, "sub")
+	if res.Output != "hello\n"+sub+"\n" || res.ExitCode != 1 || res.Dir != sub {
+		t.Fatalf("expected the state to carry over, got %+v", res)
+	}
+
+	// Commands run in subshells, so everything else they change is carried over too
+	m.Run(ctx, `PLAIN=value; greet() { echo "hi $1"; }; umask 027; set -o noglob`, time.Minute)
+	if res, _ := m.Run(ctx, "greet $PLAIN; umask; echo *; unset PLAIN; unset -f greet", time.Minute); res.Output != "hi value\n0027\n*\n" {
+		t.Fatalf("expected variables, functions and options to carry over, got %q", res.Output)
+	}
+	if res, _ := m.Run(ctx, `echo "[$PLAIN]"; type greet >/dev/null 2>&1 || echo gone`, time.Minute); res.Output != "[]\ngone\n" {
+		t.Fatalf("expected unset variables and functions to stay unset, got %q", res.Output)
+	}
+
+	// Background processes start where the persistent shell is
+	p, err := m.Start("pwd")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	waitFor(t, "the exit", func() bool { return !p.Running() })
+	if out, _ := m.Read(p.ID, nil); out.Output != sub+"\n" {
+		t.Fatalf("expected the background shell in %s, got %q", sub, out.Output)
+	}
+
+	m.Reset()
+	res, err = m.Run(ctx, `pwd; echo "[$GREETING]"`, time.Minute)
+	if err != nil || res.Output != dir+"\n[]\n" || res.Reset {
+		t.Fatalf("expected a fresh shell after reset, got %+v, %v", res, err)
+	}
+}
+
+func TestRunTimeoutKeepsShell(t *testing.T) {
+	m := NewManager(t.TempDir())
+	defer m.Close()
+	ctx := context.Background()
+
+	m.Run(ctx, "cd / && export KEEP=1", time.Minute)
+	start := time.Now()
+	res, err := m.Run(ctx, "echo before; sleep 60; echo after", 200*time.Millisecond)
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if !res.TimedOut || res.Reset || time.Since(start) > 5*time.Second {
+		t.Fatalf("expected the command to be stopped without losing the shell, got %+v", res)
+	}
+	if !strings.HasPrefix(res.Output, "before\n") || strings.Contains(res.Output, "after") {
+		t.Fatalf("expected the rest of the command to be skipped, got %q", res.Output)
+	}
+
+	res, err = m.Run(ctx, "echo $KEEP; pwd", time.Minute)
+	if err != nil || res.Output != "1\n/\n" {
+		t.Fatalf("expected the shell state to survive the timeout, got %+v, %v", res, err)
+	}
+}
+
+func TestRunRestartsAfterExit(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(dir)
+	defer m.Close()
+	ctx := context.Background()
+
+	m.Run(ctx, "cd / && export GONE=1", time.Minute)
+	res, err := m.Run(ctx, "echo bye; exit 4", time.Minute)
+	if err != nil || !res.Reset || res.ExitCode != 4 || res.Output != "bye\n" {
+		t.Fatalf("expected the shell exit to be reported, got %+v, %v", res, err)
+	}
+
+	// The new shell starts where the old one was, without its environment
+	res, err = m.Run(ctx, `pwd; echo "[$GONE]"`, time.Minute)
+	if err != nil || res.Output != "/\n[]\n" {
+		t.Fatalf("expected a new shell, got %+v, %v", res, err)
+	}
+
+	m.Close()
+	if _, err := m.Run(ctx, "true", time.Minute); err != ErrClosed {
+		t.Fatalf("expected a closed manager to refuse commands, got %v", err)
+	}
+}
+
+func TestCloseDuringShellStart(t *testing.T) {
+	ctx := context.Background()
+	// Close at different points of the start
+	for i := range 20 {
+		m := NewManager(t.TempDir())
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			m.Run(ctx, "true", time.Minute)
+		}()
+		time.Sleep(time.Duration(i) * 100 * time.Microsecond)
+		m.Close()
+		<-done
+
+		m.mu.Lock()
+		leaked := m.shell
+		m.mu.Unlock()
+		if leaked != nil {
+			leaked.close()
+			t.Fatalf("expected no shell to outlive Close")
+		}
+	}
+}
@@ -0,0 +1,22 @@
+//go:build !unix
+
+package shell
+
+import "os/exec"
+
+// setProcessGroup is a no-op where process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command's own process only
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return cmd.Process.Kill()
+}
+
+// interruptShell is a no-op without signals; timed out commands restart the shell
+func interruptShell(cmd *exec.Cmd) {}
+
+// killChildGroups is a no-op where process groups are not supported
+func killChildGroups(cmd *exec.Cmd) {}
@@ -0,0 +1,67 @@
+//go:build unix
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// setProcessGroup starts the command in its own process group, so that
+// killing the group also stops the processes it spawned
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// killProcessGroup sends SIGKILL to the process group of a started command
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// interruptShell sends SIGINT to the shell itself, not to its group
+func interruptShell(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		syscall.Kill(cmd.Process.Pid, syscall.SIGINT)
+	}
+}
+
+// killChildGroups sends SIGKILL to the process groups of the command's
+// children; with job control each job of a shell has its own group
+func killChildGroups(cmd *exec.Cmd) {
+	if cmd.Process == nil {
+		return
+	}
+	for _, pid := range childPIDs(cmd.Process.Pid) {
+		if pgid, err := syscall.Getpgid(pid); err == nil && pgid != cmd.Process.Pid {
+			syscall.Kill(-pgid, syscall.SIGKILL)
+		} else {
+			syscall.Kill(pid, syscall.SIGKILL)
+		}
+	}
+}
+
+// childPIDs lists the direct children of a process, from /proc where
+// available and from pgrep elsewhere
+func childPIDs(pid int) []int {
+	data, err := os.ReadFile(fmt.Sprintf("/proc/%d/task/%d/children", pid, pid))
+	if err != nil {
+		data, _ = exec.Command("pgrep", "-P", strconv.Itoa(pid)).Output()
+	}
+	var pids []int
+	for _, f := range strings.Fields(string(data)) {
+		if n, err := strconv.Atoi(f); err == nil {
+			pids = append(pids, n)
+		}
+	}
+	return pids
+}
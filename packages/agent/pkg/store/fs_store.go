@@ -204,10 +204,38 @@ func (s *FSStore) scanEventsLocked(fn func(types.Event) error) error {
 			var e types.UserMessageEvent
 			_ = json.Unmarshal(line, &e)
 			evt = &e
+		case "system_prompt":
+			var e types.SystemPromptEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "llm_token":
+			var e types.LLMTokenEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "llm_reasoning":
+			var e types.LLMReasoningEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "tool_call_start":
+			var e types.ToolCallStartEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "tool_call_delta":
+			var e types.ToolCallDeltaEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "tool_call_end":
+			var e types.ToolCallEndEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
 		case "llm_response":
 			var e types.LLMResponseEvent
 			_ = json.Unmarshal(line, &e)
 			evt = &e
+		case "llm_retry":
+			var e types.LLMRetryEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
 		case "tool_result":
 			var e types.ToolResultEvent
 			_ = json.Unmarshal(line, &e)
@@ -220,6 +248,10 @@ func (s *FSStore) scanEventsLocked(fn func(types.Event) error) error {
 			var e types.CheckpointEvent
 			_ = json.Unmarshal(line, &e)
 			evt = &e
+		case "context_compacted":
+			var e types.ContextCompactedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
 		case "permission_request":
 			var e types.PermissionRequestEvent
 			_ = json.Unmarshal(line, &e)
@@ -228,6 +260,54 @@ func (s *FSStore) scanEventsLocked(fn func(types.Event) error) error {
 			var e types.PermissionResponseEvent
 			_ = json.Unmarshal(line, &e)
 			evt = &e
+		case "plan_generated":
+			var e types.PlanGeneratedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "plan_approved":
+			var e types.PlanApprovedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "plan_rejected":
+			var e types.PlanRejectedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "mode_transition":
+			var e types.ModeTransitionEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "model_override":
+			var e types.ModelOverrideEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "goal_created":
+			var e types.GoalCreatedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "goal_failed":
+			var e types.GoalFailedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "loop_detected":
+			var e types.LoopDetectedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "tasks_updated":
+			var e types.TasksUpdatedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "subagent":
+			var e types.SubAgentEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "hook_context":
+			var e types.HookContextEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
+		case "artifact_created":
+			var e types.ArtifactCreatedEvent
+			_ = json.Unmarshal(line, &e)
+			evt = &e
 		default:
 			// Fallback or unknown
 			evt = &base
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/gm-agent-org/gm-agent/pkg/hook"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
 
@@ -28,13 +29,19 @@ type Executor struct {
 	policy             *Policy
 	handlers           map[string]Handler
 	permissionCallback PermissionCallback
+	hooks              *hook.Runner
+
+	// Serializes permission prompts; clients display one request at a time
+	// while tool calls may execute concurrently
+	permissions chan struct{}
 }
 
 func NewExecutor(registry *Registry, policy *Policy) *Executor {
 	return &Executor{
-		registry: registry,
-		policy:   policy,
-		handlers: make(map[string]Handler),
+		registry:    registry,
+		policy:      policy,
+		handlers:    make(map[string]Handler),
+		permissions: make(chan struct{}, 1),
 	}
 }
 
@@ -43,6 +50,11 @@ func (e *Executor) SetPermissionCallback(cb PermissionCallback) {
 	e.permissionCallback = cb
 }
 
+// SetHooks sets the PreToolUse and PostToolUse hooks run around every call
+func (e *Executor) SetHooks(hooks *hook.Runner) {
+	e.hooks = hooks
+}
+
 func (e *Executor) RegisterHandler(name string, handler Handler) {
 	e.handlers[name] = handler
 }
@@ -54,7 +66,19 @@ func (e *Executor) Execute(ctx context.Context, mode types.RuntimeMode, call *ty
 		return nil, fmt.Errorf("tool not found: %s", call.Name)
 	}
 
-	// 2. Check Policy (with mode)
+	// 2. PreToolUse hooks may block the call or rewrite its arguments,
+	// so the policy checks the arguments that will actually run
+	pre := e.hooks.Run(ctx, hook.Input{Event: hook.PreToolUse, ToolName: call.Name, ToolInput: hook.ToolInput(call.Arguments)})
+	if pre.Blocked {
+		return blockedResult(call, pre.Reason), nil
+	}
+	if pre.ToolInput != "" {
+		rewritten := *call
+		rewritten.Arguments = pre.ToolInput
+		call = &rewritten
+	}
+
+	// 3. Check Policy (with mode)
 	action, err := e.policy.Check(ctx, mode, call.Name, call.Arguments)
 	if err != nil {
 		return nil, err
@@ -63,7 +87,7 @@ func (e *Executor) Execute(ctx context.Context, mode types.RuntimeMode, call *ty
 		return nil, fmt.Errorf("policy denied execution of tool: %s", call.Name)
 	}
 
-	// 3. Handle PolicyConfirm - request user approval
+	// 4. Handle PolicyConfirm - request user approval
 	if action == PolicyConfirm {
 		if e.permissionCallback == nil {
 			// No callback registered, treat as allow (for backward compatibility)
@@ -77,7 +101,14 @@ func (e *Executor) Execute(ctx context.Context, mode types.RuntimeMode, call *ty
 				Metadata:   toolDef.Metadata,
 			}
 
+			// Calls waiting for another prompt give up with their context
+			select {
+			case e.permissions <- struct{}{}:
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
 			approved, err := e.permissionCallback(ctx, req)
+			<-e.permissions
 			if err != nil {
 				return nil, fmt.Errorf("permission request failed: %w", err)
 			}
@@ -93,27 +124,67 @@ func (e *Executor) Execute(ctx context.Context, mode types.RuntimeMode, call *ty
 		}
 	}
 
-	// 4. Lookup Handler
+	// 5. Lookup Handler
 	handler, ok := e.handlers[call.Name]
 	if !ok {
 		return nil, fmt.Errorf("no handler implementation for tool: %s", call.Name)
 	}
 
-	// 5. Execute
-	output, err := handler(ctx, call.Arguments)
+	// 6. Execute
+	var parts []types.ContentPart
+	output, err := handler(context.WithValue(ctx, attachmentsKey{}, &parts), call.Arguments)
 
 	result := &types.ToolResult{
 		ToolCallID: call.ID,
 		ToolName:   call.Name,
 		Content:    output,
 		IsError:    err != nil,
+		Parts:      parts,
 	}
 	if err != nil {
 		result.Error = err.Error()
 	}
+
+	// 7. PostToolUse hooks may reject the result, e.g. when a check fails on the change
+	post := e.hooks.Run(ctx, hook.Input{
+		Event:      hook.PostToolUse,
+		ToolName:   call.Name,
+		ToolInput:  hook.ToolInput(call.Arguments),
+		ToolOutput: output,
+		ToolError:  result.Error,
+	})
+	if post.Blocked {
+		result.IsError = true
+		result.Error = "rejected by hook: " + post.Reason
+		result.Content = fmt.Sprintf("%s\n\nRejected by hook: %s", output, post.Reason)
+	}
 	return result, nil
 }
 
+type attachmentsKey struct{}
+
+// Attach adds content parts, such as images, to the result of the tool call
+// running in ctx. It reports false if ctx does not belong to an Executor call.
+func Attach(ctx context.Context, parts ...types.ContentPart) bool {
+	dst, ok := ctx.Value(attachmentsKey{}).(*[]types.ContentPart)
+	if !ok {
+		return false
+	}
+	*dst = append(*dst, parts...)
+	return true
+}
+
+// blockedResult reports a call stopped by a hook to the LLM
+func blockedResult(call *types.ToolCall, reason string) *types.ToolResult {
+	return &types.ToolResult{
+		ToolCallID: call.ID,
+		ToolName:   call.Name,
+		Content:    "Blocked by hook: " + reason,
+		IsError:    true,
+		Error:      "blocked by hook: " + reason,
+	}
+}
+
 func (e *Executor) List() []types.Tool {
 	return e.registry.List()
 }
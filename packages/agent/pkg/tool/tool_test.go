@@ -2,9 +2,13 @@ package tool
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/gm-agent-org/gm-agent/pkg/config"
+	"github.com/gm-agent-org/gm-agent/pkg/hook"
 	"github.com/gm-agent-org/gm-agent/pkg/types"
 )
 
@@ -31,11 +35,11 @@ func TestPolicyCheck(t *testing.T) {
 	cfg := config.SecurityConfig{AllowedTools: []string{"safe"}, AllowFileSystem: false}
 	policy := NewPolicy(cfg, reg, nil)
 
-	if action, err := policy.Check(context.Background(), "safe", "{}"); err != nil || action != PolicyConfirm {
+	if action, err := policy.Check(context.Background(), types.ModeExecuting, "safe", "{}"); err != nil || action != PolicyConfirm {
 		t.Fatalf("expected confirm for allowed tool, got %v %v", action, err)
 	}
 
-	if _, err := policy.Check(context.Background(), "other", "{}"); err == nil {
+	if _, err := policy.Check(context.Background(), types.ModeExecuting, "other", "{}"); err == nil {
 		t.Fatalf("expected error for non-whitelisted tool")
 	}
 
@@ -46,7 +50,7 @@ func TestPolicyCheck(t *testing.T) {
 	reg2.Register(types.Tool{Name: "read_file", Metadata: map[string]string{"category": "filesystem"}})
 
 	policy = NewPolicy(cfg2, reg2, nil)
-	if _, err := policy.Check(context.Background(), "read_file", "{}"); err == nil {
+	if _, err := policy.Check(context.Background(), types.ModeExecuting, "read_file", "{}"); err == nil {
 		t.Fatalf("expected filesystem denial when allow flag is false")
 	}
 }
@@ -64,7 +68,7 @@ func TestExecutor(t *testing.T) {
 	})
 
 	call := &types.ToolCall{ID: "1", Name: "echo", Arguments: "hello"}
-	res, err := exec.Execute(context.Background(), call)
+	res, err := exec.Execute(context.Background(), types.ModeExecuting, call)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
@@ -74,7 +78,107 @@ func TestExecutor(t *testing.T) {
 
 	// Missing handler
 	call.Name = "missing"
-	if _, err := exec.Execute(context.Background(), call); err == nil {
+	if _, err := exec.Execute(context.Background(), types.ModeExecuting, call); err == nil {
 		t.Fatalf("expected error for missing tool")
 	}
 }
+
+func TestExecutorHooks(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(types.Tool{Name: "echo"}); err != nil {
+		t.Fatalf("register tool: %v", err)
+	}
+	exec := NewExecutor(reg, NewPolicy(config.SecurityConfig{AutoApprove: true}, reg, nil))
+	ran := 0
+	exec.RegisterHandler("echo", func(ctx context.Context, args string) (string, error) {
+		ran++
+		return args, nil
+	})
+
+	hooks, err := hook.New(config.HookTable{
+		"PreToolUse": {
+			{Command: `grep -q forbidden && { echo "no forbidden words" >&2; exit 2; }; exit 0`},
+			{Command: `grep -q '"loud"' && echo '{"tool_input":{"text":"LOUD"}}'; exit 0`},
+		},
+		"PostToolUse": {
+			{Command: `grep -q LOUD && echo '{"decision":"block","reason":"too loud"}'; exit 0`},
+			{Command: `grep -q quiet && echo '{"decision":"block"}'; exit 0`},
+		},
+	}, t.TempDir(), nil)
+	if err != nil {
+		t.Fatalf("new hooks: %v", err)
+	}
+	exec.SetHooks(hooks)
+
+	res, err := exec.Execute(context.Background(), types.ModeExecuting, &types.ToolCall{ID: "1", Name: "echo", Arguments: `{"text":"forbidden"}`})
+	if err != nil || !res.IsError || ran != 0 || !strings.Contains(res.Content, "no forbidden words") {
+		t.Fatalf("expected PreToolUse hook to block the call, got %+v %v", res, err)
+	}
+
+	res, err = exec.Execute(context.Background(), types.ModeExecuting, &types.ToolCall{ID: "2", Name: "echo", Arguments: `{"text":"loud"}`})
+	if err != nil || ran != 1 {
+		t.Fatalf("expected the call to run, got %+v %v", res, err)
+	}
+	if !strings.HasPrefix(res.Content, `{"text":"LOUD"}`) || !res.IsError || !strings.Contains(res.Error, "too loud") {
+		t.Fatalf("expected rewritten arguments and a rejected result, got %+v", res)
+	}
+
+	// A block without a reason still tells the LLM why the result was rejected
+	res, err = exec.Execute(context.Background(), types.ModeExecuting, &types.ToolCall{ID: "3", Name: "echo", Arguments: `{"text":"quiet"}`})
+	if err != nil || !res.IsError || !strings.HasSuffix(res.Content, "Rejected by hook: blocked by PostToolUse hook") {
+		t.Fatalf("expected a rejected result with a default reason, got %+v %v", res, err)
+	}
+}
+
+func TestExecutorSerializesPermissionPrompts(t *testing.T) {
+	reg := NewRegistry()
+	if err := reg.Register(types.Tool{Name: "echo"}); err != nil {
+		t.Fatalf("register tool: %v", err)
+	}
+	exec := NewExecutor(reg, NewPolicy(config.SecurityConfig{}, reg, nil))
+	exec.RegisterHandler("echo", func(ctx context.Context, args string) (string, error) {
+		return args, nil
+	})
+
+	prompted := make(chan string, 2)
+	answer := make(chan bool)
+	exec.SetPermissionCallback(func(ctx context.Context, req PermissionRequest) (bool, error) {
+		prompted <- req.Patterns[0]
+		return <-answer, nil
+	})
+
+	results := make(chan *types.ToolResult, 2)
+	for _, arg := range []string{"a", "b"} {
+		go func() {
+			res, _ := exec.Execute(context.Background(), types.ModeExecuting, &types.ToolCall{ID: arg, Name: "echo", Arguments: arg})
+			results <- res
+		}()
+	}
+
+	<-prompted
+	select {
+	case arg := <-prompted:
+		t.Fatalf("expected one prompt at a time, got a second one for %q", arg)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// A call waiting for the prompt gives up with its context
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := exec.Execute(ctx, types.ModeExecuting, &types.ToolCall{ID: "c", Name: "echo", Arguments: "c"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the waiting call to be cancelled, got %v", err)
+	}
+
+	answer <- true
+	<-prompted
+	answer <- false
+	approved := 0
+	for range 2 {
+		if res := <-results; res != nil && !res.IsError {
+			approved++
+		}
+	}
+	if approved != 1 {
+		t.Fatalf("expected exactly one approved call, got %d", approved)
+	}
+}
@@ -18,11 +18,12 @@ func TestStateCloneDeepCopy(t *testing.T) {
 	deadline := now.Add(time.Hour)
 
 	original := &State{
-		Version:      10,
-		UpdatedAt:    now,
-		SystemPrompt: "test prompt",
+		Version:       10,
+		UpdatedAt:     now,
+		SystemPrompt:  "test prompt",
+		ModelOverride: &ModelRoute{Provider: "openai", Model: "gpt-4o"},
 		Goals: []Goal{
-			{ID: "g1", Description: "goal 1", Status: GoalStatusPending, Deadline: &deadline},
+			{ID: "g1", Description: "goal 1", Status: GoalStatusPending, Deadline: &deadline, AllowedTools: []string{"read_file"}, Loop: LoopStats{Results: []string{"r1"}}},
 			{ID: "g2", Description: "goal 2", Status: GoalStatusCompleted},
 		},
 		Tasks: map[string]*Task{
@@ -84,6 +85,18 @@ func TestStateCloneDeepCopy(t *testing.T) {
 	if *clone.Goals[0].Deadline != *original.Goals[0].Deadline {
 		t.Errorf("Goal Deadline value mismatch")
 	}
+	clone.Goals[0].AllowedTools[0] = "run_shell"
+	if original.Goals[0].AllowedTools[0] != "read_file" {
+		t.Errorf("Goal AllowedTools should be deep copied")
+	}
+	clone.Goals[0].Loop.Results[0] = "r2"
+	if original.Goals[0].Loop.Results[0] != "r1" {
+		t.Errorf("Goal Loop.Results should be deep copied")
+	}
+
+	if clone.ModelOverride == original.ModelOverride || *clone.ModelOverride != *original.ModelOverride {
+		t.Errorf("ModelOverride should be deep copied")
+	}
 
 	// Verify Tasks are deep copied
 	if clone.Tasks["t1"] == original.Tasks["t1"] {
@@ -309,10 +322,17 @@ func TestMessageClone(t *testing.T) {
 		ID:        "m1",
 		Content:   "test",
 		ToolCalls: []ToolCall{{ID: "tc1", Name: "tool1"}, {ID: "tc2", Name: "tool2"}},
+		Parts:     []ContentPart{{Type: ContentPartImage, ArtifactID: "art1"}},
 	}
 
 	clone := original.Clone()
 
+	// Verify Parts deep copy
+	clone.Parts[0].Data = "loaded"
+	if original.Parts[0].Data != "" {
+		t.Errorf("Modifying clone parts should not affect original")
+	}
+
 	// Verify ToolCalls deep copy
 	clone.ToolCalls[0].Name = "modified"
 	if original.ToolCalls[0].Name == "modified" {
@@ -333,3 +353,18 @@ func TestMessageCloneNilToolCalls(t *testing.T) {
 		t.Errorf("expected nil tool calls")
 	}
 }
+
+func TestUsageLedgerClone(t *testing.T) {
+	original := NewUsageLedger()
+	original.Record("goal1", "gpt-4o", Usage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}, 0.01)
+
+	clone := original.Clone()
+	clone.Record("goal1", "gpt-4o", Usage{PromptTokens: 1, TotalTokens: 1}, 0)
+
+	if original.Session.Calls != 1 || original.ByGoal["goal1"].TotalTokens != 15 || original.ByModel["gpt-4o"].Calls != 1 {
+		t.Errorf("Recording on clone should not affect original: %+v", original)
+	}
+	if clone.Session.TotalTokens != 16 || clone.ByGoal["goal1"].Calls != 2 {
+		t.Errorf("unexpected clone totals: %+v", clone.Session)
+	}
+}
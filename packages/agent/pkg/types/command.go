@@ -46,9 +46,11 @@ func NewBaseCommand(cmdType string) BaseCommand {
 // CallLLMCommand
 type CallLLMCommand struct {
 	BaseCommand
+	Provider string    `json:"provider,omitempty"` // Gateway of the model; "" is the default
 	Model    string    `json:"model"`
 	Messages []Message `json:"messages"`
 	Tools    []Tool    `json:"tools,omitempty"`
+	GoalID   string    `json:"goal_id,omitempty"`
 }
 
 // CallToolCommand
@@ -57,6 +59,7 @@ type CallToolCommand struct {
 	ToolCallID string         `json:"tool_call_id,omitempty"`
 	ToolName   string         `json:"tool_name"`
 	Arguments  map[string]any `json:"arguments"`
+	GoalID     string         `json:"goal_id,omitempty"` // Goal whose LLM response requested the call
 }
 
 // ApplyPatchCommand
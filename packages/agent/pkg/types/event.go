@@ -1,6 +1,9 @@
 package types
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // Event is the interface for all system events
 type Event interface {
@@ -43,9 +46,17 @@ func NewBaseEvent(eventType, actor, subject string) BaseEvent {
 // UserMessageEvent
 type UserMessageEvent struct {
 	BaseEvent
-	Content  string   `json:"content"`
-	Priority int      `json:"priority"`
-	Semantic Semantic `json:"semantic"`
+	Content  string        `json:"content"`
+	Priority int           `json:"priority"`
+	Semantic Semantic      `json:"semantic"`
+	Deadline *time.Time    `json:"deadline,omitempty"`  // Applies to the goal the message creates
+	MaxSteps int           `json:"max_steps,omitempty"` // Step budget of the goal the message creates; 0 is unlimited
+	Parts    []ContentPart `json:"parts,omitempty"`     // Text and images, in order; Content holds the text
+
+	// Set when the message was expanded from a slash command
+	Command      string   `json:"command,omitempty"`
+	AllowedTools []string `json:"allowed_tools,omitempty"` // Restricts the tools of the goal the message creates
+	Model        string   `json:"model,omitempty"`         // Model for the goal the message creates
 }
 
 // SystemPromptEvent
@@ -61,6 +72,8 @@ type LLMResponseEvent struct {
 	Content   string     `json:"content"`
 	ToolCalls []ToolCall `json:"tool_calls,omitempty"`
 	Usage     Usage      `json:"usage"`
+	CostUSD   float64    `json:"cost_usd,omitempty"` // Priced from the configured price table
+	GoalID    string     `json:"goal_id,omitempty"`  // Goal the call was made for
 }
 
 // LLMTokenEvent represents an incremental text chunk from LLM
@@ -69,15 +82,63 @@ type LLMTokenEvent struct {
 	Delta string `json:"delta"`
 }
 
-// ToolResultEvent
-type ToolResultEvent struct {
+// LLMReasoningEvent is an incremental chunk of the model's reasoning, for
+// providers that expose their thinking
+type LLMReasoningEvent struct {
+	BaseEvent
+	Delta string `json:"delta"`
+}
+
+// ToolCallStartEvent is emitted when the LLM starts generating a tool call
+type ToolCallStartEvent struct {
+	BaseEvent
+	ToolCallID string `json:"tool_call_id"`
+	ToolName   string `json:"tool_name"`
+	Index      int    `json:"index"` // Position of the call in the response
+}
+
+// ToolCallDeltaEvent carries the next fragment of a tool call's JSON arguments
+type ToolCallDeltaEvent struct {
+	BaseEvent
+	ToolCallID string `json:"tool_call_id"`
+	Index      int    `json:"index"`
+	Delta      string `json:"delta"`
+}
+
+// ToolCallEndEvent is emitted when a tool call has been fully generated
+type ToolCallEndEvent struct {
 	BaseEvent
 	ToolCallID string `json:"tool_call_id"`
 	ToolName   string `json:"tool_name"`
-	Success    bool   `json:"success"`
-	Output     string `json:"output"`
-	Error      string `json:"error,omitempty"`
-	Duration   int64  `json:"duration_ms"`
+	Index      int    `json:"index"`
+	Size       int    `json:"size"` // Length of the arguments in bytes
+}
+
+// LLMRetryEvent records a retried or failed-over LLM call
+type LLMRetryEvent struct {
+	BaseEvent
+	Action       string `json:"action"` // "retry" or "fallback"
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+	Attempt      int    `json:"attempt,omitempty"`
+	ErrorKind    string `json:"error_kind"` // rate_limit, server, network, context_length, auth, ...
+	Error        string `json:"error"`
+	DelayMs      int64  `json:"delay_ms,omitempty"`      // Wait before the retry
+	NextProvider string `json:"next_provider,omitempty"` // Fallback target
+	NextModel    string `json:"next_model,omitempty"`
+}
+
+// ToolResultEvent
+type ToolResultEvent struct {
+	BaseEvent
+	ToolCallID string        `json:"tool_call_id"`
+	ToolName   string        `json:"tool_name"`
+	Success    bool          `json:"success"`
+	Output     string        `json:"output"`
+	Error      string        `json:"error,omitempty"`
+	Duration   int64         `json:"duration_ms"`
+	Parts      []ContentPart `json:"parts,omitempty"`   // Images returned by the tool, e.g. read_file
+	GoalID     string        `json:"goal_id,omitempty"` // Goal whose LLM response requested the call
 }
 
 // ErrorEvent
@@ -86,6 +147,24 @@ type ErrorEvent struct {
 	CommandID string        `json:"command_id"`
 	Error     string        `json:"error"`
 	Severity  ErrorSeverity `json:"severity"`
+	GoalID    string        `json:"goal_id,omitempty"` // Goal the failed command worked on
+}
+
+// ContextCompactedEvent is emitted when older messages in the context window
+// are replaced by an LLM-generated summary
+type ContextCompactedEvent struct {
+	BaseEvent
+	Summary           string `json:"summary"`
+	MessagesCompacted int    `json:"messages_compacted"` // Number of leading messages replaced by the summary
+	TokensBefore      int    `json:"tokens_before"`
+	TokensAfter       int    `json:"tokens_after"`
+	Trigger           string `json:"trigger"` // "auto" or "manual"
+
+	// The summarization call, accounted like any other LLM call
+	Model   string  `json:"model,omitempty"`
+	Usage   Usage   `json:"usage"`
+	CostUSD float64 `json:"cost_usd,omitempty"`
+	GoalID  string  `json:"goal_id,omitempty"` // Goal being worked on when compacting automatically
 }
 
 // CheckpointEvent
@@ -148,3 +227,68 @@ type ModeTransitionEvent struct {
 	ToMode   RuntimeMode `json:"to_mode"`
 	Reason   string      `json:"reason"`
 }
+
+// ModelOverrideEvent sets the model of a session; an empty route clears it
+type ModelOverrideEvent struct {
+	BaseEvent
+	Route ModelRoute `json:"route"`
+}
+
+// GoalCreatedEvent adds a goal directly, e.g. the sub-task of a sub-agent
+type GoalCreatedEvent struct {
+	BaseEvent
+	Goal Goal `json:"goal"`
+}
+
+// GoalFailedEvent is emitted when the scheduler gives up on a goal
+type GoalFailedEvent struct {
+	BaseEvent
+	GoalID string `json:"goal_id"`
+	Reason string `json:"reason"` // e.g. step budget exhausted, deadline passed
+}
+
+// LoopDetectedEvent reports a goal that keeps repeating itself without progress
+type LoopDetectedEvent struct {
+	BaseEvent
+	GoalID string `json:"goal_id"`
+	Kind   string `json:"kind"`   // repeated_call, repeated_error or no_progress
+	Count  int    `json:"count"`  // Repetitions or idle steps that triggered the detector
+	Detail string `json:"detail"` // Repeated tools or error
+	Action string `json:"action"` // warn, escalate or stop
+}
+
+// TasksUpdatedEvent replaces the task list of a goal (e.g. via the todo_write tool)
+type TasksUpdatedEvent struct {
+	BaseEvent
+	GoalID string `json:"goal_id"`
+	Tasks  []Task `json:"tasks"` // The complete list, in order
+}
+
+// SubAgentEvent reports the progress of a sub-agent spawned by a tool call
+type SubAgentEvent struct {
+	BaseEvent
+	AgentID     string                 `json:"agent_id"`
+	ToolCallID  string                 `json:"tool_call_id"`
+	Status      string                 `json:"status"` // started, event, completed, failed
+	Description string                 `json:"description,omitempty"`
+	ChildType   string                 `json:"child_type,omitempty"` // Type of the forwarded child event
+	Payload     json.RawMessage        `json:"payload,omitempty"`    // Forwarded child event
+	Summary     string                 `json:"summary,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Usage       map[string]UsageTotals `json:"usage,omitempty"`   // Child usage by model, merged into the parent ledger
+	GoalID      string                 `json:"goal_id,omitempty"` // Parent goal that spawned the sub-agent
+}
+
+// ArtifactCreatedEvent registers an artifact in the session state, e.g. an
+// uploaded image. The content is kept in the artifact store.
+type ArtifactCreatedEvent struct {
+	BaseEvent
+	Artifact Artifact `json:"artifact"`
+}
+
+// HookContextEvent adds text returned by a lifecycle hook to the conversation
+type HookContextEvent struct {
+	BaseEvent
+	Hook    string `json:"hook"` // Event the hook ran for, e.g. PostToolUse
+	Content string `json:"content"`
+}
@@ -1,13 +1,17 @@
 package types
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // State represents the complete system state snapshot.
 // All Runtime decisions are based on this state.
 type State struct {
 	// Version control
-	Version   int64     `json:"version"`    // State version, incremented on each update
-	UpdatedAt time.Time `json:"updated_at"` // Last update time
+	Version     int64     `json:"version"`                 // State version, incremented on each update
+	UpdatedAt   time.Time `json:"updated_at"`              // Last update time
+	LastEventID string    `json:"last_event_id,omitempty"` // Last event applied by the reducer
 
 	// System Configuration
 	SystemPrompt string `json:"system_prompt,omitempty"`
@@ -16,20 +20,27 @@ type State struct {
 	Mode        RuntimeMode `json:"mode,omitempty"`         // Current runtime mode (planning/executing)
 	PlanContent string      `json:"plan_content,omitempty"` // Generated plan during planning mode
 
+	// Model chosen for this session, overriding the configured routes
+	ModelOverride *ModelRoute `json:"model_override,omitempty"`
+
 	// Goal Management
 	Goals []Goal `json:"goals"` // List of goals (sorted by priority)
 
 	// Task Management
-	Tasks map[string]*Task `json:"tasks"` // Task table: task_id -> Task
+	Tasks map[string]*Task `json:"tasks"` // Task table: goal_id/task_id -> Task
 
 	// Artifact Management
 	Artifacts map[string]*Artifact `json:"artifacts"` // Artifact table: artifact_id -> Artifact
 
-	// Resource Locks
+	// Resource Locks; held locks live in the runtime and are never persisted,
+	// so this is only set in snapshots written by older versions
 	Locks map[string]*Lock `json:"locks"` // Lock table: resource_path -> Lock
 
 	// Context Window
 	Context *ContextWindow `json:"context"` // LLM Context
+
+	// Usage Accounting
+	Usage *UsageLedger `json:"usage,omitempty"` // Token and cost totals
 }
 
 // NewState creates a new empty state
@@ -56,6 +67,7 @@ func (s *State) Clone() *State {
 	newState := &State{
 		Version:      s.Version,
 		UpdatedAt:    s.UpdatedAt,
+		LastEventID:  s.LastEventID,
 		SystemPrompt: s.SystemPrompt,
 		Mode:         s.Mode,
 		PlanContent:  s.PlanContent,
@@ -65,6 +77,11 @@ func (s *State) Clone() *State {
 		Locks:        make(map[string]*Lock, len(s.Locks)),
 	}
 
+	if s.ModelOverride != nil {
+		override := *s.ModelOverride
+		newState.ModelOverride = &override
+	}
+
 	// Deep copy Goals
 	for i, g := range s.Goals {
 		newState.Goals[i] = g.Clone()
@@ -97,6 +114,11 @@ func (s *State) Clone() *State {
 		newState.Context = s.Context.Clone()
 	}
 
+	// Deep copy Usage
+	if s.Usage != nil {
+		newState.Usage = s.Usage.Clone()
+	}
+
 	return newState
 }
 
@@ -107,6 +129,12 @@ func (g Goal) Clone() Goal {
 		d := *g.Deadline
 		clone.Deadline = &d
 	}
+	if g.AllowedTools != nil {
+		clone.AllowedTools = append([]string(nil), g.AllowedTools...)
+	}
+	if g.Loop.Results != nil {
+		clone.Loop.Results = append([]string(nil), g.Loop.Results...)
+	}
 	return clone
 }
 
@@ -212,6 +240,10 @@ func (m Message) Clone() Message {
 		clone.ToolCalls = make([]ToolCall, len(m.ToolCalls))
 		copy(clone.ToolCalls, m.ToolCalls)
 	}
+	if m.Parts != nil {
+		clone.Parts = make([]ContentPart, len(m.Parts))
+		copy(clone.Parts, m.Parts)
+	}
 
 	return clone
 }
@@ -221,26 +253,61 @@ type Goal struct {
 	ID          string     `json:"id"`
 	Type        GoalType   `json:"type"`
 	Description string     `json:"description"`
-	Priority    int        `json:"priority"` // 0 is highest
+	Priority    int        `json:"priority"` // Lower runs first; 0 is highest for normal work, preempting goals go below it
 	Status      GoalStatus `json:"status"`
 
 	// Source
 	SourceEventID string `json:"source_event_id"`
+	ParentGoalID  string `json:"parent_goal_id,omitempty"` // Failed goal a recovery goal works around
 
 	// Constraints
-	Deadline *time.Time `json:"deadline,omitempty"`
-	MaxSteps int        `json:"max_steps"`
+	Deadline     *time.Time `json:"deadline,omitempty"`
+	MaxSteps     int        `json:"max_steps"`
+	AllowedTools []string   `json:"allowed_tools,omitempty"` // Tools the goal may use; empty allows all
+	Model        string     `json:"model,omitempty"`         // Overrides the runtime model
 
 	// Progress
-	StepsUsed int `json:"steps_used"`
+	StepsUsed         int       `json:"steps_used"`
+	ConsecutiveErrors int       `json:"consecutive_errors,omitempty"` // Reset by the next successful LLM call
+	Loop              LoopStats `json:"loop"`                         // Signs that the goal is stuck
 
 	// Timestamps
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// LoopStats tracks repeated work of a goal for the runtime's loop detection
+type LoopStats struct {
+	CallsHash      string   `json:"calls_hash,omitempty"`      // Tool calls and arguments of the last step
+	Calls          string   `json:"calls,omitempty"`           // Tool names of the last step
+	RepeatedCalls  int      `json:"repeated_calls,omitempty"`  // Steps in a row with identical tool calls
+	LastError      string   `json:"last_error,omitempty"`      // Most recent tool or command error
+	RepeatedErrors int      `json:"repeated_errors,omitempty"` // Identical errors in a row
+	IdleSteps      int      `json:"idle_steps,omitempty"`      // Steps since a tool last returned something new
+	Results        []string `json:"results,omitempty"`         // Hashes of recent successful tool results
+	Warnings       int      `json:"warnings,omitempty"`        // Corrective messages shown so far
+}
+
 type GoalType string
 
+// ModelRoute selects the gateway and model of an LLM call. An empty Provider
+// is the default gateway; an empty Model is the provider's configured model.
+type ModelRoute struct {
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+}
+
+// String formats the route as "provider:model"
+func (r ModelRoute) String() string {
+	switch {
+	case r.Provider == "":
+		return r.Model
+	case r.Model == "":
+		return r.Provider
+	}
+	return r.Provider + ":" + r.Model
+}
+
 const (
 	GoalTypeUserRequest GoalType = "user_request"
 	GoalTypeSubTask     GoalType = "sub_task"
@@ -255,6 +322,9 @@ const (
 	GoalStatusCompleted  GoalStatus = "completed"
 	GoalStatusFailed     GoalStatus = "failed"
 	GoalStatusCancelled  GoalStatus = "cancelled"
+
+	GoalStatusAwaitingApproval GoalStatus = "awaiting_approval" // Plan generated, waiting for user review
+	GoalStatusAwaitingInput    GoalStatus = "awaiting_input"    // Stuck, waiting for the user's next message
 )
 
 // Task represents an execution unit
@@ -266,6 +336,7 @@ type Task struct {
 	Type        string         `json:"type"`
 	Description string         `json:"description"`
 	Inputs      map[string]any `json:"inputs"`
+	Order       int            `json:"order"` // Position in the goal's task list
 
 	Status TaskStatus `json:"status"`
 
@@ -348,15 +419,157 @@ type Message struct {
 	ToolCallID string `json:"tool_call_id,omitempty"`
 	ToolName   string `json:"tool_name,omitempty"` // Required for Gemini
 
+	// Multimodal: ordered text and image parts. Content still holds the text,
+	// so text-only code paths keep working.
+	Parts []ContentPart `json:"parts,omitempty"`
+
 	TokenCount int       `json:"token_count"`
 	Timestamp  time.Time `json:"timestamp"`
 }
 
+// ContentPartType is the kind of a message content part
+type ContentPartType string
+
+const (
+	ContentPartText  ContentPartType = "text"
+	ContentPartImage ContentPartType = "image"
+)
+
+// ContentPart is one piece of a multimodal message. An image is either a
+// session artifact or inline base64 data; artifacts are loaded before the
+// message is sent to the LLM.
+type ContentPart struct {
+	Type       ContentPartType `json:"type"`
+	Text       string          `json:"text,omitempty"`
+	ArtifactID string          `json:"artifact_id,omitempty"`
+	MimeType   string          `json:"mime_type,omitempty"` // e.g. image/png
+	Data       string          `json:"data,omitempty"`      // Base64 encoded image
+}
+
+// HasImages reports whether the message carries image parts
+func (m Message) HasImages() bool {
+	for _, p := range m.Parts {
+		if p.Type == ContentPartImage {
+			return true
+		}
+	}
+	return false
+}
+
+// PartsText joins the text parts, e.g. to fill Message.Content
+func PartsText(parts []ContentPart) string {
+	var texts []string
+	for _, p := range parts {
+		if p.Type == ContentPartText && p.Text != "" {
+			texts = append(texts, p.Text)
+		}
+	}
+	return strings.Join(texts, "\n")
+}
+
+// UsageTotals accumulates token usage and cost
+type UsageTotals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// Add accumulates one LLM call into the totals
+func (u *UsageTotals) Add(usage Usage, cost float64) {
+	u.Calls++
+	u.PromptTokens += usage.PromptTokens
+	u.CompletionTokens += usage.CompletionTokens
+	u.TotalTokens += usage.TotalTokens
+	u.CostUSD += cost
+}
+
+// Merge adds totals accumulated elsewhere (e.g. by a sub-agent)
+func (u *UsageTotals) Merge(other UsageTotals) {
+	u.Calls += other.Calls
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+	u.CostUSD += other.CostUSD
+}
+
+// UsageLedger tracks LLM usage for the session, broken down by goal and model
+type UsageLedger struct {
+	Session UsageTotals             `json:"session"`
+	ByGoal  map[string]*UsageTotals `json:"by_goal"`  // goal_id -> totals
+	ByModel map[string]*UsageTotals `json:"by_model"` // model -> totals
+}
+
+// NewUsageLedger creates an empty ledger
+func NewUsageLedger() *UsageLedger {
+	return &UsageLedger{
+		ByGoal:  make(map[string]*UsageTotals),
+		ByModel: make(map[string]*UsageTotals),
+	}
+}
+
+// Record adds one LLM call to the session, goal and model totals
+func (l *UsageLedger) Record(goalID, model string, usage Usage, cost float64) {
+	l.Session.Add(usage, cost)
+	if goalID != "" {
+		if l.ByGoal[goalID] == nil {
+			l.ByGoal[goalID] = &UsageTotals{}
+		}
+		l.ByGoal[goalID].Add(usage, cost)
+	}
+	if model != "" {
+		if l.ByModel[model] == nil {
+			l.ByModel[model] = &UsageTotals{}
+		}
+		l.ByModel[model].Add(usage, cost)
+	}
+}
+
+// Merge adds per-model totals recorded elsewhere (e.g. by a sub-agent) under a goal.
+// Totals under the empty model name only count toward the session and goal.
+func (l *UsageLedger) Merge(goalID string, byModel map[string]UsageTotals) {
+	for model, totals := range byModel {
+		l.Session.Merge(totals)
+		if goalID != "" {
+			if l.ByGoal[goalID] == nil {
+				l.ByGoal[goalID] = &UsageTotals{}
+			}
+			l.ByGoal[goalID].Merge(totals)
+		}
+		if model != "" {
+			if l.ByModel[model] == nil {
+				l.ByModel[model] = &UsageTotals{}
+			}
+			l.ByModel[model].Merge(totals)
+		}
+	}
+}
+
+// Clone creates a deep copy of the UsageLedger
+func (l *UsageLedger) Clone() *UsageLedger {
+	if l == nil {
+		return nil
+	}
+	clone := NewUsageLedger()
+	clone.Session = l.Session
+	for k, v := range l.ByGoal {
+		t := *v
+		clone.ByGoal[k] = &t
+	}
+	for k, v := range l.ByModel {
+		t := *v
+		clone.ByModel[k] = &t
+	}
+	return clone
+}
+
 // Lock represents a resource lock
 type Lock struct {
 	Resource   string    `json:"resource"`
 	Owner      string    `json:"owner"` // task_id or goal_id
 	Type       LockType  `json:"type"`
+	Holders    int       `json:"holders"` // Concurrent holders of a shared read lock
 	AcquiredAt time.Time `json:"acquired_at"`
 	ExpiresAt  time.Time `json:"expires_at"`
 }
@@ -20,11 +20,12 @@ type ToolCall struct {
 
 // ToolResult represents the output of a tool execution
 type ToolResult struct {
-	ToolCallID string `json:"tool_call_id"`
-	ToolName   string `json:"tool_name"`
-	Content    string `json:"content"`
-	IsError    bool   `json:"is_error"`
-	Error      string `json:"error,omitempty"`
+	ToolCallID string        `json:"tool_call_id"`
+	ToolName   string        `json:"tool_name"`
+	Content    string        `json:"content"`
+	IsError    bool          `json:"is_error"`
+	Error      string        `json:"error,omitempty"`
+	Parts      []ContentPart `json:"parts,omitempty"` // Images attached by the handler
 }
 
 // FileChange represents a file modification that can be reverted
@@ -13,6 +13,7 @@ import (
 
 	"github.com/gm-agent-org/gm-agent/pkg/config"
 	"github.com/gm-agent-org/gm-agent/pkg/llm"
+	"github.com/gm-agent-org/gm-agent/pkg/llm/mock"
 	"github.com/gm-agent-org/gm-agent/pkg/runtime"
 	"github.com/gm-agent-org/gm-agent/pkg/store"
 	"github.com/gm-agent-org/gm-agent/pkg/tool"
@@ -87,3 +88,85 @@ func TestEndToEndGoalCompletion(t *testing.T) {
 		t.Fatalf("expected at least user and tool messages, got %d", len(state.Context.Messages))
 	}
 }
+
+const createFileScenario = `
+turns:
+  - chunks: ["Creating ", "the file."]
+    tool_calls:
+      - id: call-create
+        name: create_file
+        arguments: {path: docs/notes.md, content: "# Notes"}
+  - match: {role: tool, tool_name: create_file}
+    tool_calls:
+      - id: call-done
+        name: task_complete
+        arguments: {summary: created docs/notes.md}
+`
+
+func TestEndToEndScenario(t *testing.T) {
+	ctx := context.Background()
+	fsStore := store.NewFSStore(t.TempDir())
+	if err := fsStore.Open(ctx); err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+
+	registry := tool.NewRegistry()
+	policy := tool.NewPolicy(config.SecurityConfig{AutoApprove: true}, registry, fsStore)
+	executor := tool.NewExecutor(registry, policy)
+	var created []string
+	for _, name := range []string{"create_file", "task_complete"} {
+		if err := registry.Register(types.Tool{Name: name, Parameters: types.JSONSchema{"type": "object"}}); err != nil {
+			t.Fatalf("register tool: %v", err)
+		}
+	}
+	executor.RegisterHandler("create_file", func(ctx context.Context, args string) (string, error) {
+		created = append(created, args)
+		return "created", nil
+	})
+	executor.RegisterHandler("task_complete", func(ctx context.Context, args string) (string, error) {
+		return "completed", nil
+	})
+
+	scenario, err := mock.ParseScenario([]byte(createFileScenario))
+	if err != nil {
+		t.Fatalf("parse scenario: %v", err)
+	}
+	provider := mock.NewScenario(scenario)
+	gateway := llm.NewGateway(provider, config.ProviderOptions{})
+
+	rt := runtime.New(runtime.Config{
+		MaxSteps:           10,
+		CheckpointInterval: 1,
+		DecisionTimeout:    2 * time.Second,
+		DispatchTimeout:    2 * time.Second,
+	}, fsStore, gateway, executor, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	reqEvent := &types.UserMessageEvent{BaseEvent: types.NewBaseEvent("user_message", "user", "e2e"), Content: "write some notes"}
+	if err := rt.Ingest(ctx, reqEvent); err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if err := rt.Run(ctx); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	state := rt.GetState()
+	if provider.Remaining() != 0 {
+		t.Fatalf("expected every scripted turn to be used, %d left", provider.Remaining())
+	}
+	if len(created) != 1 {
+		t.Fatalf("expected create_file to run once, got %d", len(created))
+	}
+	if len(state.Goals) != 1 || state.Goals[0].Status != types.GoalStatusCompleted {
+		t.Fatalf("expected goal completed by task_complete, got %+v", state.Goals)
+	}
+	var artifact *types.Artifact
+	for _, a := range state.Artifacts {
+		artifact = a
+	}
+	if artifact == nil || artifact.Path != "docs/notes.md" {
+		t.Fatalf("expected create_file artifact, got %+v", state.Artifacts)
+	}
+	if msg := state.Context.Messages[1]; msg.Content != "Creating the file." {
+		t.Fatalf("expected streamed chunks to be joined, got %q", msg.Content)
+	}
+}
@@ -156,6 +156,50 @@ func (c *Client) CreateSession(ctx context.Context, prompt string) (string, erro
 	return resp.ID, nil
 }
 
+type ModeRequest struct {
+	Mode string `json:"mode"`
+}
+
+type PlanRejectRequest struct {
+	Feedback string `json:"feedback,omitempty"`
+}
+
+// SetMode switches a session between "planning" and "executing" mode
+func (c *Client) SetMode(ctx context.Context, sessionID, mode string) error {
+	status, body, err := c.Post(ctx, fmt.Sprintf("/api/v1/session/%s/mode", sessionID), ModeRequest{Mode: mode})
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("set mode failed: status=%d body=%s", status, string(body))
+	}
+	return nil
+}
+
+// ApprovePlan approves the plan awaiting review so the agent executes it
+func (c *Client) ApprovePlan(ctx context.Context, sessionID string) error {
+	status, body, err := c.Post(ctx, fmt.Sprintf("/api/v1/session/%s/plan/approve", sessionID), nil)
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("approve plan failed: status=%d body=%s", status, string(body))
+	}
+	return nil
+}
+
+// RejectPlan rejects the plan awaiting review; the agent replans using the feedback
+func (c *Client) RejectPlan(ctx context.Context, sessionID, feedback string) error {
+	status, body, err := c.Post(ctx, fmt.Sprintf("/api/v1/session/%s/plan/reject", sessionID), PlanRejectRequest{Feedback: feedback})
+	if err != nil {
+		return err
+	}
+	if status != 200 {
+		return fmt.Errorf("reject plan failed: status=%d body=%s", status, string(body))
+	}
+	return nil
+}
+
 func (c *Client) SendMessage(ctx context.Context, sessionID, content string) error {
 	status, body, err := c.Post(ctx, fmt.Sprintf("/api/v1/session/%s/message", sessionID), MessageRequest{Content: content})
 	if err != nil {
@@ -312,3 +356,96 @@ func (c *Client) Rewind(ctx context.Context, sessionID string, checkpointID stri
 	}
 	return &resp, nil
 }
+
+// Usage types
+type UsageTotals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	TotalTokens      int     `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+type UsageResponse struct {
+	SessionID string                 `json:"session_id"`
+	Total     UsageTotals            `json:"total"`
+	ByGoal    map[string]UsageTotals `json:"by_goal"`
+	ByModel   map[string]UsageTotals `json:"by_model"`
+}
+
+// GetUsage gets token usage and cost for a session
+func (c *Client) GetUsage(ctx context.Context, sessionID string) (*UsageResponse, error) {
+	status, body, err := c.Get(ctx, fmt.Sprintf("/api/v1/session/%s/usage", sessionID))
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("get usage failed: status=%d body=%s", status, string(body))
+	}
+
+	var resp UsageResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// TaskResponse is one entry of the agent's task list
+type TaskResponse struct {
+	ID          string `json:"id"`
+	GoalID      string `json:"goal_id"`
+	Description string `json:"description"`
+	Status      string `json:"status"`
+}
+
+// TaskListResponse is the ordered task list of a session
+type TaskListResponse struct {
+	SessionID string         `json:"session_id"`
+	Tasks     []TaskResponse `json:"tasks"`
+}
+
+// GetTasks gets the task list the agent maintains for a session
+func (c *Client) GetTasks(ctx context.Context, sessionID string) (*TaskListResponse, error) {
+	status, body, err := c.Get(ctx, fmt.Sprintf("/api/v1/session/%s/tasks", sessionID))
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("get tasks failed: status=%d body=%s", status, string(body))
+	}
+
+	var resp TaskListResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SlashCommand is a user-defined prompt template run as /name
+type SlashCommand struct {
+	Name         string   `json:"name"`
+	Description  string   `json:"description"`
+	ArgumentHint string   `json:"argument_hint"`
+	AllowedTools []string `json:"allowed_tools"`
+	Model        string   `json:"model"`
+	Scope        string   `json:"scope"`
+}
+
+// ListCommands gets the slash commands defined on the server
+func (c *Client) ListCommands(ctx context.Context) ([]SlashCommand, error) {
+	status, body, err := c.Get(ctx, "/api/v1/commands")
+	if err != nil {
+		return nil, err
+	}
+	if status != 200 {
+		return nil, fmt.Errorf("list commands failed: status=%d body=%s", status, string(body))
+	}
+
+	var resp struct {
+		Commands []SlashCommand `json:"commands"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Commands, nil
+}
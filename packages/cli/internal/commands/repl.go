@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/spinner"
@@ -33,6 +34,8 @@ type model struct {
 	// Streaming state
 	streamingInProgress bool
 	streamingRaw        string
+	reasoningRaw        string           // Thinking of the current response, shown live
+	toolStreams         []toolCallStream // Tool calls the model is still generating
 
 	eventCh     <-chan client.Event
 	ctx         context.Context
@@ -48,6 +51,13 @@ type model struct {
 		SelectedOption int // 0=Allow once, 1=Deny, 2=Always allow, 3=Deny all
 	}
 
+	// Plan mode state
+	planMode   bool // Agent proposes a plan for review before making changes
+	planReview *struct {
+		SelectedOption   int  // 0=Approve, 1=Reject with feedback
+		EnteringFeedback bool // Typing rejection feedback in the textarea
+	}
+
 	// UI enhancements
 	welcomeInfo   WelcomeInfo
 	inputHistory  []string
@@ -55,7 +65,14 @@ type model struct {
 	showStatusBar bool
 	width         int
 	height        int
-	model         string // LLM model name
+	model         string             // LLM model name
+	usage         client.UsageTotals // Running token usage and cost for the session
+
+	// Task list maintained by the agent (todo tools)
+	tasks []client.TaskResponse
+
+	// Custom slash commands defined on the server, for /commands and Tab completion
+	slashCommands []client.SlashCommand
 }
 
 // Custom Messages
@@ -66,10 +83,18 @@ type sessionCreatedMsg struct {
 type eventMsg client.Event
 type nextEventMsg struct{}
 type permissionHandledMsg struct{}
+type planReviewedMsg struct{ approved bool }
+type modeChangedMsg struct{ planning bool }
+
+const (
+	defaultPlaceholder  = "Ask anything... (Shift+Enter for new line)"
+	planPlaceholder     = "Describe the task to plan... (Shift+Enter for new line)"
+	feedbackPlaceholder = "What should change in the plan? (Enter to send, Esc to go back)"
+)
 
 func initialModel(cfg *Config) model {
 	ta := textarea.New()
-	ta.Placeholder = "Ask anything... (Shift+Enter for new line)"
+	ta.Placeholder = defaultPlaceholder
 	ta.Focus()
 	ta.Prompt = "❯ "
 	ta.CharLimit = 0 // Unlimited
@@ -128,6 +153,7 @@ func (m model) Init() tea.Cmd {
 	return tea.Batch(
 		textarea.Blink,
 		m.spinner.Tick,
+		loadCommandsCmd(m.client, m.ctx, false),
 	)
 }
 
@@ -138,8 +164,8 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		spCmd tea.Cmd
 	)
 
-	// Update components normally unless blocked by permission
-	if m.permissionRequest == nil {
+	// Update components normally unless blocked by permission or plan review
+	if m.permissionRequest == nil && (m.planReview == nil || m.planReview.EnteringFeedback) {
 		m.textarea, tiCmd = m.textarea.Update(msg)
 	}
 	m.viewport, vpCmd = m.viewport.Update(msg)
@@ -188,6 +214,47 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil // Ignore other keys while waiting for permission
 		}
 
+		// Handle plan review interaction
+		if m.planReview != nil {
+			if m.planReview.EnteringFeedback {
+				switch msg.Type {
+				case tea.KeyEsc:
+					m.planReview.EnteringFeedback = false
+					m.textarea.Reset()
+					m.refreshPlaceholder()
+					return m, nil
+				case tea.KeyEnter:
+					feedback := strings.TrimSpace(m.textarea.Value())
+					m.textarea.Reset()
+					return m, reviewPlanCmd(m.client, m.ctx, m.sessionID, false, feedback)
+				}
+				return m, tea.Batch(tiCmd, vpCmd, spCmd) // Typing feedback
+			}
+
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "y", "Y":
+				return m, reviewPlanCmd(m.client, m.ctx, m.sessionID, true, "")
+			case "n", "N":
+				m.startPlanFeedback()
+				return m, nil
+			case "up", "k":
+				m.planReview.SelectedOption = 0
+				return m, nil
+			case "down", "j":
+				m.planReview.SelectedOption = 1
+				return m, nil
+			case "enter":
+				if m.planReview.SelectedOption == 0 {
+					return m, reviewPlanCmd(m.client, m.ctx, m.sessionID, true, "")
+				}
+				m.startPlanFeedback()
+				return m, nil
+			}
+			return m, nil // Ignore other keys while reviewing the plan
+		}
+
 		switch msg.Type {
 		case tea.KeyCtrlC, tea.KeyEsc:
 			return m, tea.Quit
@@ -210,6 +277,11 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.textarea.SetValue("")
 			}
 			return m, nil
+		case tea.KeyTab:
+			if !m.waiting {
+				m.completeCommand()
+			}
+			return m, nil
 		case tea.KeyEnter:
 			if m.waiting {
 				return m, nil
@@ -234,8 +306,10 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.textarea.Reset()
 				m.messages = []string{}
 				m.messages = append(m.messages, styleSystemMessage("Starting new session..."))
+				m.usage = client.UsageTotals{}
+				m.tasks = nil
 				m.updateViewport()
-				return m, createSessionCmd(m.client, m.ctx)
+				return m, createSessionCmd(m.client, m.ctx, m.planMode)
 			case input == "/clear":
 				m.textarea.Reset()
 				m.messages = []string{}
@@ -264,6 +338,37 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.textarea.Reset()
 				m.waiting = true
 				return m, listCheckpointsCmd(m.client, m.ctx, m.sessionID)
+			case input == "/plan":
+				m.textarea.Reset()
+				planning := !m.planMode
+				if m.sessionID == "" {
+					// Applied when the session is created
+					return m.Update(modeChangedMsg{planning: planning})
+				}
+				m.waiting = true
+				return m, setModeCmd(m.client, m.ctx, m.sessionID, planning)
+			case input == "/commands":
+				m.textarea.Reset()
+				m.waiting = true
+				return m, loadCommandsCmd(m.client, m.ctx, true)
+			case input == "/usage":
+				if m.sessionID == "" {
+					m.messages = append(m.messages, styleSystemMessage("⚠️ No active session"))
+					m.updateViewport()
+					return m, nil
+				}
+				m.textarea.Reset()
+				m.waiting = true
+				return m, usageCmd(m.client, m.ctx, m.sessionID)
+			case input == "/tasks":
+				if m.sessionID == "" {
+					m.messages = append(m.messages, styleSystemMessage("⚠️ No active session"))
+					m.updateViewport()
+					return m, nil
+				}
+				m.textarea.Reset()
+				m.waiting = true
+				return m, tasksCmd(m.client, m.ctx, m.sessionID)
 			case strings.HasPrefix(input, "/rewind "):
 				if m.sessionID == "" {
 					m.messages = append(m.messages, styleSystemMessage("⚠️ No active session"))
@@ -304,7 +409,7 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// If no session yet, create one first with the message
 			if m.sessionID == "" {
-				return m, createSessionWithMessageCmd(m.client, m.ctx, input)
+				return m, createSessionWithMessageCmd(m.client, m.ctx, input, m.planMode)
 			}
 
 			// Send to API
@@ -336,6 +441,30 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.eventCh = msg.ch
 		return m, waitForNextEvent(m.eventCh)
 
+	case planReviewedMsg:
+		m.planReview = nil
+		m.refreshPlaceholder()
+		m.waiting = true
+		if msg.approved {
+			m.messages = append(m.messages, styleSystemMessage("✅ Plan approved, executing"))
+		} else {
+			m.messages = append(m.messages, styleSystemMessage("↩ Plan rejected, replanning"))
+		}
+		m.updateViewport()
+		return m, nil
+
+	case modeChangedMsg:
+		m.waiting = false
+		m.planMode = msg.planning
+		m.refreshPlaceholder()
+		if msg.planning {
+			m.messages = append(m.messages, styleSystemMessage("📋 Plan mode on: the agent will propose a plan for review before making changes"))
+		} else {
+			m.messages = append(m.messages, styleSystemMessage("▶ Plan mode off"))
+		}
+		m.updateViewport()
+		return m, nil
+
 	case permissionHandledMsg:
 		// Clear permission request after successful submission
 		m.permissionRequest = nil
@@ -374,6 +503,29 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.updateViewport()
 		return m, nil
 
+	case usageLoadedMsg:
+		m.waiting = false
+		m.usage = msg.usage.Total
+		m.messages = append(m.messages, RenderUsage(msg.usage))
+		m.updateViewport()
+		return m, nil
+
+	case tasksLoadedMsg:
+		m.waiting = false
+		m.tasks = msg.tasks.Tasks
+		m.messages = append(m.messages, RenderTaskList(msg.tasks.Tasks))
+		m.updateViewport()
+		return m, nil
+
+	case commandsLoadedMsg:
+		m.slashCommands = msg.commands
+		if msg.show {
+			m.waiting = false
+			m.messages = append(m.messages, RenderCommandList(msg.commands))
+			m.updateViewport()
+		}
+		return m, nil
+
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
 		m.height = msg.Height
@@ -409,18 +561,75 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.streamingRaw += data.Delta
 				m.messages[len(m.messages)-1] = styleAssistantMessage(m.streamingRaw)
 			}
+		case "llm_reasoning":
+			var data struct {
+				Delta string `json:"delta"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				m.reasoningRaw += data.Delta
+			}
+		case "tool_call_start":
+			var data struct {
+				Index    int    `json:"index"`
+				ToolName string `json:"tool_name"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				m.toolStreams = append(m.toolStreams, toolCallStream{index: data.Index, name: data.ToolName})
+			}
+		case "tool_call_delta":
+			var data struct {
+				Index int    `json:"index"`
+				Delta string `json:"delta"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				for i := range m.toolStreams {
+					if m.toolStreams[i].index == data.Index {
+						m.toolStreams[i].size += len(data.Delta)
+					}
+				}
+			}
+		case "tool_call_end":
+			var data struct {
+				Index int `json:"index"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				for i := range m.toolStreams {
+					if m.toolStreams[i].index == data.Index {
+						m.toolStreams[i].done = true
+					}
+				}
+			}
 		case "llm_response":
 			wasStreaming := m.streamingInProgress
 			m.streamingInProgress = false
 			m.streamingRaw = ""
+			m.reasoningRaw = ""
+			m.toolStreams = nil
 			var data struct {
+				Model     string `json:"model"`
 				Content   string `json:"content"`
 				ToolCalls []struct {
 					Name      string `json:"name"`
 					Arguments string `json:"arguments"`
 				} `json:"tool_calls"`
+				Usage struct {
+					PromptTokens     int `json:"prompt_tokens"`
+					CompletionTokens int `json:"completion_tokens"`
+					TotalTokens      int `json:"total_tokens"`
+				} `json:"usage"`
+				CostUSD float64 `json:"cost_usd"`
 			}
 			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				// Update status bar usage
+				if data.Model != "" {
+					m.model = data.Model
+				}
+				m.usage.Calls++
+				m.usage.PromptTokens += data.Usage.PromptTokens
+				m.usage.CompletionTokens += data.Usage.CompletionTokens
+				m.usage.TotalTokens += data.Usage.TotalTokens
+				m.usage.CostUSD += data.CostUSD
+
 				if data.Content != "" {
 					rendered, _ := m.renderer.Render(data.Content)
 					if wasStreaming && len(m.messages) > 0 {
@@ -448,6 +657,24 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					}
 				}
 			}
+		case "llm_retry":
+			var data struct {
+				Action       string `json:"action"`
+				Provider     string `json:"provider"`
+				ErrorKind    string `json:"error_kind"`
+				DelayMs      int64  `json:"delay_ms"`
+				NextProvider string `json:"next_provider"`
+				NextModel    string `json:"next_model"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				if data.Action == "fallback" {
+					m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("↪ %s failed (%s), falling back to %s %s",
+						data.Provider, data.ErrorKind, data.NextProvider, data.NextModel)))
+				} else {
+					m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("⟳ %s %s, retrying in %.1fs",
+						data.Provider, data.ErrorKind, float64(data.DelayMs)/1000)))
+				}
+			}
 		case "tool_result":
 			var data struct {
 				ToolName string `json:"tool_name"`
@@ -491,6 +718,112 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					SelectedOption: 0, // Default to "Allow once"
 				}
 			}
+		case "plan_generated":
+			// The plan itself was already shown as the assistant's reply
+			m.planReview = &struct {
+				SelectedOption   int
+				EnteringFeedback bool
+			}{}
+			m.messages = append(m.messages, styleSystemMessage("📋 Plan ready for review"))
+		case "subagent":
+			var data struct {
+				Status      string          `json:"status"`
+				Description string          `json:"description"`
+				ChildType   string          `json:"child_type"`
+				Payload     json.RawMessage `json:"payload"`
+				Error       string          `json:"error"`
+				Usage       map[string]struct {
+					Calls            int     `json:"calls"`
+					PromptTokens     int     `json:"prompt_tokens"`
+					CompletionTokens int     `json:"completion_tokens"`
+					TotalTokens      int     `json:"total_tokens"`
+					CostUSD          float64 `json:"cost_usd"`
+				} `json:"usage"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				switch data.Status {
+				case "started":
+					m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("🤖 Sub-agent started: %s", data.Description)))
+				case "event":
+					// Only the sub-agent's tool activity is shown; its summary arrives as the tool result
+					if data.ChildType == "tool_result" {
+						var result struct {
+							ToolName string `json:"tool_name"`
+							Success  bool   `json:"success"`
+						}
+						if err := json.Unmarshal(data.Payload, &result); err == nil {
+							status := "✓"
+							if !result.Success {
+								status = "✗"
+							}
+							m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("  ↳ %s %s", status, result.ToolName)))
+						}
+					}
+				case "completed", "failed":
+					for _, u := range data.Usage {
+						m.usage.Calls += u.Calls
+						m.usage.PromptTokens += u.PromptTokens
+						m.usage.CompletionTokens += u.CompletionTokens
+						m.usage.TotalTokens += u.TotalTokens
+						m.usage.CostUSD += u.CostUSD
+					}
+					if data.Status == "failed" {
+						m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("🤖 Sub-agent failed: %s", data.Error)))
+					} else {
+						m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("🤖 Sub-agent finished: %s", data.Description)))
+					}
+				}
+			}
+		case "goal_failed":
+			var data struct {
+				Reason string `json:"reason"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("⛔ Goal stopped: %s", data.Reason)))
+			}
+		case "loop_detected":
+			var data struct {
+				Kind   string `json:"kind"`
+				Count  int    `json:"count"`
+				Detail string `json:"detail"`
+				Action string `json:"action"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				text := fmt.Sprintf("🔁 Agent looks stuck (%s ×%d", strings.ReplaceAll(data.Kind, "_", " "), data.Count)
+				if data.Detail != "" {
+					text += ": " + data.Detail
+				}
+				text += ")"
+				if data.Action == "escalate" {
+					text += ", paused: reply to continue"
+				}
+				m.messages = append(m.messages, styleSystemMessage(text))
+			}
+		case "goal_created":
+			var data struct {
+				Goal struct {
+					Type        string `json:"type"`
+					Description string `json:"description"`
+				} `json:"goal"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil && data.Goal.Type == "recovery" {
+				m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("🩹 Recovering: %s", data.Goal.Description)))
+			}
+		case "tasks_updated":
+			var data struct {
+				Tasks []client.TaskResponse `json:"tasks"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				m.tasks = data.Tasks
+			}
+		case "mode_transition":
+			var data struct {
+				ToMode string `json:"to_mode"`
+			}
+			if err := json.Unmarshal(msg.Data, &data); err == nil {
+				m.planMode = data.ToMode == "planning"
+				m.refreshPlaceholder()
+			}
 		case "error":
 			var data struct {
 				Error string `json:"error"`
@@ -513,6 +846,12 @@ func (m model) View() string {
 	s.WriteString(m.viewport.View())
 	s.WriteString("\n")
 
+	// Live checklist while the agent has unfinished tasks
+	if hasOpenTasks(m.tasks) {
+		s.WriteString(RenderTaskList(m.tasks))
+		s.WriteString("\n")
+	}
+
 	// Spinner / Status
 	if m.permissionRequest != nil {
 		s.WriteString(RenderPermissionRequest(
@@ -521,6 +860,8 @@ func (m model) View() string {
 			m.permissionRequest.Patterns,
 			m.permissionRequest.SelectedOption,
 		))
+	} else if m.planReview != nil && !m.planReview.EnteringFeedback {
+		s.WriteString(RenderPlanReview(m.planReview.SelectedOption))
 	} else if m.waiting {
 		s.WriteString(m.spinner.View() + " Thinking...\n")
 	} else {
@@ -528,24 +869,73 @@ func (m model) View() string {
 	}
 
 	// Input
-	if m.permissionRequest == nil {
+	if m.permissionRequest == nil && (m.planReview == nil || m.planReview.EnteringFeedback) {
 		s.WriteString(m.textarea.View())
 	}
 
 	// Status bar
 	if m.showStatusBar && m.width > 0 {
 		s.WriteString("\n")
-		s.WriteString(RenderStatusBar(m.sessionID, m.model, m.width))
+		s.WriteString(RenderStatusBar(m.sessionID, m.model, m.usage, m.width))
 	}
 
 	return s.String()
 }
 
+// startPlanFeedback switches the plan review to collecting rejection feedback
+func (m *model) startPlanFeedback() {
+	m.planReview.EnteringFeedback = true
+	m.textarea.Reset()
+	m.textarea.Placeholder = feedbackPlaceholder
+}
+
+// refreshPlaceholder shows whether the next message will be planned or executed
+func (m *model) refreshPlaceholder() {
+	if m.planMode {
+		m.textarea.Placeholder = planPlaceholder
+	} else {
+		m.textarea.Placeholder = defaultPlaceholder
+	}
+}
+
 func (m *model) updateViewport() {
-	m.viewport.SetContent(strings.Join(m.messages, "\n\n"))
+	content := m.messages
+	if live := m.liveLines(); len(live) > 0 {
+		content = append(append([]string{}, m.messages...), live...)
+	}
+	m.viewport.SetContent(strings.Join(content, "\n\n"))
 	m.viewport.GotoBottom()
 }
 
+// toolCallStream tracks a tool call while the model generates its arguments
+type toolCallStream struct {
+	index int
+	name  string
+	size  int
+	done  bool
+}
+
+// liveLines renders the reasoning and tool calls of the response in progress.
+// They are dropped once the final response arrives.
+func (m *model) liveLines() []string {
+	var lines []string
+	if m.reasoningRaw != "" && !m.streamingInProgress {
+		thinking := strings.Join(strings.Fields(m.reasoningRaw), " ")
+		if r := []rune(thinking); len(r) > 160 {
+			thinking = "…" + string(r[len(r)-160:])
+		}
+		lines = append(lines, styleSystemMessage("💭 "+thinking))
+	}
+	for _, tc := range m.toolStreams {
+		state := "Preparing"
+		if tc.done {
+			state = "Prepared"
+		}
+		lines = append(lines, styleSystemMessage(fmt.Sprintf("🛠 %s %s… (%d bytes)", state, tc.name, tc.size)))
+	}
+	return lines
+}
+
 // Commands
 
 func submitPermissionCmd(c *client.Client, ctx context.Context, sid, reqID string, approved, always bool) tea.Cmd {
@@ -557,19 +947,52 @@ func submitPermissionCmd(c *client.Client, ctx context.Context, sid, reqID strin
 	}
 }
 
+func reviewPlanCmd(c *client.Client, ctx context.Context, sid string, approved bool, feedback string) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if approved {
+			err = c.ApprovePlan(ctx, sid)
+		} else {
+			err = c.RejectPlan(ctx, sid, feedback)
+		}
+		if err != nil {
+			return errMsg(err)
+		}
+		return planReviewedMsg{approved: approved}
+	}
+}
+
+func setModeCmd(c *client.Client, ctx context.Context, sid string, planning bool) tea.Cmd {
+	return func() tea.Msg {
+		mode := "executing"
+		if planning {
+			mode = "planning"
+		}
+		if err := c.SetMode(ctx, sid, mode); err != nil {
+			return errMsg(err)
+		}
+		return modeChangedMsg{planning: planning}
+	}
+}
+
 // Commands
 
-func createSessionCmd(c *client.Client, ctx context.Context) tea.Cmd {
-	return createSessionWithMessageCmd(c, ctx, "")
+func createSessionCmd(c *client.Client, ctx context.Context, planning bool) tea.Cmd {
+	return createSessionWithMessageCmd(c, ctx, "", planning)
 }
 
-func createSessionWithMessageCmd(c *client.Client, ctx context.Context, pendingMessage string) tea.Cmd {
+func createSessionWithMessageCmd(c *client.Client, ctx context.Context, pendingMessage string, planning bool) tea.Cmd {
 	return func() tea.Msg {
 		// Create session with empty prompt - no LLM call until message is sent
 		sid, err := c.CreateSession(ctx, "")
 		if err != nil {
 			return errMsg(err)
 		}
+		if planning {
+			if err := c.SetMode(ctx, sid, "planning"); err != nil {
+				return errMsg(err)
+			}
+		}
 		return sessionCreatedMsg{sessionID: sid, pendingMessage: pendingMessage}
 	}
 }
@@ -623,6 +1046,116 @@ type rewindCompletedMsg struct {
 	result *client.RewindResponse
 }
 
+type usageLoadedMsg struct {
+	usage *client.UsageResponse
+}
+
+func usageCmd(c *client.Client, ctx context.Context, sid string) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := c.GetUsage(ctx, sid)
+		if err != nil {
+			return errMsg(err)
+		}
+		return usageLoadedMsg{usage: resp}
+	}
+}
+
+type tasksLoadedMsg struct {
+	tasks *client.TaskListResponse
+}
+
+func tasksCmd(c *client.Client, ctx context.Context, sid string) tea.Cmd {
+	return func() tea.Msg {
+		resp, err := c.GetTasks(ctx, sid)
+		if err != nil {
+			return errMsg(err)
+		}
+		return tasksLoadedMsg{tasks: resp}
+	}
+}
+
+type commandsLoadedMsg struct {
+	commands []client.SlashCommand
+	show     bool // Print the list (for /commands) rather than just refresh it
+}
+
+func loadCommandsCmd(c *client.Client, ctx context.Context, show bool) tea.Cmd {
+	return func() tea.Msg {
+		cmds, err := c.ListCommands(ctx)
+		if err != nil {
+			if !show {
+				return nil // Completion just lacks custom commands
+			}
+			return errMsg(err)
+		}
+		return commandsLoadedMsg{commands: cmds, show: show}
+	}
+}
+
+// builtinCommands are the slash commands handled by the REPL itself
+var builtinCommands = []string{
+	"/help", "/new", "/clear", "/history", "/checkpoints", "/rewind",
+	"/usage", "/tasks", "/plan", "/commands", "/exit", "/quit",
+}
+
+// completeCommand completes the slash command being typed: a single match is
+// filled in, several are narrowed to their common prefix or listed
+func (m *model) completeCommand() {
+	input := m.textarea.Value()
+	if !strings.HasPrefix(input, "/") || strings.ContainsAny(input, " \n") {
+		return
+	}
+
+	var matches []string
+	hints := map[string]string{}
+	names := append([]string(nil), builtinCommands...)
+	for _, c := range m.slashCommands {
+		names = append(names, "/"+c.Name)
+		hints["/"+c.Name] = c.ArgumentHint
+	}
+	for _, name := range names {
+		if strings.HasPrefix(name, input) && !slices.Contains(matches, name) {
+			matches = append(matches, name)
+		}
+	}
+
+	switch {
+	case len(matches) == 0:
+		return
+	case len(matches) == 1:
+		m.textarea.SetValue(matches[0] + " ")
+		if hint := hints[matches[0]]; hint != "" {
+			m.messages = append(m.messages, styleSystemMessage(fmt.Sprintf("%s %s", matches[0], hint)))
+			m.updateViewport()
+		}
+	case len(commonPrefix(matches)) > len(input):
+		m.textarea.SetValue(commonPrefix(matches))
+	default:
+		m.messages = append(m.messages, styleSystemMessage(strings.Join(matches, "  ")))
+		m.updateViewport()
+	}
+}
+
+func commonPrefix(values []string) string {
+	prefix := values[0]
+	for _, v := range values[1:] {
+		for !strings.HasPrefix(v, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// hasOpenTasks reports whether any task is still pending, running or blocked
+func hasOpenTasks(tasks []client.TaskResponse) bool {
+	for _, t := range tasks {
+		if t.Status == "pending" || t.Status == "running" || t.Status == "blocked" {
+			return true
+		}
+	}
+	return false
+}
+
 // Checkpoint commands
 func listCheckpointsCmd(c *client.Client, ctx context.Context, sid string) tea.Cmd {
 	return func() tea.Msg {
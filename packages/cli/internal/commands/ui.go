@@ -5,9 +5,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/lipgloss"
+	"github.com/gm-agent-org/gm-agent/packages/cli/internal/client"
 )
 
 // Color palette (Claude Code inspired)
@@ -184,7 +186,7 @@ func RenderWelcome(info WelcomeInfo) string {
 }
 
 // RenderStatusBar renders the bottom status bar
-func RenderStatusBar(sessionID string, model string, width int) string {
+func RenderStatusBar(sessionID string, model string, usage client.UsageTotals, width int) string {
 	wd, _ := os.Getwd()
 	shortDir := shortenPath(wd)
 
@@ -194,6 +196,10 @@ func RenderStatusBar(sessionID string, model string, width int) string {
 	)
 
 	right := styleStatusItem.Render("Model:") + styleStatusValue.Render(model) + " "
+	if usage.TotalTokens > 0 {
+		right = styleStatusItem.Render("Tokens:") + styleStatusValue.Render(formatTokens(usage.TotalTokens)) + "  " +
+			styleStatusItem.Render("Cost:") + styleStatusValue.Render(fmt.Sprintf("$%.4f", usage.CostUSD)) + "  " + right
+	}
 
 	// Calculate padding
 	padding := width - lipgloss.Width(left) - lipgloss.Width(right)
@@ -204,6 +210,113 @@ func RenderStatusBar(sessionID string, model string, width int) string {
 	return styleStatusBar.Width(width).Render(left + strings.Repeat(" ", padding) + right)
 }
 
+// formatTokens renders a token count compactly (e.g. 950, 12.3k, 1.2M)
+func formatTokens(n int) string {
+	switch {
+	case n >= 1_000_000:
+		return fmt.Sprintf("%.1fM", float64(n)/1_000_000)
+	case n >= 1_000:
+		return fmt.Sprintf("%.1fk", float64(n)/1_000)
+	default:
+		return fmt.Sprintf("%d", n)
+	}
+}
+
+// RenderUsage renders the usage breakdown for a session
+func RenderUsage(usage *client.UsageResponse) string {
+	var b strings.Builder
+
+	b.WriteString(styleTitle.Render("Usage"))
+	b.WriteString("\n\n")
+	b.WriteString(fmt.Sprintf("  %s %d calls, %s tokens (%s in / %s out), $%.4f\n",
+		styleStatusItem.Render("Session:"),
+		usage.Total.Calls,
+		formatTokens(usage.Total.TotalTokens),
+		formatTokens(usage.Total.PromptTokens),
+		formatTokens(usage.Total.CompletionTokens),
+		usage.Total.CostUSD))
+
+	if len(usage.ByModel) > 0 {
+		b.WriteString("\n")
+		b.WriteString(styleSubtitle.Render("By model:"))
+		b.WriteString("\n")
+		models := make([]string, 0, len(usage.ByModel))
+		for name := range usage.ByModel {
+			models = append(models, name)
+		}
+		sort.Strings(models)
+		for _, name := range models {
+			t := usage.ByModel[name]
+			b.WriteString(fmt.Sprintf("  %-28s %4d calls  %8s tokens  $%.4f\n", name, t.Calls, formatTokens(t.TotalTokens), t.CostUSD))
+		}
+	}
+
+	if len(usage.ByGoal) > 0 {
+		b.WriteString("\n")
+		b.WriteString(styleSubtitle.Render("By goal:"))
+		b.WriteString("\n")
+		goals := make([]string, 0, len(usage.ByGoal))
+		for id := range usage.ByGoal {
+			goals = append(goals, id)
+		}
+		sort.Strings(goals)
+		for _, id := range goals {
+			t := usage.ByGoal[id]
+			b.WriteString(fmt.Sprintf("  %-28s %4d calls  %8s tokens  $%.4f\n", id, t.Calls, formatTokens(t.TotalTokens), t.CostUSD))
+		}
+	}
+
+	return b.String()
+}
+
+// RenderTaskList renders the agent's task list as a checklist
+func RenderTaskList(tasks []client.TaskResponse) string {
+	if len(tasks) == 0 {
+		return styleSystemMessage("No tasks yet")
+	}
+
+	var b strings.Builder
+	b.WriteString(styleToolName.Render("Tasks"))
+	b.WriteString("\n")
+	for _, t := range tasks {
+		mark, style := "☐", lipgloss.NewStyle().Foreground(colorText)
+		switch t.Status {
+		case "running":
+			mark, style = "▶", lipgloss.NewStyle().Foreground(colorWarning).Bold(true)
+		case "completed":
+			mark, style = "☑", lipgloss.NewStyle().Foreground(colorMuted).Strikethrough(true)
+		case "blocked", "failed":
+			mark, style = "⚠", lipgloss.NewStyle().Foreground(colorError)
+		case "cancelled":
+			mark, style = "☒", lipgloss.NewStyle().Foreground(colorMuted)
+		}
+		b.WriteString(fmt.Sprintf("  %s %s\n", mark, style.Render(t.Description)))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// RenderCommandList renders the slash commands defined on the server
+func RenderCommandList(cmds []client.SlashCommand) string {
+	if len(cmds) == 0 {
+		return styleSystemMessage("No custom commands. Add Markdown prompts to .gm/commands/ or ~/.gm/commands/")
+	}
+
+	var b strings.Builder
+	b.WriteString(styleToolName.Render("Custom Commands"))
+	b.WriteString("\n")
+	for _, c := range cmds {
+		usage := "/" + c.Name
+		if c.ArgumentHint != "" {
+			usage += " " + c.ArgumentHint
+		}
+		b.WriteString(fmt.Sprintf("  %s  %s %s\n",
+			styleCommand.Render(usage),
+			styleSubtitle.Render(c.Description),
+			lipgloss.NewStyle().Foreground(colorMuted).Render("("+c.Scope+")")))
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
 // RenderToolCall renders a tool call in card style
 func RenderToolCall(toolName string, args map[string]interface{}, status string) string {
 	var b strings.Builder
@@ -347,6 +460,74 @@ func RenderPermissionRequest(toolName string, permission string, patterns []stri
 	return b.String()
 }
 
+// RenderPlanReview renders the approve/reject prompt for a generated plan
+func RenderPlanReview(selectedOption int) string {
+	var b strings.Builder
+
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(colorSecondary)
+	b.WriteString(headerStyle.Render("╭─ Plan Review ─────────────────────────────────────────╮"))
+	b.WriteString("\n")
+	b.WriteString("│ ")
+	b.WriteString(lipgloss.NewStyle().Foreground(colorText).Render("The agent proposed the plan above. Nothing has been changed yet."))
+	b.WriteString("\n│\n")
+
+	options := []struct {
+		key   string
+		label string
+		desc  string
+	}{
+		{"Y", "Approve", "Switch to executing mode and carry out the plan"},
+		{"N", "Reject", "Give feedback and ask for a new plan"},
+	}
+
+	selectedStyle := lipgloss.NewStyle().
+		Background(lipgloss.Color("#374151")).
+		Foreground(colorText).
+		Bold(true).
+		Padding(0, 1)
+	normalStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Padding(0, 1)
+	descStyle := lipgloss.NewStyle().
+		Foreground(colorMuted).
+		Italic(true)
+
+	for i, opt := range options {
+		b.WriteString("│  ")
+		b.WriteString(lipgloss.NewStyle().
+			Background(colorSecondary).
+			Foreground(colorText).
+			Bold(true).
+			Padding(0, 1).
+			Render(opt.key))
+		b.WriteString(" ")
+		if i == selectedOption {
+			b.WriteString(selectedStyle.Render(opt.label))
+		} else {
+			b.WriteString(normalStyle.Render(opt.label))
+		}
+		b.WriteString(" ")
+		b.WriteString(descStyle.Render(opt.desc))
+		b.WriteString("\n")
+	}
+
+	b.WriteString(headerStyle.Render("╰───────────────────────────────────────────────────────╯"))
+	b.WriteString("\n")
+
+	keyStyle := lipgloss.NewStyle().Foreground(colorPrimary).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(colorMuted).Italic(true)
+	b.WriteString(hintStyle.Render("  Press "))
+	b.WriteString(keyStyle.Render("Y"))
+	b.WriteString(hintStyle.Render("/"))
+	b.WriteString(keyStyle.Render("N"))
+	b.WriteString(hintStyle.Render(" or use "))
+	b.WriteString(keyStyle.Render("↑↓"))
+	b.WriteString(hintStyle.Render(" + "))
+	b.WriteString(keyStyle.Render("Enter"))
+
+	return b.String()
+}
+
 // RenderHelp renders the help screen
 func RenderHelp() string {
 	var b strings.Builder
@@ -366,6 +547,10 @@ func RenderHelp() string {
 		{"/rewind <id>", "Rewind conversation to a checkpoint"},
 		{"/rewind <id> --code", "Rewind code changes only"},
 		{"/rewind <id> --all", "Rewind both code and conversation"},
+		{"/usage", "Show token usage and cost for current session"},
+		{"/tasks", "Show the agent's task list"},
+		{"/plan", "Toggle plan mode (review a plan before changes)"},
+		{"/commands", "List custom commands from .gm/commands"},
 		{"/exit, /quit", "Exit the CLI"},
 	}
 
@@ -381,6 +566,7 @@ func RenderHelp() string {
 	b.WriteString(fmt.Sprintf("  %s  %s\n", styleCommand.Render("Enter      "), styleSubtitle.Render("Send message")))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", styleCommand.Render("Shift+Enter"), styleSubtitle.Render("New line")))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", styleCommand.Render("↑/↓        "), styleSubtitle.Render("Navigate history")))
+	b.WriteString(fmt.Sprintf("  %s  %s\n", styleCommand.Render("Tab        "), styleSubtitle.Render("Complete a /command")))
 	b.WriteString(fmt.Sprintf("  %s  %s\n", styleCommand.Render("Ctrl+C     "), styleSubtitle.Render("Exit")))
 
 	return b.String()
@@ -2,6 +2,8 @@ module github.com/gm-agent-org/gm-agent/packages/sdk/go
 
 go 1.25.5
 
+require gopkg.in/yaml.v3 v3.0.1
+
 require (
 	github.com/deepmap/oapi-codegen v1.16.3 // indirect
 	github.com/dprotaso/go-yit v0.0.0-20220510233725-9ba8df137936 // indirect
@@ -24,5 +26,4 @@ require (
 	golang.org/x/text v0.20.0 // indirect
 	golang.org/x/tools v0.25.1 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )